@@ -0,0 +1,385 @@
+package events
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AggSpec describes which events an Aggregator rolls up, and which aggregates
+// it computes from their arguments.
+type AggSpec struct {
+	// Sources lists the sources of the events to aggregate, either exact
+	// values or glob patterns where '*' matches any sequence of characters and
+	// '?' matches a single character.
+	Sources []string
+
+	// Messages lists the messages of the events to aggregate.
+	Messages []string
+
+	// Names of the arguments to compute aggregates for. Count applies to
+	// arguments of any type, the other aggregates ignore values that aren't
+	// numbers.
+	Sum   []string
+	Count []string
+	Min   []string
+	Max   []string
+	Avg   []string
+}
+
+// Aggregator is a handler which rolls up the events matching a specification
+// into one event per interval, forwarding the other events unchanged.
+//
+// Matching events are grouped by message fingerprint (see OnceHandler) and
+// source. On each interval, the aggregator emits one event per group, which
+// has the message and source of the group and carries these arguments:
+//
+//	events_aggregated: number of events in the group
+//	<name>_sum:        sum of the values of the argument name
+//	<name>_count:      number of events that had the argument name
+//	<name>_min:        minimum value of the argument name
+//	<name>_max:        maximum value of the argument name
+//	<name>_avg:        average value of the argument name, as a float64
+//
+// Sums, minimums and maximums are int64 values when the argument only had
+// integer values, and float64 values otherwise. Aggregates of arguments that
+// had no numeric values are omitted.
+//
+// Intervals where no events matched don't produce events. The pending
+// aggregates are also emitted by Flush and Close.
+//
+// It is safe to use an aggregator concurrently from multiple goroutines.
+type Aggregator struct {
+	// Now returns the time set on the aggregated events, it defaults to
+	// time.Now and may be set to a different function before the aggregator
+	// is used (in tests for example).
+	Now func() time.Time
+
+	handler Handler
+	spec    AggSpec
+	sources map[string]bool
+	globs   []string
+
+	mutex  sync.Mutex
+	groups map[aggKey]*aggGroup
+	order  []*aggGroup // groups in the order they were created
+
+	once sync.Once
+	done chan struct{}
+	exit chan struct{}
+}
+
+type aggKey struct {
+	fingerprint uint64
+	source      string
+}
+
+type aggGroup struct {
+	message string
+	source  string
+	count   int64
+	args    map[string]*aggValue
+}
+
+// aggValue accumulates the numeric values of an argument, integers are summed
+// separately so they don't lose precision while no float values were seen.
+type aggValue struct {
+	count    int64   // events with the argument
+	numbers  int64   // numeric values
+	isum     int64   // sum of integer values
+	fsum     float64 // sum of float values
+	float    bool    // whether float values were seen
+	min, max float64
+	imin     int64
+	imax     int64
+}
+
+// NewAggregator returns an Aggregator which forwards events to h, emitting the
+// aggregates of the events matching spec every interval.
+func NewAggregator(h Handler, interval time.Duration, spec AggSpec) *Aggregator {
+	a := &Aggregator{
+		Now:     time.Now,
+		handler: h,
+		spec:    spec,
+		sources: make(map[string]bool),
+		groups:  make(map[aggKey]*aggGroup),
+		done:    make(chan struct{}),
+		exit:    make(chan struct{}),
+	}
+
+	for _, s := range spec.Sources {
+		if strings.ContainsAny(s, "*?") {
+			a.globs = append(a.globs, s)
+		} else {
+			a.sources[s] = true
+		}
+	}
+
+	tick, stop := newTicker(interval)
+	go a.run(tick, stop)
+	return a
+}
+
+// HandleEvent satisfies the Handler interface.
+func (a *Aggregator) HandleEvent(e *Event) {
+	a.handle(e, forwardEvent)
+}
+
+// HandleEventSync satisfies the SyncHandler interface.
+func (a *Aggregator) HandleEventSync(ctx context.Context, e *Event) error {
+	return handleSync(ctx, a, func(forward func(Handler, *Event)) { a.handle(e, forward) })
+}
+
+// HandleEventContext satisfies the ContextHandler interface.
+func (a *Aggregator) HandleEventContext(ctx context.Context, e *Event) error {
+	return handleContext(ctx, func(forward func(Handler, *Event)) { a.handle(e, forward) })
+}
+
+func (a *Aggregator) handle(e *Event, forward func(Handler, *Event)) {
+	if IsDiagnostic(e) || !a.match(e) {
+		forward(a.handler, e)
+		return
+	}
+
+	key := aggKey{fingerprint(e, false), e.Source}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	g := a.groups[key]
+
+	if g == nil {
+		// The strings are copied because the event may be reused by the
+		// producer after the handler returns.
+		g = &aggGroup{
+			message: string(append([]byte(nil), e.Message...)),
+			source:  string(append([]byte(nil), e.Source...)),
+			args:    make(map[string]*aggValue),
+		}
+		a.groups[key] = g
+		a.order = append(a.order, g)
+	}
+
+	g.count++
+
+	for _, arg := range e.Args {
+		if a.aggregated(arg.Name) {
+			v := g.args[arg.Name]
+			if v == nil {
+				v = &aggValue{}
+				g.args[arg.Name] = v
+			}
+			v.add(arg.Value)
+		}
+	}
+}
+
+// Unwrap returns the handler that events are forwarded to.
+func (a *Aggregator) Unwrap() Handler {
+	return a.handler
+}
+
+// Flush emits the pending aggregates, then flushes the handler that events are
+// forwarded to.
+func (a *Aggregator) Flush() error {
+	a.emit()
+	return flushHandler(a.handler)
+}
+
+// Close stops the aggregator, emits the pending aggregates, and closes the
+// handler that events are forwarded to.
+func (a *Aggregator) Close() error {
+	a.once.Do(func() { close(a.done) })
+	<-a.exit
+	a.emit()
+	return closeHandler(a.handler)
+}
+
+func (a *Aggregator) run(tick <-chan time.Time, stop func()) {
+	defer close(a.exit)
+	defer stop()
+
+	for {
+		select {
+		case <-a.done:
+			return
+		case <-tick:
+			a.emit()
+		}
+	}
+}
+
+func (a *Aggregator) emit() {
+	a.mutex.Lock()
+	groups := a.order
+	a.order = nil
+	a.groups = make(map[aggKey]*aggGroup)
+	a.mutex.Unlock()
+
+	if len(groups) == 0 {
+		return
+	}
+
+	now := a.Now()
+
+	for _, g := range groups {
+		args := Args{{"events_aggregated", g.count}}
+		args = appendAggregates(args, g, a.spec.Sum, "_sum", (*aggValue).sum)
+		args = appendAggregates(args, g, a.spec.Count, "_count", (*aggValue).countValue)
+		args = appendAggregates(args, g, a.spec.Min, "_min", (*aggValue).minValue)
+		args = appendAggregates(args, g, a.spec.Max, "_max", (*aggValue).maxValue)
+		args = appendAggregates(args, g, a.spec.Avg, "_avg", (*aggValue).avg)
+
+		a.handler.HandleEvent(&Event{
+			Message: g.message,
+			Source:  g.source,
+			Args:    args,
+			Time:    now,
+		})
+	}
+}
+
+func appendAggregates(args Args, g *aggGroup, names []string, suffix string, value func(*aggValue) interface{}) Args {
+	for _, name := range names {
+		if v := g.args[name]; v != nil {
+			if x := value(v); x != nil {
+				args = append(args, Arg{name + suffix, x})
+			}
+		}
+	}
+	return args
+}
+
+func (a *Aggregator) match(e *Event) bool {
+	if a.sources[e.Source] {
+		return true
+	}
+
+	for _, pattern := range a.globs {
+		if globMatch(pattern, e.Source) {
+			return true
+		}
+	}
+
+	for _, m := range a.spec.Messages {
+		if m == e.Message {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (a *Aggregator) aggregated(name string) bool {
+	for _, names := range [...][]string{a.spec.Sum, a.spec.Count, a.spec.Min, a.spec.Max, a.spec.Avg} {
+		for _, n := range names {
+			if n == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (v *aggValue) add(x interface{}) {
+	v.count++
+
+	var i int64
+	var f float64
+	var isInt bool
+
+	switch n := x.(type) {
+	case int:
+		i, isInt = int64(n), true
+	case int8:
+		i, isInt = int64(n), true
+	case int16:
+		i, isInt = int64(n), true
+	case int32:
+		i, isInt = int64(n), true
+	case int64:
+		i, isInt = n, true
+	case uint:
+		i, isInt = int64(n), true
+	case uint8:
+		i, isInt = int64(n), true
+	case uint16:
+		i, isInt = int64(n), true
+	case uint32:
+		i, isInt = int64(n), true
+	case uint64:
+		i, isInt = int64(n), true
+	case float32:
+		f = float64(n)
+	case float64:
+		f = n
+	default:
+		return
+	}
+
+	if isInt {
+		f = float64(i)
+		v.isum += i
+	} else {
+		v.fsum += f
+		v.float = true
+	}
+
+	if v.numbers == 0 || f < v.min {
+		v.min, v.imin = f, i
+	}
+
+	if v.numbers == 0 || f > v.max {
+		v.max, v.imax = f, i
+	}
+
+	v.numbers++
+}
+
+// The methods below return the aggregates of v, or nil if the argument had no
+// numeric values.
+
+func (v *aggValue) countValue() interface{} {
+	return v.count
+}
+
+func (v *aggValue) sum() interface{} {
+	switch {
+	case v.numbers == 0:
+		return nil
+	case v.float:
+		return v.fsum + float64(v.isum)
+	default:
+		return v.isum
+	}
+}
+
+func (v *aggValue) minValue() interface{} {
+	switch {
+	case v.numbers == 0:
+		return nil
+	case v.float:
+		return v.min
+	default:
+		return v.imin
+	}
+}
+
+func (v *aggValue) maxValue() interface{} {
+	switch {
+	case v.numbers == 0:
+		return nil
+	case v.float:
+		return v.max
+	default:
+		return v.imax
+	}
+}
+
+func (v *aggValue) avg() interface{} {
+	if v.numbers == 0 {
+		return nil
+	}
+	return (v.fsum + float64(v.isum)) / float64(v.numbers)
+}
@@ -0,0 +1,132 @@
+package events
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestAggregator(h Handler, spec AggSpec) (*Aggregator, chan time.Time, func()) {
+	tick, _, restore := fakeTicker()
+	clock := &fakeClock{now: time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)}
+	a := NewAggregator(h, time.Minute, spec)
+	a.Now = clock.Now
+	return a, tick, restore
+}
+
+func TestAggregator(t *testing.T) {
+	r := &eventRecorder{}
+	a, tick, restore := newTestAggregator(r, AggSpec{
+		Sources:  []string{"cache/*"},
+		Messages: []string{"bytes written"},
+		Sum:      []string{"bytes", "size"},
+		Count:    []string{"key"},
+		Min:      []string{"size"},
+		Max:      []string{"size"},
+		Avg:      []string{"size"},
+	})
+	defer restore()
+	defer a.Close()
+
+	a.HandleEvent(&Event{Message: "cache hit", Source: "cache/lru.go:42", Args: Args{{"key", "A"}, {"size", 10}}})
+	a.HandleEvent(&Event{Message: "request", Source: "http/server.go:12"})
+	a.HandleEvent(&Event{Message: "cache hit", Source: "cache/lru.go:42", Args: Args{{"key", "B"}, {"size", 2.5}}})
+	a.HandleEvent(&Event{Message: "bytes written", Args: Args{{"bytes", 100}}})
+	a.HandleEvent(&Event{Message: "cache hit", Source: "cache/lru.go:42", Args: Args{{"key", "C"}, {"size", int64(30)}}})
+	a.HandleEvent(&Event{Message: "bytes written", Args: Args{{"bytes", uint8(20)}, {"other", 1}}})
+	a.HandleEvent(&Event{Message: "cache miss", Source: "cache/lru.go:50", Args: Args{{"key", "D"}, {"size", "large"}}})
+
+	// Non-matching events are forwarded right away.
+	if e := r.wait(t); e.Message != "request" {
+		t.Fatal("bad event:", e)
+	}
+
+	tick <- time.Time{}
+
+	now := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	want := []*Event{
+		{
+			Message: "cache hit",
+			Source:  "cache/lru.go:42",
+			Time:    now,
+			Args: Args{
+				{"events_aggregated", int64(3)},
+				{"size_sum", 42.5},
+				{"key_count", int64(3)},
+				{"size_min", 2.5},
+				{"size_max", 30.0},
+				{"size_avg", 42.5 / 3},
+			},
+		},
+		{
+			Message: "bytes written",
+			Time:    now,
+			Args: Args{
+				{"events_aggregated", int64(2)},
+				{"bytes_sum", int64(120)},
+			},
+		},
+		{
+			Message: "cache miss",
+			Source:  "cache/lru.go:50",
+			Time:    now,
+			Args: Args{
+				{"events_aggregated", int64(1)},
+				{"key_count", int64(1)},
+			},
+		},
+	}
+
+	for _, w := range want {
+		if diff := Diff(w, r.wait(t)); len(diff) != 0 {
+			t.Error(diff)
+		}
+	}
+
+	a.HandleEvent(&Event{Message: "cache hit", Source: "cache/lru.go:42", Args: Args{{"size", 1}, {"size", 3}}})
+	a.Flush()
+
+	e := r.wait(t)
+	m := e.Args.Map()
+
+	if m["events_aggregated"] != int64(1) || m["size_min"] != int64(1) || m["size_max"] != int64(3) || m["size_avg"] != 2.0 {
+		t.Error("bad aggregates of integers:", e.Args)
+	}
+}
+
+func TestAggregatorEmptyInterval(t *testing.T) {
+	h := &drainHandler{}
+	a, tick, restore := newTestAggregator(h, AggSpec{Messages: []string{"cache hit"}})
+	defer restore()
+
+	a.HandleEvent(&Event{Message: "cache hit"})
+	tick <- time.Time{}
+	tick <- time.Time{} // the first tick was handled when this one is received
+	tick <- time.Time{}
+
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := atomic.LoadInt64(&h.count); n != 1 {
+		t.Error("bad number of events:", n)
+	}
+
+	if atomic.LoadInt32(&h.closed) == 0 {
+		t.Error("the handler was not closed")
+	}
+}
+
+func TestAggregatorClose(t *testing.T) {
+	r := &eventRecorder{}
+	a, _, restore := newTestAggregator(r, AggSpec{Messages: []string{"cache hit"}})
+	defer restore()
+
+	a.HandleEvent(&Event{Message: "cache hit"})
+	a.HandleEvent(&Event{Message: "cache hit"})
+	a.Close()
+
+	if e := r.wait(t); e.Args.Map()["events_aggregated"] != int64(2) {
+		t.Error("the pending aggregates were not emitted by Close:", e)
+	}
+}
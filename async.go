@@ -0,0 +1,570 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// DefaultAsyncQueueSize is the queue size used by async handlers when
+	// none is configured.
+	DefaultAsyncQueueSize = 1024
+
+	// DefaultAsyncSummaryInterval is the minimum interval between the events
+	// that async handlers emit to report dropped events, when none is
+	// configured.
+	DefaultAsyncSummaryInterval = 10 * time.Second
+)
+
+// QueuePolicy values define what async handlers do with the events they
+// receive while their queue is full.
+type QueuePolicy int
+
+const (
+	// DropNewest discards the event that could not be queued.
+	DropNewest QueuePolicy = iota
+
+	// DropOldest discards the oldest event in the queue to make room for
+	// the new one.
+	DropOldest
+
+	// Block makes the producer wait until there is room in the queue, or
+	// until the block timeout expires in which case the event is discarded.
+	Block
+)
+
+// String satisfies the fmt.Stringer interface.
+func (p QueuePolicy) String() string {
+	switch p {
+	case DropNewest:
+		return "drop-newest"
+	case DropOldest:
+		return "drop-oldest"
+	case Block:
+		return "block"
+	default:
+		return fmt.Sprintf("QueuePolicy(%d)", int(p))
+	}
+}
+
+// AsyncConfig carries the configuration of async handlers.
+type AsyncConfig struct {
+	// QueueSize is the maximum number of events waiting to be passed to the
+	// handler, defaults to DefaultAsyncQueueSize.
+	QueueSize int
+
+	// Policy is applied to events received while the queue is full.
+	Policy QueuePolicy
+
+	// BlockTimeout limits the time producers wait for room in the queue with
+	// the Block policy, zero means no limit.
+	BlockTimeout time.Duration
+
+	// Classify may be set to select the policy of each event, overriding the
+	// Policy field. For example to block on regular events but drop debug
+	// events.
+	Classify func(*Event) QueuePolicy
+
+	// SummaryInterval is the minimum interval between the events that the
+	// handler emits to report how many events were dropped, defaults to
+	// DefaultAsyncSummaryInterval. No events are emitted when nothing was
+	// dropped.
+	SummaryInterval time.Duration
+
+	// Interner may be set to share the strings that repeat across the queued
+	// events (see Event.CloneInterned), which reduces the memory used by large
+	// queues.
+	Interner *Interner
+
+	// MaxAge may be set to drop the events whose time is older than MaxAge
+	// when they leave the queue, for example after the handler was blocked by
+	// a long outage. The expired events are reported by a diagnostic event
+	// each time the handler is flushed or closed. Zero means no limit.
+	MaxAge time.Duration
+
+	// Now returns the current time used to compute the age of events, it
+	// defaults to time.Now and may be set to a different function (in tests
+	// for example).
+	Now func() time.Time
+}
+
+// AsyncStats carries counters describing the activity of an async handler.
+type AsyncStats struct {
+	QueueLength   int   // number of events currently in the queue
+	QueueSize     int   // capacity of the queue
+	Handled       int64 // number of events passed to the handler
+	DroppedNewest int64 // events discarded by the DropNewest policy, or after Close
+	DroppedOldest int64 // events discarded by the DropOldest policy
+	Timeouts      int64 // events discarded after reaching the block timeout
+	Canceled      int64 // events discarded because their context was done while blocked
+	Expired       int64 // events discarded because they were older than MaxAge
+}
+
+// Dropped returns the total number of events that were discarded.
+func (s AsyncStats) Dropped() int64 {
+	return s.DroppedNewest + s.DroppedOldest + s.Timeouts + s.Canceled + s.Expired
+}
+
+// AsyncHandler is a handler which queues events and passes them to another
+// handler from a background goroutine, decoupling the producers of events
+// from the latency of the handler.
+//
+// When events are dropped the handler periodically emits a diagnostic event to
+// the handler it wraps, with the number of dropped events since the last
+// report. Diagnostic events received by the handler are queued regardless of
+// the queue capacity and the policy, they are passed to the wrapped handler by
+// the background goroutine ahead of the other queued events, and are never
+// dropped nor counted in the stats.
+//
+// It is safe to use an async handler concurrently from multiple goroutines.
+type AsyncHandler struct {
+	// counters are first to guarantee their alignment for atomic operations
+	handled       int64
+	droppedNewest int64
+	droppedOldest int64
+	timeouts      int64
+	canceled      int64
+	expired       int64
+
+	handler Handler
+	config  AsyncConfig
+
+	mutex   sync.Mutex
+	idle    sync.Cond     // signaled when events are done
+	queue   []*Event      // ring buffer of events
+	head    int           // index of the oldest event in queue
+	length  int           // number of events in queue
+	space   chan struct{} // closed when room is made in the queue
+	waiters int           // number of producers waiting on space
+	wake    chan struct{} // wakes up the background goroutine
+	diags   []*Event      // diagnostic events, not bounded by the queue size
+	closed  bool
+	pushed  uint64 // number of events pushed to the queue
+	done    uint64 // number of events that left the queue (handled or dropped)
+
+	// values of the counters at the time of the last summary
+	reported [4]int64
+
+	// value of the expired counter at the time of the last flush
+	reportedExpired int64
+
+	exit chan struct{}
+}
+
+// NewAsyncHandler returns an AsyncHandler which passes events to h, configured
+// with config. The handler must be closed to release the resources it holds.
+func NewAsyncHandler(h Handler, config AsyncConfig) *AsyncHandler {
+	if config.QueueSize <= 0 {
+		config.QueueSize = DefaultAsyncQueueSize
+	}
+
+	if config.SummaryInterval <= 0 {
+		config.SummaryInterval = DefaultAsyncSummaryInterval
+	}
+
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+
+	a := &AsyncHandler{
+		handler: h,
+		config:  config,
+		queue:   make([]*Event, config.QueueSize),
+		space:   make(chan struct{}),
+		wake:    make(chan struct{}, 1),
+		exit:    make(chan struct{}),
+	}
+
+	a.idle.L = &a.mutex
+	go a.run()
+	return a
+}
+
+// HandleEvent satisfies the Handler interface.
+//
+// The event is cloned before being queued, the method may block if the queue
+// is full and the event's policy is Block.
+func (a *AsyncHandler) HandleEvent(e *Event) {
+	a.enqueue(nil, e)
+}
+
+// HandleEventContext satisfies the ContextHandler interface.
+//
+// The method behaves like HandleEvent, but it gives up waiting for room in the
+// queue when ctx is done, returning the context error.
+func (a *AsyncHandler) HandleEventContext(ctx context.Context, e *Event) error {
+	return a.enqueue(ctx, e)
+}
+
+func (a *AsyncHandler) enqueue(ctx context.Context, e *Event) error {
+	if IsDiagnostic(e) {
+		a.diagnostic(e)
+		return nil
+	}
+
+	var cancel <-chan struct{}
+	policy := a.config.Policy
+
+	if ctx != nil {
+		cancel = ctx.Done()
+	}
+
+	if classify := a.config.Classify; classify != nil {
+		policy = classify(e)
+	}
+
+	var timer <-chan time.Time
+	e = e.CloneInterned(a.config.Interner)
+	a.mutex.Lock()
+
+	for !a.closed && a.length == len(a.queue) {
+		switch policy {
+		case Block:
+			space := a.space
+			a.waiters++
+			a.mutex.Unlock()
+
+			if timer == nil && a.config.BlockTimeout > 0 {
+				t := time.NewTimer(a.config.BlockTimeout)
+				defer t.Stop()
+				timer = t.C
+			}
+
+			select {
+			case <-space:
+				a.mutex.Lock()
+				a.waiters--
+				continue
+			case <-timer:
+				a.release()
+				atomic.AddInt64(&a.timeouts, 1)
+				return nil
+			case <-cancel:
+				a.release()
+				atomic.AddInt64(&a.canceled, 1)
+				return ctx.Err()
+			}
+
+		case DropOldest:
+			a.queue[a.head] = nil
+			a.head = (a.head + 1) % len(a.queue)
+			a.length--
+			a.done++
+			atomic.AddInt64(&a.droppedOldest, 1)
+			a.idle.Broadcast()
+
+		default:
+			a.mutex.Unlock()
+			atomic.AddInt64(&a.droppedNewest, 1)
+			return nil
+		}
+	}
+
+	if a.closed {
+		a.mutex.Unlock()
+		atomic.AddInt64(&a.droppedNewest, 1)
+		return nil
+	}
+
+	a.queue[(a.head+a.length)%len(a.queue)] = e
+	a.length++
+	a.pushed++
+	a.mutex.Unlock()
+
+	select {
+	case a.wake <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// HandleEventSync satisfies the SyncHandler interface.
+//
+// The event bypasses the queue and is passed to the handler from the calling
+// goroutine, possibly before events that were queued earlier.
+func (a *AsyncHandler) HandleEventSync(ctx context.Context, e *Event) error {
+	a.mutex.Lock()
+	closed := a.closed
+	a.mutex.Unlock()
+
+	if closed {
+		atomic.AddInt64(&a.droppedNewest, 1)
+		return &SyncError{Stage: "*events.AsyncHandler", Err: ErrHandlerClosed}
+	}
+
+	if err := HandleEventSync(ctx, a.handler, e); err != nil {
+		return syncStage(a, err)
+	}
+
+	atomic.AddInt64(&a.handled, 1)
+	return nil
+}
+
+// release is called by producers that stop waiting for room in the queue.
+func (a *AsyncHandler) release() {
+	a.mutex.Lock()
+	a.waiters--
+	a.mutex.Unlock()
+}
+
+// Unwrap returns the handler that events are passed to.
+func (a *AsyncHandler) Unwrap() Handler {
+	return a.handler
+}
+
+// EventHealth satisfies the HealthReporter interface. The handler is degraded
+// while its queue is full, and unhealthy after it was closed.
+func (a *AsyncHandler) EventHealth() Health {
+	a.mutex.Lock()
+	closed := a.closed
+	a.mutex.Unlock()
+
+	s := a.Stats()
+	h := Health{
+		QueueDepth: s.QueueLength,
+		QueueSize:  s.QueueSize,
+		Dropped:    s.Dropped(),
+	}
+
+	switch {
+	case closed:
+		h.Status, h.Message = Unhealthy, "the handler is closed"
+	case s.QueueLength >= s.QueueSize:
+		h.Status, h.Message = Degraded, "the queue is full"
+	}
+
+	return h
+}
+
+// Stats returns the current values of the handler's counters.
+func (a *AsyncHandler) Stats() AsyncStats {
+	a.mutex.Lock()
+	length := a.length
+	a.mutex.Unlock()
+
+	return AsyncStats{
+		QueueLength:   length,
+		QueueSize:     len(a.queue),
+		Handled:       atomic.LoadInt64(&a.handled),
+		DroppedNewest: atomic.LoadInt64(&a.droppedNewest),
+		DroppedOldest: atomic.LoadInt64(&a.droppedOldest),
+		Timeouts:      atomic.LoadInt64(&a.timeouts),
+		Canceled:      atomic.LoadInt64(&a.canceled),
+		Expired:       atomic.LoadInt64(&a.expired),
+	}
+}
+
+// Dropped satisfies the DropCounter interface.
+func (a *AsyncHandler) Dropped() int64 {
+	return atomic.LoadInt64(&a.droppedNewest) +
+		atomic.LoadInt64(&a.droppedOldest) +
+		atomic.LoadInt64(&a.timeouts) +
+		atomic.LoadInt64(&a.canceled) +
+		atomic.LoadInt64(&a.expired)
+}
+
+// Flush waits for the events queued before the call to be passed to the
+// handler, reports the events that expired since the last flush, then flushes
+// the handler.
+func (a *AsyncHandler) Flush() error {
+	a.mutex.Lock()
+
+	for pushed := a.pushed; a.done < pushed; {
+		a.idle.Wait()
+	}
+
+	a.mutex.Unlock()
+	a.expiredSummary()
+	return flushHandler(a.handler)
+}
+
+// Close stops accepting events, waits for the queued events to be passed to
+// the handler, then closes it. Events received after Close are dropped.
+func (a *AsyncHandler) Close() error {
+	a.mutex.Lock()
+
+	if a.closed {
+		a.mutex.Unlock()
+		<-a.exit
+		return nil
+	}
+
+	a.closed = true
+	close(a.space) // wake up blocked producers
+	a.mutex.Unlock()
+
+	select {
+	case a.wake <- struct{}{}:
+	default:
+	}
+
+	<-a.exit
+	a.expiredSummary()
+	return closeHandler(a.handler)
+}
+
+func (a *AsyncHandler) run() {
+	defer close(a.exit)
+
+	ticker := time.NewTicker(a.config.SummaryInterval)
+	defer ticker.Stop()
+
+	for {
+		e, diag, closed := a.pop()
+
+		if e != nil {
+			switch {
+			case diag:
+				a.handle(e)
+			case a.tooOld(e):
+				atomic.AddInt64(&a.expired, 1)
+			default:
+				a.handle(e)
+				atomic.AddInt64(&a.handled, 1)
+			}
+
+			a.mutex.Lock()
+			a.done++
+			a.idle.Broadcast()
+			a.mutex.Unlock()
+
+			// Drops happen while the queue is full, so the summary must
+			// also be emitted while the handler is busy.
+			select {
+			case <-ticker.C:
+				a.summary()
+			default:
+			}
+			continue
+		}
+
+		if closed {
+			a.summary()
+			return
+		}
+
+		select {
+		case <-a.wake:
+		case <-ticker.C:
+			a.summary()
+		}
+	}
+}
+
+// pop removes the oldest diagnostic event, or the oldest event from the queue
+// if there are no diagnostic events, returning nil if the queue is empty.
+func (a *AsyncHandler) pop() (e *Event, diag bool, closed bool) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if len(a.diags) != 0 {
+		e = a.diags[0]
+		a.diags[0] = nil
+		a.diags = a.diags[1:]
+		return e, true, a.closed
+	}
+
+	if a.length == 0 {
+		return nil, false, a.closed
+	}
+
+	e = a.queue[a.head]
+	a.queue[a.head] = nil
+	a.head = (a.head + 1) % len(a.queue)
+	a.length--
+
+	if a.waiters != 0 && !a.closed {
+		close(a.space)
+		a.space = make(chan struct{})
+	}
+
+	return e, false, a.closed
+}
+
+// summary emits an event reporting the events dropped since the last call,
+// expired events are reported separately by expiredSummary.
+func (a *AsyncHandler) summary() {
+	counts := [4]int64{
+		atomic.LoadInt64(&a.droppedNewest),
+		atomic.LoadInt64(&a.droppedOldest),
+		atomic.LoadInt64(&a.timeouts),
+		atomic.LoadInt64(&a.canceled),
+	}
+
+	newest := counts[0] - a.reported[0]
+	oldest := counts[1] - a.reported[1]
+	timeouts := counts[2] - a.reported[2]
+	canceled := counts[3] - a.reported[3]
+	dropped := newest + oldest + timeouts + canceled
+
+	if dropped == 0 {
+		return
+	}
+
+	a.reported = counts
+	a.handle(Diagnostic(fmt.Sprintf("async handler dropped %d events", dropped),
+		Arg{"dropped", dropped},
+		Arg{"dropped_newest", newest},
+		Arg{"dropped_oldest", oldest},
+		Arg{"timeouts", timeouts},
+		Arg{"canceled", canceled},
+	))
+}
+
+// tooOld returns true if e is older than the MaxAge of the handler. Events
+// without a time never expire.
+func (a *AsyncHandler) tooOld(e *Event) bool {
+	return a.config.MaxAge > 0 && !e.Time.IsZero() && a.config.Now().Sub(e.Time) > a.config.MaxAge
+}
+
+// expiredSummary emits an event reporting the events that expired since the
+// last call.
+func (a *AsyncHandler) expiredSummary() {
+	a.mutex.Lock()
+	expired := atomic.LoadInt64(&a.expired)
+	n := expired - a.reportedExpired
+	a.reportedExpired = expired
+	a.mutex.Unlock()
+
+	if n == 0 {
+		return
+	}
+
+	a.handle(Diagnostic(fmt.Sprintf("async handler dropped %d expired events", n),
+		Arg{"dropped", n},
+		Arg{"max_age", a.config.MaxAge},
+	))
+}
+
+// diagnostic queues the diagnostic event e, it is never dropped because the
+// queue is full, and isn't counted as dropped after the handler was closed.
+func (a *AsyncHandler) diagnostic(e *Event) {
+	e = e.Clone()
+	a.mutex.Lock()
+
+	if a.closed {
+		a.mutex.Unlock()
+		return
+	}
+
+	a.diags = append(a.diags, e)
+	a.pushed++
+	a.mutex.Unlock()
+
+	select {
+	case a.wake <- struct{}{}:
+	default:
+	}
+}
+
+// handle passes e to the handler, panics are recovered so the worker keeps
+// running, and reported to the default handler.
+func (a *AsyncHandler) handle(e *Event) {
+	if _, diag := safeCall(a.handler, func() bool { a.handler.HandleEvent(e); return false }); diag != nil {
+		reportPanic(a.handler, diag)
+	}
+}
@@ -0,0 +1,380 @@
+package events
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// gateHandler blocks every event until it is released, started receives the
+// events as soon as they are passed to the handler.
+type gateHandler struct {
+	eventRecorder
+	started chan *Event
+	release chan struct{}
+	flushed int32
+}
+
+func newGateHandler() *gateHandler {
+	return &gateHandler{
+		started: make(chan *Event, 100),
+		release: make(chan struct{}),
+	}
+}
+
+func (h *gateHandler) HandleEvent(e *Event) {
+	h.started <- e
+	<-h.release
+	h.eventRecorder.HandleEvent(e)
+}
+
+func (h *gateHandler) Flush() error {
+	atomic.StoreInt32(&h.flushed, 1)
+	return nil
+}
+
+func (h *gateHandler) waitStarted(t *testing.T, msg string) {
+	select {
+	case e := <-h.started:
+		if e.Message != msg {
+			t.Fatalf("expected %q to be passed to the handler but got %q", msg, e.Message)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("%q was not passed to the handler within 1 second", msg)
+	}
+}
+
+func (h *gateHandler) messages() []string {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	m := make([]string, len(h.events))
+	for i, e := range h.events {
+		m[i] = e.Message
+	}
+	return m
+}
+
+// newBlockedAsyncHandler returns an async handler with a queue of two events,
+// the event "A" is held by the downstream handler and events "B" and "C" are
+// queued.
+func newBlockedAsyncHandler(t *testing.T, config AsyncConfig) (*AsyncHandler, *gateHandler) {
+	if config.SummaryInterval == 0 {
+		config.SummaryInterval = time.Hour
+	}
+	config.QueueSize = 2
+
+	g := newGateHandler()
+	a := NewAsyncHandler(g, config)
+
+	a.HandleEvent(&Event{Message: "A"})
+	g.waitStarted(t, "A")
+	a.HandleEvent(&Event{Message: "B"})
+	a.HandleEvent(&Event{Message: "C"})
+	return a, g
+}
+
+func assertMessages(t *testing.T, found []string, expected ...string) {
+	if len(found) != len(expected) {
+		t.Errorf("expected %q but got %q", expected, found)
+		return
+	}
+	for i := range found {
+		if found[i] != expected[i] {
+			t.Errorf("expected %q but got %q", expected, found)
+			return
+		}
+	}
+}
+
+func assertStats(t *testing.T, found AsyncStats, expected AsyncStats) {
+	if found != expected {
+		t.Errorf("bad stats:\nexpected: %+v\nfound:    %+v", expected, found)
+	}
+}
+
+func TestAsyncHandlerDropNewest(t *testing.T) {
+	a, g := newBlockedAsyncHandler(t, AsyncConfig{Policy: DropNewest})
+	a.HandleEvent(&Event{Message: "D"})
+	a.HandleEvent(&Event{Message: "E"})
+
+	assertStats(t, a.Stats(), AsyncStats{QueueLength: 2, QueueSize: 2, DroppedNewest: 2})
+
+	close(g.release)
+	a.Close()
+
+	assertStats(t, a.Stats(), AsyncStats{QueueSize: 2, Handled: 3, DroppedNewest: 2})
+	assertMessages(t, g.messages(), "A", "B", "C", "async handler dropped 2 events")
+
+	if e := g.events[3]; e.Args.Map()["dropped_newest"] != int64(2) {
+		t.Error("bad summary event:", e.Args)
+	}
+}
+
+func TestAsyncHandlerDiagnostic(t *testing.T) {
+	for _, policy := range []QueuePolicy{Block, DropNewest, DropOldest} {
+		a, g := newBlockedAsyncHandler(t, AsyncConfig{Policy: policy})
+
+		// The handler is blocked, the diagnostic event is queued instead of
+		// being passed to it by the producer.
+		a.HandleEvent(Diagnostic("D"))
+
+		assertStats(t, a.Stats(), AsyncStats{QueueLength: 2, QueueSize: 2})
+
+		close(g.release)
+		a.Close()
+
+		assertStats(t, a.Stats(), AsyncStats{QueueSize: 2, Handled: 3})
+		assertMessages(t, g.messages(), "A", "D", "B", "C")
+
+		a.HandleEvent(Diagnostic("E"))
+
+		if n := a.Dropped(); n != 0 {
+			t.Error("the diagnostic event received after Close was counted as dropped:", n)
+		}
+	}
+}
+
+func TestAsyncHandlerDropOldest(t *testing.T) {
+	a, g := newBlockedAsyncHandler(t, AsyncConfig{Policy: DropOldest})
+	a.HandleEvent(&Event{Message: "D"})
+	a.HandleEvent(&Event{Message: "E"})
+
+	assertStats(t, a.Stats(), AsyncStats{QueueLength: 2, QueueSize: 2, DroppedOldest: 2})
+
+	close(g.release)
+	a.Close()
+
+	assertStats(t, a.Stats(), AsyncStats{QueueSize: 2, Handled: 3, DroppedOldest: 2})
+	assertMessages(t, g.messages(), "A", "D", "E", "async handler dropped 2 events")
+
+	if e := g.events[3]; e.Args.Map()["dropped_oldest"] != int64(2) {
+		t.Error("bad summary event:", e.Args)
+	}
+}
+
+func TestAsyncHandlerBlock(t *testing.T) {
+	t.Run("timeout", func(t *testing.T) {
+		a, g := newBlockedAsyncHandler(t, AsyncConfig{Policy: Block, BlockTimeout: 10 * time.Millisecond})
+
+		start := time.Now()
+		a.HandleEvent(&Event{Message: "D"})
+
+		if elapsed := time.Since(start); elapsed < 10*time.Millisecond {
+			t.Error("the producer was not blocked:", elapsed)
+		}
+
+		assertStats(t, a.Stats(), AsyncStats{QueueLength: 2, QueueSize: 2, Timeouts: 1})
+
+		close(g.release)
+		a.Close()
+
+		assertMessages(t, g.messages(), "A", "B", "C", "async handler dropped 1 events")
+	})
+
+	t.Run("no timeout", func(t *testing.T) {
+		a, g := newBlockedAsyncHandler(t, AsyncConfig{Policy: Block})
+		done := make(chan struct{})
+
+		go func() {
+			a.HandleEvent(&Event{Message: "D"})
+			close(done)
+		}()
+
+		select {
+		case <-done:
+			t.Fatal("the producer was not blocked")
+		case <-time.After(10 * time.Millisecond):
+		}
+
+		// Releasing "A" makes room for "D" in the queue.
+		g.release <- struct{}{}
+		g.waitStarted(t, "B")
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("the producer was not unblocked")
+		}
+
+		close(g.release)
+		a.Close()
+
+		assertStats(t, a.Stats(), AsyncStats{QueueSize: 2, Handled: 4})
+		assertMessages(t, g.messages(), "A", "B", "C", "D")
+	})
+
+	t.Run("close", func(t *testing.T) {
+		a, g := newBlockedAsyncHandler(t, AsyncConfig{Policy: Block})
+		done := make(chan struct{})
+
+		go func() {
+			a.HandleEvent(&Event{Message: "D"})
+			close(done)
+		}()
+
+		time.Sleep(10 * time.Millisecond)
+		close(g.release)
+		a.Close()
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("the producer was not unblocked by Close")
+		}
+	})
+}
+
+func TestAsyncHandlerClassify(t *testing.T) {
+	a, g := newBlockedAsyncHandler(t, AsyncConfig{
+		Policy: Block,
+		Classify: func(e *Event) QueuePolicy {
+			if e.Debug {
+				return DropNewest
+			}
+			return Block
+		},
+	})
+
+	done := make(chan struct{})
+
+	go func() {
+		a.HandleEvent(&Event{Message: "D"})
+		close(done)
+	}()
+
+	a.HandleEvent(&Event{Message: "debug", Debug: true})
+	assertStats(t, a.Stats(), AsyncStats{QueueLength: 2, QueueSize: 2, DroppedNewest: 1})
+
+	select {
+	case <-done:
+		t.Fatal("the producer of a regular event was not blocked")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	close(g.release)
+	<-done
+	a.Close()
+
+	assertMessages(t, g.messages(), "A", "B", "C", "D", "async handler dropped 1 events")
+}
+
+func TestAsyncHandlerSummary(t *testing.T) {
+	a, g := newBlockedAsyncHandler(t, AsyncConfig{SummaryInterval: 10 * time.Millisecond})
+	defer a.Close()
+
+	for i := 0; i != 3; i++ {
+		a.HandleEvent(&Event{Message: "dropped"})
+	}
+
+	close(g.release)
+
+	for i, msg := range []string{"A", "B", "C", "async handler dropped 3 events"} {
+		if e := g.wait(t); e == nil || e.Message != msg {
+			t.Fatalf("event %d: expected %q but got %+v", i, msg, e)
+		}
+	}
+
+	// No summary is emitted when nothing was dropped.
+	time.Sleep(50 * time.Millisecond)
+
+	if n := g.len(); n != 0 {
+		t.Error("unexpected summary events:", n)
+	}
+}
+
+func TestAsyncHandlerFlush(t *testing.T) {
+	g := newGateHandler()
+	close(g.release)
+
+	a := NewAsyncHandler(g, AsyncConfig{})
+	defer a.Close()
+
+	for i := 0; i != 100; i++ {
+		a.HandleEvent(&Event{Message: "A"})
+	}
+
+	a.Flush()
+
+	if n := g.len(); n != 100 {
+		t.Error("Flush returned before all events were handled:", n)
+	}
+
+	if atomic.LoadInt32(&g.flushed) == 0 {
+		t.Error("the handler was not flushed")
+	}
+}
+
+func TestAsyncHandlerMaxAge(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)}
+	g := newGateHandler()
+	a := NewAsyncHandler(g, AsyncConfig{
+		QueueSize:       4,
+		MaxAge:          time.Minute,
+		Now:             clock.Now,
+		SummaryInterval: time.Hour,
+	})
+
+	a.HandleEvent(&Event{Message: "A", Time: clock.Now()})
+	g.waitStarted(t, "A")
+	a.HandleEvent(&Event{Message: "B", Time: clock.Now().Add(-30 * time.Second)})
+	a.HandleEvent(&Event{Message: "C", Time: clock.Now().Add(-50 * time.Second)})
+	a.HandleEvent(&Event{Message: "D"})
+
+	// The age is checked when the events leave the queue, "C" expires while
+	// the handler is blocked.
+	clock.add(20 * time.Second)
+	close(g.release)
+	a.Flush()
+	a.Flush()
+
+	assertMessages(t, g.messages(), "A", "B", "D", "async handler dropped 1 expired events")
+	assertStats(t, a.Stats(), AsyncStats{QueueSize: 4, Handled: 3, Expired: 1})
+	g.reset()
+
+	a.HandleEvent(&Event{Message: "E", Time: clock.Now().Add(-2 * time.Minute)})
+	a.HandleEvent(&Event{Message: "F", Time: clock.Now().Add(-3 * time.Minute)})
+	a.HandleEvent(&Event{Message: "G", Time: clock.Now()})
+	a.Close()
+
+	assertMessages(t, g.messages(), "G", "async handler dropped 2 expired events")
+	assertStats(t, a.Stats(), AsyncStats{QueueSize: 4, Handled: 4, Expired: 3})
+}
+
+func TestAsyncHandlerClose(t *testing.T) {
+	h := &drainHandler{}
+	a := NewAsyncHandler(h, AsyncConfig{})
+	a.HandleEvent(&Event{Message: "A"})
+
+	if err := a.Close(); err != nil {
+		t.Error(err)
+	}
+
+	if err := a.Close(); err != nil {
+		t.Error(err)
+	}
+
+	a.HandleEvent(&Event{Message: "B"})
+
+	if n := atomic.LoadInt64(&h.count); n != 1 {
+		t.Error("bad number of events handled:", n)
+	}
+
+	if atomic.LoadInt32(&h.closed) == 0 {
+		t.Error("the handler was not closed")
+	}
+
+	assertStats(t, a.Stats(), AsyncStats{QueueSize: DefaultAsyncQueueSize, Handled: 1, DroppedNewest: 1})
+}
+
+func BenchmarkAsyncHandler(b *testing.B) {
+	a := NewAsyncHandler(Discard, AsyncConfig{Policy: Block})
+	defer a.Close()
+
+	e := &Event{Message: "Hello World!", Args: Args{{"name", "Luke"}}}
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			a.HandleEvent(e)
+		}
+	})
+}
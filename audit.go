@@ -0,0 +1,203 @@
+package events
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+// AuditTimeFormat is the format of event times in the records written by
+// AuditHandler, times are always written in UTC.
+const AuditTimeFormat = "2006-01-02T15:04:05.000000000Z"
+
+// AuditHandler is a handler which writes events to an audit log, where records
+// are chained with HMAC-SHA256 so that any modification of the log can be
+// detected by VerifyAuditLog.
+//
+// Each event is written as a line made of a canonical JSON representation of
+// the event, with arguments sorted by name and the time formatted with
+// AuditTimeFormat, followed by a tab and the hexadecimal MAC of the record. The
+// MAC of a record is computed over the MAC of the previous record and the JSON
+// representation of the event, so removing, reordering or altering records
+// breaks the chain.
+//
+// Each handler starts a new chain, so it should be given an empty output (a
+// new file for example).
+//
+// It is safe to use the handler concurrently from multiple goroutines.
+type AuditHandler struct {
+	mutex  sync.Mutex
+	output io.Writer
+	key    []byte
+	prev   [sha256.Size]byte
+	buffer bytes.Buffer
+	err    error
+}
+
+// NewAuditHandler returns an AuditHandler which writes records to w, signed
+// with key.
+func NewAuditHandler(w io.Writer, key []byte) *AuditHandler {
+	return &AuditHandler{
+		output: w,
+		key:    append([]byte(nil), key...),
+	}
+}
+
+// HandleEvent satisfies the Handler interface.
+//
+// Events are dropped after the handler failed to write to its output, the
+// error is reported by Flush and Close.
+func (a *AuditHandler) HandleEvent(e *Event) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if a.err != nil {
+		return
+	}
+
+	record, err := marshalAuditRecord(e)
+	if err != nil {
+		a.err = err
+		return
+	}
+
+	mac := auditMAC(a.key, a.prev[:], record)
+
+	a.buffer.Reset()
+	a.buffer.Write(record)
+	a.buffer.WriteByte('\t')
+	a.buffer.WriteString(hex.EncodeToString(mac))
+	a.buffer.WriteByte('\n')
+
+	if _, err := a.output.Write(a.buffer.Bytes()); err != nil {
+		a.err = err
+		return
+	}
+
+	copy(a.prev[:], mac)
+}
+
+// Flush returns the error that occurred while writing records, if any, and
+// commits the output to stable storage if it has a Sync method (like *os.File).
+func (a *AuditHandler) Flush() error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if a.err != nil {
+		return a.err
+	}
+
+	if s, ok := a.output.(interface {
+		Sync() error
+	}); ok {
+		return s.Sync()
+	}
+
+	return nil
+}
+
+// Close flushes the handler and closes its output if it implements io.Closer.
+func (a *AuditHandler) Close() error {
+	err := a.Flush()
+
+	if c, ok := a.output.(io.Closer); ok {
+		if cerr := c.Close(); err == nil {
+			err = cerr
+		}
+	}
+
+	return err
+}
+
+// AuditError is returned by VerifyAuditLog when a record of the audit log
+// doesn't match its MAC.
+type AuditError struct {
+	Record int    // index of the first invalid record, starting at zero
+	Reason string // description of the problem
+}
+
+// Error satisfies the error interface.
+func (e *AuditError) Error() string {
+	return fmt.Sprintf("events: audit log record %d: %s", e.Record, e.Reason)
+}
+
+// VerifyAuditLog reads an audit log written by an AuditHandler from r and
+// verifies the MAC of each record with key.
+//
+// The function returns the number of valid records. When a record is invalid
+// (because it was modified, removed, reordered, or cut), r is not read further
+// and the error is an *AuditError carrying the index of the record. Other
+// errors are returned if reading from r failed.
+//
+// Removing records at the end of the log can't be detected from the log alone,
+// programs that need to detect it must compare the number of records with a
+// count kept elsewhere.
+func VerifyAuditLog(r io.Reader, key []byte) (n int, err error) {
+	var prev [sha256.Size]byte
+	var br = bufio.NewReader(r)
+
+	for ; ; n++ {
+		line, err := br.ReadBytes('\n')
+
+		if err != nil {
+			if err == io.EOF {
+				if len(line) == 0 {
+					return n, nil
+				}
+				return n, &AuditError{Record: n, Reason: "truncated record"}
+			}
+			return n, err
+		}
+
+		line = line[:len(line)-1]
+		i := bytes.LastIndexByte(line, '\t')
+
+		if i < 0 {
+			return n, &AuditError{Record: n, Reason: "missing MAC"}
+		}
+
+		mac, err := hex.DecodeString(string(line[i+1:]))
+		if err != nil || len(mac) != sha256.Size {
+			return n, &AuditError{Record: n, Reason: "malformed MAC"}
+		}
+
+		if !hmac.Equal(mac, auditMAC(key, prev[:], line[:i])) {
+			return n, &AuditError{Record: n, Reason: "MAC mismatch"}
+		}
+
+		copy(prev[:], mac)
+	}
+}
+
+func auditMAC(key []byte, prev []byte, record []byte) []byte {
+	m := hmac.New(sha256.New, key)
+	m.Write(prev)
+	m.Write(record)
+	return m.Sum(nil)
+}
+
+func marshalAuditRecord(e *Event) ([]byte, error) {
+	args := append(make(Args, 0, len(e.Args)), e.Args...)
+	sort.Stable(byArgName(args))
+
+	return json.Marshal(struct {
+		Time    string `json:"time"`
+		Source  string `json:"source"`
+		Message string `json:"message"`
+		Debug   bool   `json:"debug"`
+		Args    Args   `json:"args"`
+	}{
+		Time:    e.Time.UTC().Format(AuditTimeFormat),
+		Source:  e.Source,
+		Message: e.Message,
+		Debug:   e.Debug,
+		Args:    args,
+	})
+}
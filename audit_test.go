@@ -0,0 +1,158 @@
+package events
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+var auditKey = []byte("0123456789abcdef")
+
+func writeAuditLog(t *testing.T) []string {
+	b := &bytes.Buffer{}
+	a := NewAuditHandler(b, auditKey)
+	now := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	a.HandleEvent(&Event{Message: "user logged in", Args: Args{{"user", "luke"}, {"address", "127.0.0.1"}}, Time: now})
+	a.HandleEvent(&Event{Message: "permission granted", Args: Args{{"user", "luke"}, {"role", "admin"}}, Time: now})
+	a.HandleEvent(&Event{Message: "user logged out", Args: Args{{"user", "luke"}}, Time: now})
+
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.SplitAfter(b.String(), "\n")
+	return lines[:len(lines)-1]
+}
+
+func TestAuditHandler(t *testing.T) {
+	lines := writeAuditLog(t)
+
+	if len(lines) != 3 {
+		t.Fatalf("bad number of records: %q", lines)
+	}
+
+	const record = `{"time":"2017-01-01T00:00:00.000000000Z","source":"","message":"user logged in","debug":false,"args":{"address":"127.0.0.1","user":"luke"}}` + "\t"
+
+	if !strings.HasPrefix(lines[0], record) {
+		t.Errorf("bad record:\n%s\n%s", lines[0], record)
+	}
+
+	n, err := VerifyAuditLog(strings.NewReader(strings.Join(lines, "")), auditKey)
+
+	if n != 3 || err != nil {
+		t.Error("bad verification:", n, err)
+	}
+}
+
+func TestVerifyAuditLog(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func([]string) []string
+		key    []byte
+		record int
+		reason string
+	}{
+		{
+			name: "modified byte",
+			tamper: func(lines []string) []string {
+				lines[1] = strings.Replace(lines[1], "admin", "adman", 1)
+				return lines
+			},
+			record: 1,
+			reason: "MAC mismatch",
+		},
+		{
+			name: "modified MAC",
+			tamper: func(lines []string) []string {
+				b := []byte(lines[2])
+				if b[len(b)-2] == '0' {
+					b[len(b)-2] = '1'
+				} else {
+					b[len(b)-2] = '0'
+				}
+				lines[2] = string(b)
+				return lines
+			},
+			record: 2,
+			reason: "MAC mismatch",
+		},
+		{
+			name: "deleted record",
+			tamper: func(lines []string) []string {
+				return append(lines[:1], lines[2:]...)
+			},
+			record: 1,
+			reason: "MAC mismatch",
+		},
+		{
+			name: "reordered records",
+			tamper: func(lines []string) []string {
+				lines[1], lines[2] = lines[2], lines[1]
+				return lines
+			},
+			record: 1,
+			reason: "MAC mismatch",
+		},
+		{
+			name: "truncated record",
+			tamper: func(lines []string) []string {
+				lines[2] = lines[2][:len(lines[2])/2]
+				return lines
+			},
+			record: 2,
+			reason: "truncated record",
+		},
+		{
+			name:   "wrong key",
+			tamper: func(lines []string) []string { return lines },
+			key:    []byte("fedcba9876543210"),
+			record: 0,
+			reason: "MAC mismatch",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			key := test.key
+			if key == nil {
+				key = auditKey
+			}
+
+			log := strings.Join(test.tamper(writeAuditLog(t)), "")
+			n, err := VerifyAuditLog(strings.NewReader(log), key)
+
+			e, ok := err.(*AuditError)
+			if !ok {
+				t.Fatalf("bad error: %#v", err)
+			}
+
+			if n != test.record || e.Record != test.record || e.Reason != test.reason {
+				t.Errorf("bad verification: n=%d %v", n, err)
+			}
+		})
+	}
+}
+
+type failingWriter struct{ n int }
+
+func (w *failingWriter) Write(b []byte) (int, error) {
+	if w.n == 0 {
+		return 0, errors.New("disk full")
+	}
+	w.n--
+	return len(b), nil
+}
+
+func TestAuditHandlerWriteError(t *testing.T) {
+	a := NewAuditHandler(&failingWriter{n: 1}, auditKey)
+	a.HandleEvent(&Event{Message: "A"})
+	a.HandleEvent(&Event{Message: "B"})
+	a.HandleEvent(&Event{Message: "C"})
+
+	if err := a.Flush(); err == nil || err.Error() != "disk full" {
+		t.Error("bad error:", err)
+	}
+}
@@ -0,0 +1,699 @@
+package events
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math"
+	"sync"
+	"time"
+)
+
+// BinaryVersion is the most recent version of the binary encoding, it is the
+// version written by BinaryEncoder by default.
+const BinaryVersion = 2
+
+// DefaultBinaryCompressThreshold is the minimum size of the fields of the
+// frames compressed by BinaryEncoder, when none is configured.
+const DefaultBinaryCompressThreshold = 256
+
+// BinaryCompression values select the codec used by BinaryEncoder to compress
+// frames.
+type BinaryCompression byte
+
+const (
+	// BinaryUncompressed disables the compression of frames.
+	BinaryUncompressed BinaryCompression = iota
+
+	// BinaryGzip compresses frames with gzip.
+	BinaryGzip
+)
+
+// MaxBinaryFrameSize is the maximum size of the frames accepted by the binary
+// decoder, in bytes. Frames declaring a larger size are rejected before memory
+// is allocated for them, so hostile inputs can't exhaust the memory of the
+// program.
+var MaxBinaryFrameSize = 4 << 20
+
+var (
+	// ErrBinaryTruncated is returned when decoding a binary frame which is not
+	// complete.
+	ErrBinaryTruncated = errors.New("events: truncated binary frame")
+
+	// ErrBinaryFrameSize is returned when encoding or decoding a binary frame
+	// larger than MaxBinaryFrameSize.
+	ErrBinaryFrameSize = errors.New("events: binary frame larger than the maximum size")
+
+	// ErrBinaryFormat is returned when decoding a malformed binary frame.
+	ErrBinaryFormat = errors.New("events: malformed binary frame")
+
+	// ErrBinaryVersion is returned by BinaryEncoder when configured to write a
+	// version of the encoding that it doesn't support, or to compress frames
+	// with a codec or a version that doesn't support it.
+	ErrBinaryVersion = errors.New("events: unsupported binary encoding version")
+)
+
+// Tags of the fields of binary frames.
+const (
+	binaryTime       = 1
+	binaryMessage    = 2
+	binarySource     = 3
+	binaryDebug      = 4
+	binaryArg        = 5
+	binaryCompressed = 6
+)
+
+// Types of the argument values of binary frames.
+const (
+	binaryNil byte = iota
+	binaryFalse
+	binaryTrue
+	binaryInt
+	binaryUint
+	binaryFloat
+	binaryString
+	binaryBytes
+	binaryTimeValue
+	binaryDuration
+	binaryError
+	binaryJSON
+)
+
+// BinaryEncoder encodes events in a compact binary format, which carries byte
+// slices, times and durations natively and is designed to be read by programs
+// built with different versions of the package.
+//
+// Each event is encoded as a frame made of a one byte version, followed by the
+// size of the frame body as an unsigned varint, and by the body. The body is a
+// sequence of fields, each starting with its tag and the size of its payload
+// as unsigned varints:
+//
+//	1 time:       signed varint of the Unix seconds, unsigned varint of the nanoseconds
+//	2 message:    bytes of the message
+//	3 source:     bytes of the source
+//	4 debug:      the byte 1, the field is omitted for events that aren't debug events
+//	5 arg:        unsigned varint of the name size, the name, the value type, the value
+//	6 compressed: the codec (1 for gzip), the compressed fields, since version 2
+//
+// Fields are written in this order with one arg field per argument, the zero
+// time and empty message and source are omitted. When the encoder compresses
+// a frame its body is made of a single compressed field, which holds all the
+// other fields of the frame. Values have one of these types:
+//
+//	0 nil
+//	1 false
+//	2 true
+//	3 int:      signed varint, for all signed integer types
+//	4 uint:     unsigned varint, for all unsigned integer types
+//	5 float:    IEEE 754 binary64 in little endian, for float32 and float64
+//	6 string:   bytes of the string, also used for values implementing Formatter
+//	7 bytes:    the bytes of []byte and BytesValue values
+//	8 time:     like the time field
+//	9 duration: signed varint of the nanoseconds
+//	10 error:   the error message
+//	11 json:    JSON representation of other values, see Args.MarshalJSON
+//
+// Compatibility between versions is guaranteed by these rules: new versions of
+// the encoding only add field tags and value types, the meaning of existing
+// ones never changes. Decoders accept frames of any version, skipping the
+// fields with unknown tags and trailing bytes of values of known types. The
+// unknown fields and values are preserved so frames can be relayed without
+// losing information, see BinaryFrame and BinaryValue.
+type BinaryEncoder struct {
+	// EncodeVersion is the version of the encoding written by the encoder, it
+	// defaults to BinaryVersion. Programs sending frames to readers built with
+	// older versions of the package may set it to the version known to the
+	// readers, so the encoder doesn't write fields that they would ignore.
+	EncodeVersion int
+
+	// Compression may be set to compress each frame on its own, frames aren't
+	// compressed by default. Compression requires version 2 of the encoding,
+	// the encoder returns ErrBinaryVersion if EncodeVersion is lower.
+	Compression BinaryCompression
+
+	// CompressThreshold is the minimum size of the fields of a frame for the
+	// frame to be compressed, defaults to DefaultBinaryCompressThreshold.
+	// Frames are also written uncompressed when compression doesn't make them
+	// smaller.
+	CompressThreshold int
+}
+
+// BinaryFrame is a decoded binary frame.
+type BinaryFrame struct {
+	// Version is the version of the encoding that the frame was written with.
+	// It may be more recent than BinaryVersion.
+	Version int
+
+	// Event is the event carried by the frame.
+	Event *Event
+
+	// Unknown holds the encoded fields with tags unknown to the decoder, in
+	// the order they appeared in the frame.
+	Unknown []byte
+}
+
+// BinaryValue is an argument value of a type unknown to the binary decoder,
+// written by a more recent version of the encoding. BinaryEncoder writes it
+// back unchanged.
+type BinaryValue struct {
+	Type byte
+	Data []byte
+}
+
+// EncodeEvent satisfies the Encoder interface.
+func (enc BinaryEncoder) EncodeEvent(dst []byte, e *Event) ([]byte, error) {
+	return enc.EncodeFrame(dst, BinaryFrame{Event: e})
+}
+
+// EncodeFrame appends the binary frame f to dst and returns the extended
+// buffer. The unknown fields of f are written after the fields of the event,
+// and the version of the frame is the most recent of f.Version and the
+// encoder's version, so decoded frames are relayed without losing information.
+func (enc BinaryEncoder) EncodeFrame(dst []byte, f BinaryFrame) ([]byte, error) {
+	version := enc.EncodeVersion
+
+	if version == 0 {
+		version = BinaryVersion
+	}
+
+	if version < 1 || version > BinaryVersion {
+		return dst, ErrBinaryVersion
+	}
+
+	if enc.Compression != BinaryUncompressed && (version < 2 || enc.Compression != BinaryGzip) {
+		return dst, ErrBinaryVersion
+	}
+
+	if f.Version > version {
+		version = f.Version
+	}
+
+	if version > math.MaxUint8 {
+		return dst, ErrBinaryVersion
+	}
+
+	e := f.Event
+	start := len(dst)
+	dst = append(dst, byte(version))
+	body := len(dst)
+
+	if !e.Time.IsZero() {
+		dst = appendUvarint(dst, binaryTime)
+		dst = appendBinaryLength(dst, len(dst), func(b []byte) []byte {
+			return appendBinaryTime(b, e.Time)
+		})
+	}
+
+	if len(e.Message) != 0 {
+		dst = appendBinaryString(dst, binaryMessage, e.Message)
+	}
+
+	if len(e.Source) != 0 {
+		dst = appendBinaryString(dst, binarySource, e.Source)
+	}
+
+	if e.Debug {
+		dst = append(dst, binaryDebug, 1, 1)
+	}
+
+	for _, a := range e.Args {
+		var err error
+		dst = appendUvarint(dst, binaryArg)
+		dst = appendBinaryLength(dst, len(dst), func(b []byte) []byte {
+			b = appendUvarint(b, uint64(len(a.Name)))
+			b = append(b, a.Name...)
+			b, err = appendBinaryValue(b, a.Value)
+			return b
+		})
+		if err != nil {
+			return dst[:start], err
+		}
+	}
+
+	dst = append(dst, f.Unknown...)
+
+	if len(dst)-body > MaxBinaryFrameSize {
+		return dst[:start], ErrBinaryFrameSize
+	}
+
+	if enc.Compression != BinaryUncompressed {
+		threshold := enc.CompressThreshold
+
+		if threshold <= 0 {
+			threshold = DefaultBinaryCompressThreshold
+		}
+
+		if len(dst)-body >= threshold {
+			dst = compressBinaryBody(dst, body)
+		}
+	}
+
+	return insertUvarint(dst, body), nil
+}
+
+var binaryGzipWriters sync.Pool // *gzip.Writer
+
+// compressBinaryBody replaces the fields at b[body:] with a compressed field
+// holding them, unless the compressed field would be larger than the fields.
+func compressBinaryBody(b []byte, body int) []byte {
+	z := bytes.Buffer{}
+	z.WriteByte(byte(BinaryGzip))
+
+	w, _ := binaryGzipWriters.Get().(*gzip.Writer)
+	if w == nil {
+		w = gzip.NewWriter(&z)
+	} else {
+		w.Reset(&z)
+	}
+
+	w.Write(b[body:])
+	w.Close()
+	binaryGzipWriters.Put(w)
+
+	if z.Len()+1+binary.MaxVarintLen64 >= len(b)-body {
+		return b
+	}
+
+	b = appendUvarint(b[:body], binaryCompressed)
+	b = appendUvarint(b, uint64(z.Len()))
+	return append(b, z.Bytes()...)
+}
+
+// DecodeBinaryFrame decodes the binary frame at the beginning of b, returning
+// the frame and its size.
+//
+// The function returns ErrBinaryTruncated if b doesn't contain a whole frame,
+// ErrBinaryFrameSize if the frame is larger than MaxBinaryFrameSize, and
+// ErrBinaryFormat if the frame is malformed. The size is returned along with
+// ErrBinaryFormat when only the body of the frame is malformed, so the
+// following frames can still be decoded.
+//
+// The decoded values don't reference the memory of b.
+func DecodeBinaryFrame(b []byte) (f BinaryFrame, n int, err error) {
+	if len(b) == 0 {
+		return f, 0, ErrBinaryTruncated
+	}
+
+	if b[0] == 0 {
+		return f, 0, ErrBinaryFormat
+	}
+
+	size, k := binary.Uvarint(b[1:])
+
+	switch {
+	case k == 0:
+		return f, 0, ErrBinaryTruncated
+	case k < 0:
+		return f, 0, ErrBinaryFormat
+	case size > uint64(MaxBinaryFrameSize):
+		return f, 0, ErrBinaryFrameSize
+	case size > uint64(len(b)-1-k):
+		return f, 0, ErrBinaryTruncated
+	}
+
+	n = 1 + k + int(size)
+	f, err = decodeBinaryBody(int(b[0]), b[1+k:n])
+	return f, n, err
+}
+
+// binaryReadChunk is the size of the buffers allocated by BinaryReader before
+// reading frames.
+const binaryReadChunk = 64 * 1024
+
+// BinaryReader reads binary frames from a stream, it implements the Reader
+// interface so the frames can be filtered with a StreamQuery.
+type BinaryReader struct {
+	r   *bufio.Reader
+	buf []byte
+}
+
+// NewBinaryReader returns a BinaryReader which reads frames from r.
+func NewBinaryReader(r io.Reader) *BinaryReader {
+	return &BinaryReader{r: bufio.NewReader(r)}
+}
+
+// ReadFrame reads the next frame of the stream. It returns io.EOF when the end
+// of the stream was reached, and io.ErrUnexpectedEOF if the stream ends in the
+// middle of a frame. Other errors are the ones of DecodeBinaryFrame, the reader
+// can still be used after ErrBinaryFormat if the frame header could be read.
+func (r *BinaryReader) ReadFrame() (BinaryFrame, error) {
+	version, err := r.r.ReadByte()
+	if err != nil {
+		return BinaryFrame{}, err
+	}
+
+	if version == 0 {
+		return BinaryFrame{}, ErrBinaryFormat
+	}
+
+	size, err := binary.ReadUvarint(r.r)
+
+	switch {
+	case err == io.EOF:
+		return BinaryFrame{}, io.ErrUnexpectedEOF
+	case err != nil && err != io.ErrUnexpectedEOF:
+		return BinaryFrame{}, ErrBinaryFormat
+	case err != nil:
+		return BinaryFrame{}, err
+	case size > uint64(MaxBinaryFrameSize):
+		return BinaryFrame{}, ErrBinaryFrameSize
+	}
+
+	var body []byte
+
+	if size <= binaryReadChunk || size <= uint64(cap(r.buf)) {
+		if uint64(cap(r.buf)) < size {
+			r.buf = make([]byte, binaryReadChunk)
+		}
+		body = r.buf[:size]
+		_, err = io.ReadFull(r.r, body)
+	} else {
+		// The buffer grows as data is read, so a frame header declaring a
+		// large size doesn't cause the allocation of a buffer that the stream
+		// doesn't fill.
+		body, err = ioutil.ReadAll(io.LimitReader(r.r, int64(size)))
+		if err == nil && uint64(len(body)) != size {
+			err = io.ErrUnexpectedEOF
+		}
+		r.buf = body
+	}
+
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return BinaryFrame{}, err
+	}
+
+	return decodeBinaryBody(int(version), body)
+}
+
+// ReadEvent satisfies the Reader interface, it returns the event of the next
+// frame.
+func (r *BinaryReader) ReadEvent() (*Event, error) {
+	f, err := r.ReadFrame()
+	return f.Event, err
+}
+
+func decodeBinaryBody(version int, b []byte) (BinaryFrame, error) {
+	f := BinaryFrame{Version: version, Event: &Event{}}
+
+	if err := decodeBinaryFields(&f, b, false); err != nil {
+		return BinaryFrame{}, err
+	}
+
+	return f, nil
+}
+
+// decodeBinaryFields decodes the fields of b into f, compressed is true when
+// b was the payload of a compressed field, which can't be nested.
+func decodeBinaryFields(f *BinaryFrame, b []byte, compressed bool) error {
+	e := f.Event
+
+	for len(b) != 0 {
+		tag, payload, n, ok := decodeBinaryField(b)
+		if !ok {
+			return ErrBinaryFormat
+		}
+
+		switch tag {
+		case binaryTime:
+			t, ok := decodeBinaryTime(payload)
+			if !ok {
+				return ErrBinaryFormat
+			}
+			e.Time = t
+
+		case binaryMessage:
+			e.Message = string(payload)
+
+		case binarySource:
+			e.Source = string(payload)
+
+		case binaryDebug:
+			e.Debug = len(payload) != 0 && payload[0] != 0
+
+		case binaryArg:
+			a, ok := decodeBinaryArg(payload)
+			if !ok {
+				return ErrBinaryFormat
+			}
+			e.Args = append(e.Args, a)
+
+		case binaryCompressed:
+			if len(payload) == 0 || BinaryCompression(payload[0]) != BinaryGzip {
+				// Codecs unknown to this version are preserved like unknown
+				// fields.
+				f.Unknown = append(f.Unknown, b[:n]...)
+				break
+			}
+
+			if compressed {
+				return ErrBinaryFormat
+			}
+
+			fields, err := decompressBinaryFields(payload[1:])
+			if err != nil {
+				return err
+			}
+
+			if err := decodeBinaryFields(f, fields, true); err != nil {
+				return err
+			}
+
+		default:
+			f.Unknown = append(f.Unknown, b[:n]...)
+		}
+
+		b = b[n:]
+	}
+
+	return nil
+}
+
+// decompressBinaryFields decompresses the payload of a compressed field, the
+// decompressed fields are limited to MaxBinaryFrameSize.
+func decompressBinaryFields(b []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, ErrBinaryFormat
+	}
+
+	fields, err := ioutil.ReadAll(io.LimitReader(r, int64(MaxBinaryFrameSize)+1))
+
+	switch {
+	case err != nil:
+		return nil, ErrBinaryFormat
+	case len(fields) > MaxBinaryFrameSize:
+		return nil, ErrBinaryFrameSize
+	}
+
+	return fields, nil
+}
+
+// decodeBinaryField decodes the field at the beginning of b, returning its
+// tag, its payload and its size.
+func decodeBinaryField(b []byte) (tag uint64, payload []byte, n int, ok bool) {
+	tag, k1 := binary.Uvarint(b)
+	if k1 <= 0 {
+		return
+	}
+
+	size, k2 := binary.Uvarint(b[k1:])
+	if k2 <= 0 || size > uint64(len(b)-k1-k2) {
+		return
+	}
+
+	n = k1 + k2 + int(size)
+	return tag, b[k1+k2 : n], n, true
+}
+
+func decodeBinaryArg(b []byte) (Arg, bool) {
+	size, k := binary.Uvarint(b)
+	if k <= 0 || size >= uint64(len(b)-k) {
+		return Arg{}, false
+	}
+
+	name := string(b[k : k+int(size)])
+	b = b[k+int(size):]
+	v, ok := decodeBinaryValue(b[0], b[1:])
+	return Arg{name, v}, ok
+}
+
+func decodeBinaryValue(t byte, b []byte) (interface{}, bool) {
+	switch t {
+	case binaryNil:
+		return nil, true
+
+	case binaryFalse:
+		return false, true
+
+	case binaryTrue:
+		return true, true
+
+	case binaryInt:
+		v, k := binary.Varint(b)
+		return v, k > 0
+
+	case binaryUint:
+		v, k := binary.Uvarint(b)
+		return v, k > 0
+
+	case binaryFloat:
+		if len(b) < 8 {
+			return nil, false
+		}
+		return math.Float64frombits(binary.LittleEndian.Uint64(b)), true
+
+	case binaryString:
+		return string(b), true
+
+	case binaryBytes:
+		return append([]byte{}, b...), true
+
+	case binaryTimeValue:
+		return decodeBinaryTime(b)
+
+	case binaryDuration:
+		v, k := binary.Varint(b)
+		return time.Duration(v), k > 0
+
+	case binaryError:
+		return errors.New(string(b)), true
+
+	case binaryJSON:
+		d := json.NewDecoder(bytes.NewReader(b))
+		d.UseNumber()
+
+		var v interface{}
+		if err := d.Decode(&v); err != nil {
+			return nil, false
+		}
+		return convertJSONNumbers(v), true
+
+	default:
+		return BinaryValue{Type: t, Data: append([]byte{}, b...)}, true
+	}
+}
+
+func decodeBinaryTime(b []byte) (time.Time, bool) {
+	sec, k1 := binary.Varint(b)
+	if k1 <= 0 {
+		return time.Time{}, false
+	}
+
+	nsec, k2 := binary.Uvarint(b[k1:])
+	if k2 <= 0 || nsec >= uint64(time.Second) {
+		return time.Time{}, false
+	}
+
+	return time.Unix(sec, int64(nsec)).UTC(), true
+}
+
+func appendBinaryValue(b []byte, v interface{}) ([]byte, error) {
+	switch x := v.(type) {
+	case nil:
+		return append(b, binaryNil), nil
+	case bool:
+		if x {
+			return append(b, binaryTrue), nil
+		}
+		return append(b, binaryFalse), nil
+	case int:
+		return appendVarint(append(b, binaryInt), int64(x)), nil
+	case int8:
+		return appendVarint(append(b, binaryInt), int64(x)), nil
+	case int16:
+		return appendVarint(append(b, binaryInt), int64(x)), nil
+	case int32:
+		return appendVarint(append(b, binaryInt), int64(x)), nil
+	case int64:
+		return appendVarint(append(b, binaryInt), x), nil
+	case uint:
+		return appendUvarint(append(b, binaryUint), uint64(x)), nil
+	case uint8:
+		return appendUvarint(append(b, binaryUint), uint64(x)), nil
+	case uint16:
+		return appendUvarint(append(b, binaryUint), uint64(x)), nil
+	case uint32:
+		return appendUvarint(append(b, binaryUint), uint64(x)), nil
+	case uint64:
+		return appendUvarint(append(b, binaryUint), x), nil
+	case uintptr:
+		return appendUvarint(append(b, binaryUint), uint64(x)), nil
+	case float32:
+		return appendBinaryFloat(append(b, binaryFloat), float64(x)), nil
+	case float64:
+		return appendBinaryFloat(append(b, binaryFloat), x), nil
+	case string:
+		return append(append(b, binaryString), x...), nil
+	case []byte:
+		return append(append(b, binaryBytes), x...), nil
+	case BytesValue:
+		return append(append(b, binaryBytes), x.Data...), nil
+	case time.Time:
+		return appendBinaryTime(append(b, binaryTimeValue), x), nil
+	case time.Duration:
+		return appendVarint(append(b, binaryDuration), int64(x)), nil
+	case BinaryValue:
+		return append(append(b, x.Type), x.Data...), nil
+	case KeepValue:
+		return appendBinaryValue(b, x.Value)
+	case Formatter:
+		return append(append(b, binaryString), x.FormatEventValue()...), nil
+	case error:
+		return append(append(b, binaryError), x.Error()...), nil
+	}
+
+	j, err := marshalJSONValue(v)
+	return append(append(b, binaryJSON), j...), err
+}
+
+func appendBinaryString(b []byte, tag uint64, s string) []byte {
+	b = appendUvarint(b, tag)
+	b = appendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+func appendBinaryTime(b []byte, t time.Time) []byte {
+	b = appendVarint(b, t.Unix())
+	return appendUvarint(b, uint64(t.Nanosecond()))
+}
+
+func appendBinaryFloat(b []byte, f float64) []byte {
+	var tmp [8]byte
+	binary.LittleEndian.PutUint64(tmp[:], math.Float64bits(f))
+	return append(b, tmp[:]...)
+}
+
+// appendBinaryLength appends the payload produced by f to b, preceded by its
+// size.
+func appendBinaryLength(b []byte, start int, f func([]byte) []byte) []byte {
+	return insertUvarint(f(b), start)
+}
+
+// insertUvarint inserts the size of b[start:] at start, as an unsigned varint.
+func insertUvarint(b []byte, start int) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	n := len(b) - start
+	k := binary.PutUvarint(tmp[:], uint64(n))
+	b = append(b, tmp[:k]...)
+	copy(b[start+k:], b[start:start+n])
+	copy(b[start:], tmp[:k])
+	return b
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	return append(b, tmp[:binary.PutUvarint(tmp[:], v)]...)
+}
+
+func appendVarint(b []byte, v int64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	return append(b, tmp[:binary.PutVarint(tmp[:], v)]...)
+}
@@ -0,0 +1,58 @@
+//go:build go1.18
+// +build go1.18
+
+package events
+
+import (
+	"runtime"
+	"testing"
+)
+
+func FuzzDecodeBinaryFrame(f *testing.F) {
+	for _, file := range []string{
+		"testdata/binary-v1-minimal.bin",
+		"testdata/binary-v1-full.bin",
+		"testdata/binary-v2-future.bin",
+	} {
+		f.Add(readFixture(f, file))
+	}
+
+	f.Add(gzipBinaryFields([]byte("\x02\x05hello")))
+
+	// Compressed fields are decompressed up to MaxBinaryFrameSize, which is
+	// lowered to keep the allocation bound meaningful.
+	defer func(max int) { MaxBinaryFrameSize = max }(MaxBinaryFrameSize)
+	MaxBinaryFrameSize = 64 * 1024
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		var m0, m1 runtime.MemStats
+
+		runtime.ReadMemStats(&m0)
+		frame, n, err := DecodeBinaryFrame(b)
+		runtime.ReadMemStats(&m1)
+
+		// Decoding must allocate memory in proportion of the input size, not of
+		// the sizes declared by the length prefixes, or of the maximum frame
+		// size for compressed fields.
+		if a := m1.TotalAlloc - m0.TotalAlloc; a > uint64(64*len(b)+64*1024+4*MaxBinaryFrameSize) {
+			t.Fatalf("%d bytes allocated to decode %d bytes", a, len(b))
+		}
+
+		if err != nil {
+			return
+		}
+
+		if n > len(b) {
+			t.Fatal("bad frame size:", n)
+		}
+
+		r, err := BinaryEncoder{}.EncodeFrame(nil, frame)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, _, err := DecodeBinaryFrame(r); err != nil {
+			t.Fatal("the decoded frame couldn't be encoded again:", err)
+		}
+	})
+}
@@ -0,0 +1,466 @@
+package events
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The fixtures of testdata were written independently of BinaryEncoder and
+// must never be regenerated, they guarantee that frames written by previous
+// versions of the package can still be decoded.
+var binaryFixtures = []struct {
+	file  string
+	event *Event
+	args  Args // decoded arguments, if different from the event arguments
+}{
+	{
+		file:  "testdata/binary-v1-minimal.bin",
+		event: &Event{Message: "hello"},
+	},
+	{
+		file: "testdata/binary-v1-full.bin",
+		event: &Event{
+			Time:    time.Date(2017, 1, 1, 12, 34, 56, 789000000, time.UTC),
+			Message: "request served",
+			Source:  "server.go:42",
+			Debug:   true,
+			Args: Args{
+				{"nil", nil},
+				{"bool", true},
+				{"int", -42},
+				{"uint", uint(42)},
+				{"float", 1.5},
+				{"string", "héllo"},
+				{"bytes", []byte{0, 1, 2, 0xff}},
+				{"time", time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)},
+				{"duration", 1500 * time.Millisecond},
+				{"error", errors.New("EOF")},
+				{"json", map[string][]int{"a": {1, 2}}},
+			},
+		},
+		args: Args{
+			{"nil", nil},
+			{"bool", true},
+			{"int", int64(-42)},
+			{"uint", uint64(42)},
+			{"float", 1.5},
+			{"string", "héllo"},
+			{"bytes", []byte{0, 1, 2, 0xff}},
+			{"time", time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)},
+			{"duration", 1500 * time.Millisecond},
+			{"error", errors.New("EOF")},
+			{"json", map[string]interface{}{"a": []interface{}{int64(1), int64(2)}}},
+		},
+	},
+}
+
+func readFixture(t testing.TB, file string) []byte {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestBinaryEncoderGolden(t *testing.T) {
+	for _, test := range binaryFixtures {
+		t.Run(test.file, func(t *testing.T) {
+			b, err := BinaryEncoder{EncodeVersion: 1}.EncodeEvent(nil, test.event)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if want := readFixture(t, test.file); !bytes.Equal(b, want) {
+				t.Errorf("bad frame:\n%x\n%x", b, want)
+			}
+		})
+	}
+}
+
+func TestDecodeBinaryFrameGolden(t *testing.T) {
+	for _, test := range binaryFixtures {
+		t.Run(test.file, func(t *testing.T) {
+			b := readFixture(t, test.file)
+			f, n, err := DecodeBinaryFrame(b)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if n != len(b) || f.Version != 1 || f.Unknown != nil {
+				t.Errorf("bad frame: n=%d version=%d unknown=%x", n, f.Version, f.Unknown)
+			}
+
+			want := *test.event
+			if test.args != nil {
+				want.Args = test.args
+			}
+
+			if !reflect.DeepEqual(f.Event, &want) {
+				t.Errorf("bad event:\n%#v\n%#v", f.Event, &want)
+			}
+		})
+	}
+}
+
+func TestDecodeBinaryFrameFuture(t *testing.T) {
+	b := readFixture(t, "testdata/binary-v2-future.bin")
+	f, n, err := DecodeBinaryFrame(b)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != len(b) || f.Version != 2 {
+		t.Errorf("bad frame: n=%d version=%d", n, f.Version)
+	}
+
+	want := &Event{
+		Message: "hello",
+		Args:    Args{{"int", int64(7)}, {"future", BinaryValue{Type: 40, Data: []byte("xyz")}}},
+	}
+
+	if !reflect.DeepEqual(f.Event, want) {
+		t.Errorf("bad event: %#v", f.Event)
+	}
+
+	if want := []byte("\x09\x05extra\xac\x02\x02\x00\x01"); !bytes.Equal(f.Unknown, want) {
+		t.Errorf("bad unknown fields: %x", f.Unknown)
+	}
+
+	// The frame is relayed without losing the unknown fields and values, only
+	// the trailing bytes of the known int value are lost.
+	r, err := BinaryEncoder{}.EncodeFrame(nil, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if f2, _, err := DecodeBinaryFrame(r); err != nil || !reflect.DeepEqual(f2, f) {
+		t.Errorf("the relayed frame differs: %v\n%#v\n%#v", err, f2, f)
+	}
+}
+
+func TestBinaryEncoderVersion(t *testing.T) {
+	e := &Event{Message: "hello"}
+
+	if b, err := (BinaryEncoder{EncodeVersion: 1}).EncodeEvent(nil, e); err != nil || b[0] != 1 {
+		t.Errorf("bad frame: %x (%v)", b, err)
+	}
+
+	for _, v := range []int{-1, BinaryVersion + 1} {
+		if _, err := (BinaryEncoder{EncodeVersion: v}).EncodeEvent(nil, e); err != ErrBinaryVersion {
+			t.Errorf("bad error for version %d: %v", v, err)
+		}
+	}
+}
+
+func TestDecodeBinaryFrameErrors(t *testing.T) {
+	tests := []struct {
+		frame string
+		err   error
+	}{
+		{"", ErrBinaryTruncated},
+		{"\x01", ErrBinaryTruncated},
+		{"\x01\x80", ErrBinaryTruncated},
+		{"\x01\x07\x02\x05hel", ErrBinaryTruncated},
+		{"\x00\x00", ErrBinaryFormat},
+		{"\x01\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\x01", ErrBinaryFormat},
+		{"\x01\xff\xff\xff\xff\xff\xff\xff\xff\x7f", ErrBinaryFrameSize},
+		{"\x01\x80\x80\x80\x04", ErrBinaryFrameSize},
+		{"\x01\x06\x02\xff\xff\xff\xff\x0f", ErrBinaryFormat},         // field size
+		{"\x01\x04\x05\x02\x05a", ErrBinaryFormat},                    // name size
+		{"\x01\x04\x05\x02\x01a", ErrBinaryFormat},                    // missing value type
+		{"\x01\x05\x05\x03\x01a\x05", ErrBinaryFormat},                // float size
+		{"\x01\x07\x05\x05\x01a\x0b{\"", ErrBinaryFormat},             // json value
+		{"\x01\x04\x01\x02\x00\xff", ErrBinaryFormat},                 // nanoseconds
+		{"\x01\x08\x01\x06\x00\xff\xff\xff\xff\x0f", ErrBinaryFormat}, // nanoseconds
+		{"\x01\x04\x05\x02\x00\x03", ErrBinaryFormat},                 // int value
+		{"\x01\x05\x05\x03\x00\x09\x80", ErrBinaryFormat},             // duration value
+		{"\x01\x08\x05\x06\x00\x08\x80\x80\x80\x80", ErrBinaryFormat}, // time value
+	}
+
+	for _, test := range tests {
+		if _, _, err := DecodeBinaryFrame([]byte(test.frame)); err != test.err {
+			t.Errorf("%q: bad error: %v", test.frame, err)
+		}
+	}
+}
+
+func TestDecodeBinaryFrameHostileSize(t *testing.T) {
+	tests := []struct {
+		frame string
+		err   error
+	}{
+		{"\x01\xff\xff\xff\x7f\x02\xff\xff\xff\x01", ErrBinaryFrameSize},
+		{"\x01\xff\xff\xff\x01\x02\xff\xff\xff\x01", io.ErrUnexpectedEOF}, // just below the maximum size
+	}
+
+	for _, test := range tests {
+		var m0, m1 runtime.MemStats
+		frame := []byte(test.frame)
+
+		runtime.ReadMemStats(&m0)
+		_, _, err1 := DecodeBinaryFrame(frame)
+		_, err2 := NewBinaryReader(bytes.NewReader(frame)).ReadFrame()
+		runtime.ReadMemStats(&m1)
+
+		if test.err == ErrBinaryFrameSize && err1 != ErrBinaryFrameSize {
+			t.Errorf("%q: bad error: %v", test.frame, err1)
+		}
+
+		if err2 != test.err {
+			t.Errorf("%q: bad reader error: %v", test.frame, err2)
+		}
+
+		if n := m1.TotalAlloc - m0.TotalAlloc; n > 256*1024 {
+			t.Errorf("%q: too much memory allocated: %d", test.frame, n)
+		}
+	}
+}
+
+func TestBinaryReader(t *testing.T) {
+	buf := &bytes.Buffer{}
+	h := NewWriterHandler(buf, BinaryEncoder{})
+
+	for _, test := range binaryFixtures {
+		h.HandleEvent(test.event)
+	}
+
+	buf.Write(readFixture(t, "testdata/binary-v2-future.bin"))
+	r := NewBinaryReader(buf)
+
+	for _, test := range binaryFixtures {
+		e, err := r.ReadEvent()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e.Message != test.event.Message {
+			t.Error("bad event:", e)
+		}
+	}
+
+	if f, err := r.ReadFrame(); err != nil || f.Version != 2 || len(f.Unknown) == 0 {
+		t.Errorf("bad future frame: %v %+v", err, f)
+	}
+
+	if _, err := r.ReadFrame(); err != io.EOF {
+		t.Error("bad error at the end of the stream:", err)
+	}
+}
+
+func TestBinaryReaderTruncated(t *testing.T) {
+	b := readFixture(t, "testdata/binary-v1-full.bin")
+
+	for _, n := range []int{1, 2, len(b) - 1} {
+		if _, err := NewBinaryReader(bytes.NewReader(b[:n])).ReadFrame(); err != io.ErrUnexpectedEOF {
+			t.Errorf("bad error for %d bytes: %v", n, err)
+		}
+	}
+}
+
+func compressibleEvent() *Event {
+	e := &Event{
+		Time:    time.Date(2017, 1, 1, 12, 34, 56, 0, time.UTC),
+		Message: "request served",
+		Source:  "server.go:42",
+	}
+	for i := 0; i != 20; i++ {
+		e.Args = append(e.Args, Arg{"header." + strconv.Itoa(i), "application/json; charset=utf-8"})
+	}
+	return e
+}
+
+func TestBinaryEncoderCompression(t *testing.T) {
+	e := compressibleEvent()
+	enc := BinaryEncoder{Compression: BinaryGzip}
+
+	plain, _ := BinaryEncoder{}.EncodeEvent(nil, e)
+	b, err := enc.EncodeEvent(nil, e)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(b) >= len(plain) {
+		t.Errorf("the compressed frame isn't smaller: %d >= %d", len(b), len(plain))
+	}
+
+	f, n, err := DecodeBinaryFrame(b)
+	if err != nil || n != len(b) || f.Unknown != nil {
+		t.Fatalf("bad frame: n=%d unknown=%x (%v)", n, f.Unknown, err)
+	}
+
+	want, _, _ := DecodeBinaryFrame(plain)
+
+	if !reflect.DeepEqual(f, want) {
+		t.Errorf("bad event:\n%#v\n%#v", f.Event, want.Event)
+	}
+
+	// Small frames are written uncompressed.
+	small := &Event{Message: "hello"}
+
+	if b, _ := enc.EncodeEvent(nil, small); !bytes.Equal(b, []byte("\x02\x07\x02\x05hello")) {
+		t.Errorf("the small frame was compressed: %x", b)
+	}
+
+	if _, err := (BinaryEncoder{EncodeVersion: 1, Compression: BinaryGzip}).EncodeEvent(nil, e); err != ErrBinaryVersion {
+		t.Error("bad error compressing a version 1 frame:", err)
+	}
+}
+
+func TestBinaryReaderCompression(t *testing.T) {
+	buf := &bytes.Buffer{}
+	h := NewWriterHandler(buf, BinaryEncoder{Compression: BinaryGzip, CompressThreshold: 1})
+
+	h.HandleEvent(compressibleEvent())
+	h.HandleEvent(&Event{Message: "hello"})
+	h.HandleEvent(compressibleEvent())
+
+	r := NewBinaryReader(buf)
+
+	for _, msg := range []string{"request served", "hello", "request served"} {
+		if e, err := r.ReadEvent(); err != nil || e.Message != msg {
+			t.Errorf("bad event: %+v (%v)", e, err)
+		}
+	}
+
+	if _, err := r.ReadEvent(); err != io.EOF {
+		t.Error("bad error at the end of the stream:", err)
+	}
+}
+
+func TestDecodeBinaryFrameCompressed(t *testing.T) {
+	b, _ := BinaryEncoder{Compression: BinaryGzip}.EncodeEvent(nil, compressibleEvent())
+
+	// The size of the decompressed fields is limited like the size of frames.
+	defer func(max int) { MaxBinaryFrameSize = max }(MaxBinaryFrameSize)
+	MaxBinaryFrameSize = len(b) + 10
+
+	if _, _, err := DecodeBinaryFrame(b); err != ErrBinaryFrameSize {
+		t.Error("bad error for a frame decompressing above the maximum size:", err)
+	}
+
+	MaxBinaryFrameSize = 1024
+
+	// Compressed fields can't be nested.
+	_, k := binary.Uvarint(b[1:])
+	nested := gzipBinaryFields(b[1+k:])
+
+	if _, _, err := DecodeBinaryFrame(nested); err != ErrBinaryFormat {
+		t.Error("bad error for nested compressed fields:", err)
+	}
+
+	// The fields compressed with an unknown codec are preserved.
+	unknown := []byte("\x02\x0d\x02\x05hello\x06\x04\x09xyz")
+	f, _, err := DecodeBinaryFrame(unknown)
+
+	if err != nil || f.Event.Message != "hello" || !bytes.Equal(f.Unknown, []byte("\x06\x04\x09xyz")) {
+		t.Errorf("bad frame: %+v (%v)", f, err)
+	}
+}
+
+// gzipBinaryFields returns a version 2 frame made of a compressed field holding
+// fields.
+func gzipBinaryFields(fields []byte) []byte {
+	z := &bytes.Buffer{}
+	z.WriteByte(byte(BinaryGzip))
+	w := gzip.NewWriter(z)
+	w.Write(fields)
+	w.Close()
+
+	body := appendUvarint(appendUvarint(nil, binaryCompressed), uint64(z.Len()))
+	body = append(body, z.Bytes()...)
+	return append(appendUvarint([]byte{2}, uint64(len(body))), body...)
+}
+
+func TestBinaryReaderQuery(t *testing.T) {
+	buf := &bytes.Buffer{}
+	h := NewWriterHandler(buf, BinaryEncoder{})
+
+	for i := 0; i != 10; i++ {
+		h.HandleEvent(&Event{Message: "hello", Args: Args{{"i", i}}})
+	}
+
+	var list []int64
+
+	err := NewStreamQuery(NewBinaryReader(buf)).WhereFilter("args.i >= 7").Run(func(e *Event) error {
+		list = append(list, e.Args[0].Value.(int64))
+		return nil
+	})
+
+	if err != nil || !reflect.DeepEqual(list, []int64{7, 8, 9}) {
+		t.Error("bad query results:", list, err)
+	}
+}
+
+// BenchmarkBinaryEncoderGzip encodes batches of 1000 events with compression
+// and reports the ratio of the compressed and uncompressed sizes. The frames of
+// the small events are below the compression threshold.
+func BenchmarkBinaryEncoderGzip(b *testing.B) {
+	body := strings.Repeat(`{"id":42,"name":"Luke","roles":["admin","user"]},`, 40)
+
+	for _, test := range []struct {
+		name string
+		args func(i int) Args
+	}{
+		{"small", func(i int) Args { return nil }},
+		{"large", func(i int) Args { return Args{{"body", body}} }},
+	} {
+		b.Run(test.name, func(b *testing.B) {
+			batch := make([]*Event, 1000)
+
+			for i := range batch {
+				batch[i] = &Event{
+					Time:    time.Date(2017, 1, 1, 12, 34, 56, 0, time.UTC).Add(time.Duration(i) * time.Millisecond),
+					Message: "request served",
+					Source:  "server.go:42",
+					Args: append(Args{
+						{"method", "GET"},
+						{"path", "/api/users/" + strconv.Itoa(i%50)},
+						{"status", 200},
+						{"duration", time.Duration(i) * time.Microsecond},
+						{"user_agent", "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko)"},
+					}, test.args(i)...),
+				}
+			}
+
+			enc := BinaryEncoder{Compression: BinaryGzip}
+			buf := make([]byte, 0, 4096)
+			size, compressed := 0, 0
+			b.ReportAllocs()
+
+			for i := 0; i != b.N; i++ {
+				for _, e := range batch {
+					buf, _ = enc.EncodeEvent(buf[:0], e)
+					compressed += len(buf)
+
+					if i == 0 {
+						plain, _ := BinaryEncoder{}.EncodeEvent(nil, e)
+						size += len(plain)
+					}
+				}
+			}
+
+			b.ReportMetric(float64(compressed)/float64(b.N)/float64(size), "ratio")
+		})
+	}
+}
+
+func BenchmarkBinaryEncoder(b *testing.B) {
+	e := binaryFixtures[1].event
+	buf := make([]byte, 0, 1024)
+	b.ReportAllocs()
+
+	for i := 0; i != b.N; i++ {
+		buf, _ = BinaryEncoder{}.EncodeEvent(buf[:0], e)
+	}
+}
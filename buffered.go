@@ -0,0 +1,250 @@
+package events
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// DefaultBufferSize is the default number of events buffered by a
+	// BufferedHandler before they are passed to its handler.
+	DefaultBufferSize = 100
+
+	// DefaultBufferInterval is the default maximum time that events wait in
+	// a BufferedHandler before they are passed to its handler.
+	DefaultBufferInterval = time.Second
+)
+
+// BufferedHandler is a handler which accumulates events and passes them in
+// batches to another handler, flushing it after each batch. It suits handlers
+// which are expensive to flush, like the ones writing to a network connection
+// through a buffer.
+//
+// A batch is passed when size events were buffered, and every interval by a
+// background goroutine if events are pending. Events are passed to the handler
+// in the order they were received. Diagnostic events bypass the buffer.
+//
+// It is safe to use a buffered handler concurrently from multiple goroutines.
+type BufferedHandler struct {
+	dropped int64 // accessed atomically
+
+	handler Handler
+	size    int
+
+	mutex  sync.Mutex
+	events []*Event
+	closed bool
+
+	// held while a batch is passed to the handler, so batches are never
+	// interleaved
+	flushing sync.Mutex
+
+	once sync.Once
+	done chan struct{}
+	exit chan struct{}
+}
+
+// NewBufferedHandler returns a BufferedHandler which passes events to h in
+// batches of size events, or every interval. Zero or negative values select
+// DefaultBufferSize and DefaultBufferInterval. The handler must be closed to
+// release the resources it holds.
+func NewBufferedHandler(h Handler, size int, interval time.Duration) *BufferedHandler {
+	if size <= 0 {
+		size = DefaultBufferSize
+	}
+
+	if interval <= 0 {
+		interval = DefaultBufferInterval
+	}
+
+	b := &BufferedHandler{
+		handler: h,
+		size:    size,
+		events:  make([]*Event, 0, size),
+		done:    make(chan struct{}),
+		exit:    make(chan struct{}),
+	}
+
+	tick, stop := newTicker(interval)
+	go b.run(tick, stop)
+	return b
+}
+
+// HandleEvent satisfies the Handler interface.
+//
+// The event is cloned before being buffered. When the buffer is full, the
+// batch is passed to the handler by the calling goroutine. Events received
+// after Close are dropped.
+func (b *BufferedHandler) HandleEvent(e *Event) {
+	b.handle(nil, e)
+}
+
+// HandleEventContext satisfies the ContextHandler interface.
+//
+// The context is passed to the handler with the diagnostic events, and with
+// the batch when the buffer is full and the calling goroutine passes it. The
+// handler isn't flushed if passing the batch returned an error.
+func (b *BufferedHandler) HandleEventContext(ctx context.Context, e *Event) error {
+	return b.handle(ctx, e)
+}
+
+// handle buffers e, the events are passed to the handler with ctx unless it is
+// nil.
+func (b *BufferedHandler) handle(ctx context.Context, e *Event) error {
+	if IsDiagnostic(e) {
+		return b.pass(ctx, e)
+	}
+
+	e = e.Clone()
+	b.mutex.Lock()
+
+	if b.closed {
+		b.mutex.Unlock()
+		atomic.AddInt64(&b.dropped, 1)
+		return nil
+	}
+
+	b.events = append(b.events, e)
+	full := len(b.events) >= b.size
+	b.mutex.Unlock()
+
+	if full {
+		return b.flush(ctx, false)
+	}
+
+	return nil
+}
+
+func (b *BufferedHandler) pass(ctx context.Context, e *Event) error {
+	if ctx == nil {
+		b.handler.HandleEvent(e)
+		return nil
+	}
+	return HandleEventContext(ctx, b.handler, e)
+}
+
+// HandleEventSync satisfies the SyncHandler interface.
+//
+// The event bypasses the buffer and is passed to the handler from the calling
+// goroutine, possibly before events that were buffered earlier.
+func (b *BufferedHandler) HandleEventSync(ctx context.Context, e *Event) error {
+	b.mutex.Lock()
+	closed := b.closed
+	b.mutex.Unlock()
+
+	if closed {
+		atomic.AddInt64(&b.dropped, 1)
+		return &SyncError{Stage: "*events.BufferedHandler", Err: ErrHandlerClosed}
+	}
+
+	return syncStage(b, HandleEventSync(ctx, b.handler, e))
+}
+
+// Unwrap returns the handler that events are passed to.
+func (b *BufferedHandler) Unwrap() Handler {
+	return b.handler
+}
+
+// Dropped satisfies the DropCounter interface, it returns the number of events
+// received after the handler was closed.
+func (b *BufferedHandler) Dropped() int64 {
+	return atomic.LoadInt64(&b.dropped)
+}
+
+// EventHealth satisfies the HealthReporter interface, the queue of the report
+// is the buffer. The handler is unhealthy after it was closed.
+func (b *BufferedHandler) EventHealth() Health {
+	b.mutex.Lock()
+	pending := len(b.events)
+	closed := b.closed
+	b.mutex.Unlock()
+
+	h := Health{
+		QueueDepth: pending,
+		QueueSize:  b.size,
+		Dropped:    b.Dropped(),
+	}
+
+	if closed {
+		h.Status, h.Message = Unhealthy, "the handler is closed"
+	}
+
+	return h
+}
+
+// Flush passes the buffered events to the handler, then flushes it.
+func (b *BufferedHandler) Flush() error {
+	return b.flush(nil, true)
+}
+
+// Close stops the handler, passes the buffered events to the handler it wraps,
+// and closes it.
+func (b *BufferedHandler) Close() error {
+	b.once.Do(func() { close(b.done) })
+	<-b.exit
+
+	b.mutex.Lock()
+	closed := b.closed
+	b.closed = true
+	b.mutex.Unlock()
+
+	if closed {
+		return nil
+	}
+
+	err := b.flush(nil, true)
+
+	if cerr := closeHandler(b.handler); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+func (b *BufferedHandler) run(tick <-chan time.Time, stop func()) {
+	defer close(b.exit)
+	defer stop()
+
+	for {
+		select {
+		case <-b.done:
+			return
+		case <-tick:
+			b.flush(nil, false)
+		}
+	}
+}
+
+// flush passes the buffered events to the handler and flushes it, the handler
+// is not flushed when no events were buffered unless force is true. When ctx is
+// not nil the events are passed with it, and the handler isn't flushed if one
+// of them returned an error.
+func (b *BufferedHandler) flush(ctx context.Context, force bool) (err error) {
+	b.flushing.Lock()
+	defer b.flushing.Unlock()
+
+	b.mutex.Lock()
+	batch := b.events
+	if len(batch) != 0 {
+		b.events = make([]*Event, 0, b.size)
+	}
+	b.mutex.Unlock()
+
+	if len(batch) == 0 && !force {
+		return nil
+	}
+
+	for _, e := range batch {
+		if perr := b.pass(ctx, e); err == nil {
+			err = perr
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return flushHandler(b.handler)
+}
@@ -0,0 +1,148 @@
+package events
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func (s *shardSink) counts() (events int, flushed int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.events), s.flushed
+}
+
+func TestBufferedHandlerSize(t *testing.T) {
+	_, _, restore := fakeTicker()
+	defer restore()
+
+	s := &shardSink{}
+	b := NewBufferedHandler(s, 3, time.Hour)
+	defer b.Close()
+
+	e := &Event{Message: "A"}
+	b.HandleEvent(e)
+	b.HandleEvent(&Event{Message: "B"})
+	e.Message = "modified"
+
+	if n, f := s.counts(); n != 0 || f != 0 {
+		t.Errorf("events were passed before the buffer was full: events=%d flushed=%d", n, f)
+	}
+
+	if h := b.EventHealth(); h != (Health{QueueDepth: 2, QueueSize: 3}) {
+		t.Errorf("bad health: %+v", h)
+	}
+
+	b.HandleEvent(&Event{Message: "C"})
+
+	if n, f := s.counts(); n != 3 || f != 1 {
+		t.Fatalf("the batch was not passed: events=%d flushed=%d", n, f)
+	}
+
+	if m := s.events[0].Message; m != "A" {
+		t.Error("the buffered event was not copied:", m)
+	}
+}
+
+func TestBufferedHandlerInterval(t *testing.T) {
+	tick, stopped, restore := fakeTicker()
+	defer restore()
+
+	s := &shardSink{}
+	b := NewBufferedHandler(s, 10, time.Second)
+
+	// Ticks without pending events don't flush the handler.
+	tick <- time.Now()
+	b.HandleEvent(&Event{Message: "A"})
+	tick <- time.Now()
+
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(time.Millisecond) {
+		n, f := s.counts()
+
+		if n == 1 && f == 1 {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("the batch was not passed on tick: events=%d flushed=%d", n, f)
+		}
+	}
+
+	b.Close()
+
+	if atomic.LoadInt32(stopped) != 1 {
+		t.Error("the ticker was not stopped")
+	}
+}
+
+func TestBufferedHandlerClose(t *testing.T) {
+	_, _, restore := fakeTicker()
+	defer restore()
+
+	s := &shardSink{err: errors.New("oops")}
+	b := NewBufferedHandler(s, 10, time.Second)
+
+	b.HandleEvent(&Event{Message: "A"})
+	b.HandleEvent(Diagnostic("D"))
+
+	if n, _ := s.counts(); n != 1 || s.events[0].Message != "D" {
+		t.Error("the diagnostic event was buffered")
+	}
+
+	if err := b.Close(); err != s.err {
+		t.Error("bad error:", err)
+	}
+
+	if err := b.Close(); err != nil {
+		t.Error("bad error closing the handler twice:", err)
+	}
+
+	b.HandleEvent(&Event{Message: "B"})
+
+	if n, f := s.counts(); n != 2 || f != 1 || s.closed != 1 {
+		t.Errorf("bad state after close: events=%d flushed=%d closed=%d", n, f, s.closed)
+	}
+
+	if n := countDropped(b); n != 1 {
+		t.Error("bad number of dropped events:", n)
+	}
+
+	if h := b.EventHealth(); h.Status != Unhealthy || h.Dropped != 1 || h.QueueDepth != 0 {
+		t.Errorf("bad health after close: %+v", h)
+	}
+}
+
+func TestBufferedHandlerOrder(t *testing.T) {
+	s := &shardSink{}
+	b := NewBufferedHandler(s, 7, time.Millisecond)
+	wg := sync.WaitGroup{}
+
+	for i := 0; i != 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j != 500; j++ {
+				b.HandleEvent(&Event{Message: strconv.Itoa(i), Args: Args{{"seq", j}}})
+			}
+		}(i)
+	}
+
+	wg.Wait()
+	b.Close()
+
+	next := map[string]int{}
+
+	for _, e := range s.events {
+		if seq := e.Args[0].Value.(int); seq != next[e.Message] {
+			t.Fatalf("event %d of producer %s passed after event %d", seq, e.Message, next[e.Message]-1)
+		}
+		next[e.Message]++
+	}
+
+	if len(s.events) != 2000 {
+		t.Error("bad number of events:", len(s.events))
+	}
+}
@@ -0,0 +1,143 @@
+package events
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"strconv"
+)
+
+// BytesFormat values define how byte slices are rendered by event handlers.
+type BytesFormat int
+
+const (
+	// BytesDefault renders byte slices in the default format of the handler,
+	// which is BytesHex unless the handler was configured otherwise.
+	BytesDefault BytesFormat = iota
+
+	// BytesHex renders byte slices as lowercase hexadecimal strings, like
+	// "deadbeef".
+	BytesHex
+
+	// BytesBase64 renders byte slices as standard base64 strings, like
+	// "3q2+7w==".
+	BytesBase64
+
+	// BytesPreview renders the first bytes of byte slices in hexadecimal and
+	// the total size, like "0xdeadbeef…16 bytes".
+	BytesPreview
+)
+
+// bytesPreviewSize is the number of bytes shown by the BytesPreview format.
+const bytesPreviewSize = 4
+
+// BytesValue is an argument value carrying a byte slice and the format it is
+// preferably rendered in. Values are usually built with the Bytes function,
+// for example:
+//
+//	events.Log("checksum: %{sum}s", events.Bytes(sum).Base64())
+type BytesValue struct {
+	Data   []byte
+	Format BytesFormat
+}
+
+// Bytes returns a BytesValue carrying b, rendered in the default format of
+// the handlers.
+func Bytes(b []byte) BytesValue {
+	return BytesValue{Data: b}
+}
+
+// Hex returns a copy of v rendered as a hexadecimal string.
+func (v BytesValue) Hex() BytesValue {
+	v.Format = BytesHex
+	return v
+}
+
+// Base64 returns a copy of v rendered as a base64 string.
+func (v BytesValue) Base64() BytesValue {
+	v.Format = BytesBase64
+	return v
+}
+
+// Preview returns a copy of v rendered as a truncated preview.
+func (v BytesValue) Preview() BytesValue {
+	v.Format = BytesPreview
+	return v
+}
+
+// FormatEventValue satisfies the Formatter interface.
+func (v BytesValue) FormatEventValue() string {
+	return string(AppendBytes(nil, v.Data, v.Format))
+}
+
+// String satisfies the fmt.Stringer interface.
+func (v BytesValue) String() string {
+	return v.FormatEventValue()
+}
+
+// AppendBytes appends b rendered in the given format to dst and returns the
+// extended buffer. BytesDefault renders b in hexadecimal.
+func AppendBytes(dst []byte, b []byte, format BytesFormat) []byte {
+	switch format {
+	case BytesBase64:
+		n := len(dst)
+		dst = grow(dst, base64.StdEncoding.EncodedLen(len(b)))
+		base64.StdEncoding.Encode(dst[n:], b)
+		return dst
+
+	case BytesPreview:
+		p := b
+		if len(p) > bytesPreviewSize {
+			p = p[:bytesPreviewSize]
+		}
+		dst = append(dst, '0', 'x')
+		dst = appendHex(dst, p)
+		if len(p) != len(b) {
+			dst = append(dst, "…"...)
+			dst = strconv.AppendInt(dst, int64(len(b)), 10)
+			dst = append(dst, " bytes"...)
+		}
+		return dst
+
+	default:
+		return appendHex(dst, b)
+	}
+}
+
+// AppendBytesValue appends v to dst if it is a byte slice or a BytesValue and
+// returns the extended buffer and true, otherwise it returns dst unchanged and
+// false. Byte slices and values of BytesValue without a preferred format are
+// rendered in the given format.
+//
+// The function is intended to be used by the implementations of event
+// handlers, which usually have an option to configure the format of byte
+// slices.
+func AppendBytesValue(dst []byte, v interface{}, format BytesFormat) ([]byte, bool) {
+	switch x := v.(type) {
+	case []byte:
+		return AppendBytes(dst, x, format), true
+	case BytesValue:
+		if x.Format != BytesDefault {
+			format = x.Format
+		}
+		return AppendBytes(dst, x.Data, format), true
+	default:
+		return dst, false
+	}
+}
+
+func appendHex(dst []byte, b []byte) []byte {
+	n := len(dst)
+	dst = grow(dst, hex.EncodedLen(len(b)))
+	hex.Encode(dst[n:], b)
+	return dst
+}
+
+// grow extends the length of b by n bytes.
+func grow(b []byte, n int) []byte {
+	if cap(b)-len(b) < n {
+		g := make([]byte, len(b), 2*cap(b)+n)
+		copy(g, b)
+		b = g
+	}
+	return b[:len(b)+n]
+}
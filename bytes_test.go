@@ -0,0 +1,62 @@
+package events
+
+import "testing"
+
+func TestAppendBytes(t *testing.T) {
+	b := []byte{0xde, 0xad, 0xbe, 0xef, 0x00, 0x01}
+
+	tests := []struct {
+		data   []byte
+		format BytesFormat
+		out    string
+	}{
+		{b, BytesDefault, "deadbeef0001"},
+		{b, BytesHex, "deadbeef0001"},
+		{b, BytesBase64, "3q2+7wAB"},
+		{b, BytesPreview, "0xdeadbeef…6 bytes"},
+		{b[:4], BytesPreview, "0xdeadbeef"},
+		{nil, BytesHex, ""},
+		{nil, BytesBase64, ""},
+		{nil, BytesPreview, "0x"},
+	}
+
+	for _, test := range tests {
+		if s := string(AppendBytes([]byte("prefix:"), test.data, test.format)); s != "prefix:"+test.out {
+			t.Errorf("%x (%d): %q != %q", test.data, test.format, s, test.out)
+		}
+	}
+}
+
+func TestAppendBytesValue(t *testing.T) {
+	b := []byte{0xde, 0xad, 0xbe, 0xef}
+
+	tests := []struct {
+		value  interface{}
+		format BytesFormat
+		out    string
+	}{
+		{b, BytesDefault, "deadbeef"},
+		{b, BytesBase64, "3q2+7w=="},
+		{Bytes(b), BytesBase64, "3q2+7w=="},
+		{Bytes(b).Hex(), BytesBase64, "deadbeef"},
+		{Bytes(b).Preview(), BytesDefault, "0xdeadbeef"},
+	}
+
+	for _, test := range tests {
+		if s, ok := AppendBytesValue(nil, test.value, test.format); !ok || string(s) != test.out {
+			t.Errorf("%#v (%d): %q != %q", test.value, test.format, s, test.out)
+		}
+	}
+
+	if _, ok := AppendBytesValue(nil, "deadbeef", BytesHex); ok {
+		t.Error("strings must not be rendered as byte slices")
+	}
+
+	if s, _ := FormatValue(b); s != "deadbeef" {
+		t.Error("bad formatted value:", s)
+	}
+
+	if s := Bytes(b).Base64().String(); s != "3q2+7w==" {
+		t.Error("bad string:", s)
+	}
+}
@@ -0,0 +1,311 @@
+package events
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"unicode"
+)
+
+// DefaultCanonicalCacheSize is the default number of names whose canonical
+// name is remembered by a Canonicalizer.
+const DefaultCanonicalCacheSize = 4096
+
+// CollisionPolicy values define what a Canonicalizer does when an argument is
+// renamed to the name of another argument of the same event.
+type CollisionPolicy int
+
+const (
+	// KeepExisting drops the renamed argument, keeping the value of the
+	// argument which already had the canonical name.
+	KeepExisting CollisionPolicy = iota
+
+	// Overwrite drops the argument which already had the canonical name,
+	// keeping the value of the renamed argument. When multiple arguments are
+	// renamed to the same name, the value of the last one is kept.
+	Overwrite
+
+	// KeepBoth keeps both arguments, the renamed argument gets the canonical
+	// name followed by a numeric suffix ("request_id_2" for example).
+	KeepBoth
+)
+
+// CanonicalRule maps argument names matching a pattern to a canonical name.
+type CanonicalRule struct {
+	// Pattern is the exact name of the arguments that the rule applies to,
+	// or a glob pattern where '*' matches any sequence of characters and '?'
+	// matches a single character.
+	Pattern string
+
+	// Name is the canonical name given to the arguments matching the pattern.
+	Name string
+
+	// Convert is called with the names matching the pattern to compute their
+	// canonical name when Name is empty.
+	Convert func(name string) string
+}
+
+// Canonicalizer renames event arguments according to a list of rules, so the
+// same concept is reported under the same name by all parts of a program.
+//
+// Rules are tried in order and the first one matching a name is applied. The
+// rules are applied again to the new name until it doesn't change, so renames
+// can be chained (with "reqId" => "requestId" and "requestId" => "request_id",
+// "reqId" becomes "request_id").
+//
+// The fields must not be modified after the canonicalizer was first used, it is
+// then safe to use concurrently from multiple goroutines.
+type Canonicalizer struct {
+	// Rules is the list of rules applied to argument names.
+	Rules []CanonicalRule
+
+	// Collision defines what happens when an argument gets the name of
+	// another argument of the same event.
+	Collision CollisionPolicy
+
+	// CacheSize is the maximum number of names whose canonical name is
+	// remembered, it defaults to DefaultCanonicalCacheSize when zero. The
+	// canonical names of the names seen after the cache was filled are
+	// computed on each use, so programs producing arguments with unbounded
+	// names don't grow the cache forever.
+	CacheSize int
+
+	cache  sync.Map // name => canonical name
+	cached int64    // number of entries in cache, accessed atomically
+}
+
+// SnakeCaseRules returns a rule set which converts camelCase argument names to
+// snake_case.
+func SnakeCaseRules() []CanonicalRule {
+	return []CanonicalRule{{Pattern: "*", Convert: SnakeCase}}
+}
+
+// SnakeCase converts a camelCase name to snake_case, for example "requestId"
+// becomes "request_id" and "HTTPServer" becomes "http_server".
+func SnakeCase(name string) string {
+	runes := []rune(name)
+	b := make([]rune, 0, len(runes)+4)
+
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i != 0 && runes[i-1] != '_' {
+				prev := runes[i-1]
+				next := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+
+				if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && next) {
+					b = append(b, '_')
+				}
+			}
+			r = unicode.ToLower(r)
+		}
+		b = append(b, r)
+	}
+
+	return string(b)
+}
+
+// Name returns the canonical name of an argument named name.
+func (c *Canonicalizer) Name(name string) string {
+	if v, ok := c.cache.Load(name); ok {
+		return v.(string)
+	}
+
+	canonical := name
+
+	// Bound the number of renames in case the rules have cycles.
+	for i := 0; i <= len(c.Rules); i++ {
+		next := c.rename(canonical)
+
+		if next == canonical {
+			break
+		}
+
+		canonical = next
+	}
+
+	size := int64(c.CacheSize)
+	if size <= 0 {
+		size = DefaultCanonicalCacheSize
+	}
+
+	if atomic.AddInt64(&c.cached, 1) <= size {
+		if _, loaded := c.cache.LoadOrStore(name, canonical); !loaded {
+			return canonical
+		}
+	}
+
+	atomic.AddInt64(&c.cached, -1)
+	return canonical
+}
+
+func (c *Canonicalizer) rename(name string) string {
+	for _, r := range c.Rules {
+		if r.Pattern == name || (strings.ContainsAny(r.Pattern, "*?") && globMatch(r.Pattern, name)) {
+			if len(r.Name) != 0 {
+				return r.Name
+			}
+			if r.Convert != nil {
+				return r.Convert(name)
+			}
+			return name
+		}
+	}
+	return name
+}
+
+// Canonicalize returns a copy of args where the arguments are renamed to their
+// canonical names, with collisions resolved according to the canonicalizer's
+// policy. The function returns args itself if no arguments were renamed.
+func (c *Canonicalizer) Canonicalize(args Args) Args {
+	list, _ := c.canonicalize(args)
+	return list
+}
+
+func (c *Canonicalizer) canonicalize(args Args) (Args, bool) {
+	var names []string
+
+	for i, a := range args {
+		if name := c.Name(a.Name); name != a.Name {
+			if names == nil {
+				names = make([]string, len(args))
+			}
+			names[i] = name
+		}
+	}
+
+	if names == nil {
+		return args, false
+	}
+
+	// Names of the arguments that aren't renamed, and canonical names of the
+	// arguments that are.
+	native := make(map[string]bool, len(args))
+	renamed := make(map[string]bool, len(args))
+
+	for i, a := range args {
+		if len(names[i]) == 0 {
+			native[a.Name] = true
+		} else {
+			renamed[names[i]] = true
+		}
+	}
+
+	list := make(Args, 0, len(args))
+	taken := make(map[string]int, len(args)) // position of renamed arguments
+
+	for i, a := range args {
+		name := names[i]
+
+		if len(name) == 0 {
+			if c.Collision != Overwrite || !renamed[a.Name] {
+				list = append(list, a)
+			}
+			continue
+		}
+
+		j, collision := taken[name]
+
+		if collision || native[name] {
+			switch c.Collision {
+			case KeepExisting:
+				continue
+
+			case Overwrite:
+				if collision {
+					list[j].Value = a.Value
+					continue
+				}
+
+			case KeepBoth:
+				name = suffixName(name, args, list)
+			}
+		}
+
+		taken[name] = len(list)
+		list = append(list, Arg{name, a.Value})
+	}
+
+	return list, true
+}
+
+// suffixName returns name followed by the first numeric suffix that makes it
+// different from the names of the arguments in args and list.
+func suffixName(name string, args Args, list Args) string {
+	for n := 2; ; n++ {
+		s := name + "_" + strconv.Itoa(n)
+
+		if _, ok := args.Get(s); !ok {
+			if _, ok := list.Get(s); !ok {
+				return s
+			}
+		}
+	}
+}
+
+// MutateEvent satisfies the Mutator interface, it renames the arguments of e
+// in place and returns it.
+func (c *Canonicalizer) MutateEvent(e *Event) *Event {
+	e.Args = c.Canonicalize(e.Args)
+	return e
+}
+
+// CanonicalizeHandler returns a handler which renames the arguments of events
+// with c before passing them to h. Events that have arguments to rename are
+// copied, h never sees the original events modified.
+//
+// Diagnostic events are passed to h unchanged.
+func CanonicalizeHandler(h Handler, c *Canonicalizer) Handler {
+	return &canonicalizeHandler{
+		handler: h,
+		canon:   c,
+	}
+}
+
+type canonicalizeHandler struct {
+	handler Handler
+	canon   *Canonicalizer
+}
+
+func (c *canonicalizeHandler) HandleEvent(e *Event) {
+	c.handle(e, forwardEvent)
+}
+
+func (c *canonicalizeHandler) HandleEventSync(ctx context.Context, e *Event) error {
+	return handleSync(ctx, c, func(forward func(Handler, *Event)) { c.handle(e, forward) })
+}
+
+func (c *canonicalizeHandler) HandleEventContext(ctx context.Context, e *Event) error {
+	return handleContext(ctx, func(forward func(Handler, *Event)) { c.handle(e, forward) })
+}
+
+func (c *canonicalizeHandler) handle(e *Event, forward func(Handler, *Event)) {
+	if IsDiagnostic(e) {
+		forward(c.handler, e)
+		return
+	}
+
+	args, changed := c.canon.canonicalize(e.Args)
+
+	if !changed {
+		forward(c.handler, e)
+		return
+	}
+
+	renamed := *e
+	renamed.Args = args
+	forward(c.handler, &renamed)
+}
+
+func (c *canonicalizeHandler) Unwrap() Handler {
+	return c.handler
+}
+
+func (c *canonicalizeHandler) Flush() error {
+	return flushHandler(c.handler)
+}
+
+func (c *canonicalizeHandler) Close() error {
+	return closeHandler(c.handler)
+}
@@ -0,0 +1,175 @@
+package events
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestSnakeCase(t *testing.T) {
+	tests := []struct {
+		in  string
+		out string
+	}{
+		{"", ""},
+		{"id", "id"},
+		{"requestId", "request_id"},
+		{"RequestID", "request_id"},
+		{"HTTPServer", "http_server"},
+		{"userID2", "user_id2"},
+		{"ipv4Address", "ipv4_address"},
+		{"request_id", "request_id"},
+		{"already_Snake", "already_snake"},
+		{"req.id", "req.id"},
+	}
+
+	for _, test := range tests {
+		if s := SnakeCase(test.in); s != test.out {
+			t.Errorf("SnakeCase(%q): %q != %q", test.in, s, test.out)
+		}
+	}
+}
+
+func TestCanonicalizerName(t *testing.T) {
+	c := &Canonicalizer{
+		Rules: append([]CanonicalRule{
+			{Pattern: "req.id", Name: "requestId"},
+			{Pattern: "rid", Name: "req.id"},
+			{Pattern: "x-*", Convert: func(s string) string { return s[2:] }},
+			{Pattern: "a", Name: "b"},
+			{Pattern: "b", Name: "a"},
+		}, SnakeCaseRules()...),
+	}
+
+	tests := []struct {
+		in  string
+		out string
+	}{
+		{"req.id", "request_id"},
+		{"rid", "request_id"}, // chained renames
+		{"requestId", "request_id"},
+		{"request_id", "request_id"},
+		{"x-userName", "user_name"},
+		{"other", "other"},
+	}
+
+	for _, test := range tests {
+		for i := 0; i != 2; i++ { // second time from the cache
+			if s := c.Name(test.in); s != test.out {
+				t.Errorf("Name(%q): %q != %q", test.in, s, test.out)
+			}
+		}
+	}
+
+	// Cycles must terminate.
+	c.Name("a")
+}
+
+func TestCanonicalizerCacheSize(t *testing.T) {
+	c := &Canonicalizer{Rules: SnakeCaseRules(), CacheSize: 10}
+
+	for i := 0; i != 100; i++ {
+		if s := c.Name("argNumber" + strconv.Itoa(i)); s != "arg_number"+strconv.Itoa(i) {
+			t.Fatal("bad canonical name:", s)
+		}
+	}
+
+	n := 0
+	c.cache.Range(func(interface{}, interface{}) bool { n++; return true })
+
+	if n != 10 || c.cached != 10 {
+		t.Errorf("the cache was not bounded: %d entries (%d counted)", n, c.cached)
+	}
+}
+
+func TestCanonicalizerCollisions(t *testing.T) {
+	rules := []CanonicalRule{
+		{Pattern: "req.id", Name: "request_id"},
+		{Pattern: "requestId", Name: "request_id"},
+	}
+
+	tests := []struct {
+		policy CollisionPolicy
+		args   Args
+		want   Args
+	}{
+		{
+			policy: KeepExisting,
+			args:   Args{{"requestId", 1}, {"request_id", 2}, {"req.id", 3}},
+			want:   Args{{"request_id", 2}},
+		},
+		{
+			policy: KeepExisting,
+			args:   Args{{"requestId", 1}, {"req.id", 3}},
+			want:   Args{{"request_id", 1}},
+		},
+		{
+			policy: Overwrite,
+			args:   Args{{"request_id", 2}, {"requestId", 1}, {"other", 0}},
+			want:   Args{{"request_id", 1}, {"other", 0}},
+		},
+		{
+			policy: Overwrite,
+			args:   Args{{"requestId", 1}, {"req.id", 3}},
+			want:   Args{{"request_id", 3}},
+		},
+		{
+			policy: KeepBoth,
+			args:   Args{{"requestId", 1}, {"request_id", 2}, {"req.id", 3}},
+			want:   Args{{"request_id_2", 1}, {"request_id", 2}, {"request_id_3", 3}},
+		},
+		{
+			policy: KeepBoth,
+			args:   Args{{"requestId", 1}, {"request_id_2", 2}, {"req.id", 3}},
+			want:   Args{{"request_id", 1}, {"request_id_2", 2}, {"request_id_3", 3}},
+		},
+	}
+
+	for _, test := range tests {
+		c := &Canonicalizer{Rules: rules, Collision: test.policy}
+		args := c.Canonicalize(test.args)
+
+		if diff := Diff(&Event{Args: test.want}, &Event{Args: args}); len(diff) != 0 {
+			t.Errorf("policy %d: %v\n%v", test.policy, args, diff)
+		}
+
+		// Running the canonicalizer on its own output changes nothing.
+		if again := c.Canonicalize(args); &again[0] != &args[0] {
+			t.Errorf("policy %d: the canonicalizer is not idempotent: %v", test.policy, again)
+		}
+	}
+}
+
+func TestCanonicalizeHandler(t *testing.T) {
+	r := &eventRecorder{}
+	h := CanonicalizeHandler(r, &Canonicalizer{Rules: SnakeCaseRules()})
+
+	e := &Event{Message: "hello", Args: Args{{"requestId", 1}, {"userName", "luke"}}}
+	h.HandleEvent(e)
+
+	want := &Event{Message: "hello", Args: Args{{"request_id", 1}, {"user_name", "luke"}}}
+
+	if diff := Diff(want, r.wait(t)); len(diff) != 0 {
+		t.Error(diff)
+	}
+
+	if e.Args[0].Name != "requestId" {
+		t.Error("the original event was modified:", e.Args)
+	}
+
+	h.HandleEvent(&Event{Message: "world"})
+
+	if e := r.wait(t); e.Message != "world" || len(e.Args) != 0 {
+		t.Error("bad event:", e)
+	}
+
+	d := Diagnostic("handler panicked", Arg{"panicValue", "boom"})
+	h.HandleEvent(d)
+
+	if e := r.wait(t); e.Args[0].Name != "panicValue" {
+		t.Error("the arguments of the diagnostic event were renamed:", e.Args)
+	}
+
+	if u, ok := h.(interface{ Unwrap() Handler }); !ok || u.Unwrap() != r {
+		t.Error("the handler doesn't unwrap to the handler it wraps")
+	}
+}
@@ -0,0 +1,267 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrCaptureActive is returned by Capture.Start when a capture is already in
+// progress.
+var ErrCaptureActive = errors.New("events: a capture is already active")
+
+// CaptureStats carries the counts of events written by a capture.
+type CaptureStats struct {
+	Start  time.Time     // time at which the capture started
+	Length time.Duration // time during which the capture was active
+	Events int64         // number of events written
+	Debug  int64         // number of debug events written
+	Errors int64         // number of events that failed to be written
+}
+
+// Capture is a handler which passes events through to another handler, and can
+// be asked to also record every event it sees to a writer for a bounded period
+// of time, for example to debug an incident in a running program.
+//
+// While a capture is active debug events are produced by loggers even if they
+// are disabled program-wide (see SetDebugEnabled). Loggers write them directly
+// to the active captures instead of passing them to their handler, so no other
+// handler receives them. Other events are only seen by the capture if they
+// reach it, so it must be installed before the handlers that filter events.
+//
+// It is safe to use the handler concurrently from multiple goroutines.
+type Capture struct {
+	// Now returns the current time, it defaults to time.Now and may be set to
+	// a different function before the handler is used (in tests for example).
+	Now func() time.Time
+
+	// OnComplete is called with the stats of each capture when it stops, it
+	// may be set before captures are started.
+	OnComplete func(CaptureStats)
+
+	active  int32 // non-zero when session isn't nil, accessed atomically
+	handler Handler
+	mutex   sync.Mutex
+	session *captureSession
+}
+
+type captureSession struct {
+	output   io.Writer
+	deadline time.Time
+	timer    *time.Timer
+	buffer   []byte
+	stats    CaptureStats
+}
+
+// NewCaptureController returns a Capture which passes events to h.
+func NewCaptureController(h Handler) *Capture {
+	return &Capture{
+		Now:     time.Now,
+		handler: h,
+	}
+}
+
+// Start begins recording events to w with JSONEncoder, the capture stops
+// automatically after d, or when Stop is called. The writer is flushed when
+// the capture stops if it has a Flush method, but it isn't closed.
+//
+// Only one capture may be active at a time, the method returns
+// ErrCaptureActive if a capture is already in progress.
+func (c *Capture) Start(w io.Writer, d time.Duration) error {
+	if d <= 0 {
+		return errors.New("events: the capture duration must be positive")
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.session != nil {
+		return ErrCaptureActive
+	}
+
+	now := c.Now()
+	s := &captureSession{
+		output:   w,
+		deadline: now.Add(d),
+		stats:    CaptureStats{Start: now},
+	}
+	s.timer = time.AfterFunc(d, func() { c.stop(s) })
+
+	c.session = s
+	atomic.StoreInt32(&c.active, 1)
+	debugCaptures.add(c)
+	return nil
+}
+
+// Stop stops the active capture and returns its stats, the returned boolean is
+// false if no capture was active.
+func (c *Capture) Stop() (stats CaptureStats, ok bool) {
+	c.mutex.Lock()
+	s := c.session
+	c.mutex.Unlock()
+
+	if s == nil {
+		return
+	}
+
+	return c.stop(s)
+}
+
+// Active returns true if a capture is in progress.
+func (c *Capture) Active() bool {
+	return atomic.LoadInt32(&c.active) != 0
+}
+
+// HandleEvent satisfies the Handler interface.
+func (c *Capture) HandleEvent(e *Event) {
+	c.handle(e, forwardEvent)
+}
+
+// HandleEventSync satisfies the SyncHandler interface.
+func (c *Capture) HandleEventSync(ctx context.Context, e *Event) error {
+	return handleSync(ctx, c, func(forward func(Handler, *Event)) { c.handle(e, forward) })
+}
+
+// HandleEventContext satisfies the ContextHandler interface.
+func (c *Capture) HandleEventContext(ctx context.Context, e *Event) error {
+	return handleContext(ctx, func(forward func(Handler, *Event)) { c.handle(e, forward) })
+}
+
+func (c *Capture) handle(e *Event, forward func(Handler, *Event)) {
+	if atomic.LoadInt32(&c.active) != 0 {
+		c.capture(e)
+	}
+
+	forward(c.handler, e)
+}
+
+// Unwrap returns the handler that events are passed to.
+func (c *Capture) Unwrap() Handler {
+	return c.handler
+}
+
+// Flush flushes the handler that events are passed to.
+func (c *Capture) Flush() error {
+	return flushHandler(c.handler)
+}
+
+// Close stops the active capture and closes the handler that events are passed
+// to.
+func (c *Capture) Close() error {
+	c.Stop()
+	return closeHandler(c.handler)
+}
+
+func (c *Capture) capture(e *Event) {
+	c.mutex.Lock()
+	s := c.session
+
+	if s == nil {
+		c.mutex.Unlock()
+		return
+	}
+
+	if !c.Now().Before(s.deadline) {
+		c.mutex.Unlock()
+		c.stop(s)
+		return
+	}
+
+	var err error
+
+	if s.buffer, err = (JSONEncoder{}).EncodeEvent(s.buffer[:0], e); err == nil {
+		_, err = s.output.Write(s.buffer)
+	}
+
+	switch {
+	case err != nil:
+		s.stats.Errors++
+	case e.Debug:
+		s.stats.Debug++
+		fallthrough
+	default:
+		s.stats.Events++
+	}
+
+	c.mutex.Unlock()
+}
+
+func (c *Capture) stop(s *captureSession) (stats CaptureStats, ok bool) {
+	c.mutex.Lock()
+
+	if c.session != s {
+		c.mutex.Unlock()
+		return
+	}
+
+	s.timer.Stop()
+	c.session = nil
+	atomic.StoreInt32(&c.active, 0)
+	debugCaptures.remove(c)
+
+	if f, ok := s.output.(interface {
+		Flush() error
+	}); ok {
+		f.Flush()
+	}
+
+	stats = s.stats
+	stats.Length = c.Now().Sub(stats.Start)
+	c.mutex.Unlock()
+
+	if c.OnComplete != nil {
+		c.OnComplete(stats)
+	}
+
+	return stats, true
+}
+
+// debugCaptures is the set of active captures, loggers produce debug events
+// for them while it isn't empty.
+var debugCaptures captureSet
+
+func debugCaptureActive() bool {
+	return atomic.LoadInt32(&debugCaptures.count) != 0
+}
+
+// captureSet is a handler which writes the events it receives to a set of
+// captures. The list is copied when it changes, so it can be read without
+// holding the mutex.
+type captureSet struct {
+	count int32 // length of list, accessed atomically
+	mutex sync.Mutex
+	list  atomic.Value // []*Capture
+}
+
+func (s *captureSet) add(c *Capture) {
+	s.mutex.Lock()
+	list, _ := s.list.Load().([]*Capture)
+	list = append(list[:len(list):len(list)], c)
+	s.list.Store(list)
+	atomic.StoreInt32(&s.count, int32(len(list)))
+	s.mutex.Unlock()
+}
+
+func (s *captureSet) remove(c *Capture) {
+	s.mutex.Lock()
+	list, _ := s.list.Load().([]*Capture)
+	next := make([]*Capture, 0, len(list))
+	for _, x := range list {
+		if x != c {
+			next = append(next, x)
+		}
+	}
+	s.list.Store(next)
+	atomic.StoreInt32(&s.count, int32(len(next)))
+	s.mutex.Unlock()
+}
+
+func (s *captureSet) HandleEvent(e *Event) {
+	list, _ := s.list.Load().([]*Capture)
+	for _, c := range list {
+		c.capture(e)
+	}
+}
@@ -0,0 +1,151 @@
+package events
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCapture(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)}
+	r := &eventRecorder{}
+	c := NewCaptureController(r)
+	c.Now = clock.Now
+
+	done := make(chan CaptureStats, 1)
+	c.OnComplete = func(stats CaptureStats) { done <- stats }
+
+	l := NewLogger(c)
+	l.Log("before")
+
+	b := &bytes.Buffer{}
+	w := bufio.NewWriter(b)
+
+	if err := c.Start(w, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Start(&bytes.Buffer{}, time.Minute); err != ErrCaptureActive {
+		t.Error("a second capture was started:", err)
+	}
+
+	SetDebugEnabled(false)
+	defer SetDebugEnabled(true)
+
+	l.Log("A")
+	l.Debug("B")
+	clock.add(time.Minute)
+	l.Log("after")
+
+	var stats CaptureStats
+
+	select {
+	case stats = <-done:
+	default:
+		t.Fatal("the capture did not stop")
+	}
+
+	if c.Active() {
+		t.Error("the capture is still active")
+	}
+
+	if stats.Events != 2 || stats.Debug != 1 || stats.Errors != 0 || stats.Length != time.Minute {
+		t.Errorf("bad capture stats: %+v", stats)
+	}
+
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+
+	if len(lines) != 2 {
+		t.Fatalf("bad capture output:\n%s", b.String())
+	}
+
+	if e := ParseJSONLine(lines[0]); e.Message != "A" || e.Debug {
+		t.Error("bad event:", e)
+	}
+
+	if e := ParseJSONLine(lines[1]); e.Message != "B" || !e.Debug {
+		t.Error("the debug event was not captured:", e)
+	}
+
+	// The debug event produced for the capture is not passed through.
+	for _, msg := range []string{"before", "A", "after"} {
+		if e := r.wait(t); e.Message != msg {
+			t.Error("bad event:", e)
+		}
+	}
+
+	if n := r.len(); n != 0 {
+		t.Error("unexpected events passed through:", n)
+	}
+
+	l.Debug("C")
+
+	if n := r.len(); n != 0 {
+		t.Error("debug events are produced after the capture stopped")
+	}
+}
+
+func TestCaptureDebugGate(t *testing.T) {
+	c := NewCaptureController(Discard)
+	r := &eventRecorder{}
+
+	// The logger isn't sending events to the capture.
+	l := NewLogger(r)
+
+	SetDebugEnabled(false)
+	defer SetDebugEnabled(true)
+
+	b := &bytes.Buffer{}
+	c.Start(b, time.Hour)
+	l.Debug("A")
+	l.Log("B")
+	c.Stop()
+
+	if e := r.wait(t); e.Message != "B" {
+		t.Error("bad event:", e)
+	}
+
+	if n := r.len(); n != 0 {
+		t.Error("the debug event produced for the capture was passed to the handler")
+	}
+
+	if e := ParseJSONLine(strings.TrimSpace(b.String())); e.Message != "A" || !e.Debug {
+		t.Errorf("the debug event was not captured:\n%s", b.String())
+	}
+}
+
+func TestCaptureStop(t *testing.T) {
+	c := NewCaptureController(Discard)
+
+	if _, ok := c.Stop(); ok {
+		t.Error("a capture was stopped before being started")
+	}
+
+	b := &bytes.Buffer{}
+	c.Start(b, time.Hour)
+	c.HandleEvent(&Event{Message: "A"})
+
+	stats, ok := c.Stop()
+
+	if !ok || stats.Events != 1 {
+		t.Errorf("bad capture stats: %+v", stats)
+	}
+
+	c.HandleEvent(&Event{Message: "B"})
+
+	if strings.Contains(b.String(), "B") {
+		t.Error("an event was captured after the capture stopped")
+	}
+
+	if err := c.Start(b, time.Hour); err != nil {
+		t.Error("a new capture could not be started:", err)
+	}
+
+	c.Close()
+
+	if c.Active() {
+		t.Error("the capture is still active after Close")
+	}
+}
@@ -0,0 +1,335 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultCardOther is the value that replaces the arguments exceeding their
+// cardinality budget when the rule doesn't set one.
+const DefaultCardOther = "<other>"
+
+// CardRule is a rule of a CardinalityLimiter, it bounds the number of distinct
+// values of an argument.
+type CardRule struct {
+	// Arg is the name of the argument that the rule applies to.
+	Arg string
+
+	// Budget is the maximum number of distinct values of the argument let
+	// through over the time window.
+	Budget int
+
+	// Window is the duration after which values that weren't seen anymore
+	// stop counting against the budget, it defaults to one minute.
+	Window time.Duration
+
+	// Other is the value set on the arguments that exceed the budget, it
+	// defaults to DefaultCardOther.
+	Other interface{}
+}
+
+// CardinalityLimiter is a handler which bounds the number of distinct values
+// of arguments, like user IDs or URLs, that would otherwise blow up the cost of
+// indexing events downstream.
+//
+// For each rule, the limiter remembers the distinct values of the argument
+// seen over a sliding time window. Values already seen are forwarded
+// unchanged, and new values are remembered until the budget of the rule is
+// reached. Past this point new values are replaced by the Other value of the
+// rule on a copy of the event, the original event is never modified. A value
+// is forgotten after it wasn't seen for one to two windows, which frees room in
+// the budget.
+//
+// Values are compared by equality when they are strings, booleans or numbers,
+// and by their fmt representation otherwise.
+//
+// The limiter counts the values it replaced, and reports the counts of each
+// argument with a diagnostic event at most once per ReportInterval when values
+// were replaced. The pending counts are also reported by Flush and Close.
+//
+// It is safe to use a limiter concurrently from multiple goroutines.
+type CardinalityLimiter struct {
+	// Now returns the current time, it defaults to time.Now and may be set to
+	// a different function before the limiter is used (in tests for example).
+	Now func() time.Time
+
+	// ReportInterval is the minimum time between two reports of the replaced
+	// values, it defaults to one minute and may be changed before the limiter
+	// is used.
+	ReportInterval time.Duration
+
+	handler Handler
+	rules   map[string]*cardArg
+	order   []*cardArg // rules in the order they were given
+
+	mutex  sync.Mutex
+	report time.Time // time of the last report
+}
+
+type cardArg struct {
+	rule     CardRule
+	epoch    int64 // index of the current window
+	cur      map[interface{}]struct{}
+	prev     map[interface{}]struct{}
+	replaced int64 // values replaced since the limiter was created
+	pending  int64 // values replaced since the last report
+}
+
+// NewCardinalityLimiter returns a CardinalityLimiter which forwards events to
+// h, bounding the cardinality of the arguments named by rules.
+//
+// The function panics if a rule doesn't name an argument, if two rules name
+// the same argument, or if a budget is lower than 1.
+func NewCardinalityLimiter(h Handler, rules ...CardRule) *CardinalityLimiter {
+	l := &CardinalityLimiter{
+		Now:            time.Now,
+		ReportInterval: time.Minute,
+		handler:        h,
+		rules:          make(map[string]*cardArg, len(rules)),
+	}
+
+	for _, r := range rules {
+		if len(r.Arg) == 0 {
+			panic("events.NewCardinalityLimiter: rules must name an argument")
+		}
+
+		if r.Budget < 1 {
+			panic("events.NewCardinalityLimiter: invalid budget for the argument " + r.Arg)
+		}
+
+		if l.rules[r.Arg] != nil {
+			panic("events.NewCardinalityLimiter: duplicate rule for the argument " + r.Arg)
+		}
+
+		if r.Window <= 0 {
+			r.Window = time.Minute
+		}
+
+		if r.Other == nil {
+			r.Other = DefaultCardOther
+		}
+
+		c := &cardArg{
+			rule: r,
+			cur:  make(map[interface{}]struct{}),
+			prev: make(map[interface{}]struct{}),
+		}
+		l.rules[r.Arg] = c
+		l.order = append(l.order, c)
+	}
+
+	return l
+}
+
+// HandleEvent satisfies the Handler interface.
+func (l *CardinalityLimiter) HandleEvent(e *Event) {
+	l.handle(e, forwardEvent)
+}
+
+// HandleEventSync satisfies the SyncHandler interface.
+func (l *CardinalityLimiter) HandleEventSync(ctx context.Context, e *Event) error {
+	return handleSync(ctx, l, func(forward func(Handler, *Event)) { l.handle(e, forward) })
+}
+
+// HandleEventContext satisfies the ContextHandler interface.
+func (l *CardinalityLimiter) HandleEventContext(ctx context.Context, e *Event) error {
+	return handleContext(ctx, func(forward func(Handler, *Event)) { l.handle(e, forward) })
+}
+
+func (l *CardinalityLimiter) handle(e *Event, forward func(Handler, *Event)) {
+	if IsDiagnostic(e) || !l.match(e) {
+		forward(l.handler, e)
+		return
+	}
+
+	e, reports := l.limit(e, false)
+	forward(l.handler, e)
+	l.emit(reports)
+}
+
+// MutateEvent satisfies the Mutator interface, it replaces the values
+// exceeding their budget in e and returns it. The reports of replaced values
+// are sent to the limiter's handler, which may be nil when the limiter is only
+// used as a mutator, in which case the counts are only available with
+// Replaced.
+func (l *CardinalityLimiter) MutateEvent(e *Event) *Event {
+	if IsDiagnostic(e) || !l.match(e) {
+		return e
+	}
+
+	e, reports := l.limit(e, true)
+	l.emit(reports)
+	return e
+}
+
+// limit replaces the values of e that exceed their budget, on a copy of e
+// unless inPlace is true. It also returns the reports that are due.
+func (l *CardinalityLimiter) limit(e *Event, inPlace bool) (*Event, []*Event) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := l.Now()
+	cloned := inPlace
+
+	for i, a := range e.Args {
+		if c := l.rules[a.Name]; c != nil && !c.admit(now, a.Value) {
+			if !cloned {
+				e, cloned = e.Clone(), true
+			}
+			e.Args[i].Value = c.rule.Other
+		}
+	}
+
+	if l.report.IsZero() {
+		l.report = now
+	} else if now.Sub(l.report) >= l.ReportInterval {
+		l.report = now
+		return e, l.reports()
+	}
+
+	return e, nil
+}
+
+// Replaced returns the number of values that the limiter replaced since it was
+// created, by argument name. The map only has entries for arguments that had
+// values replaced.
+func (l *CardinalityLimiter) Replaced() map[string]int64 {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	m := make(map[string]int64)
+
+	for _, c := range l.order {
+		if c.replaced != 0 {
+			m[c.rule.Arg] = c.replaced
+		}
+	}
+
+	return m
+}
+
+// Unwrap returns the handler that events are forwarded to.
+func (l *CardinalityLimiter) Unwrap() Handler {
+	return l.handler
+}
+
+// Flush reports the pending counts of replaced values, then flushes the
+// handler that events are forwarded to.
+func (l *CardinalityLimiter) Flush() error {
+	l.flushReports()
+	return flushHandler(l.handler)
+}
+
+// Close reports the pending counts of replaced values, then closes the handler
+// that events are forwarded to.
+func (l *CardinalityLimiter) Close() error {
+	l.flushReports()
+	return closeHandler(l.handler)
+}
+
+func (l *CardinalityLimiter) match(e *Event) bool {
+	for _, a := range e.Args {
+		if l.rules[a.Name] != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *CardinalityLimiter) flushReports() {
+	l.mutex.Lock()
+	reports := l.reports()
+	l.mutex.Unlock()
+	l.emit(reports)
+}
+
+func (l *CardinalityLimiter) emit(reports []*Event) {
+	if l.handler != nil {
+		for _, r := range reports {
+			l.handler.HandleEvent(r)
+		}
+	}
+}
+
+// reports returns the diagnostic events reporting the values replaced since
+// the last report, the mutex must be held by the caller.
+func (l *CardinalityLimiter) reports() (events []*Event) {
+	for _, c := range l.order {
+		if c.pending != 0 {
+			events = append(events, Diagnostic("argument values replaced to bound their cardinality",
+				Arg{"arg", c.rule.Arg},
+				Arg{"budget", c.rule.Budget},
+				Arg{"replaced", c.pending},
+			))
+			c.pending = 0
+		}
+	}
+	return
+}
+
+// admit returns true if v can be let through, it remembers v if the budget
+// allows it.
+func (c *cardArg) admit(now time.Time, v interface{}) bool {
+	c.rotate(now.UnixNano() / int64(c.rule.Window))
+	k := cardKey(v)
+
+	if _, ok := c.cur[k]; ok {
+		return true
+	}
+
+	if _, ok := c.prev[k]; ok {
+		delete(c.prev, k)
+		c.cur[k] = struct{}{}
+		return true
+	}
+
+	if len(c.cur)+len(c.prev) < c.rule.Budget {
+		// The string is copied because the event may be reused by the
+		// producer after the handler returns.
+		if s, ok := k.(string); ok {
+			k = string(append([]byte(nil), s...))
+		}
+		c.cur[k] = struct{}{}
+		return true
+	}
+
+	c.replaced++
+	c.pending++
+	return false
+}
+
+// rotate moves the values of the current window to the previous one when
+// epoch is the next window, or forgets all values when more time has passed.
+func (c *cardArg) rotate(epoch int64) {
+	switch {
+	case epoch <= c.epoch:
+	case epoch == c.epoch+1:
+		c.prev, c.cur = c.cur, c.prev
+		for k := range c.cur {
+			delete(c.cur, k)
+		}
+		c.epoch = epoch
+	default:
+		for k := range c.cur {
+			delete(c.cur, k)
+		}
+		for k := range c.prev {
+			delete(c.prev, k)
+		}
+		c.epoch = epoch
+	}
+}
+
+func cardKey(v interface{}) interface{} {
+	switch v.(type) {
+	case nil, string, bool,
+		int, int8, int16, int32, int64,
+		uint, uint8, uint16, uint32, uint64, uintptr,
+		float32, float64, time.Duration:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
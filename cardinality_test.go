@@ -0,0 +1,161 @@
+package events
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newTestCardinalityLimiter(h Handler, rules ...CardRule) (*CardinalityLimiter, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)}
+	l := NewCardinalityLimiter(h, rules...)
+	l.Now = clock.Now
+	return l, clock
+}
+
+func TestCardinalityLimiter(t *testing.T) {
+	r := &eventRecorder{}
+	l, _ := newTestCardinalityLimiter(r, CardRule{Arg: "user", Budget: 3})
+
+	var values []string
+
+	for i := 0; i != 20; i++ {
+		e := &Event{Message: "login", Args: Args{{"user", fmt.Sprint("u", i%5)}, {"ok", true}}}
+		l.HandleEvent(e)
+
+		if v, _ := e.Args.Get("user"); v != fmt.Sprint("u", i%5) {
+			t.Fatal("the original event was modified:", v)
+		}
+
+		values = append(values, fmt.Sprint(r.wait(t).Args[0].Value))
+	}
+
+	for i, v := range values {
+		want := fmt.Sprint("u", i%5)
+		if i%5 >= 3 {
+			want = DefaultCardOther
+		}
+		if v != want {
+			t.Errorf("bad value at index %d: %s != %s", i, v, want)
+		}
+	}
+
+	if m := l.Replaced(); !reflect.DeepEqual(m, map[string]int64{"user": 8}) {
+		t.Error("bad replaced counts:", m)
+	}
+}
+
+func TestCardinalityLimiterWindow(t *testing.T) {
+	r := &eventRecorder{}
+	l, clock := newTestCardinalityLimiter(r, CardRule{Arg: "url", Budget: 2, Window: time.Minute, Other: "?"})
+	l.ReportInterval = time.Hour
+
+	send := func(url string) interface{} {
+		l.HandleEvent(&Event{Args: Args{{"url", url}}})
+		return r.wait(t).Args[0].Value
+	}
+
+	send("/a")
+	send("/b")
+
+	if v := send("/c"); v != "?" {
+		t.Error("/c must be replaced:", v)
+	}
+
+	// /a is seen in the next window so it keeps counting against the budget,
+	// /b is forgotten after two windows.
+	clock.add(time.Minute)
+	send("/a")
+	clock.add(time.Minute)
+
+	if v := send("/c"); v != "/c" {
+		t.Error("/c must pass after /b expired:", v)
+	}
+
+	if v := send("/b"); v != "?" {
+		t.Error("/b must be replaced:", v)
+	}
+
+	if v := send("/a"); v != "/a" {
+		t.Error("/a must still pass:", v)
+	}
+}
+
+func TestCardinalityLimiterReports(t *testing.T) {
+	r := &eventRecorder{}
+	l, clock := newTestCardinalityLimiter(r,
+		CardRule{Arg: "user", Budget: 1},
+		CardRule{Arg: "url", Budget: 1},
+	)
+	l.ReportInterval = 10 * time.Second
+
+	for i := 0; i != 5; i++ {
+		l.HandleEvent(&Event{Args: Args{{"user", i}, {"url", "/"}}})
+	}
+
+	clock.add(10 * time.Second)
+	l.HandleEvent(&Event{Args: Args{{"user", 0}}})
+	l.HandleEvent(&Event{Args: Args{{"user", 42}}})
+	l.Flush()
+
+	var reports []*Event
+
+	for _, e := range r.events {
+		if IsDiagnostic(e) {
+			reports = append(reports, e)
+		}
+	}
+
+	if len(reports) != 2 {
+		t.Fatal("bad number of reports:", len(reports))
+	}
+
+	if want := (Args{{"arg", "user"}, {"budget", 1}, {"replaced", int64(4)}}); !reflect.DeepEqual(reports[0].Args, want) {
+		t.Error("bad first report:", reports[0].Args)
+	}
+
+	if want := (Args{{"arg", "user"}, {"budget", 1}, {"replaced", int64(1)}}); !reflect.DeepEqual(reports[1].Args, want) {
+		t.Error("bad report on flush:", reports[1].Args)
+	}
+}
+
+func TestCardinalityLimiterPassThrough(t *testing.T) {
+	r := &eventRecorder{}
+	l, _ := newTestCardinalityLimiter(r, CardRule{Arg: "user", Budget: 1})
+
+	e1 := &Event{Args: Args{{"other", 1}}}
+	e2 := &Event{Source: DiagnosticSource, Args: Args{{"user", 1}}}
+	e3 := &Event{Source: DiagnosticSource, Args: Args{{"user", 2}}}
+
+	for _, e := range []*Event{e1, e2, e3} {
+		l.HandleEvent(e)
+	}
+
+	if n := len(l.Replaced()); n != 0 {
+		t.Error("the events must pass through:", l.Replaced())
+	}
+
+	if r.len() != 3 || r.events[2].Args[0].Value != 2 {
+		t.Error("bad events:", r.events)
+	}
+}
+
+func TestNewCardinalityLimiterPanics(t *testing.T) {
+	tests := [][]CardRule{
+		{{Budget: 1}},
+		{{Arg: "a"}},
+		{{Arg: "a", Budget: 1}, {Arg: "a", Budget: 2}},
+	}
+
+	for _, rules := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Error("no panic for rules:", rules)
+				}
+			}()
+			NewCardinalityLimiter(&eventRecorder{}, rules...)
+		}()
+	}
+}
@@ -0,0 +1,184 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// MessageFingerprint returns the fingerprint of events that a Logger produced
+// from the given format, or with the given message for events that weren't
+// formatted. Catalog uses it to find the translations of events, so a
+// translation applies to all the messages produced by a log call. It is also
+// the MSGID written by RFC5424Encoder, so the fingerprints of the events of a
+// program can be found in its logs.
+//
+// Events don't carry their format, loggers remember the formats of the last
+// few thousand messages they produced. Events whose message was formatted
+// before that get the fingerprint of their message.
+func MessageFingerprint(format string) uint64 {
+	return stringFingerprint(format)
+}
+
+// Catalog holds translations of event messages, keyed by the fingerprint of
+// the formats of the messages (see MessageFingerprint) and by language.
+//
+// Translations are templates using the placeholders of Logger formats,
+// "%{name}s" for example is replaced by the value of the argument name of the
+// event formatted with the %s verb. Every verb of a template must name an
+// argument, and "%%" is written as a percent sign.
+//
+// It is safe to use a catalog concurrently from multiple goroutines, lookups
+// don't acquire locks.
+type Catalog struct {
+	templates sync.Map // catalogKey => *catalogTemplate
+}
+
+type catalogKey struct {
+	fingerprint uint64
+	lang        string
+}
+
+type catalogTemplate struct {
+	format string   // fmt format of the template
+	names  []string // names of the arguments referenced by the format
+}
+
+// NewCatalog returns a new empty catalog.
+func NewCatalog() *Catalog {
+	return &Catalog{}
+}
+
+// Register sets template as the translation in lang of the messages with the
+// given fingerprint, replacing the previous translation if there was one.
+func (c *Catalog) Register(fingerprint uint64, lang string, template string) {
+	format, args := appendFormat(nil, nil, template, nil)
+	t := &catalogTemplate{format: string(format)}
+
+	for _, a := range args {
+		t.names = append(t.names, a.Name)
+	}
+
+	c.templates.Store(catalogKey{fingerprint, lang}, t)
+}
+
+// Localize returns the message of e translated in lang.
+//
+// The translation of a regional language like "pt-BR" falls back to the
+// translation in the base language ("pt") if there is one. When there is no
+// translation the function returns the message of e unchanged. When e lacks
+// an argument referenced by the translation, the function returns the message
+// of e followed by a marker naming the argument, like "%!{name}(MISSING)".
+func (c *Catalog) Localize(e *Event, lang string) string {
+	t := c.lookup(formatFingerprint(e), lang)
+
+	if t == nil {
+		return e.Message
+	}
+
+	values := make([]interface{}, len(t.names))
+
+	for i, name := range t.names {
+		v, ok := e.Args.Get(name)
+		if !ok {
+			return e.Message + " %!{" + name + "}(MISSING)"
+		}
+		values[i] = v
+	}
+
+	return fmt.Sprintf(t.format, values...)
+}
+
+func (c *Catalog) lookup(fingerprint uint64, lang string) *catalogTemplate {
+	for {
+		if t, ok := c.templates.Load(catalogKey{fingerprint, lang}); ok {
+			return t.(*catalogTemplate)
+		}
+
+		i := strings.LastIndexAny(lang, "-_")
+		if i < 0 {
+			return nil
+		}
+
+		lang = lang[:i]
+	}
+}
+
+// Localizer is a handler which translates the messages of events with a
+// catalog before passing them to another handler, so different handlers can
+// render the same events in different languages.
+//
+// Events are copied when their message is translated, the handler never sees
+// the original events modified. Diagnostic events are passed unchanged.
+type Localizer struct {
+	handler Handler
+	catalog *Catalog
+	lang    string
+}
+
+// NewLocalizer returns a Localizer which translates the messages of events in
+// lang with catalog, and passes them to h.
+func NewLocalizer(h Handler, catalog *Catalog, lang string) *Localizer {
+	return &Localizer{
+		handler: h,
+		catalog: catalog,
+		lang:    lang,
+	}
+}
+
+// HandleEvent satisfies the Handler interface.
+func (l *Localizer) HandleEvent(e *Event) {
+	l.handle(e, forwardEvent)
+}
+
+// HandleEventSync satisfies the SyncHandler interface.
+func (l *Localizer) HandleEventSync(ctx context.Context, e *Event) error {
+	return handleSync(ctx, l, func(forward func(Handler, *Event)) { l.handle(e, forward) })
+}
+
+// HandleEventContext satisfies the ContextHandler interface.
+func (l *Localizer) HandleEventContext(ctx context.Context, e *Event) error {
+	return handleContext(ctx, func(forward func(Handler, *Event)) { l.handle(e, forward) })
+}
+
+func (l *Localizer) handle(e *Event, forward func(Handler, *Event)) {
+	if m, ok := l.localize(e); ok {
+		c := *e
+		c.Message = m
+		e = &c
+	}
+	forward(l.handler, e)
+}
+
+// MutateEvent satisfies the Mutator interface, it translates the message of e
+// in place and returns it.
+func (l *Localizer) MutateEvent(e *Event) *Event {
+	if m, ok := l.localize(e); ok {
+		e.Message = m
+	}
+	return e
+}
+
+// Unwrap returns the handler that events are forwarded to.
+func (l *Localizer) Unwrap() Handler {
+	return l.handler
+}
+
+// Flush flushes the handler that events are forwarded to.
+func (l *Localizer) Flush() error {
+	return flushHandler(l.handler)
+}
+
+// Close closes the handler that events are forwarded to.
+func (l *Localizer) Close() error {
+	return closeHandler(l.handler)
+}
+
+func (l *Localizer) localize(e *Event) (string, bool) {
+	if IsDiagnostic(e) {
+		return "", false
+	}
+	m := l.catalog.Localize(e, l.lang)
+	return m, m != e.Message
+}
@@ -0,0 +1,147 @@
+package events
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func newTestCatalog() *Catalog {
+	c := NewCatalog()
+	f := MessageFingerprint("disk full")
+	c.Register(f, "fr", "disque plein sur %{device}s (%{used}d%% utilisé)")
+	c.Register(f, "pt", "disco cheio em %{device}s")
+	c.Register(f, "pt-BR", "disco lotado em %{device}s")
+	c.Register(MessageFingerprint("server started"), "fr", "serveur démarré")
+	return c
+}
+
+func TestCatalogLocalize(t *testing.T) {
+	c := newTestCatalog()
+	e := &Event{Message: "disk full", Args: Args{{"device", "/dev/sda"}, {"used", 98}}}
+
+	tests := []struct {
+		event *Event
+		lang  string
+		want  string
+	}{
+		{e, "fr", "disque plein sur /dev/sda (98% utilisé)"},
+		{e, "pt", "disco cheio em /dev/sda"},
+		{e, "pt-BR", "disco lotado em /dev/sda"},
+		{e, "pt-PT", "disco cheio em /dev/sda"},
+		{e, "de", "disk full"},
+		{&Event{Message: "server started"}, "fr", "serveur démarré"},
+		{&Event{Message: "disk full", Args: Args{{"used", 98}}}, "fr", "disk full %!{device}(MISSING)"},
+		{&Event{Message: "unknown", Args: Args{{"device", "/dev/sda"}}}, "fr", "unknown"},
+	}
+
+	for _, test := range tests {
+		if s := c.Localize(test.event, test.lang); s != test.want {
+			t.Errorf("%s: %q != %q", test.lang, s, test.want)
+		}
+	}
+}
+
+func TestCatalogRegisterReplace(t *testing.T) {
+	c := NewCatalog()
+	f := MessageFingerprint("hello")
+	c.Register(f, "fr", "salut")
+	c.Register(f, "fr", "bonjour")
+
+	if s := c.Localize(&Event{Message: "hello"}, "fr"); s != "bonjour" {
+		t.Error("the translation was not replaced:", s)
+	}
+}
+
+func TestLocalizer(t *testing.T) {
+	c := newTestCatalog()
+	fr, pt := &eventRecorder{}, &eventRecorder{}
+	h := MultiHandler(NewLocalizer(fr, c, "fr"), NewLocalizer(pt, c, "pt"))
+
+	e := &Event{Message: "disk full", Args: Args{{"device", "/dev/sda"}, {"used", 98}}}
+	h.HandleEvent(e)
+
+	if m := fr.wait(t).Message; m != "disque plein sur /dev/sda (98% utilisé)" {
+		t.Error("bad french message:", m)
+	}
+
+	if m := pt.wait(t).Message; m != "disco cheio em /dev/sda" {
+		t.Error("bad portuguese message:", m)
+	}
+
+	if e.Message != "disk full" {
+		t.Error("the original event was modified:", e.Message)
+	}
+
+	d := Diagnostic("server started")
+	h.HandleEvent(d)
+
+	if m := fr.wait(t).Message; m != "server started" {
+		t.Error("the diagnostic event was translated:", m)
+	}
+}
+
+func TestLocalizerLogger(t *testing.T) {
+	c := NewCatalog()
+	c.Register(MessageFingerprint("disk full on %{device}s"), "fr", "disque plein sur %{device}s")
+
+	r := &eventRecorder{}
+	l := NewLogger(NewLocalizer(r, c, "fr"))
+
+	for _, device := range []string{"/dev/sda", "/dev/sdb"} {
+		l.Log("disk full on %{device}s", device)
+
+		if m := r.wait(t).Message; m != "disque plein sur "+device {
+			t.Error("bad french message:", m)
+		}
+	}
+}
+
+func TestLocalizerMutator(t *testing.T) {
+	r := &eventRecorder{}
+	e := &Event{Message: "server started"}
+	NewMutatorChain(r, NewLocalizer(nil, newTestCatalog(), "fr")).HandleEvent(e)
+
+	if m := r.wait(t).Message; m != "serveur démarré" || e.Message != "server started" {
+		t.Error("bad messages:", m, e.Message)
+	}
+}
+
+func TestCatalogConcurrent(t *testing.T) {
+	c := NewCatalog()
+	wg := sync.WaitGroup{}
+
+	for i := 0; i != 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			msg := fmt.Sprint("message ", i)
+
+			for j := 0; j != 100; j++ {
+				c.Register(MessageFingerprint(msg), "fr", "message %{i}d")
+
+				if s := c.Localize(&Event{Message: msg, Args: Args{{"i", i}}}, "fr"); s != fmt.Sprint("message ", i) {
+					t.Error("bad message:", s)
+					return
+				}
+			}
+		}(i)
+	}
+
+	wg.Wait()
+}
+
+func BenchmarkCatalogLocalize(b *testing.B) {
+	c := newTestCatalog()
+	e := &Event{Message: "disk full", Args: Args{{"device", "/dev/sda"}, {"used", 98}}}
+
+	for _, lang := range []string{"fr", "de"} {
+		b.Run(lang, func(b *testing.B) {
+			b.ReportAllocs()
+
+			for i := 0; i != b.N; i++ {
+				c.Localize(e, lang)
+			}
+		})
+	}
+}
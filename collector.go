@@ -0,0 +1,282 @@
+package events
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// DefaultSchemaMaxFingerprints is the default number of fingerprints
+	// tracked by the collectors returned by NewSchemaCollector.
+	DefaultSchemaMaxFingerprints = 1000
+
+	// DefaultSchemaMaxArgs is the default number of arguments tracked per
+	// fingerprint by the collectors returned by NewSchemaCollector.
+	DefaultSchemaMaxArgs = 100
+)
+
+// InferredSchema is the schema of events inferred by a SchemaCollector.
+type InferredSchema struct {
+	// Message and Source are the message and source of the first event
+	// observed with the schema's fingerprint.
+	Message string
+	Source  string
+
+	// Count is the number of events observed with the schema's fingerprint.
+	Count int64
+
+	// Fields is the list of arguments observed in the events, in the order
+	// they were first seen.
+	Fields []InferredField
+
+	// Truncated is true if some arguments were not tracked because the
+	// collector's MaxArgs limit was reached.
+	Truncated bool
+}
+
+// InferredField describes an argument of the events of an InferredSchema.
+type InferredField struct {
+	Name string
+
+	// Kind is the kind of the first non-null value observed for the argument,
+	// or KindNull if the argument only had null values.
+	Kind Kind
+
+	// Kinds lists all the kinds observed for the argument except KindNull, in
+	// the order they were first seen.
+	Kinds []Kind
+
+	// Conflict is true if the argument was observed with values of different
+	// kinds, null values excluded.
+	Conflict bool
+
+	// Nullable is true if the argument was observed with null values.
+	Nullable bool
+
+	// Count is the number of events the argument was observed in, arguments
+	// with a count lower than their schema's are optional.
+	Count int64
+}
+
+// SchemaCollector is a handler which infers the schema of the events it
+// receives from the kinds of their arguments (see KindOf), then forwards them
+// to another handler.
+//
+// Events are grouped by message fingerprint (see OnceHandler) and source, so
+// events produced by the same log statement share a schema as long as their
+// message doesn't embed argument values.
+//
+// The memory used by the collector is bounded by MaxFingerprints and MaxArgs,
+// events with new fingerprints are not tracked once the limit is reached, and
+// the count of those events is reported by the Untracked method.
+//
+// It is safe to use the collector concurrently from multiple goroutines.
+type SchemaCollector struct {
+	// MaxFingerprints is the maximum number of schemas tracked by the
+	// collector, it defaults to DefaultSchemaMaxFingerprints and may be
+	// changed before the collector is used.
+	MaxFingerprints int
+
+	// MaxArgs is the maximum number of arguments tracked per schema, it
+	// defaults to DefaultSchemaMaxArgs and may be changed before the
+	// collector is used.
+	MaxArgs int
+
+	untracked int64 // accessed atomically
+	handler   Handler
+	mutex     sync.Mutex
+	schemas   map[schemaKey]*collectedSchema
+	order     []*collectedSchema
+}
+
+type schemaKey struct {
+	fingerprint uint64
+	source      string
+}
+
+type collectedSchema struct {
+	InferredSchema
+	fields map[string]int // index of fields in the Fields slice
+	last   []int64        // value of Count when fields were last observed
+}
+
+// NewSchemaCollector returns a SchemaCollector which forwards events to h. If
+// h is nil the events are only observed.
+func NewSchemaCollector(h Handler) *SchemaCollector {
+	return &SchemaCollector{
+		MaxFingerprints: DefaultSchemaMaxFingerprints,
+		MaxArgs:         DefaultSchemaMaxArgs,
+		handler:         h,
+		schemas:         make(map[schemaKey]*collectedSchema),
+	}
+}
+
+// HandleEvent satisfies the Handler interface.
+func (c *SchemaCollector) HandleEvent(e *Event) {
+	c.handle(e, forwardEvent)
+}
+
+// HandleEventSync satisfies the SyncHandler interface.
+func (c *SchemaCollector) HandleEventSync(ctx context.Context, e *Event) error {
+	return handleSync(ctx, c, func(forward func(Handler, *Event)) { c.handle(e, forward) })
+}
+
+// HandleEventContext satisfies the ContextHandler interface.
+func (c *SchemaCollector) HandleEventContext(ctx context.Context, e *Event) error {
+	return handleContext(ctx, func(forward func(Handler, *Event)) { c.handle(e, forward) })
+}
+
+func (c *SchemaCollector) handle(e *Event, forward func(Handler, *Event)) {
+	if !IsDiagnostic(e) {
+		c.observe(e)
+	}
+
+	if c.handler != nil {
+		forward(c.handler, e)
+	}
+}
+
+func (c *SchemaCollector) observe(e *Event) {
+	key := schemaKey{fingerprint(e, false), e.Source}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	s := c.schemas[key]
+
+	if s == nil {
+		if len(c.schemas) >= c.MaxFingerprints {
+			atomic.AddInt64(&c.untracked, 1)
+			return
+		}
+
+		s = &collectedSchema{
+			InferredSchema: InferredSchema{Message: e.Message, Source: e.Source},
+			fields:         make(map[string]int),
+		}
+		c.schemas[key] = s
+		c.order = append(c.order, s)
+	}
+
+	s.Count++
+
+	for _, a := range e.Args {
+		j, ok := s.fields[a.Name]
+
+		if !ok {
+			if len(s.Fields) >= c.MaxArgs {
+				s.Truncated = true
+				continue
+			}
+			j = len(s.Fields)
+			s.fields[a.Name] = j
+			s.Fields = append(s.Fields, InferredField{Name: a.Name, Kind: KindNull})
+			s.last = append(s.last, 0)
+		}
+
+		f := &s.Fields[j]
+
+		// Repeated arguments are counted once per event.
+		if s.last[j] != s.Count {
+			s.last[j] = s.Count
+			f.Count++
+		}
+
+		switch k := KindOf(a.Value); {
+		case k == KindNull:
+			f.Nullable = true
+		case !f.hasKind(k):
+			f.Conflict = len(f.Kinds) != 0
+			f.Kinds = append(f.Kinds, k)
+
+			if f.Kind == KindNull {
+				f.Kind = k
+			}
+		}
+	}
+}
+
+func (f *InferredField) hasKind(k Kind) bool {
+	for _, x := range f.Kinds {
+		if x == k {
+			return true
+		}
+	}
+	return false
+}
+
+// Schemas returns the list of schemas inferred by the collector so far, in the
+// order their first event was observed.
+func (c *SchemaCollector) Schemas() []InferredSchema {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	list := make([]InferredSchema, len(c.order))
+
+	for i, s := range c.order {
+		list[i] = s.InferredSchema
+		list[i].Fields = make([]InferredField, len(s.Fields))
+
+		for j, f := range s.Fields {
+			f.Kinds = append([]Kind(nil), f.Kinds...)
+			list[i].Fields[j] = f
+		}
+	}
+
+	return list
+}
+
+// Conflicts returns the list of schemas that have at least one argument
+// observed with values of different kinds, only the conflicting fields are
+// returned in each schema.
+func (c *SchemaCollector) Conflicts() []InferredSchema {
+	var list []InferredSchema
+
+	for _, s := range c.Schemas() {
+		fields := s.Fields[:0]
+
+		for _, f := range s.Fields {
+			if f.Conflict {
+				fields = append(fields, f)
+			}
+		}
+
+		if len(fields) != 0 {
+			s.Fields = fields
+			list = append(list, s)
+		}
+	}
+
+	return list
+}
+
+// Untracked returns the number of events that were not tracked because the
+// MaxFingerprints limit was reached.
+func (c *SchemaCollector) Untracked() int64 {
+	return atomic.LoadInt64(&c.untracked)
+}
+
+// Reset discards all the schemas inferred by the collector.
+func (c *SchemaCollector) Reset() {
+	c.mutex.Lock()
+	c.schemas = make(map[schemaKey]*collectedSchema)
+	c.order = nil
+	c.mutex.Unlock()
+	atomic.StoreInt64(&c.untracked, 0)
+}
+
+// Unwrap returns the handler that events are forwarded to.
+func (c *SchemaCollector) Unwrap() Handler {
+	return c.handler
+}
+
+// Flush flushes the handler that events are forwarded to.
+func (c *SchemaCollector) Flush() error {
+	return flushHandler(c.handler)
+}
+
+// Close closes the handler that events are forwarded to.
+func (c *SchemaCollector) Close() error {
+	return closeHandler(c.handler)
+}
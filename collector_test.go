@@ -0,0 +1,90 @@
+package events
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSchemaCollector(t *testing.T) {
+	r := &eventRecorder{}
+	c := NewSchemaCollector(r)
+
+	c.HandleEvent(&Event{Message: "request", Source: "http.go:1", Args: Args{{"status", 200}, {"path", "/"}}})
+	c.HandleEvent(&Event{Message: "request", Source: "http.go:1", Args: Args{{"status", 404}, {"error", (*int)(nil)}}})
+	c.HandleEvent(&Event{Message: "request", Source: "http.go:2", Args: Args{{"status", "OK"}}})
+	c.HandleEvent(&Event{Message: "request", Source: "http.go:1", Args: Args{{"status", "OK"}, {"status", 1.5}}})
+
+	if n := r.len(); n != 4 {
+		t.Error("bad number of forwarded events:", n)
+	}
+
+	want := []InferredSchema{
+		{
+			Message: "request",
+			Source:  "http.go:1",
+			Count:   3,
+			Fields: []InferredField{
+				{Name: "status", Kind: KindInt, Kinds: []Kind{KindInt, KindString, KindFloat}, Conflict: true, Count: 3},
+				{Name: "path", Kind: KindString, Kinds: []Kind{KindString}, Count: 1},
+				{Name: "error", Kind: KindNull, Nullable: true, Count: 1},
+			},
+		},
+		{
+			Message: "request",
+			Source:  "http.go:2",
+			Count:   1,
+			Fields: []InferredField{
+				{Name: "status", Kind: KindString, Kinds: []Kind{KindString}, Count: 1},
+			},
+		},
+	}
+
+	if found := c.Schemas(); !reflect.DeepEqual(found, want) {
+		t.Errorf("bad schemas:\n%+v\n%+v", found, want)
+	}
+
+	conflicts := c.Conflicts()
+
+	if len(conflicts) != 1 || conflicts[0].Source != "http.go:1" || len(conflicts[0].Fields) != 1 || conflicts[0].Fields[0].Name != "status" {
+		t.Errorf("bad conflicts: %+v", conflicts)
+	}
+
+	// The returned schemas are copies.
+	c.Schemas()[0].Fields[0].Kinds[0] = KindBool
+
+	if k := c.Schemas()[0].Fields[0].Kinds[0]; k != KindInt {
+		t.Error("the collector state was modified:", k)
+	}
+
+	c.Reset()
+
+	if n := len(c.Schemas()); n != 0 {
+		t.Error("the schemas were not discarded:", n)
+	}
+}
+
+func TestSchemaCollectorLimits(t *testing.T) {
+	c := NewSchemaCollector(nil)
+	c.MaxFingerprints = 2
+	c.MaxArgs = 2
+
+	c.HandleEvent(&Event{Message: "A", Args: Args{{"a", 1}, {"b", 2}, {"c", 3}}})
+	c.HandleEvent(&Event{Message: "B"})
+	c.HandleEvent(&Event{Message: "C"})
+	c.HandleEvent(&Event{Message: "D"})
+	c.HandleEvent(&Event{Message: "A", Args: Args{{"d", 4}, {"a", "1"}}})
+
+	schemas := c.Schemas()
+
+	if len(schemas) != 2 {
+		t.Fatal("bad number of schemas:", len(schemas))
+	}
+
+	if s := schemas[0]; !s.Truncated || len(s.Fields) != 2 || !s.Fields[0].Conflict {
+		t.Errorf("bad schema: %+v", s)
+	}
+
+	if n := c.Untracked(); n != 2 {
+		t.Error("bad number of untracked events:", n)
+	}
+}
@@ -0,0 +1,135 @@
+package events
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// The ContextHandler interface may be implemented by handlers that can block
+// while handling events, to give up when the context of the producer is done.
+type ContextHandler interface {
+	Handler
+
+	// HandleEventContext is like HandleEvent but returns ctx.Err() if ctx is
+	// done before the handler could accept the event. Implementations must
+	// count or report the abandoned events.
+	HandleEventContext(ctx context.Context, e *Event) error
+}
+
+// HandleEventContext passes e to h, using its HandleEventContext method if h
+// implements ContextHandler. Other handlers receive the event through their
+// HandleEvent method and the function returns nil.
+func HandleEventContext(ctx context.Context, h Handler, e *Event) error {
+	if c, ok := h.(ContextHandler); ok {
+		return c.HandleEventContext(ctx, e)
+	}
+	h.HandleEvent(e)
+	return nil
+}
+
+// handleContext calls handle with a forward function which passes events to
+// the handlers that come next with HandleEventContext, returning the first error
+// that occurred. It is the context counterpart of handleSync.
+func handleContext(ctx context.Context, handle func(forward func(Handler, *Event))) (err error) {
+	handle(func(h Handler, e *Event) {
+		if herr := HandleEventContext(ctx, h, e); err == nil {
+			err = herr
+		}
+	})
+	return
+}
+
+// LogContext emits a log event to the default logger, giving up if ctx is done
+// while handlers are blocked (see ContextHandler).
+func LogContext(ctx context.Context, format string, args ...interface{}) {
+	DefaultLogger.log(ctx, 1, false, format, args...)
+}
+
+// DebugContext emits a debug event to the default logger, giving up if ctx is
+// done while handlers are blocked (see ContextHandler).
+func DebugContext(ctx context.Context, format string, args ...interface{}) {
+	DefaultLogger.debug(ctx, 1, format, args...)
+}
+
+// LogContext is like Log but gives up if ctx is done while handlers are
+// blocked (see ContextHandler).
+func (l *Logger) LogContext(ctx context.Context, format string, args ...interface{}) {
+	l.log(ctx, 1, false, format, args...)
+}
+
+// DebugContext is like Debug but gives up if ctx is done while handlers are
+// blocked (see ContextHandler).
+func (l *Logger) DebugContext(ctx context.Context, format string, args ...interface{}) {
+	l.debug(ctx, 1, format, args...)
+}
+
+func (p *priorityHandler) HandleEventContext(ctx context.Context, e *Event) error {
+	return HandleEventContext(ctx, p.Handler, e)
+}
+
+// HandleEventContext passes the event to all handlers, returning the first
+// error that occurred. Handlers may still stop the propagation of the event
+// (see NextHandler).
+func (m *multiHandler) HandleEventContext(ctx context.Context, e *Event) error {
+	_, err := m.handleEventContextNext(ctx, e)
+	return err
+}
+
+func (m *multiHandler) handleEventContextNext(ctx context.Context, e *Event) (stop bool, err error) {
+	for i, h := range m.handlers {
+		stop, diag := safeCall(h, func() bool {
+			stop, herr := handleEventContextNext(ctx, h, e)
+			if err == nil {
+				err = herr
+			}
+			return stop
+		})
+
+		if diag != nil {
+			m.report(i, diag)
+		}
+
+		if stop {
+			return true, err
+		}
+	}
+	return false, err
+}
+
+// handleEventContextNext passes e to h like HandleEventContext, and returns
+// true if h stopped the propagation of the event like handleEventNext does.
+// Priority wrappers and nested multi handlers are traversed so the handlers
+// they wrap can still stop the propagation.
+func handleEventContextNext(ctx context.Context, h Handler, e *Event) (bool, error) {
+	switch x := h.(type) {
+	case *priorityHandler:
+		return handleEventContextNext(ctx, x.Handler, e)
+	case *multiHandler:
+		return x.handleEventContextNext(ctx, e)
+	case ContextHandler:
+		return false, x.HandleEventContext(ctx, e)
+	default:
+		return handleEventNext(h, e), nil
+	}
+}
+
+func (defaultHandler) HandleEventContext(ctx context.Context, e *Event) (err error) {
+	for {
+		b := loadDefault()
+		atomic.AddInt64(&b.calls, 1)
+
+		if b == loadDefault() {
+			defer atomic.AddInt64(&b.calls, -1)
+
+			_, diag := safeCall(b.handler, func() bool { err = HandleEventContext(ctx, b.handler, e); return false })
+
+			if diag != nil {
+				reportPanic(b.handler, diag)
+			}
+
+			return
+		}
+
+		atomic.AddInt64(&b.calls, -1)
+	}
+}
@@ -0,0 +1,166 @@
+package events
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAsyncHandlerContextCanceled(t *testing.T) {
+	a, g := newBlockedAsyncHandler(t, AsyncConfig{Policy: Block})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := a.HandleEventContext(ctx, &Event{Message: "D"}); err != context.Canceled {
+		t.Error("bad error:", err)
+	}
+
+	assertStats(t, a.Stats(), AsyncStats{QueueLength: 2, QueueSize: 2, Canceled: 1})
+
+	close(g.release)
+	a.Close()
+
+	assertMessages(t, g.messages(), "A", "B", "C", "async handler dropped 1 events")
+
+	if e := g.events[3]; e.Args.Map()["canceled"] != int64(1) {
+		t.Error("bad summary event:", e.Args)
+	}
+}
+
+func TestAsyncHandlerContextDeadline(t *testing.T) {
+	a, g := newBlockedAsyncHandler(t, AsyncConfig{Policy: Block})
+
+	start := time.Now()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if err := a.HandleEventContext(ctx, &Event{Message: "D"}); err != context.DeadlineExceeded {
+		t.Error("bad error:", err)
+	}
+
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Error("the handler returned before the deadline:", elapsed)
+	}
+
+	// The queue has room again, the context isn't checked when the handler
+	// doesn't have to block.
+	g.release <- struct{}{}
+	g.waitStarted(t, "B")
+
+	if err := a.HandleEventContext(ctx, &Event{Message: "E"}); err != nil {
+		t.Error("bad error:", err)
+	}
+
+	close(g.release)
+	a.Close()
+
+	assertStats(t, a.Stats(), AsyncStats{QueueSize: 2, Handled: 4, Canceled: 1})
+	assertMessages(t, g.messages(), "A", "B", "C", "E", "async handler dropped 1 events")
+}
+
+func TestHandleEventContext(t *testing.T) {
+	a, g := newBlockedAsyncHandler(t, AsyncConfig{Policy: Block})
+	r := newGateHandler()
+	close(r.release)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for _, h := range []Handler{
+		a,
+		MultiHandler(r, a),
+		WithPriority(a, 1),
+	} {
+		if err := HandleEventContext(ctx, h, &Event{Message: "D"}); err != context.Canceled {
+			t.Errorf("%T: bad error: %v", h, err)
+		}
+	}
+
+	if err := HandleEventContext(ctx, r, &Event{Message: "E"}); err != nil {
+		t.Error("bad error from a plain handler:", err)
+	}
+
+	assertMessages(t, r.messages(), "D", "E")
+
+	close(g.release)
+	a.Close()
+
+	assertStats(t, a.Stats(), AsyncStats{QueueSize: 2, Handled: 3, Canceled: 3})
+}
+
+func TestMultiHandlerContextNext(t *testing.T) {
+	calls := []string{}
+	r := &eventRecorder{}
+	m := MultiHandler(WithPriority(&stopHandler{name: "A", stop: true, calls: &calls}, 10), r)
+
+	if err := HandleEventContext(context.Background(), m, &Event{Message: "A"}); err != nil {
+		t.Error("bad error:", err)
+	}
+
+	if err := HandleEventContext(context.Background(), MultiHandler(m, r), &Event{Message: "B"}); err != nil {
+		t.Error("bad error:", err)
+	}
+
+	if n := r.len(); n != 0 {
+		t.Error("the propagation of the events was not stopped:", n)
+	}
+
+	if !reflect.DeepEqual(calls, []string{"A", "A"}) {
+		t.Error("bad calls:", calls)
+	}
+}
+
+func TestHandleEventContextWrappers(t *testing.T) {
+	for _, w := range testWrappers(t) {
+		t.Run(w.name, func(t *testing.T) {
+			a, g := newBlockedAsyncHandler(t, AsyncConfig{Policy: Block})
+			defer a.Close()
+			defer close(g.release)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			if err := HandleEventContext(ctx, w.wrap(a), &Event{Message: "D"}); err != context.Canceled {
+				t.Error("the context was not passed to the wrapped handler:", err)
+			}
+		})
+	}
+}
+
+func TestLoggerLogContext(t *testing.T) {
+	a, g := newBlockedAsyncHandler(t, AsyncConfig{Policy: Block})
+	l := NewLogger(a)
+	l.EnableSource = false
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	l.LogContext(ctx, "D")
+	l.DebugContext(ctx, "E")
+
+	close(g.release)
+	l.LogContext(context.Background(), "F")
+	a.Close()
+
+	assertStats(t, a.Stats(), AsyncStats{QueueSize: 2, Handled: 4, Canceled: 2})
+	assertMessages(t, g.messages(), "A", "B", "C", "F", "async handler dropped 2 events")
+}
+
+func TestDefaultHandlerContext(t *testing.T) {
+	a, g := newBlockedAsyncHandler(t, AsyncConfig{Policy: Block})
+	defer SwapDefaultHandler(SwapDefaultHandler(a))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	LogContext(ctx, "D")
+	DebugContext(ctx, "E")
+
+	close(g.release)
+	a.Close()
+
+	assertStats(t, a.Stats(), AsyncStats{QueueSize: 2, Handled: 3, Canceled: 2})
+}
@@ -0,0 +1,395 @@
+package events
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNoPanicTrace is returned by ParsePanicTrace when its input doesn't
+// contain the trace of a crash.
+var ErrNoPanicTrace = errors.New("events: no panic trace found")
+
+// CrashFrame is a frame of the stack of a crashed goroutine.
+type CrashFrame struct {
+	Func string `json:"func"`
+	File string `json:"file"`
+	Line int    `json:"line"`
+}
+
+func (f CrashFrame) String() string {
+	return fmt.Sprintf("%s (%s:%d)", f.Func, f.File, f.Line)
+}
+
+// CrashOption values configure the behavior of CaptureCrash.
+type CrashOption func(*crashConfig)
+
+// CrashTimeout sets the maximum amount of time spent flushing the handler
+// after a crash was reported, it defaults to ExitTimeout.
+func CrashTimeout(timeout time.Duration) CrashOption {
+	return func(c *crashConfig) { c.timeout = timeout }
+}
+
+// CrashOutput makes the Go runtime write the traces of fatal crashes to the
+// file at path, in addition to stderr (see debug.SetCrashOutput, it requires
+// Go 1.23 or later). This covers crashes that happen outside of goroutines
+// started by Go, and fatal errors which cannot be recovered from.
+//
+// The runtime exits right after writing the trace, so the crash is reported
+// the next time CaptureCrash is called with the same path, usually when the
+// program restarts. The event has the previous_run argument set to true.
+func CrashOutput(path string) CrashOption {
+	return func(c *crashConfig) { c.output = path }
+}
+
+type crashConfig struct {
+	handler Handler
+	timeout time.Duration
+	output  string
+}
+
+var crashHooks struct {
+	mutex  sync.Mutex
+	config *crashConfig
+}
+
+// CaptureCrash installs h as the handler receiving the events that describe the
+// crashes of the program, and returns a function restoring the previous
+// configuration.
+//
+// A crash event is emitted when a goroutine started by Go panics, carrying
+// the panic message, the parsed stack of the goroutine and metadata about the
+// process. The handler is then flushed, and the panic resumes to terminate the
+// program with its usual output and status code.
+//
+// If h is nil the default handler is used.
+func CaptureCrash(h Handler, opts ...CrashOption) (restore func()) {
+	if h == nil {
+		h = DefaultHandler
+	}
+
+	c := &crashConfig{handler: h, timeout: ExitTimeout}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.output != "" {
+		if e := readCrashOutput(c.output); e != nil {
+			h.HandleEvent(e)
+		}
+
+		if err := setCrashOutput(c.output); err != nil {
+			h.HandleEvent(Diagnostic("the crash output could not be set",
+				Arg{"path", c.output},
+				Arg{"error", err},
+			))
+		}
+	}
+
+	crashHooks.mutex.Lock()
+	prev := crashHooks.config
+	crashHooks.config = c
+	crashHooks.mutex.Unlock()
+
+	return func() {
+		crashHooks.mutex.Lock()
+		defer crashHooks.mutex.Unlock()
+
+		if crashHooks.config == c {
+			crashHooks.config = prev
+
+			if c.output != "" && (prev == nil || prev.output == "") {
+				setCrashOutput("")
+			}
+		}
+	}
+}
+
+// Go runs f in a new goroutine. If f panics, the crash is reported to the
+// handler installed by CaptureCrash before the panic resumes and terminates
+// the program.
+func Go(f func()) {
+	go func() {
+		defer func() {
+			if v := recover(); v != nil {
+				reportCrash(v, debug.Stack())
+				panic(v)
+			}
+		}()
+		f()
+	}()
+}
+
+func reportCrash(v interface{}, stack []byte) {
+	crashHooks.mutex.Lock()
+	defer crashHooks.mutex.Unlock()
+
+	c := crashHooks.config
+	if c == nil {
+		return
+	}
+
+	t := &crashTrace{kind: "panic", message: fmt.Sprint(v)}
+	t.parseGoroutine(strings.Split(string(stack), "\n"))
+
+	// The first frames are the ones of debug.Stack, of the deferred function
+	// of Go, and of the runtime function raising the panic.
+	for i, f := range t.frames {
+		if f.Func == "panic" {
+			t.frames = t.frames[i+1:]
+			break
+		}
+	}
+
+	e := t.event()
+	e.Time = time.Now()
+	e.Args = append(e.Args,
+		Arg{"pid", os.Getpid()},
+		Arg{"program", filepath.Base(os.Args[0])},
+		Arg{"go_version", runtime.Version()},
+		Arg{"uptime", time.Since(processStart)},
+		Arg{"goroutines", runtime.NumGoroutine()},
+	)
+
+	c.handler.HandleEvent(e)
+	flushHandlerTimeout(c.handler, c.timeout)
+}
+
+// readCrashOutput returns the crash event parsed from the file at path, or nil
+// if the file doesn't contain a crash trace.
+func readCrashOutput(path string) *Event {
+	b, err := ioutil.ReadFile(path)
+	if err != nil || len(b) == 0 {
+		return nil
+	}
+
+	e, err := ParsePanicTrace(b)
+
+	if err != nil {
+		// The runtime writes the message of fatal errors to stderr before it
+		// starts writing to the crash output, only the stacks are found in the
+		// file.
+		t := &crashTrace{kind: "fatal error", message: "unknown fatal error"}
+		lines := strings.Split(strings.TrimLeft(string(b), "\n"), "\n")
+
+		if !t.parseGoroutine(lines) {
+			return nil
+		}
+
+		e = t.event()
+	}
+
+	if info, err := os.Stat(path); err == nil {
+		e.Time = info.ModTime()
+	}
+
+	e.Args = append(e.Args, Arg{"previous_run", true})
+	return e
+}
+
+// ParsePanicTrace parses the output of the Go runtime when a program crashes
+// because of a panic or a fatal error, returning an event describing the
+// crash. Lines preceding the trace are ignored.
+//
+// The message of the event is the panic message (the last one when panics
+// were recovered and raised again). The event has the following arguments:
+//
+//	crash       "panic" or "fatal error"
+//	panics      the messages of all panics, when there were more than one
+//	signal      the description of the signal that caused the panic, if any
+//	goroutine   the identifier of the crashed goroutine
+//	stack       the frames of the goroutine, as a []CrashFrame
+//	created_by  the CrashFrame where the goroutine was started, if any
+//
+// The source of the event is the location of the first frame which isn't in
+// the runtime.
+func ParsePanicTrace(b []byte) (*Event, error) {
+	lines := strings.Split(string(bytes.Replace(b, []byte("\r\n"), []byte("\n"), -1)), "\n")
+	start := -1
+
+	for i, line := range lines {
+		if strings.HasPrefix(line, "panic: ") || strings.HasPrefix(line, "fatal error: ") {
+			start = i
+			break
+		}
+	}
+
+	if start < 0 {
+		return nil, ErrNoPanicTrace
+	}
+
+	t := &crashTrace{}
+	lines = lines[start:]
+
+	if strings.HasPrefix(lines[0], "panic: ") {
+		t.kind, t.message = "panic", trimRecovered(lines[0][7:])
+	} else {
+		t.kind, t.message = "fatal error", lines[0][13:]
+	}
+
+	t.panics = []string{t.message}
+	lines = lines[1:]
+
+	for len(lines) != 0 && lines[0] != "" {
+		switch line := lines[0]; {
+		case strings.HasPrefix(line, "\tpanic: "):
+			t.message = trimRecovered(line[8:])
+			t.panics = append(t.panics, t.message)
+		case strings.HasPrefix(line, "[signal ") && strings.HasSuffix(line, "]"):
+			t.signal = line[8 : len(line)-1]
+		default:
+			t.message = trimRecovered(t.message + "\n" + strings.TrimPrefix(line, "\t"))
+			t.panics[len(t.panics)-1] = t.message
+		}
+		lines = lines[1:]
+	}
+
+	if len(t.panics) == 1 {
+		t.panics = nil
+	}
+
+	for len(lines) != 0 && !strings.HasPrefix(lines[0], "goroutine ") {
+		lines = lines[1:]
+	}
+
+	if len(lines) == 0 || !t.parseGoroutine(lines) {
+		return nil, ErrNoPanicTrace
+	}
+
+	return t.event(), nil
+}
+
+// crashTrace is the parsed representation of the trace of a crash.
+type crashTrace struct {
+	kind      string
+	message   string
+	panics    []string
+	signal    string
+	goroutine int
+	frames    []CrashFrame
+	createdBy *CrashFrame
+}
+
+// parseGoroutine parses the stack of the goroutine at the beginning of lines,
+// which must start with the goroutine header.
+func (t *crashTrace) parseGoroutine(lines []string) bool {
+	header := strings.Fields(lines[0])
+
+	if len(header) < 3 || header[0] != "goroutine" {
+		return false
+	}
+
+	id, err := strconv.Atoi(header[1])
+	if err != nil {
+		return false
+	}
+
+	t.goroutine = id
+
+	for i := 1; i+1 < len(lines) && lines[i] != ""; i++ {
+		fn := lines[i]
+
+		if strings.HasPrefix(fn, "...") {
+			continue // "...additional frames elided..."
+		}
+
+		file, line, ok := parseCrashLocation(lines[i+1])
+		if !ok {
+			continue
+		}
+		i++
+
+		if strings.HasPrefix(fn, "created by ") {
+			fn = fn[11:]
+			if j := strings.Index(fn, " in goroutine "); j >= 0 {
+				fn = fn[:j]
+			}
+			t.createdBy = &CrashFrame{Func: fn, File: file, Line: line}
+			continue
+		}
+
+		if strings.HasSuffix(fn, ")") {
+			if j := strings.LastIndexByte(fn, '('); j > 0 {
+				fn = fn[:j]
+			}
+		}
+
+		t.frames = append(t.frames, CrashFrame{Func: fn, File: file, Line: line})
+	}
+
+	return true
+}
+
+// parseCrashLocation parses the location of a frame, written by the runtime as
+// "\t/path/to/file.go:42 +0x1d".
+func parseCrashLocation(s string) (file string, line int, ok bool) {
+	if !strings.HasPrefix(s, "\t") {
+		return
+	}
+
+	s = s[1:]
+
+	if i := strings.Index(s, " +0x"); i >= 0 {
+		s = s[:i]
+	} else if i := strings.Index(s, " fp="); i >= 0 {
+		s = s[:i]
+	}
+
+	i := strings.LastIndexByte(s, ':')
+	if i < 0 {
+		return
+	}
+
+	if line, err := strconv.Atoi(s[i+1:]); err == nil {
+		return s[:i], line, true
+	}
+
+	return
+}
+
+func trimRecovered(s string) string {
+	for _, suffix := range [...]string{" [recovered]", " [recovered, repanicked]"} {
+		s = strings.TrimSuffix(s, suffix)
+	}
+	return s
+}
+
+func (t *crashTrace) event() *Event {
+	e := &Event{
+		Message: t.message,
+		Args:    Args{{"crash", t.kind}},
+	}
+
+	if t.panics != nil {
+		e.Args = append(e.Args, Arg{"panics", t.panics})
+	}
+
+	if t.signal != "" {
+		e.Args = append(e.Args, Arg{"signal", t.signal})
+	}
+
+	e.Args = append(e.Args, Arg{"goroutine", t.goroutine}, Arg{"stack", t.frames})
+
+	if t.createdBy != nil {
+		e.Args = append(e.Args, Arg{"created_by", *t.createdBy})
+	}
+
+	for _, f := range t.frames {
+		if f.Func != "panic" && !strings.HasPrefix(f.Func, "runtime.") {
+			e.Source = trimGOPATH(f.Func, f.File) + ":" + strconv.Itoa(f.Line)
+			break
+		}
+	}
+
+	return e
+}
@@ -0,0 +1,24 @@
+//go:build go1.23
+// +build go1.23
+
+package events
+
+import (
+	"os"
+	"runtime/debug"
+)
+
+func setCrashOutput(path string) error {
+	if path == "" {
+		return debug.SetCrashOutput(nil, debug.CrashOptions{})
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	// The runtime keeps a duplicate of the file descriptor.
+	defer f.Close()
+	return debug.SetCrashOutput(f, debug.CrashOptions{})
+}
@@ -0,0 +1,13 @@
+//go:build !go1.23
+// +build !go1.23
+
+package events
+
+import "errors"
+
+func setCrashOutput(path string) error {
+	if path == "" {
+		return nil
+	}
+	return errors.New("events: the crash output requires Go 1.23 or later")
+}
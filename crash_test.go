@@ -0,0 +1,299 @@
+package events
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParsePanicTrace(t *testing.T) {
+	tests := []struct {
+		file  string
+		event *Event
+	}{
+		{
+			file: "testdata/panic-go1.10-goroutine.txt",
+			event: &Event{
+				Message: "worker failed: connection refused",
+				Source:  "app/worker.go:21",
+				Args: Args{
+					{"crash", "panic"},
+					{"goroutine", 7},
+					{"stack", []CrashFrame{
+						{"main.worker", "/home/user/go/src/example.com/app/worker.go", 21},
+					}},
+					{"created_by", CrashFrame{"main.main", "/home/user/go/src/example.com/app/main.go", 14}},
+				},
+			},
+		},
+		{
+			file: "testdata/panic-go1.18-signal.txt",
+			event: &Event{
+				Message: "runtime error: invalid memory address or nil pointer dereference",
+				Source:  "user/app/server/server.go:33",
+				Args: Args{
+					{"crash", "panic"},
+					{"signal", "SIGSEGV: segmentation violation code=0x1 addr=0x0 pc=0x47e0b6"},
+					{"goroutine", 1},
+					{"stack", []CrashFrame{
+						{"example.com/app/server.(*Server).handle", "/home/user/app/server/server.go", 33},
+						{"main.main", "/home/user/app/main.go", 9},
+					}},
+				},
+			},
+		},
+		{
+			file: "testdata/panic-go1.21-recovered.txt",
+			event: &Event{
+				Message: "second failure",
+				Source:  "app/main.go:27",
+				Args: Args{
+					{"crash", "panic"},
+					{"panics", []string{"first failure", "second failure"}},
+					{"goroutine", 18},
+					{"stack", []CrashFrame{
+						{"main.cleanup", "/home/user/app/main.go", 27},
+						{"panic", "/usr/local/go/src/runtime/panic.go", 914},
+						{"main.run", "/home/user/app/main.go", 33},
+					}},
+					{"created_by", CrashFrame{"main.main", "/home/user/app/main.go", 38}},
+				},
+			},
+		},
+		{
+			file: "testdata/fatal-go1.23-system.txt",
+			event: &Event{
+				Message: "concurrent map writes",
+				Source:  "app/cache.go:18",
+				Args: Args{
+					{"crash", "fatal error"},
+					{"goroutine", 24},
+					{"stack", []CrashFrame{
+						{"runtime.throw", "/usr/local/go/src/runtime/panic.go", 1067},
+						{"runtime.mapassign_faststr", "/usr/local/go/src/runtime/map_faststr.go", 223},
+						{"main.fill", "/home/user/app/cache.go", 18},
+					}},
+					{"created_by", CrashFrame{"main.main", "/home/user/app/main.go", 10}},
+				},
+			},
+		},
+		{
+			file: "testdata/panic-go1.25-repanicked.txt",
+			event: &Event{
+				Message: "invalid configuration:\nmissing \"addr\"\nmissing \"port\"",
+				Source:  "app/config.go:12",
+				Args: Args{
+					{"crash", "panic"},
+					{"goroutine", 1},
+					{"stack", []CrashFrame{
+						{"main.load.func1", "/home/user/app/config.go", 12},
+						{"main.load", "/home/user/app/config.go", 20},
+						{"main.main", "/home/user/app/main.go", 5},
+					}},
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.file, func(t *testing.T) {
+			e, err := ParsePanicTrace(readFixture(t, test.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(e, test.event) {
+				t.Errorf("bad event:\n%#v\n%#v", e, test.event)
+			}
+		})
+	}
+}
+
+func TestParsePanicTraceErrors(t *testing.T) {
+	for _, trace := range []string{
+		"",
+		"hello world\n",
+		"panic: boom\n",
+		"panic: boom\n\nexit status 2\n",
+		"panic: boom\n\ngoroutine X [running]:\nmain.main()\n",
+	} {
+		if _, err := ParsePanicTrace([]byte(trace)); err != ErrNoPanicTrace {
+			t.Errorf("%q: bad error: %v", trace, err)
+		}
+	}
+}
+
+func TestParsePanicTraceCRLF(t *testing.T) {
+	trace := strings.Replace(string(readFixture(t, "testdata/panic-go1.18-signal.txt")), "\n", "\r\n", -1)
+	e, err := ParsePanicTrace([]byte(trace))
+
+	if err != nil || e.Source != "user/app/server/server.go:33" {
+		t.Errorf("bad event: %v (%v)", e, err)
+	}
+}
+
+func TestCaptureCrash(t *testing.T) {
+	r := &eventRecorder{}
+	restore := CaptureCrash(r, CrashTimeout(time.Second))
+
+	func() {
+		defer func() {
+			reportCrash(recover(), debug.Stack())
+		}()
+		panic("boom")
+	}()
+
+	restore()
+
+	e := r.wait(t)
+
+	if e.Message != "boom" || !strings.Contains(e.Source, "crash_test.go:") {
+		t.Errorf("bad crash event: %q %q", e.Message, e.Source)
+	}
+
+	stack, _ := e.Args.Get("stack")
+
+	if frames, ok := stack.([]CrashFrame); !ok || len(frames) == 0 || !strings.HasPrefix(frames[0].Func, "github.com/segmentio/events.TestCaptureCrash") {
+		t.Errorf("bad stack: %v", stack)
+	}
+
+	for _, name := range []string{"crash", "goroutine", "pid", "program", "go_version", "uptime", "goroutines"} {
+		if _, ok := e.Args.Get(name); !ok {
+			t.Error("missing argument:", name)
+		}
+	}
+
+	reportCrash("after restore", debug.Stack())
+
+	if r.len() != 0 {
+		t.Error("a crash was reported after the configuration was restored")
+	}
+}
+
+var crashHelper = flag.String("events.crash-helper", "", "run the crash test helper writing events to the given directory")
+
+// TestCrashHelper is not a real test, it runs in a subprocess started by the
+// other tests of this file.
+func TestCrashHelper(t *testing.T) {
+	if len(*crashHelper) == 0 {
+		t.Skip("only runs as a subprocess")
+	}
+
+	h := NewAsyncHandler(NewFileHandler(filepath.Join(*crashHelper, "events-{2006}.log"), time.Hour, JSONEncoder{}), AsyncConfig{})
+	CaptureCrash(h, CrashOutput(filepath.Join(*crashHelper, "crash.txt")))
+
+	switch os.Getenv("EVENTS_CRASH_MODE") {
+	case "panic":
+		Go(func() { panic("worker failed") })
+	case "fatal":
+		var mutex sync.Mutex
+		mutex.Unlock()
+	case "restart":
+		h.Close()
+		os.Exit(0)
+	}
+
+	time.Sleep(10 * time.Second)
+	os.Exit(0)
+}
+
+func runCrashHelper(t *testing.T, dir string, mode string) (stderr string, code int) {
+	cmd := exec.Command(os.Args[0], "-test.run=^TestCrashHelper$", "-events.crash-helper="+dir)
+	cmd.Env = append(os.Environ(), "EVENTS_CRASH_MODE="+mode)
+
+	b, err := cmd.CombinedOutput()
+
+	if e, ok := err.(*exec.ExitError); ok {
+		code = e.ExitCode()
+	} else if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(b), code
+}
+
+func readCrashEvents(t *testing.T, dir string) []map[string]interface{} {
+	f, err := os.Open(filepath.Join(dir, "events-"+time.Now().UTC().Format("2006")+".log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var list []map[string]interface{}
+
+	for s := bufio.NewScanner(f); s.Scan(); {
+		var m map[string]interface{}
+		if err := json.Unmarshal(s.Bytes(), &m); err != nil {
+			t.Fatal(err)
+		}
+		list = append(list, m)
+	}
+
+	return list
+}
+
+func TestCrashSubprocess(t *testing.T) {
+	dir := t.TempDir()
+	stderr, code := runCrashHelper(t, dir, "panic")
+
+	if code != 2 || !strings.Contains(stderr, "panic: worker failed") {
+		t.Fatalf("bad exit of the subprocess (%d):\n%s", code, stderr)
+	}
+
+	list := readCrashEvents(t, dir)
+
+	if len(list) == 0 {
+		t.Fatal("no crash event was written")
+	}
+
+	e := list[len(list)-1]
+	stack, _ := e["stack"].([]interface{})
+
+	if e["message"] != "worker failed" || e["crash"] != "panic" || len(stack) == 0 {
+		t.Errorf("bad crash event: %v", e)
+	}
+
+	if frame, _ := stack[0].(map[string]interface{}); !strings.Contains(frame["func"].(string), "TestCrashHelper") {
+		t.Errorf("bad first frame: %v", stack[0])
+	}
+}
+
+func TestCrashOutputSubprocess(t *testing.T) {
+	if err := setCrashOutput(""); err != nil {
+		t.Skip(err)
+	}
+
+	dir := t.TempDir()
+
+	if stderr, code := runCrashHelper(t, dir, "fatal"); code != 2 || !strings.Contains(stderr, "fatal error: sync: unlock of unlocked mutex") {
+		t.Fatalf("bad exit of the subprocess (%d):\n%s", code, stderr)
+	}
+
+	if _, code := runCrashHelper(t, dir, "restart"); code != 0 {
+		t.Fatal("bad exit code of the restarted subprocess:", code)
+	}
+
+	list := readCrashEvents(t, dir)
+
+	if len(list) != 1 {
+		t.Fatalf("bad events: %v", list)
+	}
+
+	e := list[0]
+
+	if e["crash"] != "fatal error" || e["previous_run"] != true {
+		t.Errorf("bad crash event: %v", e)
+	}
+
+	if stack, _ := json.Marshal(e["stack"]); !strings.Contains(string(stack), "TestCrashHelper") {
+		t.Errorf("bad stack: %s", stack)
+	}
+}
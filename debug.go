@@ -0,0 +1,130 @@
+package events
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DebugEnabled returns true if debug events are enabled for the whole program,
+// which is the default.
+//
+// When debug events are disabled loggers don't produce events from their Debug
+// methods, regardless of the value of their EnableDebug field.
+func DebugEnabled() bool {
+	return atomic.LoadInt32(&debugDisabled) == 0
+}
+
+// SetDebugEnabled enables or disables debug events for the whole program.
+func SetDebugEnabled(enabled bool) {
+	if enabled {
+		atomic.StoreInt32(&debugDisabled, 0)
+	} else {
+		atomic.StoreInt32(&debugDisabled, 1)
+	}
+}
+
+// DebugToggleOnSignal installs a listener which toggles the program-wide debug
+// state each time sig is received. An event announcing the new state is sent
+// to the default handler on every toggle.
+//
+// Installing multiple listeners for the same signal shares a single goroutine,
+// each arrival of the signal toggles the state only once. The returned function
+// removes the listener, it may safely be called multiple times.
+func DebugToggleOnSignal(sig os.Signal) (stop func()) {
+	var pc [1]uintptr
+	runtime.Callers(2, pc[:])
+	file, line := SourceForPC(pc[0])
+	source := fmt.Sprintf("%s:%d", file, line)
+
+	debugToggles.mutex.Lock()
+	defer debugToggles.mutex.Unlock()
+
+	t := debugToggles.signals[sig]
+
+	if t == nil {
+		t = &debugToggle{
+			sigchan: make(chan os.Signal, 1),
+			done:    make(chan struct{}),
+			exit:    make(chan struct{}),
+		}
+
+		if debugToggles.signals == nil {
+			debugToggles.signals = make(map[os.Signal]*debugToggle)
+		}
+
+		debugToggles.signals[sig] = t
+		signal.Notify(t.sigchan, sig)
+		go t.run(source)
+	}
+
+	t.refs++
+	once := sync.Once{}
+
+	return func() {
+		once.Do(func() { t.release(sig) })
+	}
+}
+
+var debugDisabled int32
+
+var debugToggles struct {
+	mutex   sync.Mutex
+	signals map[os.Signal]*debugToggle
+}
+
+type debugToggle struct {
+	refs    int
+	sigchan chan os.Signal
+	done    chan struct{}
+	exit    chan struct{}
+}
+
+func (t *debugToggle) run(source string) {
+	defer close(t.exit)
+
+	for {
+		select {
+		case sig := <-t.sigchan:
+			enabled := !DebugEnabled()
+			SetDebugEnabled(enabled)
+
+			state := "disabled"
+			if enabled {
+				state = "enabled"
+			}
+
+			DefaultHandler.HandleEvent(&Event{
+				Message: fmt.Sprintf("debug events %s by %s signal", state, sig),
+				Source:  source,
+				Time:    time.Now(),
+				Args:    Args{{"debug", enabled}, {"signal", sig}},
+			})
+
+		case <-t.done:
+			return
+		}
+	}
+}
+
+func (t *debugToggle) release(sig os.Signal) {
+	debugToggles.mutex.Lock()
+
+	if t.refs--; t.refs != 0 {
+		debugToggles.mutex.Unlock()
+		return
+	}
+
+	delete(debugToggles.signals, sig)
+	signal.Stop(t.sigchan)
+	close(t.done)
+	debugToggles.mutex.Unlock()
+
+	// Wait for the goroutine to exit so no toggle can happen after the stop
+	// function returned.
+	<-t.exit
+}
@@ -0,0 +1,141 @@
+package events
+
+import (
+	"os"
+	"reflect"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDebugToggleOnSignal(t *testing.T) {
+	recorder := &eventRecorder{}
+	logger := &Logger{Handler: recorder, EnableDebug: true}
+
+	old := SwapDefaultHandler(recorder)
+	defer SwapDefaultHandler(old)
+	defer SetDebugEnabled(true)
+
+	stop1 := DebugToggleOnSignal(os.Interrupt)
+	stop2 := DebugToggleOnSignal(os.Interrupt)
+	stop2()
+	stop2() // must be idempotent
+	defer stop1()
+
+	p, _ := os.FindProcess(os.Getpid())
+
+	for _, enabled := range []bool{false, true} {
+		p.Signal(os.Interrupt)
+
+		e := recorder.wait(t)
+		if e == nil {
+			return
+		}
+
+		if v, _ := e.Args.Get("debug"); v != enabled {
+			t.Error("bad debug state announced:", v)
+		}
+
+		if DebugEnabled() != enabled {
+			t.Error("bad debug state:", DebugEnabled())
+		}
+
+		logger.Debug("Hello World!")
+
+		if enabled != (recorder.len() == 1) {
+			t.Error("bad debug filtering when debug enabled is", enabled)
+		}
+
+		recorder.reset()
+	}
+}
+
+func TestDebugToggleOnSignalStop(t *testing.T) {
+	n := runtime.NumGoroutine()
+
+	for i := 0; i != 10; i++ {
+		stop := DebugToggleOnSignal(os.Interrupt)
+		stop()
+	}
+
+	if runtime.NumGoroutine() > n {
+		t.Error("goroutines were leaked:", runtime.NumGoroutine(), ">", n)
+	}
+}
+
+func TestDebugToggleEvent(t *testing.T) {
+	recorder := &eventRecorder{}
+
+	old := SwapDefaultHandler(recorder)
+	defer SwapDefaultHandler(old)
+	defer SetDebugEnabled(true)
+
+	stop := DebugToggleOnSignal(os.Interrupt)
+	defer stop()
+
+	p, _ := os.FindProcess(os.Getpid())
+	p.Signal(os.Interrupt)
+
+	e := recorder.wait(t)
+	if e == nil {
+		return
+	}
+
+	if e.Source == "" || e.Time.IsZero() {
+		t.Error("missing source or time in generated event")
+	}
+
+	e.Source, e.Time = "", time.Time{}
+
+	if !reflect.DeepEqual(*e, Event{
+		Message: "debug events disabled by interrupt signal",
+		Args:    Args{{"debug", false}, {"signal", os.Interrupt}},
+	}) {
+		t.Errorf("bad event: %#v", *e)
+	}
+}
+
+// eventRecorder is a handler used in tests to capture events, it is safe to use
+// from multiple goroutines.
+type eventRecorder struct {
+	mutex  sync.Mutex
+	events []*Event
+}
+
+func (r *eventRecorder) HandleEvent(e *Event) {
+	r.mutex.Lock()
+	r.events = append(r.events, e.Clone())
+	r.mutex.Unlock()
+}
+
+func (r *eventRecorder) len() int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return len(r.events)
+}
+
+func (r *eventRecorder) reset() {
+	r.mutex.Lock()
+	r.events = nil
+	r.mutex.Unlock()
+}
+
+// wait waits for the recorder to capture one event and returns it.
+func (r *eventRecorder) wait(t *testing.T) *Event {
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		r.mutex.Lock()
+
+		if len(r.events) != 0 {
+			e := r.events[0]
+			r.events = r.events[1:]
+			r.mutex.Unlock()
+			return e
+		}
+
+		r.mutex.Unlock()
+	}
+
+	t.Error("no event received within 1 second")
+	return nil
+}
@@ -0,0 +1,188 @@
+package events
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultHandler is the default handler used when non is specified.
+//
+// The value forwards events to the handler installed by SwapDefaultHandler,
+// which is safe to call while other goroutines are producing events. Assigning
+// this variable directly is still supported but isn't safe to do concurrently
+// with the use of the default handler.
+var DefaultHandler Handler = defaultHandler{}
+
+// ErrDrainTimeout is returned by Drain when the handler couldn't be flushed and
+// closed within the given timeout.
+var ErrDrainTimeout = errors.New("events: timeout draining handler")
+
+// The Flusher interface may be implemented by handlers that buffer events, the
+// Flush method is expected to block until all buffered events were processed.
+type Flusher interface {
+	Flush() error
+}
+
+// SwapDefaultHandler atomically replaces the handler that DefaultHandler
+// forwards events to by h, returning the previous one. A nil handler is
+// equivalent to Discard.
+//
+// Events that started being handled before the swap may be delivered to either
+// the old or the new handler, events that start after SwapDefaultHandler
+// returned are always delivered to h. Each event is delivered to exactly one of
+// the two.
+func SwapDefaultHandler(h Handler) (old Handler) {
+	if h == nil {
+		h = Discard
+	}
+
+	if _, self := h.(defaultHandler); self {
+		panic("events: cannot set the default handler to itself")
+	}
+
+	defaults.mutex.Lock()
+	b := loadDefault()
+	defaults.value.Store(&defaultBox{handler: h})
+	defaults.retired = append(pruneRetired(defaults.retired), b)
+	defaults.mutex.Unlock()
+
+	return b.handler
+}
+
+// Drain is intended to be called after SwapDefaultHandler to release the old
+// handler. It waits for the events that were being delivered to the previous
+// default handlers to complete, then flushes and closes old and the handlers
+// it broadcasts events to if they implement the Flusher or io.Closer
+// interfaces.
+//
+// The function returns ErrDrainTimeout if it couldn't complete within the
+// given timeout, or the first error returned by a Flush or Close method.
+func Drain(old Handler, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+
+	defaults.mutex.Lock()
+	retired := defaults.retired
+	defaults.retired = nil
+	defaults.mutex.Unlock()
+
+	for _, b := range retired {
+		for atomic.LoadInt64(&b.calls) != 0 {
+			if time.Now().After(deadline) {
+				return ErrDrainTimeout
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	done := make(chan error, 1)
+
+	go func() {
+		err := flushHandler(old)
+		if e := closeHandler(old); err == nil {
+			err = e
+		}
+		done <- err
+	}()
+
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+		return ErrDrainTimeout
+	}
+}
+
+func flushHandler(h Handler) error {
+	if f, ok := h.(Flusher); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+func closeHandler(h Handler) error {
+	if c, ok := h.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// The defaultBox type holds the handler that the default handler forwards
+// events to, and the number of events being delivered to it.
+type defaultBox struct {
+	handler Handler
+	calls   int64
+}
+
+var defaults struct {
+	value   atomic.Value // *defaultBox
+	mutex   sync.Mutex
+	retired []*defaultBox
+}
+
+func init() {
+	defaults.value.Store(&defaultBox{handler: Discard})
+}
+
+func loadDefault() *defaultBox {
+	return defaults.value.Load().(*defaultBox)
+}
+
+func pruneRetired(retired []*defaultBox) []*defaultBox {
+	i := 0
+
+	for _, b := range retired {
+		if atomic.LoadInt64(&b.calls) != 0 {
+			retired[i] = b
+			i++
+		}
+	}
+
+	for j := i; j != len(retired); j++ {
+		retired[j] = nil
+	}
+
+	return retired[:i]
+}
+
+type defaultHandler struct{}
+
+func (defaultHandler) HandleEvent(e *Event) {
+	for {
+		// The call counter is incremented before checking that the handler
+		// hasn't been swapped, this guarantees that Drain either sees the call
+		// in progress or that the call is retried on the new handler.
+		b := loadDefault()
+		atomic.AddInt64(&b.calls, 1)
+
+		if b == loadDefault() {
+			b.handle(e)
+			return
+		}
+
+		atomic.AddInt64(&b.calls, -1)
+	}
+}
+
+// Unwrap returns the handler currently installed as default handler.
+func (defaultHandler) Unwrap() Handler {
+	return loadDefault().handler
+}
+
+// Flush flushes the handler currently installed as default handler.
+func (defaultHandler) Flush() error {
+	return flushHandler(loadDefault().handler)
+}
+
+func (b *defaultBox) handle(e *Event) {
+	defer atomic.AddInt64(&b.calls, -1)
+
+	if _, diag := safeCall(b.handler, func() bool { b.handler.HandleEvent(e); return false }); diag != nil {
+		reportPanic(b.handler, diag)
+	}
+}
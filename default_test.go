@@ -0,0 +1,165 @@
+package events
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type drainHandler struct {
+	count   int64
+	closed  int32
+	flushed int32
+	late    int64
+}
+
+func (h *drainHandler) HandleEvent(e *Event) {
+	if atomic.LoadInt32(&h.closed) != 0 {
+		atomic.AddInt64(&h.late, 1)
+	}
+	atomic.AddInt64(&h.count, 1)
+}
+
+func (h *drainHandler) Flush() error {
+	atomic.StoreInt32(&h.flushed, 1)
+	return nil
+}
+
+func (h *drainHandler) Close() error {
+	atomic.StoreInt32(&h.closed, 1)
+	return nil
+}
+
+func TestSwapDefaultHandler(t *testing.T) {
+	h1 := &drainHandler{}
+	h2 := &drainHandler{}
+
+	old := SwapDefaultHandler(h1)
+	defer SwapDefaultHandler(old)
+
+	DefaultHandler.HandleEvent(&Event{})
+
+	if prev := SwapDefaultHandler(h2); prev != h1 {
+		t.Error("bad handler returned by SwapDefaultHandler:", prev)
+	}
+
+	DefaultHandler.HandleEvent(&Event{})
+	DefaultHandler.HandleEvent(&Event{})
+
+	if err := Drain(h1, time.Second); err != nil {
+		t.Error(err)
+	}
+
+	if h1.count != 1 || h2.count != 2 {
+		t.Error("bad event counts:", h1.count, h2.count)
+	}
+
+	if h1.flushed == 0 || h1.closed == 0 {
+		t.Error("the old handler wasn't flushed and closed")
+	}
+
+	if h2.flushed != 0 || h2.closed != 0 {
+		t.Error("the new handler must not be flushed or closed")
+	}
+}
+
+func TestSwapDefaultHandlerNil(t *testing.T) {
+	old := SwapDefaultHandler(nil)
+	defer SwapDefaultHandler(old)
+
+	// Would panic if the nil handler was installed.
+	DefaultHandler.HandleEvent(&Event{})
+}
+
+func TestDrainTimeout(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+
+	h := HandlerFunc(func(e *Event) { <-block })
+	old := SwapDefaultHandler(h)
+	defer SwapDefaultHandler(old)
+
+	go DefaultHandler.HandleEvent(&Event{})
+
+	// Give the goroutine a chance to enter the handler.
+	time.Sleep(10 * time.Millisecond)
+	SwapDefaultHandler(Discard)
+
+	if err := Drain(h, 10*time.Millisecond); err != ErrDrainTimeout {
+		t.Error("bad error:", err)
+	}
+}
+
+func TestDrainMultiHandler(t *testing.T) {
+	h1 := &drainHandler{}
+	h2 := &drainHandler{}
+
+	if err := Drain(MultiHandler(h1, WithPriority(h2, 1)), time.Second); err != nil {
+		t.Error(err)
+	}
+
+	if h1.closed == 0 || h2.closed == 0 || h1.flushed == 0 || h2.flushed == 0 {
+		t.Error("the handler tree wasn't entirely flushed and closed")
+	}
+}
+
+func TestSwapDefaultHandlerStress(t *testing.T) {
+	const workers = 8
+	const swaps = 2000
+
+	old := SwapDefaultHandler(Discard)
+	defer SwapDefaultHandler(old)
+
+	var handlers []*drainHandler
+	var logged int64
+	var done = make(chan struct{})
+	var wg sync.WaitGroup
+
+	logger := &Logger{Handler: DefaultHandler}
+
+	for i := 0; i != workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+					logger.Log("Hello %{name}s!", "Luke")
+					atomic.AddInt64(&logged, 1)
+				}
+			}
+		}()
+	}
+
+	h := &drainHandler{}
+	handlers = append(handlers, h)
+	SwapDefaultHandler(h)
+
+	for i := 0; i != swaps; i++ {
+		n := &drainHandler{}
+		handlers = append(handlers, n)
+
+		if err := Drain(SwapDefaultHandler(n), time.Second); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	close(done)
+	wg.Wait()
+
+	var count int64
+	for _, h := range handlers {
+		count += atomic.LoadInt64(&h.count)
+
+		if late := atomic.LoadInt64(&h.late); late != 0 {
+			t.Error(late, "events were delivered to a drained handler")
+		}
+	}
+
+	if count != logged {
+		t.Error("events were lost:", logged, "logged but", count, "delivered")
+	}
+}
@@ -0,0 +1,30 @@
+package events
+
+import "time"
+
+// DiagnosticSource is the source of the events that the handlers of this
+// package produce to report on their own activity, like the summaries of
+// dropped events or recovered panics.
+const DiagnosticSource = "events/internal"
+
+// Diagnostic returns a new diagnostic event with the given message and
+// arguments, its source is DiagnosticSource and its time is the current time.
+//
+// The wrappers of this package pass diagnostic events through without counting,
+// deduplicating, aggregating, validating or dropping them, so the reports of a
+// wrapper can't be amplified by flowing back through the same wrappers.
+// Handlers implemented by other packages should do the same, see
+// IsDiagnostic.
+func Diagnostic(message string, args ...Arg) *Event {
+	return &Event{
+		Message: message,
+		Source:  DiagnosticSource,
+		Args:    append(Args(nil), args...),
+		Time:    time.Now(),
+	}
+}
+
+// IsDiagnostic returns true if e is a diagnostic event.
+func IsDiagnostic(e *Event) bool {
+	return e.Source == DiagnosticSource
+}
@@ -0,0 +1,164 @@
+package events
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDiagnostic(t *testing.T) {
+	args := Args{{"a", 1}}
+	e := Diagnostic("hello", args...)
+	args[0].Value = 2
+
+	if e.Message != "hello" || e.Source != DiagnosticSource || e.Time.IsZero() || !IsDiagnostic(e) {
+		t.Errorf("bad diagnostic event: %+v", e)
+	}
+
+	if e.Args[0].Value != 1 {
+		t.Error("the arguments were not copied:", e.Args)
+	}
+
+	if IsDiagnostic(&Event{Message: "hello"}) {
+		t.Error("regular events must not be diagnostics")
+	}
+}
+
+func TestDiagnosticPassThrough(t *testing.T) {
+	tests := []struct {
+		name  string
+		new   func(Handler) Handler
+		check func(*testing.T, Handler)
+	}{
+		{
+			name: "once",
+			new:  func(h Handler) Handler { return NewOnce(h) },
+		},
+		{
+			name: "rate",
+			new:  func(h Handler) Handler { return NewRateTracker(h, time.Minute, 6) },
+			check: func(t *testing.T, h Handler) {
+				if n := h.(*RateTracker).Count(time.Minute); n != 0 {
+					t.Error("diagnostic events were counted:", n)
+				}
+			},
+		},
+		{
+			name: "aggregator",
+			new: func(h Handler) Handler {
+				return NewAggregator(h, time.Hour, AggSpec{Sources: []string{"*"}})
+			},
+		},
+		{
+			name: "collector",
+			new:  func(h Handler) Handler { return NewSchemaCollector(h) },
+			check: func(t *testing.T, h Handler) {
+				if s := h.(*SchemaCollector).Schemas(); len(s) != 0 {
+					t.Error("diagnostic events were collected:", s)
+				}
+			},
+		},
+		{
+			name: "validate",
+			new:  func(h Handler) Handler { return ValidateHandler(h, ValidateStrict) },
+		},
+		{
+			name: "async",
+			new: func(h Handler) Handler {
+				return NewAsyncHandler(h, AsyncConfig{QueueSize: 1, SummaryInterval: time.Hour})
+			},
+			check: func(t *testing.T, h Handler) {
+				if s := h.(*AsyncHandler).Stats(); s.Handled != 0 || s.Dropped() != 0 {
+					t.Errorf("diagnostic events were counted: %+v", s)
+				}
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := &eventRecorder{}
+			h := test.new(r)
+			defer closeHandler(h)
+
+			for i := 0; i != 3; i++ {
+				h.HandleEvent(Diagnostic("A", Arg{"n", 1}))
+			}
+
+			for i := 0; i != 3; i++ {
+				if e := r.wait(t); e == nil || e.Message != "A" || !IsDiagnostic(e) {
+					t.Fatal("bad event:", e)
+				}
+			}
+
+			if test.check != nil {
+				test.check(t, h)
+			}
+		})
+	}
+}
+
+func TestDiagnosticNoAmplification(t *testing.T) {
+	const flood = 1000
+	const injected = 10
+
+	// The sink blocks the async handler on the first event, so the flood fills
+	// the queue and the following events are dropped. Diagnostic events go
+	// straight to the recorder.
+	sink := newGateHandler()
+	block := HandlerFunc(func(e *Event) {
+		if IsDiagnostic(e) {
+			sink.eventRecorder.HandleEvent(e)
+		} else {
+			sink.HandleEvent(e)
+		}
+	})
+
+	async := NewAsyncHandler(block, AsyncConfig{QueueSize: 2, Policy: DropNewest, SummaryInterval: time.Hour})
+	once := NewOnce(async)
+	rate := NewRateTracker(once, time.Hour, 1)
+
+	// Diagnostics reported to the default handler flow back into the chain.
+	old := SwapDefaultHandler(rate)
+	defer SwapDefaultHandler(old)
+
+	rate.HandleEvent(&Event{Message: "flood", Args: Args{{"n", 0}}})
+	sink.waitStarted(t, "flood")
+
+	for i := 1; i != flood; i++ {
+		rate.HandleEvent(&Event{Message: "flood", Args: Args{{"n", i}}})
+
+		if (i+1)%(flood/injected) == 0 {
+			DefaultHandler.HandleEvent(Diagnostic("injected"))
+		}
+	}
+
+	close(sink.release)
+	async.Close()
+
+	var regular, diagnostics, summaries int
+
+	for _, e := range sink.events {
+		switch {
+		case !IsDiagnostic(e):
+			regular++
+		case e.Message == "injected":
+			diagnostics++
+		default:
+			summaries++
+
+			if n := e.Args.Map()["dropped"]; n != int64(flood-3) {
+				t.Error("bad number of dropped events:", n)
+			}
+		}
+	}
+
+	if regular != 3 || diagnostics != injected || summaries != 1 {
+		t.Errorf("bad event counts: regular=%d diagnostics=%d summaries=%d", regular, diagnostics, summaries)
+	}
+
+	if n := rate.Count(time.Hour); n != flood {
+		t.Error("bad rate count:", n)
+	}
+
+	assertStats(t, async.Stats(), AsyncStats{QueueSize: 2, Handled: 3, DroppedNewest: flood - 3})
+}
@@ -0,0 +1,372 @@
+package events
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Diff returns a human-readable representation of the differences between two
+// events, or an empty string if they are equal.
+//
+// The output is similar to a unified diff, lines starting with '-' show the
+// values of want and lines starting with '+' show the values of got, lines
+// starting with a space are equal in both events. Arguments are matched by
+// name (and position for duplicate names), map and slice values are compared
+// element by element.
+func Diff(want *Event, got *Event) string {
+	return DiffOptions{}.Diff(want, got)
+}
+
+// DiffOptions carries options to configure the behavior of event diffs.
+type DiffOptions struct {
+	// TimeTolerance is the maximum difference between two time values for
+	// them to be considered equal, it applies to the event time and to
+	// time.Time argument values.
+	TimeTolerance time.Duration
+}
+
+// Diff is like the package-level Diff function but uses the options set on
+// opts.
+func (opts DiffOptions) Diff(want *Event, got *Event) string {
+	d := &differ{opts: opts}
+
+	if want == nil || got == nil {
+		if want == got {
+			return ""
+		}
+		d.line('-', "", "", formatDiffEvent(want))
+		d.line('+', "", "", formatDiffEvent(got))
+		return d.String()
+	}
+
+	d.field("Message", want.Message, got.Message)
+	d.field("Source", want.Source, got.Source)
+	d.field("Time", want.Time, got.Time)
+	d.field("Debug", want.Debug, got.Debug)
+	d.args(want.Args, got.Args)
+
+	if !d.changed {
+		return ""
+	}
+
+	return d.String()
+}
+
+type differ struct {
+	opts    DiffOptions
+	lines   []string
+	changed bool
+}
+
+func (d *differ) String() string {
+	return "--- want\n+++ got\n" + strings.Join(d.lines, "\n") + "\n"
+}
+
+func (d *differ) line(mark byte, indent string, name string, value string) {
+	if mark != ' ' {
+		d.changed = true
+	}
+
+	switch {
+	case len(name) != 0 && len(value) != 0:
+		name += ": "
+	case len(name) != 0:
+		name += ":"
+	}
+
+	d.lines = append(d.lines, string(mark)+indent+name+value)
+}
+
+func (d *differ) field(name string, want interface{}, got interface{}) {
+	if d.equal(want, got) {
+		d.line(' ', "", name, formatDiffValue(want, got))
+	} else {
+		d.line('-', "", name, formatDiffValue(want, got))
+		d.line('+', "", name, formatDiffValue(got, want))
+	}
+}
+
+func (d *differ) args(want Args, got Args) {
+	d.line(' ', "", "Args", "")
+	used := make([]bool, len(got))
+
+	for i, a := range want {
+		j := matchArg(got, a.Name, countArg(want[:i], a.Name))
+
+		if j < 0 {
+			d.line('-', "  ", a.Name, formatDiffValue(a.Value, nil))
+			continue
+		}
+
+		used[j] = true
+		d.value("  ", a.Name, a.Value, got[j].Value)
+	}
+
+	for j, a := range got {
+		if !used[j] {
+			d.line('+', "  ", a.Name, formatDiffValue(a.Value, nil))
+		}
+	}
+}
+
+func (d *differ) value(indent string, name string, want interface{}, got interface{}) {
+	if d.equal(want, got) {
+		d.line(' ', indent, name, formatDiffValue(want, got))
+		return
+	}
+
+	w := reflect.ValueOf(want)
+	g := reflect.ValueOf(got)
+
+	if w.IsValid() && g.IsValid() && w.Type() == g.Type() {
+		switch w.Kind() {
+		case reflect.Map:
+			d.mapValue(indent, name, w, g)
+			return
+		case reflect.Slice, reflect.Array:
+			d.sliceValue(indent, name, w, g)
+			return
+		}
+	}
+
+	d.line('-', indent, name, formatDiffValue(want, got))
+	d.line('+', indent, name, formatDiffValue(got, want))
+}
+
+func (d *differ) mapValue(indent string, name string, want reflect.Value, got reflect.Value) {
+	keys := want.MapKeys()
+
+	for _, k := range got.MapKeys() {
+		if !want.MapIndex(k).IsValid() {
+			keys = append(keys, k)
+		}
+	}
+
+	sort.Slice(keys, func(i int, j int) bool {
+		return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+	})
+
+	d.line(' ', indent, name, "")
+	indent += "  "
+
+	for _, k := range keys {
+		key := fmt.Sprint(k.Interface())
+		w := want.MapIndex(k)
+		g := got.MapIndex(k)
+
+		switch {
+		case !g.IsValid():
+			d.line('-', indent, key, formatDiffValue(w.Interface(), nil))
+		case !w.IsValid():
+			d.line('+', indent, key, formatDiffValue(g.Interface(), nil))
+		default:
+			d.value(indent, key, w.Interface(), g.Interface())
+		}
+	}
+}
+
+func (d *differ) sliceValue(indent string, name string, want reflect.Value, got reflect.Value) {
+	d.line(' ', indent, name, "")
+	indent += "  "
+
+	for i := 0; i < want.Len() || i < got.Len(); i++ {
+		key := strconv.Itoa(i)
+
+		switch {
+		case i >= got.Len():
+			d.line('-', indent, key, formatDiffValue(want.Index(i).Interface(), nil))
+		case i >= want.Len():
+			d.line('+', indent, key, formatDiffValue(got.Index(i).Interface(), nil))
+		default:
+			d.value(indent, key, want.Index(i).Interface(), got.Index(i).Interface())
+		}
+	}
+}
+
+func (d *differ) equal(want interface{}, got interface{}) bool {
+	if t1, ok := want.(time.Time); ok {
+		if t2, ok := got.(time.Time); ok {
+			delta := t1.Sub(t2)
+			if delta < 0 {
+				delta = -delta
+			}
+			return delta <= d.opts.TimeTolerance
+		}
+	}
+	return reflect.DeepEqual(want, got)
+}
+
+func matchArg(args Args, name string, nth int) int {
+	for i, a := range args {
+		if a.Name == name {
+			if nth == 0 {
+				return i
+			}
+			nth--
+		}
+	}
+	return -1
+}
+
+func countArg(args Args, name string) (n int) {
+	for _, a := range args {
+		if a.Name == name {
+			n++
+		}
+	}
+	return
+}
+
+// formatDiffValue formats v for the diff output, the type of v is added when
+// its representation is the same than other's but they have different types.
+func formatDiffValue(v interface{}, other interface{}) string {
+	s := formatDiffString(v)
+
+	if other != nil && v != nil && reflect.TypeOf(v) != reflect.TypeOf(other) && s == formatDiffString(other) {
+		s += fmt.Sprintf(" (%T)", v)
+	}
+
+	return s
+}
+
+func formatDiffString(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return "nil"
+	case string:
+		return strconv.Quote(x)
+	case error:
+		return "error(" + strconv.Quote(x.Error()) + ")"
+	case time.Time:
+		return x.Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+func formatDiffEvent(e *Event) string {
+	if e == nil {
+		return "nil"
+	}
+	return fmt.Sprintf("%+v", *e)
+}
+
+// DiffArgs returns the list of arguments describing the changes between
+// before and after, which is empty if they carry the same values:
+//
+//	changed.<name>.old: value of an argument in before, when it changed
+//	changed.<name>.new: value of the argument in after
+//	added.<name>:       value of an argument only present in after
+//	removed.<name>:     value of an argument only present in before
+//
+// Arguments are matched by name and, like Map, the last argument wins when
+// names are repeated. Values are compared like Args.Equal does, values of
+// different types are always different. Maps of the same type are compared one
+// level deep, the changes of their entries are named <name>.<key>.
+//
+// The changed and removed arguments are listed in the order of before,
+// followed by the added arguments in the order of after.
+func DiffArgs(before Args, after Args) Args {
+	delta, _ := diffArgs(before, after)
+	return delta
+}
+
+// LogChange emits an event with the given message carrying the arguments
+// returned by DiffArgs, followed by a "changes" argument set to the number of
+// added, removed and changed values. No event is emitted if no values changed.
+// If h is nil the event is sent to the default handler.
+func LogChange(h Handler, message string, before Args, after Args) {
+	delta, changes := diffArgs(before, after)
+
+	if changes == 0 {
+		return
+	}
+
+	if h == nil {
+		h = DefaultHandler
+	}
+
+	h.HandleEvent(&Event{
+		Message: message,
+		Args:    append(delta, Arg{"changes", changes}),
+		Time:    time.Now(),
+	})
+}
+
+func diffArgs(before Args, after Args) (delta Args, changes int) {
+	b := before.Map()
+	a := after.Map()
+
+	for i, arg := range before {
+		if matchArg(before[i+1:], arg.Name, 0) >= 0 {
+			continue // the last value of the name is used
+		}
+
+		if v, ok := a[arg.Name]; ok {
+			delta, changes = diffArgValue(delta, changes, arg.Name, arg.Value, v, true)
+		} else {
+			delta, changes = append(delta, Arg{"removed." + arg.Name, arg.Value}), changes+1
+		}
+	}
+
+	for i, arg := range after {
+		if _, ok := b[arg.Name]; !ok && matchArg(after[i+1:], arg.Name, 0) < 0 {
+			delta, changes = append(delta, Arg{"added." + arg.Name, arg.Value}), changes+1
+		}
+	}
+
+	return
+}
+
+func diffArgValue(delta Args, changes int, name string, before interface{}, after interface{}, nested bool) (Args, int) {
+	if equalArgValues(before, after) {
+		return delta, changes
+	}
+
+	o := reflect.ValueOf(before)
+	n := reflect.ValueOf(after)
+
+	if nested && o.IsValid() && n.IsValid() && o.Type() == n.Type() && o.Kind() == reflect.Map {
+		return diffArgMap(delta, changes, name, o, n)
+	}
+
+	return append(delta,
+		Arg{"changed." + name + ".old", before},
+		Arg{"changed." + name + ".new", after},
+	), changes + 1
+}
+
+func diffArgMap(delta Args, changes int, name string, before reflect.Value, after reflect.Value) (Args, int) {
+	keys := before.MapKeys()
+
+	for _, k := range after.MapKeys() {
+		if !before.MapIndex(k).IsValid() {
+			keys = append(keys, k)
+		}
+	}
+
+	sort.Slice(keys, func(i int, j int) bool {
+		return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+	})
+
+	for _, k := range keys {
+		key := name + "." + fmt.Sprint(k.Interface())
+		o := before.MapIndex(k)
+		n := after.MapIndex(k)
+
+		switch {
+		case !n.IsValid():
+			delta, changes = append(delta, Arg{"removed." + key, o.Interface()}), changes+1
+		case !o.IsValid():
+			delta, changes = append(delta, Arg{"added." + key, n.Interface()}), changes+1
+		default:
+			delta, changes = diffArgValue(delta, changes, key, o.Interface(), n.Interface(), false)
+		}
+	}
+
+	return delta, changes
+}
@@ -0,0 +1,233 @@
+package events
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDiff(t *testing.T) {
+	t0 := time.Date(2017, 1, 1, 23, 42, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		opts DiffOptions
+		want *Event
+		got  *Event
+		diff string
+	}{
+		{
+			name: "equal",
+			want: &Event{Message: "Hello", Args: Args{{"name", "Luke"}}, Time: t0},
+			got:  &Event{Message: "Hello", Args: Args{{"name", "Luke"}}, Time: t0},
+			diff: "",
+		},
+		{
+			name: "nil",
+			want: &Event{Message: "Hello"},
+			got:  nil,
+			diff: `--- want
++++ got
+-{Message:Hello Source: Args:[] Time:0001-01-01 00:00:00 +0000 UTC Debug:false}
++nil
+`,
+		},
+		{
+			name: "fields",
+			want: &Event{Message: "Hello Luke!", Source: "a.go:1", Time: t0},
+			got:  &Event{Message: "Hello Han!", Source: "a.go:1", Time: t0.Add(time.Second), Debug: true},
+			diff: `--- want
++++ got
+-Message: "Hello Luke!"
++Message: "Hello Han!"
+ Source: "a.go:1"
+-Time: 2017-01-01T23:42:00Z
++Time: 2017-01-01T23:42:01Z
+-Debug: false
++Debug: true
+ Args:
+`,
+		},
+		{
+			name: "time tolerance",
+			opts: DiffOptions{TimeTolerance: time.Second},
+			want: &Event{Time: t0, Args: Args{{"at", t0}}},
+			got:  &Event{Time: t0.Add(time.Second), Args: Args{{"at", t0.Add(-time.Second)}}},
+			diff: "",
+		},
+		{
+			name: "args",
+			want: &Event{Message: "Hello", Args: Args{
+				{"name", "Luke"},
+				{"from", "Han"},
+				{"answer", 42},
+				{"tag", "A"},
+				{"tag", "B"},
+				{"error", errors.New("oops")},
+			}},
+			got: &Event{Message: "Hello", Args: Args{
+				{"name", "Luke"},
+				{"from", "Leia"},
+				{"tag", "A"},
+				{"question", "how are you?"},
+				{"answer", int64(42)},
+				{"error", errors.New("oops")},
+			}},
+			diff: `--- want
++++ got
+ Message: "Hello"
+ Source: ""
+ Time: 0001-01-01T00:00:00Z
+ Debug: false
+ Args:
+   name: "Luke"
+-  from: "Han"
++  from: "Leia"
+-  answer: 42 (int)
++  answer: 42 (int64)
+   tag: "A"
+-  tag: "B"
+   error: error("oops")
++  question: "how are you?"
+`,
+		},
+		{
+			name: "deep",
+			want: &Event{Args: Args{
+				{"user", map[string]interface{}{"name": "Luke", "age": 19, "ship": "X-Wing"}},
+				{"list", []int{1, 2, 3}},
+			}},
+			got: &Event{Args: Args{
+				{"user", map[string]interface{}{"name": "Luke", "age": 20, "planet": "Tatooine"}},
+				{"list", []int{1, 3}},
+			}},
+			diff: `--- want
++++ got
+ Message: ""
+ Source: ""
+ Time: 0001-01-01T00:00:00Z
+ Debug: false
+ Args:
+   user:
+-    age: 19
++    age: 20
+     name: "Luke"
++    planet: "Tatooine"
+-    ship: "X-Wing"
+   list:
+     0: 1
+-    1: 2
++    1: 3
+-    2: 3
+`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if diff := test.opts.Diff(test.want, test.got); diff != test.diff {
+				t.Errorf("\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestDiffArgs(t *testing.T) {
+	t0 := time.Date(2017, 1, 1, 23, 42, 0, 0, time.UTC)
+
+	before := Args{
+		{"host", "localhost"},
+		{"port", 8080},
+		{"timeout", time.Second},
+		{"started", t0},
+		{"debug", true},
+		{"limits", map[string]int{"conns": 10, "rps": 100, "burst": 5}},
+		{"tags", []string{"a", "b"}},
+	}
+
+	after := Args{
+		{"host", "localhost"},
+		{"port", "8080"},
+		{"timeout", 2 * time.Second},
+		{"started", t0.In(time.FixedZone("UTC+1", 3600))},
+		{"limits", map[string]int{"conns": 20, "rps": 100, "queue": 50}},
+		{"tags", []string{"a", "c"}},
+		{"region", "us-west-2"},
+	}
+
+	want := Args{
+		{"changed.port.old", 8080},
+		{"changed.port.new", "8080"},
+		{"changed.timeout.old", time.Second},
+		{"changed.timeout.new", 2 * time.Second},
+		{"removed.debug", true},
+		{"removed.limits.burst", 5},
+		{"changed.limits.conns.old", 10},
+		{"changed.limits.conns.new", 20},
+		{"added.limits.queue", 50},
+		{"changed.tags.old", []string{"a", "b"}},
+		{"changed.tags.new", []string{"a", "c"}},
+		{"added.region", "us-west-2"},
+	}
+
+	if delta := DiffArgs(before, after); !delta.Equal(want) {
+		t.Errorf("bad diff:\n%v\n%v", delta, want)
+	}
+
+	if delta := DiffArgs(before, before); delta != nil {
+		t.Error("equal lists must have no differences:", delta)
+	}
+}
+
+func TestDiffArgsNested(t *testing.T) {
+	before := Args{
+		{"db", map[string]interface{}{"pool": map[string]int{"size": 1}}},
+		{"cache", map[string]int{"ttl": 1}},
+		{"name", "A"},
+		{"name", "B"},
+	}
+
+	after := Args{
+		{"db", map[string]interface{}{"pool": map[string]int{"size": 2}}},
+		{"cache", map[string]interface{}{"ttl": 1}},
+		{"name", "B"},
+	}
+
+	// Maps are compared one level deep, and maps of different types are
+	// compared as a whole.
+	want := Args{
+		{"changed.db.pool.old", map[string]int{"size": 1}},
+		{"changed.db.pool.new", map[string]int{"size": 2}},
+		{"changed.cache.old", map[string]int{"ttl": 1}},
+		{"changed.cache.new", map[string]interface{}{"ttl": 1}},
+	}
+
+	if delta := DiffArgs(before, after); !delta.Equal(want) {
+		t.Errorf("bad diff:\n%v\n%v", delta, want)
+	}
+}
+
+func TestLogChange(t *testing.T) {
+	r := &eventRecorder{}
+	config := Args{{"port", 8080}, {"hosts", map[string]bool{"a": true}}}
+
+	LogChange(r, "config reloaded", config, Args{{"port", 8080}, {"hosts", map[string]bool{"a": true}}})
+
+	if n := r.len(); n != 0 {
+		t.Error("no event must be emitted when nothing changed:", n)
+	}
+
+	LogChange(r, "config reloaded", config, Args{{"port", 9090}, {"hosts", map[string]bool{"b": true}}})
+
+	want := Args{
+		{"changed.port.old", 8080},
+		{"changed.port.new", 9090},
+		{"removed.hosts.a", true},
+		{"added.hosts.b", true},
+		{"changes", 3},
+	}
+
+	if e := r.wait(t); e.Message != "config reloaded" || !e.Args.Equal(want) || e.Time.IsZero() {
+		t.Errorf("bad event: %+v", e)
+	}
+}
@@ -0,0 +1,96 @@
+package events
+
+// DuplicatePolicy values configure how loggers handle the arguments of the
+// same name that an event receives from the logger, the active scopes and the
+// log call.
+type DuplicatePolicy int
+
+const (
+	// KeepAll keeps all the arguments, this is the default.
+	KeepAll DuplicatePolicy = iota
+
+	// KeepFirst keeps only the first argument of each name.
+	KeepFirst
+
+	// KeepLast keeps only the value of the last argument of each name, at the
+	// position of the first one.
+	KeepLast
+
+	// DuplicateError is like KeepLast but also adds a duplicate_arg argument
+	// to the event for each name that had duplicates, so the call sites
+	// producing them can be found.
+	DuplicateError
+)
+
+// duplicateArgsMapThreshold is the number of arguments above which duplicates
+// are detected with a map instead of comparing arguments with each other.
+const duplicateArgsMapThreshold = 32
+
+// dedupArgs applies policy to args, which is modified in place, and returns the
+// resulting argument list.
+func dedupArgs(args Args, policy DuplicatePolicy) Args {
+	if policy == KeepAll || len(args) < 2 {
+		return args
+	}
+
+	var names [4]string
+	var dups = names[:0]
+	var index map[string]int
+	var out = args[:0]
+
+	if len(args) > duplicateArgsMapThreshold {
+		index = make(map[string]int, len(args))
+	}
+
+	for _, a := range args {
+		j := -1
+
+		if index != nil {
+			if i, ok := index[a.Name]; ok {
+				j = i
+			} else {
+				index[a.Name] = len(out)
+			}
+		} else {
+			for i := range out {
+				if out[i].Name == a.Name {
+					j = i
+					break
+				}
+			}
+		}
+
+		if j < 0 {
+			out = append(out, a)
+			continue
+		}
+
+		if policy != KeepFirst {
+			out[j].Value = a.Value
+		}
+
+		if policy == DuplicateError && !containsString(dups, a.Name) {
+			dups = append(dups, a.Name)
+		}
+	}
+
+	// Clear the tail so the dropped values can be garbage collected.
+	for i := len(out); i != len(args); i++ {
+		args[i] = Arg{}
+	}
+
+	for _, name := range dups {
+		out = append(out, Arg{"duplicate_arg", name})
+	}
+
+	return out
+}
+
+func containsString(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
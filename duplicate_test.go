@@ -0,0 +1,87 @@
+package events
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestLoggerDuplicatePolicy(t *testing.T) {
+	tests := []struct {
+		policy DuplicatePolicy
+		args   Args
+	}{
+		{
+			policy: KeepAll,
+			args:   Args{{"user", "bound"}, {"id", 1}, {"user", "scope"}, {"user", "format"}, {"id", 2}, {"user", "call"}},
+		},
+		{
+			policy: KeepFirst,
+			args:   Args{{"user", "bound"}, {"id", 1}},
+		},
+		{
+			policy: KeepLast,
+			args:   Args{{"user", "call"}, {"id", 2}},
+		},
+		{
+			policy: DuplicateError,
+			args:   Args{{"user", "call"}, {"id", 2}, {"duplicate_arg", "user"}, {"duplicate_arg", "id"}},
+		},
+	}
+
+	for _, test := range tests {
+		r := &eventRecorder{}
+		l := (&Logger{Handler: r, DuplicatePolicy: test.policy}).With(Args{{"user", "bound"}, {"id", 1}})
+
+		pop := PushScope(Arg{"user", "scope"})
+		l.Log("hello %{user}s", "format", Args{{"id", 2}, {"user", "call"}})
+		pop()
+
+		if e := r.wait(t); !reflect.DeepEqual(e.Args, test.args) {
+			t.Errorf("policy %d:\n- %v\n+ %v", test.policy, test.args, e.Args)
+		}
+	}
+}
+
+func TestDedupArgs(t *testing.T) {
+	// Large lists are deduplicated with a map, the results must be the same.
+	for _, n := range []int{4, 100} {
+		var args Args
+
+		for i := 0; i != n; i++ {
+			args = append(args, Arg{fmt.Sprint(i % (n / 2)), i})
+		}
+
+		out := dedupArgs(append(Args(nil), args...), KeepLast)
+
+		if len(out) != n/2 {
+			t.Errorf("%d: bad number of arguments: %d", n, len(out))
+			continue
+		}
+
+		for i, a := range out {
+			if a.Name != fmt.Sprint(i) || a.Value != i+n/2 {
+				t.Errorf("%d: bad argument at index %d: %v", n, i, a)
+				break
+			}
+		}
+	}
+
+	// Events built without a logger are not modified.
+	e := &Event{Args: Args{{"a", 1}, {"a", 2}}}
+	if c := e.Clone(); len(c.Args) != 2 {
+		t.Error("the arguments were modified:", c.Args)
+	}
+}
+
+func BenchmarkDedupArgs(b *testing.B) {
+	args := Args{{"user", 1}, {"id", 2}, {"host", 3}, {"user", 4}, {"path", 5}}
+	tmp := make(Args, len(args))
+
+	b.ReportAllocs()
+
+	for i := 0; i != b.N; i++ {
+		copy(tmp, args)
+		dedupArgs(tmp, DuplicateError)
+	}
+}
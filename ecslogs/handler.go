@@ -24,6 +24,10 @@ type Handler struct {
 	Program string
 	Pid     int
 
+	// Bytes is the format of byte slices in the event arguments, which are
+	// encoded as strings. It defaults to hexadecimal.
+	Bytes events.BytesFormat
+
 	// synchronizes writes to the output
 	mutex sync.Mutex
 }
@@ -38,6 +42,28 @@ func NewHandler(output io.Writer) *Handler {
 // HandleEvent satisfies the events.Handler interface.
 func (h *Handler) HandleEvent(e *events.Event) {
 	f := fmtPool.Get().(*formatter)
+	h.encode(f, e)
+
+	h.mutex.Lock()
+	h.Output.Write(f.buffer.b)
+	h.mutex.Unlock()
+
+	fmtPool.Put(f)
+}
+
+// EncodeEvent satisfies the events.Encoder interface, it appends to dst the
+// ecslogs representation of e. The Output field is not used, so a Handler value
+// can be used as an encoder for other handlers (see events.NewWriterHandler).
+func (h *Handler) EncodeEvent(dst []byte, e *events.Event) ([]byte, error) {
+	f := fmtPool.Get().(*formatter)
+	err := h.encode(f, e)
+	dst = append(dst, f.buffer.b...)
+	fmtPool.Put(f)
+	return dst, err
+}
+
+// encode formats e to the buffer of f.
+func (h *Handler) encode(f *formatter, e *events.Event) error {
 	f.buffer.Reset()
 	f.emitter.Reset(&f.buffer)
 
@@ -45,6 +71,7 @@ func (h *Handler) HandleEvent(e *events.Event) {
 	f.time = e.Time
 	f.message = e.Message
 	f.data.args = e.Args
+	f.data.bytes = h.Bytes
 	f.info.Source = e.Source
 	f.info.Program = h.Program
 	f.info.Pid = h.Pid
@@ -60,16 +87,12 @@ func (h *Handler) HandleEvent(e *events.Event) {
 		}
 	}
 
-	(objconv.Encoder{Emitter: &f.emitter}).Encode(f.value)
+	err := (objconv.Encoder{Emitter: &f.emitter}).Encode(f.value)
 	f.buffer.WriteByte('\n')
 
-	h.mutex.Lock()
-	h.Output.Write(f.buffer.b)
-	h.mutex.Unlock()
-
 	f.info.Source = ""
 	f.info.Errors = f.info.Errors[:0]
-	fmtPool.Put(f)
+	return err
 }
 
 type event struct {
@@ -101,6 +124,10 @@ func makeEventError(err error) eventError {
 	var errno = 0
 	var stack stackTrace
 
+	if f, ok := err.(events.Formatter); ok {
+		error = f.FormatEventValue()
+	}
+
 	if se, ok := cause.(syscall.Errno); ok {
 		errno = int(se)
 	}
@@ -118,7 +145,9 @@ func makeEventError(err error) eventError {
 }
 
 type eventData struct {
-	args events.Args
+	args  events.Args
+	bytes events.BytesFormat
+	tmp   []byte
 }
 
 func (data *eventData) EncodeValue(e objconv.Encoder) error {
@@ -129,7 +158,15 @@ func (data *eventData) EncodeValue(e objconv.Encoder) error {
 			if err = k.Encode(&data.args[i].Name); err != nil {
 				return
 			}
-			if err = v.Encode(&data.args[i].Value); err != nil {
+			if b, ok := events.AppendBytesValue(data.tmp[:0], data.args[i].Value, data.bytes); ok {
+				data.tmp = b
+				err = v.Encode(string(b))
+			} else if f, ok := data.args[i].Value.(events.Formatter); ok {
+				err = v.Encode(f.FormatEventValue())
+			} else {
+				err = v.Encode(&data.args[i].Value)
+			}
+			if err != nil {
 				return
 			}
 			i = data.next(i + 1)
@@ -2,8 +2,10 @@ package ecslogs
 
 import (
 	"bytes"
+	"fmt"
 	"io"
 	"io/ioutil"
+	"strings"
 	"testing"
 	"time"
 
@@ -54,6 +56,70 @@ func TestHandler(t *testing.T) {
 	})
 }
 
+type userID int
+
+func (id userID) FormatEventValue() string { return fmt.Sprintf("user-%d", int(id)) }
+
+type formatterError struct{}
+
+func (formatterError) Error() string            { return "error" }
+func (formatterError) FormatEventValue() string { return "formatter" }
+
+func TestHandlerFormatter(t *testing.T) {
+	b := &bytes.Buffer{}
+	h := NewHandler(b)
+	h.HandleEvent(&events.Event{
+		Message: "Hello Luke!",
+		Args:    events.Args{{"id", userID(42)}, {"error", formatterError{}}},
+		Time:    time.Date(2017, 1, 1, 23, 42, 0, 123000000, time.UTC),
+	})
+
+	const ref = `{"level":"ERROR","time":"2017-01-01T23:42:00.123Z","info":{"errors":[{"type":"ecslogs.formatterError","error":"formatter"}]},"data":{"id":"user-42"},"message":"Hello Luke!"}
+`
+
+	if s := b.String(); s != ref {
+		t.Error("bad event:")
+		t.Log("expected:", ref)
+		t.Log("found:   ", s)
+	}
+}
+
+func TestHandlerEncodeEvent(t *testing.T) {
+	b := &bytes.Buffer{}
+	h := NewHandler(b)
+	e := &events.Event{
+		Message: "Hello Luke!",
+		Args:    events.Args{{"name", "Luke"}},
+		Time:    time.Date(2017, 1, 1, 23, 42, 0, 123000000, time.UTC),
+	}
+
+	h.HandleEvent(e)
+	dst, err := h.EncodeEvent([]byte("prefix:"), e)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s := string(dst); s != "prefix:"+b.String() {
+		t.Error("bad encoding:", s)
+	}
+}
+
+func TestHandlerBytes(t *testing.T) {
+	b := &bytes.Buffer{}
+	h := NewHandler(b)
+	h.Bytes = events.BytesBase64
+
+	h.HandleEvent(&events.Event{
+		Message: "checksum",
+		Args:    events.Args{{"raw", []byte{0xde, 0xad, 0xbe, 0xef}}, {"hex", events.Bytes([]byte{1}).Hex()}},
+	})
+
+	if s := b.String(); !strings.Contains(s, `"raw":"3q2+7w=="`) || !strings.Contains(s, `"hex":"01"`) {
+		t.Error("bad encoding of byte slices:", s)
+	}
+}
+
 func BenchmarkHandler(b *testing.B) {
 	h := NewHandler(ioutil.Discard)
 	e := &events.Event{
@@ -9,10 +9,10 @@ import (
 
 func init() {
 	if !events.IsTerminal(1) {
-		events.DefaultHandler = &Handler{
+		events.SwapDefaultHandler(&Handler{
 			Output:  os.Stdout,
 			Program: filepath.Base(os.Args[0]),
 			Pid:     os.Getpid(),
-		}
+		})
 	}
 }
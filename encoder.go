@@ -0,0 +1,302 @@
+package events
+
+import (
+	"io"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+)
+
+// The Encoder interface is implemented by the types which serialize events to
+// a specific format.
+//
+// EncodeEvent appends the representation of e to dst and returns the extended
+// buffer. The representation must be a complete record, including the line
+// terminator of line-oriented formats, so the output of multiple calls can be
+// written one after the other. Encoders must not retain dst after returning.
+type Encoder interface {
+	EncodeEvent(dst []byte, e *Event) ([]byte, error)
+}
+
+// EncoderFunc makes it possible for simple function types to be used as
+// encoders.
+type EncoderFunc func([]byte, *Event) ([]byte, error)
+
+// EncodeEvent calls f.
+func (f EncoderFunc) EncodeEvent(dst []byte, e *Event) ([]byte, error) {
+	return f(dst, e)
+}
+
+// JSONEncoder encodes events as JSON objects, one per line, in the format
+// understood by ParseJSONLine. The time, level, source and message of events
+// are encoded first, followed by the event arguments in the order they appear
+// in the list, encoded like Args.MarshalJSON does.
+type JSONEncoder struct {
+	// Bytes is the format of byte slices, which are encoded as JSON strings.
+	// It defaults to hexadecimal.
+	Bytes BytesFormat
+
+	// SortArgs may be set to true to encode the event arguments in the order
+	// of their names (see Args.SortedIndex) instead of the order they appear
+	// in the list.
+	SortArgs bool
+
+	// OmitNil and OmitZero may be set to true to skip the arguments with nil or
+	// zero values (see OmitValue). Values wrapped with Keep are always encoded.
+	OmitNil  bool
+	OmitZero bool
+}
+
+// EncodeEvent satisfies the Encoder interface.
+func (enc JSONEncoder) EncodeEvent(dst []byte, e *Event) ([]byte, error) {
+	level := "info"
+	if e.Debug {
+		level = "debug"
+	}
+
+	dst = append(dst, '{')
+
+	if !e.Time.IsZero() {
+		dst = append(dst, `"time":"`...)
+		dst = e.Time.AppendFormat(dst, time.RFC3339Nano)
+		dst = append(dst, `",`...)
+	}
+
+	dst = append(dst, `"level":"`...)
+	dst = append(dst, level...)
+	dst = append(dst, '"')
+
+	if len(e.Source) != 0 {
+		dst = append(dst, `,"source":`...)
+		dst = appendJSONString(dst, e.Source)
+	}
+
+	dst = append(dst, `,"message":`...)
+	dst = appendJSONString(dst, e.Message)
+
+	var order []int
+	if enc.SortArgs && len(e.Args) > 1 {
+		order = e.Args.SortedIndex()
+	}
+
+	for i := range e.Args {
+		var err error
+		a := argAt(e.Args, order, i)
+
+		v, omit := OmitValue(a.Value, enc.OmitNil, enc.OmitZero)
+		if omit {
+			continue
+		}
+
+		dst = append(dst, ',')
+		dst = appendJSONString(dst, a.Name)
+		dst = append(dst, ':')
+
+		if dst, err = appendJSONValue(dst, v, enc.Bytes); err != nil {
+			return dst, err
+		}
+	}
+
+	return append(dst, '}', '\n'), nil
+}
+
+// argAt returns the i-th argument of args in the given order, or in the order
+// of the list if order is nil.
+func argAt(args Args, order []int, i int) Arg {
+	if order != nil {
+		i = order[i]
+	}
+	return args[i]
+}
+
+func appendJSONValue(b []byte, v interface{}, format BytesFormat) ([]byte, error) {
+	if b, ok := appendJSONBytes(b, v, format); ok {
+		return b, nil
+	}
+
+	switch x := v.(type) {
+	case nil:
+		return append(b, "null"...), nil
+	case string:
+		return appendJSONString(b, x), nil
+	case bool:
+		return strconv.AppendBool(b, x), nil
+	case int:
+		return strconv.AppendInt(b, int64(x), 10), nil
+	case int64:
+		return strconv.AppendInt(b, x, 10), nil
+	case uint64:
+		return strconv.AppendUint(b, x, 10), nil
+	}
+
+	j, err := marshalJSONValue(v)
+	return append(b, j...), err
+}
+
+// appendJSONString appends s as a JSON string to b, invalid UTF-8 sequences are
+// replaced with the unicode replacement character.
+func appendJSONString(b []byte, s string) []byte {
+	const hex = "0123456789abcdef"
+	b = append(b, '"')
+
+	for i := 0; i < len(s); {
+		c := s[i]
+
+		if c < utf8.RuneSelf {
+			switch {
+			case c == '"' || c == '\\':
+				b = append(b, '\\', c)
+			case c == '\n':
+				b = append(b, '\\', 'n')
+			case c == '\r':
+				b = append(b, '\\', 'r')
+			case c == '\t':
+				b = append(b, '\\', 't')
+			case c < ' ' || c == 0x7f:
+				b = append(b, '\\', 'u', '0', '0', hex[c>>4], hex[c&0xf])
+			default:
+				b = append(b, c)
+			}
+			i++
+			continue
+		}
+
+		r, n := utf8.DecodeRuneInString(s[i:])
+
+		switch {
+		case r == utf8.RuneError && n == 1:
+			b = append(b, "\ufffd"...)
+		case r == '\u2028' || r == '\u2029':
+			// Valid JSON, but not valid in JavaScript strings.
+			b = append(b, '\\', 'u', '2', '0', '2', hex[r&0xf])
+		default:
+			b = append(b, s[i:i+n]...)
+		}
+
+		i += n
+	}
+
+	return append(b, '"')
+}
+
+// WriterHandler is a handler which encodes events with an Encoder and writes
+// them to an io.Writer, each event is written with a single call to Write.
+//
+// Events that fail to be encoded are dropped, the encoding and write errors
+// are counted and may be retrieved with the Errors method.
+//
+// It is safe to use a writer handler concurrently from multiple goroutines.
+type WriterHandler struct {
+	errors  int64 // accessed atomically
+	mutex   sync.Mutex
+	output  io.Writer
+	encoder Encoder
+
+	// times of the last write and of the last error, and the last error,
+	// protected by the mutex
+	lastWrite time.Time
+	lastError time.Time
+	err       error
+}
+
+// NewWriterHandler returns a WriterHandler which writes events to w in the
+// format of enc.
+func NewWriterHandler(w io.Writer, enc Encoder) *WriterHandler {
+	return &WriterHandler{
+		output:  w,
+		encoder: enc,
+	}
+}
+
+// HandleEvent satisfies the Handler interface.
+func (h *WriterHandler) HandleEvent(e *Event) {
+	buf := encoderBufferPool.Get().(*encoderBuffer)
+	b, err := h.encoder.EncodeEvent(buf.b[:0], e)
+
+	h.mutex.Lock()
+
+	if err == nil {
+		_, err = h.output.Write(b)
+	}
+
+	if err != nil {
+		h.lastError, h.err = time.Now(), err
+		atomic.AddInt64(&h.errors, 1)
+	} else {
+		h.lastWrite = time.Now()
+	}
+
+	h.mutex.Unlock()
+
+	if cap(b) <= maxEncoderBufferSize {
+		buf.b = b[:0]
+	}
+
+	encoderBufferPool.Put(buf)
+}
+
+// Errors returns the number of events that couldn't be encoded or written.
+func (h *WriterHandler) Errors() int64 {
+	return atomic.LoadInt64(&h.errors)
+}
+
+// EventHealth satisfies the HealthReporter interface. The handler is degraded
+// when the last event failed to be encoded or written.
+func (h *WriterHandler) EventHealth() Health {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	health := Health{
+		Errors:    atomic.LoadInt64(&h.errors),
+		LastWrite: h.lastWrite,
+		LastError: h.lastError,
+	}
+
+	if h.err != nil && !h.lastError.Before(h.lastWrite) {
+		health.Status, health.Message = Degraded, h.err.Error()
+	}
+
+	return health
+}
+
+// Flush flushes the output of the handler if it has a Flush method (like
+// *bufio.Writer).
+func (h *WriterHandler) Flush() error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if f, ok := h.output.(interface {
+		Flush() error
+	}); ok {
+		return f.Flush()
+	}
+
+	return nil
+}
+
+// Close flushes the handler and closes its output if it implements io.Closer.
+func (h *WriterHandler) Close() error {
+	err := h.Flush()
+
+	if c, ok := h.output.(io.Closer); ok {
+		if cerr := c.Close(); err == nil {
+			err = cerr
+		}
+	}
+
+	return err
+}
+
+// maxEncoderBufferSize is the capacity above which buffers aren't kept in the
+// pool, to avoid holding on to the memory used by exceptionally large events.
+const maxEncoderBufferSize = 64 * 1024
+
+type encoderBuffer struct {
+	b []byte
+}
+
+var encoderBufferPool = sync.Pool{
+	New: func() interface{} { return &encoderBuffer{make([]byte, 0, 1024)} },
+}
@@ -0,0 +1,487 @@
+package events_test
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/segmentio/events"
+	"github.com/segmentio/events/logfmt"
+	"github.com/segmentio/events/text"
+)
+
+var encoderTests = []struct {
+	name   string
+	enc    events.Encoder
+	lines  bool                       // whether events are always encoded on a single line
+	decode func(string) *events.Event // parses the encoded events back, if possible
+}{
+	{
+		name:   "json",
+		enc:    events.JSONEncoder{},
+		lines:  true,
+		decode: events.ParseJSONLine,
+	},
+	{
+		name:   "logfmt",
+		enc:    logfmt.Encoder{},
+		lines:  true,
+		decode: events.ParseLogfmtLine,
+	},
+	{
+		name: "text",
+		enc:  &text.Handler{TimeFormat: text.DefaultTimeFormat, EnableArgs: true},
+	},
+	{
+		name: "text:layout",
+		enc:  &text.Handler{Layout: "{time} {level:>5} {source} {message} {args}"},
+	},
+}
+
+var encoderEvents = []struct {
+	name  string
+	event events.Event
+}{
+	{
+		name:  "empty",
+		event: events.Event{},
+	},
+	{
+		name: "simple",
+		event: events.Event{
+			Message: "Hello Luke!",
+			Source:  "main.go:42",
+			Args:    events.Args{{"name", "Luke"}, {"from", "Han"}, {"answer", 42}},
+			Time:    time.Date(2017, 1, 1, 23, 42, 0, 123000000, time.UTC),
+		},
+	},
+	{
+		name: "unicode",
+		event: events.Event{
+			Message: "héllo 世界 🎉  ",
+			Args:    events.Args{{"clé", "valeur ✓"}, {"emoji", "👋"}},
+			Time:    time.Date(2017, 1, 1, 0, 0, 0, 0, time.FixedZone("", 3600)),
+			Debug:   true,
+		},
+	},
+	{
+		name: "special characters",
+		event: events.Event{
+			Message: "line 1\nline 2\t\"quoted\" \\ a=b",
+			Source:  "with space.go:1",
+			Args:    events.Args{{"key with space", "a\r\nb"}, {"", ""}, {"ctl", "\x00\x1f\x7f"}},
+		},
+	},
+	{
+		name: "invalid utf8",
+		event: events.Event{
+			Message: "\xff\xfe",
+			Args:    events.Args{{"bad", "\xc3\x28"}},
+		},
+	},
+	{
+		name: "nil values",
+		event: events.Event{
+			Message: "nil",
+			Args:    events.Args{{"nil", nil}, {"error", error(nil)}, {"ptr", (*int)(nil)}, {"slice", []int(nil)}},
+		},
+	},
+	{
+		name: "errors",
+		event: events.Event{
+			Message: "oops",
+			Args:    events.Args{{"error", io.EOF}, {"wrapped", errors.New("a\nb")}},
+		},
+	},
+	{
+		name: "bytes",
+		event: events.Event{
+			Message: "checksum",
+			Args:    events.Args{{"raw", []byte{0xde, 0xad, 0xbe, 0xef}}, {"empty", []byte{}}, {"wrapped", events.Bytes([]byte("A")).Base64()}},
+		},
+	},
+	{
+		name: "huge args",
+		event: events.Event{
+			Message: strings.Repeat("m", 100000),
+			Args:    hugeArgs(),
+		},
+	},
+}
+
+func hugeArgs() (args events.Args) {
+	for i := 0; i != 1000; i++ {
+		args = append(args, events.Arg{"arg", i})
+	}
+	return append(args, events.Arg{"big", strings.Repeat("x", 1<<20)})
+}
+
+func TestEncoderConformance(t *testing.T) {
+	for _, test := range encoderTests {
+		t.Run(test.name, func(t *testing.T) {
+			for _, ev := range encoderEvents {
+				t.Run(ev.name, func(t *testing.T) {
+					e := ev.event
+
+					b1, err := test.enc.EncodeEvent(nil, &e)
+					if err != nil {
+						t.Fatal(err)
+					}
+
+					b2, err := test.enc.EncodeEvent([]byte("prefix:"), &e)
+					if err != nil {
+						t.Fatal(err)
+					}
+
+					if !bytes.HasPrefix(b2, []byte("prefix:")) || !bytes.Equal(b1, b2[7:]) {
+						t.Error("the encoder doesn't append to dst or isn't deterministic")
+					}
+
+					if !bytes.HasSuffix(b1, []byte("\n")) {
+						t.Error("the record isn't terminated by a line ending")
+					}
+
+					if n := bytes.Count(b1, []byte("\n")); test.lines && n != 1 {
+						t.Errorf("the record spans %d lines", n)
+					}
+
+					if test.decode == nil || ev.name == "invalid utf8" {
+						return
+					}
+
+					found := test.decode(string(b1[:len(b1)-1]))
+
+					if found.Message != e.Message || found.Source != e.Source || found.Debug != e.Debug || !found.Time.Equal(e.Time) {
+						t.Errorf("bad decoded event:\n%+v\n%+v", found, e)
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestEncoderBytes(t *testing.T) {
+	encoders := []struct {
+		name string
+		new  func(events.BytesFormat) events.Encoder
+	}{
+		{"json", func(f events.BytesFormat) events.Encoder { return events.JSONEncoder{Bytes: f} }},
+		{"logfmt", func(f events.BytesFormat) events.Encoder { return logfmt.Encoder{Bytes: f} }},
+		{"text", func(f events.BytesFormat) events.Encoder { return &text.Handler{EnableArgs: true, Bytes: f} }},
+		{"text:layout", func(f events.BytesFormat) events.Encoder { return &text.Handler{Layout: "{args}", Bytes: f} }},
+	}
+
+	formats := []struct {
+		format events.BytesFormat
+		out    string
+	}{
+		{events.BytesDefault, "0102030405ff"},
+		{events.BytesHex, "0102030405ff"},
+		{events.BytesBase64, "AQIDBAX/"},
+		{events.BytesPreview, "0x01020304…6 bytes"},
+	}
+
+	e := &events.Event{
+		Message: "checksum",
+		Args: events.Args{
+			{"raw", []byte{1, 2, 3, 4, 5, 0xff}},
+			{"wrapped", events.Bytes([]byte{1, 2, 3, 4, 5, 0xff})},
+			{"preferred", events.Bytes([]byte{0xde, 0xad}).Base64()},
+		},
+	}
+
+	for _, enc := range encoders {
+		for _, f := range formats {
+			b, err := enc.new(f.format).EncodeEvent(nil, e)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if n := strings.Count(string(b), f.out); n != 2 {
+				t.Errorf("%s: %d: expected the byte slices to be rendered as %q:\n%s", enc.name, f.format, f.out, b)
+			}
+
+			if !strings.Contains(string(b), "3q0=") {
+				t.Errorf("%s: %d: the preferred format of the bytes value was not used:\n%s", enc.name, f.format, b)
+			}
+		}
+	}
+
+	// Byte slices are decoded back as strings.
+	b, _ := events.JSONEncoder{}.EncodeEvent(nil, e)
+
+	if v, _ := events.ParseJSONLine(string(b)).Args.Get("raw"); v != "0102030405ff" {
+		t.Errorf("bad decoded value: %#v", v)
+	}
+
+	if j, _ := e.Args.MarshalJSON(); !strings.Contains(string(j), `"raw":"0102030405ff"`) {
+		t.Error("bad JSON encoding of the arguments:", string(j))
+	}
+}
+
+var sortArgsEncoders = []struct {
+	name string
+	enc  events.Encoder
+	want string
+}{
+	{"json", events.JSONEncoder{SortArgs: true}, `"a":1,"b":2,"b":4,"c":3`},
+	{"logfmt", logfmt.Encoder{SortArgs: true}, `a=1 b=2 b=4 c=3`},
+	{"text", &text.Handler{EnableArgs: true, SortArgs: true}, "\ta: 1\n\tb: 2\n\tb: 4\n\tc: 3\n"},
+	{"text:layout", &text.Handler{Layout: "{args}", SortArgs: true}, "a=1 b=2 b=4 c=3"},
+}
+
+func TestEncoderSortArgs(t *testing.T) {
+	for _, test := range sortArgsEncoders {
+		e := &events.Event{Message: "sorted", Args: events.Args{{"c", 3}, {"b", 2}, {"a", 1}, {"b", 4}}}
+
+		b, err := test.enc.EncodeEvent(nil, e)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !strings.Contains(string(b), test.want) {
+			t.Errorf("%s: the arguments are not sorted:\n%s", test.name, b)
+		}
+
+		if !reflect.DeepEqual(e.Args, events.Args{{"c", 3}, {"b", 2}, {"a", 1}, {"b", 4}}) {
+			t.Errorf("%s: the event arguments were modified: %v", test.name, e.Args)
+		}
+	}
+}
+
+func TestEncoderSortArgsAllocations(t *testing.T) {
+	e := &events.Event{Message: "sorted", Args: events.Args{{"c", 3}, {"b", 2}, {"a", 1}}}
+	b := make([]byte, 0, 1024)
+
+	for _, test := range sortArgsEncoders[:2] {
+		if n := testing.AllocsPerRun(100, func() { test.enc.EncodeEvent(b[:0], e) }); n > 1 {
+			t.Errorf("%s: %g allocations", test.name, n)
+		}
+	}
+}
+
+func BenchmarkEncoderSortArgs(b *testing.B) {
+	e := &events.Event{
+		Message: "Hello Luke!",
+		Args:    events.Args{{"name", "Luke"}, {"from", "Han"}, {"answer", 42}, {"ok", true}, {"count", 10}},
+	}
+
+	for _, test := range sortArgsEncoders {
+		b.Run(test.name, func(b *testing.B) {
+			buf := make([]byte, 0, 1024)
+			b.ReportAllocs()
+
+			for i := 0; i != b.N; i++ {
+				test.enc.EncodeEvent(buf[:0], e)
+			}
+		})
+	}
+}
+
+func TestEncoderNoArgs(t *testing.T) {
+	if events.RaceEnabled {
+		t.Skip("the text encoder uses a pool")
+	}
+
+	e := &events.Event{Message: "server started", Source: "main.go:42", Time: time.Now()}
+	b := make([]byte, 0, 1024)
+
+	for _, test := range sortArgsEncoders {
+		if n := testing.AllocsPerRun(100, func() { test.enc.EncodeEvent(b[:0], e) }); n != 0 {
+			t.Errorf("%s: %g allocations", test.name, n)
+		}
+	}
+}
+
+func TestLogNoArgsAllocations(t *testing.T) {
+	if events.RaceEnabled {
+		t.Skip("the logger uses a pool")
+	}
+
+	for _, h := range []events.Handler{
+		text.NewHandler("", ioutil.Discard),
+		events.NewWriterHandler(ioutil.Discard, text.NewHandler("", nil)),
+	} {
+		logger := events.NewLogger(h)
+
+		if n := testing.AllocsPerRun(100, func() { logger.Log("server started") }); n > 1 {
+			t.Errorf("%T: %g allocations", h, n)
+		}
+	}
+}
+
+func BenchmarkEncoderNoArgs(b *testing.B) {
+	for _, test := range []struct {
+		name string
+		args events.Args
+	}{
+		{"no-args", nil},
+		{"args", events.Args{{"name", "Luke"}}},
+	} {
+		e := &events.Event{Message: "server started", Source: "main.go:42", Args: test.args, Time: time.Now()}
+
+		for _, enc := range sortArgsEncoders {
+			b.Run(test.name+"/"+enc.name, func(b *testing.B) {
+				buf := make([]byte, 0, 1024)
+				b.ReportAllocs()
+
+				for i := 0; i != b.N; i++ {
+					enc.enc.EncodeEvent(buf[:0], e)
+				}
+			})
+		}
+	}
+}
+
+func TestEncoderOmit(t *testing.T) {
+	zero, one := 0, 1
+
+	args := events.Args{
+		{"nil", nil},
+		{"nilptr", (*int)(nil)},
+		{"zeroptr", &zero},
+		{"str", ""},
+		{"int", 0},
+		{"bool", false},
+		{"time", time.Time{}},
+		{"name", "Luke"},
+		{"ptr", &one},
+		{"date", time.Date(2017, 1, 2, 3, 4, 5, 0, time.UTC)},
+		{"keepnil", events.Keep(nil)},
+		{"keepzero", events.Keep(0)},
+	}
+
+	encoders := []struct {
+		name string
+		key  string
+		new  func(omitNil bool, omitZero bool) events.Encoder
+	}{
+		{"json", `"%s":`, func(n, z bool) events.Encoder { return events.JSONEncoder{OmitNil: n, OmitZero: z} }},
+		{"logfmt", ` %s=`, func(n, z bool) events.Encoder { return logfmt.Encoder{OmitNil: n, OmitZero: z} }},
+	}
+
+	tests := []struct {
+		omitNil  bool
+		omitZero bool
+		omitted  []string
+	}{
+		{false, false, nil},
+		{true, false, []string{"nil", "nilptr"}},
+		{false, true, []string{"nil", "nilptr", "str", "int", "bool", "time"}},
+		{true, true, []string{"nil", "nilptr", "str", "int", "bool", "time"}},
+	}
+
+	for _, enc := range encoders {
+		for _, test := range tests {
+			b, err := enc.new(test.omitNil, test.omitZero).EncodeEvent(nil, &events.Event{Message: "omit", Args: args})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			omitted := make(map[string]bool)
+			for _, name := range test.omitted {
+				omitted[name] = true
+			}
+
+			for _, a := range args {
+				if found := strings.Contains(string(b), fmt.Sprintf(enc.key, a.Name)); found == omitted[a.Name] {
+					t.Errorf("%s: nil=%t zero=%t: %s: found=%t\n%s", enc.name, test.omitNil, test.omitZero, a.Name, found, b)
+				}
+			}
+		}
+	}
+
+	// Omitting all the arguments doesn't leave separators behind.
+	e := &events.Event{Message: "omit", Args: events.Args{{"a", nil}, {"b", ""}}}
+
+	if b, _ := (events.JSONEncoder{OmitZero: true}).EncodeEvent(nil, e); string(b) != `{"level":"info","message":"omit"}`+"\n" {
+		t.Errorf("json: bad output: %q", b)
+	}
+
+	if b, _ := (logfmt.Encoder{OmitZero: true}).EncodeEvent(nil, e); string(b) != "level=info msg=omit\n" {
+		t.Errorf("logfmt: bad output: %q", b)
+	}
+}
+
+func TestEncoderAllocations(t *testing.T) {
+	e := &events.Event{
+		Message: "Hello Luke!",
+		Source:  "main.go:42",
+		Args:    events.Args{{"name", "Luke"}, {"answer", 42}, {"ok", true}},
+		Time:    time.Date(2017, 1, 1, 23, 42, 0, 123000000, time.UTC),
+	}
+
+	for _, test := range encoderTests {
+		if !test.lines {
+			continue
+		}
+
+		b := make([]byte, 0, 1024)
+
+		if n := testing.AllocsPerRun(100, func() { test.enc.EncodeEvent(b[:0], e) }); n != 0 {
+			t.Errorf("%s: %g allocations", test.name, n)
+		}
+	}
+}
+
+func TestWriterHandler(t *testing.T) {
+	b := &bytes.Buffer{}
+	w := bufio.NewWriter(b)
+	h := events.NewWriterHandler(w, events.JSONEncoder{})
+
+	h.HandleEvent(&events.Event{Message: "A", Args: events.Args{{"n", 1}}})
+	h.HandleEvent(&events.Event{Message: "B", Debug: true})
+
+	if b.Len() != 0 {
+		t.Error("the writer was flushed too early")
+	}
+
+	if err := h.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	const ref = `{"level":"info","message":"A","n":1}
+{"level":"debug","message":"B"}
+`
+
+	if s := b.String(); s != ref {
+		t.Errorf("bad output:\n%s\n%s", s, ref)
+	}
+
+	fail := events.NewWriterHandler(b, events.EncoderFunc(func(dst []byte, e *events.Event) ([]byte, error) {
+		return dst, errors.New("oops")
+	}))
+	fail.HandleEvent(&events.Event{})
+	fail.HandleEvent(&events.Event{})
+
+	if n := fail.Errors(); n != 2 {
+		t.Error("bad number of errors:", n)
+	}
+
+	if s := b.String(); s != ref {
+		t.Error("events that failed to be encoded were written:", s)
+	}
+}
+
+func TestHandlerWithEncoders(t *testing.T) {
+	// The existing handlers produce the same output as a writer handler
+	// using them as encoders.
+	e := &events.Event{Message: "Hello Luke!", Args: events.Args{{"name", "Luke"}}}
+
+	b1 := &bytes.Buffer{}
+	text.NewHandler("==> ", b1).HandleEvent(e)
+
+	b2 := &bytes.Buffer{}
+	events.NewWriterHandler(b2, text.NewHandler("==> ", nil)).HandleEvent(e)
+
+	if b1.String() != b2.String() {
+		t.Errorf("%q != %q", b1.String(), b2.String())
+	}
+}
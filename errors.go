@@ -0,0 +1,187 @@
+package events
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+)
+
+// MaxErrorCauses is the maximum number of causes listed by ErrorArgs for a
+// single error. When the chain of wrapped errors is longer than this limit the
+// arguments end with a "<name>.truncated" boolean set to true.
+var MaxErrorCauses = 16
+
+// ErrorArgs returns a list of arguments describing err and the chain of errors
+// that it wraps.
+//
+// The first argument is named "error" and has err as value, it is followed by
+// the messages of the wrapped errors in arguments named "error.cause.0",
+// "error.cause.1", etc... and by the message of the innermost error in an
+// argument named "error.root". Wrapped errors are discovered with the Unwrap
+// method (either returning an error or a slice of errors like those created by
+// errors.Join), or the Cause method used by github.com/pkg/errors. Errors that
+// wrap multiple errors have their causes listed depth-first, the root is the
+// innermost error of the first branch.
+//
+// The function is safe to use with errors that form cycles, each error is
+// listed at most once.
+func ErrorArgs(err error) Args {
+	if err == nil {
+		return nil
+	}
+	return appendErrorArgs(nil, "error", err)
+}
+
+// ExpandErrors returns a handler which adds the arguments produced by ErrorArgs
+// to the events it receives for each error argument, before passing them to h.
+// The arguments are named after the error argument that they describe.
+func ExpandErrors(h Handler) Handler {
+	return &expandErrorsHandler{
+		handler: h,
+	}
+}
+
+type expandErrorsHandler struct {
+	handler Handler
+}
+
+func (x *expandErrorsHandler) HandleEvent(e *Event) {
+	x.handle(e, forwardEvent)
+}
+
+func (x *expandErrorsHandler) HandleEventSync(ctx context.Context, e *Event) error {
+	return handleSync(ctx, x, func(forward func(Handler, *Event)) { x.handle(e, forward) })
+}
+
+func (x *expandErrorsHandler) HandleEventContext(ctx context.Context, e *Event) error {
+	return handleContext(ctx, func(forward func(Handler, *Event)) { x.handle(e, forward) })
+}
+
+func (x *expandErrorsHandler) handle(e *Event, forward func(Handler, *Event)) {
+	if !hasErrorCauses(e.Args) {
+		forward(x.handler, e)
+		return
+	}
+	c := *e
+	c.Args = expandErrorArgs(make(Args, 0, 2*len(e.Args)), e.Args)
+	forward(x.handler, &c)
+}
+
+func (x *expandErrorsHandler) Unwrap() Handler {
+	return x.handler
+}
+
+func (x *expandErrorsHandler) Flush() error {
+	return flushHandler(x.handler)
+}
+
+func (x *expandErrorsHandler) Close() error {
+	return closeHandler(x.handler)
+}
+
+// expandErrorArgs appends src to dst, each error argument being followed by
+// the list of its causes.
+func expandErrorArgs(dst Args, src Args) Args {
+	for _, a := range src {
+		if err, ok := a.Value.(error); ok {
+			dst = appendErrorArgs(dst, a.Name, err)
+		} else {
+			dst = append(dst, a)
+		}
+	}
+	return dst
+}
+
+func hasErrorCauses(args Args) bool {
+	for _, a := range args {
+		if err, ok := a.Value.(error); ok && len(unwrapErrors(err)) != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func appendErrorArgs(args Args, name string, err error) Args {
+	w := errorWalker{
+		seen: make(map[error]struct{}),
+	}
+	w.visit(err)
+	w.walk(err)
+
+	args = append(args, Arg{name, err})
+
+	for i, cause := range w.causes {
+		args = append(args, Arg{name + ".cause." + strconv.Itoa(i), cause.Error()})
+	}
+
+	if w.root != nil {
+		args = append(args, Arg{name + ".root", w.root.Error()})
+	}
+
+	if w.truncated {
+		args = append(args, Arg{name + ".truncated", true})
+	}
+
+	return args
+}
+
+type errorWalker struct {
+	seen      map[error]struct{}
+	causes    []error
+	root      error
+	truncated bool
+}
+
+func (w *errorWalker) walk(err error) {
+	for _, cause := range unwrapErrors(err) {
+		if w.truncated {
+			return
+		}
+
+		if cause == nil || !w.visit(cause) {
+			continue
+		}
+
+		if len(w.causes) == MaxErrorCauses {
+			w.truncated = true
+			return
+		}
+
+		w.causes = append(w.causes, cause)
+		w.walk(cause)
+
+		if w.root == nil && len(unwrapErrors(cause)) == 0 {
+			w.root = cause
+		}
+	}
+}
+
+// visit records err as seen and returns true if it wasn't seen before. Errors
+// of types that can't be compared are always reported as not seen, cycles
+// through these values are broken by MaxErrorCauses.
+func (w *errorWalker) visit(err error) bool {
+	if !reflect.TypeOf(err).Comparable() {
+		return true
+	}
+	if _, seen := w.seen[err]; seen {
+		return false
+	}
+	w.seen[err] = struct{}{}
+	return true
+}
+
+func unwrapErrors(err error) []error {
+	switch x := err.(type) {
+	case interface{ Unwrap() []error }:
+		return x.Unwrap()
+	case interface{ Unwrap() error }:
+		if cause := x.Unwrap(); cause != nil {
+			return []error{cause}
+		}
+	case interface{ Cause() error }:
+		if cause := x.Cause(); cause != nil {
+			return []error{cause}
+		}
+	}
+	return nil
+}
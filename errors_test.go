@@ -0,0 +1,230 @@
+package events
+
+import (
+	"errors"
+	"io"
+	"testing"
+)
+
+type wrapError struct {
+	msg string
+	err error
+}
+
+func (e *wrapError) Error() string { return e.msg + ": " + e.err.Error() }
+func (e *wrapError) Unwrap() error { return e.err }
+
+type causeError struct {
+	msg   string
+	cause error
+}
+
+func (e *causeError) Error() string { return e.msg }
+func (e *causeError) Cause() error  { return e.cause }
+
+type multiError []error
+
+func (e multiError) Error() string   { return "multiple errors" }
+func (e multiError) Unwrap() []error { return e }
+
+type cycleError struct {
+	msg  string
+	next *cycleError
+}
+
+func (e *cycleError) Error() string { return e.msg }
+func (e *cycleError) Unwrap() error { return e.next }
+
+type loopError struct{ self []error } // not comparable
+
+func (e loopError) Error() string { return "loop" }
+func (e loopError) Unwrap() error { return e }
+
+func TestErrorArgs(t *testing.T) {
+	a := &cycleError{msg: "A"}
+	b := &cycleError{msg: "B", next: a}
+	a.next = b
+
+	deep := &wrapError{"A", &wrapError{"B", &wrapError{"C", io.EOF}}}
+	joined := multiError{
+		&wrapError{"A", io.ErrUnexpectedEOF},
+		errors.New("B"),
+		multiError{errors.New("C"), io.EOF},
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		args Args
+	}{
+		{
+			name: "nil",
+			err:  nil,
+			args: nil,
+		},
+		{
+			name: "single",
+			err:  io.EOF,
+			args: Args{{"error", io.EOF}},
+		},
+		{
+			name: "three-deep",
+			err:  deep,
+			args: Args{
+				{"error", deep},
+				{"error.cause.0", "B: C: EOF"},
+				{"error.cause.1", "C: EOF"},
+				{"error.cause.2", "EOF"},
+				{"error.root", "EOF"},
+			},
+		},
+		{
+			name: "cause",
+			err:  &causeError{"A", io.EOF},
+			args: Args{
+				{"error", &causeError{"A", io.EOF}},
+				{"error.cause.0", "EOF"},
+				{"error.root", "EOF"},
+			},
+		},
+		{
+			name: "joined",
+			err:  joined,
+			args: Args{
+				{"error", joined},
+				{"error.cause.0", "A: unexpected EOF"},
+				{"error.cause.1", "unexpected EOF"},
+				{"error.cause.2", "B"},
+				{"error.cause.3", "multiple errors"},
+				{"error.cause.4", "C"},
+				{"error.cause.5", "EOF"},
+				{"error.root", "unexpected EOF"},
+			},
+		},
+		{
+			name: "cycle",
+			err:  a,
+			args: Args{
+				{"error", a},
+				{"error.cause.0", "B"},
+			},
+		},
+		{
+			name: "self-cause",
+			err:  &causeError{"A", nil},
+			args: Args{{"error", &causeError{"A", nil}}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			args := ErrorArgs(test.err)
+
+			if diff := Diff(&Event{Args: test.args}, &Event{Args: args}); len(diff) != 0 {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestErrorArgsTruncated(t *testing.T) {
+	defer func(max int) { MaxErrorCauses = max }(MaxErrorCauses)
+	MaxErrorCauses = 2
+
+	t.Run("chain", func(t *testing.T) {
+		err := &wrapError{"A", &wrapError{"B", &wrapError{"C", io.EOF}}}
+		args := ErrorArgs(err)
+		want := Args{
+			{"error", err},
+			{"error.cause.0", "B: C: EOF"},
+			{"error.cause.1", "C: EOF"},
+			{"error.truncated", true},
+		}
+
+		if diff := Diff(&Event{Args: want}, &Event{Args: args}); len(diff) != 0 {
+			t.Error(diff)
+		}
+	})
+
+	t.Run("uncomparable cycle", func(t *testing.T) {
+		err := loopError{}
+		args := ErrorArgs(err)
+
+		if len(args) != 4 {
+			t.Fatal(args)
+		}
+
+		if v, _ := args.Get("error.truncated"); v != true {
+			t.Error(args)
+		}
+	})
+}
+
+func TestExpandErrors(t *testing.T) {
+	r := &eventRecorder{}
+	h := ExpandErrors(r)
+	err := &wrapError{"A", io.EOF}
+
+	e := &Event{Message: "failed", Args: Args{{"name", "Luke"}, {"err", err}}}
+	h.HandleEvent(e)
+
+	want := &Event{
+		Message: "failed",
+		Args: Args{
+			{"name", "Luke"},
+			{"err", err},
+			{"err.cause.0", "EOF"},
+			{"err.root", "EOF"},
+		},
+	}
+
+	if diff := Diff(want, r.wait(t)); len(diff) != 0 {
+		t.Error(diff)
+	}
+
+	if len(e.Args) != 2 {
+		t.Error("the original event was modified:", e.Args)
+	}
+}
+
+func TestExpandErrorsWrapper(t *testing.T) {
+	d := &drainHandler{}
+	h := ExpandErrors(d)
+
+	if u, ok := h.(interface{ Unwrap() Handler }); !ok || u.Unwrap() != d {
+		t.Error("the handler doesn't unwrap to the handler it wraps")
+	}
+
+	flushHandler(h)
+	closeHandler(h)
+
+	if d.flushed == 0 || d.closed == 0 {
+		t.Error("the wrapped handler was not flushed and closed")
+	}
+}
+
+func TestLoggerErrorCauses(t *testing.T) {
+	r := &eventRecorder{}
+	l := NewLogger(r)
+	l.EnableSource = false
+	l.EnableErrorCauses = true
+	err := &wrapError{"A", io.EOF}
+
+	for i := 0; i != 2; i++ {
+		l.Log("failed: %{err}v", err, Args{{"name", "Luke"}})
+		l.Log("failed: %{err}v", io.EOF)
+	}
+
+	want := []Args{
+		{{"err", err}, {"err.cause.0", "EOF"}, {"err.root", "EOF"}, {"name", "Luke"}},
+		{{"err", io.EOF}},
+	}
+
+	for i := 0; i != 4; i++ {
+		e := r.wait(t)
+
+		if diff := Diff(&Event{Message: e.Message, Args: want[i%2], Time: e.Time}, e); len(diff) != 0 {
+			t.Error(diff)
+		}
+	}
+}
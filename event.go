@@ -1,7 +1,9 @@
 package events
 
 import (
+	"reflect"
 	"sort"
+	"strings"
 	"time"
 )
 
@@ -29,31 +31,44 @@ type Event struct {
 // Clone makes a deep copy of the event, the returned value doesn't shared any
 // pointer with the original.
 func (e *Event) Clone() *Event {
+	return e.CloneInterned(nil)
+}
+
+// CloneInterned is like Clone but uses in to share the canonical instances of
+// the event message, source, argument names and string argument values instead
+// of copying them. Strings are immutable so the returned event still doesn't
+// share any mutable data with the original. If in is nil the method behaves
+// like Clone.
+func (e *Event) CloneInterned(in *Interner) *Event {
+	if len(e.Args) == 0 && in == nil {
+		// Fast path of events without arguments, the copies of the message
+		// and source share the same memory.
+		m, s := cloneStrings(e.Message, e.Source)
+		return &Event{
+			Message: m,
+			Source:  s,
+			Time:    e.Time,
+			Debug:   e.Debug,
+		}
+	}
+
 	var a Args
-	var m []byte
-	var s []byte
 
 	if n := len(e.Args); n != 0 {
 		a = make(Args, n)
 		for i := range a {
 			a[i].Name = e.Args[i].Name
-			a[i].Value = cloneValue(e.Args[i].Value)
-		}
-	}
+			a[i].Value = cloneArgValue(e.Args[i].Value, in)
 
-	if n := len(e.Message); n != 0 {
-		m = make([]byte, n)
-		copy(m, e.Message)
-	}
-
-	if n := len(e.Source); n != 0 {
-		s = make([]byte, n)
-		copy(s, e.Source)
+			if in != nil {
+				a[i].Name = in.Intern(a[i].Name)
+			}
+		}
 	}
 
 	return &Event{
-		Message: string(m),
-		Source:  string(s),
+		Message: cloneString(e.Message, in),
+		Source:  cloneString(e.Source, in),
 		Args:    a,
 		Time:    e.Time,
 		Debug:   e.Debug,
@@ -80,6 +95,29 @@ func (args Args) Get(name string) (v interface{}, ok bool) {
 	return
 }
 
+// Equal returns true if args and other have the same arguments in the same
+// order. Values are compared deeply (like reflect.DeepEqual), except times
+// which are equal if they represent the same instant.
+func (args Args) Equal(other Args) bool {
+	if len(args) != len(other) {
+		return false
+	}
+	for i := range args {
+		if args[i].Name != other[i].Name || !equalArgValues(args[i].Value, other[i].Value) {
+			return false
+		}
+	}
+	return true
+}
+
+func equalArgValues(v1 interface{}, v2 interface{}) bool {
+	if t1, ok := v1.(time.Time); ok {
+		t2, ok := v2.(time.Time)
+		return ok && t1.Equal(t2)
+	}
+	return reflect.DeepEqual(v1, v2)
+}
+
 // Map converts an argument list to a map representation. In cases where the
 // list contains multiple arguments with the same name the value of the last
 // one will be seen in the map.
@@ -91,15 +129,83 @@ func (args Args) Map() map[string]interface{} {
 	return m
 }
 
+// Kinds returns a map of the argument names to the kinds of their values (see
+// KindOf). Like Map, the last argument wins when names are repeated.
+func (args Args) Kinds() map[string]Kind {
+	m := make(map[string]Kind, len(args))
+	for _, arg := range args {
+		m[arg.Name] = KindOf(arg.Value)
+	}
+	return m
+}
+
+// ArgsOption values configure how A builds argument lists.
+type ArgsOption int
+
+const (
+	// Sorted makes A return the arguments sorted by name, instead of the
+	// order of the map iteration.
+	Sorted ArgsOption = 1 << iota
+)
+
 // A constructs an argument list from a map.
-func A(m map[string]interface{}) Args {
+func A(m map[string]interface{}, options ...ArgsOption) Args {
 	args := make(Args, 0, len(m))
 	for name, value := range m {
 		args = append(args, Arg{name, value})
 	}
+	for _, opt := range options {
+		if opt&Sorted != 0 {
+			SortArgs(args)
+		}
+	}
 	return args
 }
 
+// SortedIndex returns the indexes of args in the order of their names, the
+// sort is stable so arguments with the same name keep their relative order.
+// The argument list itself is not modified, which makes the method usable by
+// encoders that render arguments in a canonical order.
+//
+// The method allocates a single slice.
+func (args Args) SortedIndex() []int {
+	n := len(args)
+	buf := make([]int, 2*n)
+	idx, tmp := buf[:n], buf[n:]
+
+	for i := range idx {
+		idx[i] = i
+	}
+
+	// Bottom-up merge sort, the merges take elements from the left run when
+	// names are equal, which makes the sort stable.
+	for width := 1; width < n; width *= 2 {
+		for lo := 0; lo < n; lo += 2 * width {
+			mid, hi := lo+width, lo+2*width
+			if mid > n {
+				mid = n
+			}
+			if hi > n {
+				hi = n
+			}
+
+			i, j, k := lo, mid, lo
+			for ; i < mid && j < hi; k++ {
+				if args[idx[j]].Name < args[idx[i]].Name {
+					tmp[k], j = idx[j], j+1
+				} else {
+					tmp[k], i = idx[i], i+1
+				}
+			}
+			k += copy(tmp[k:], idx[i:mid])
+			copy(tmp[k:], idx[j:hi])
+		}
+		idx, tmp = tmp, idx
+	}
+
+	return idx[:n:n]
+}
+
 // SortArgs sorts a list of argument by their argument names.
 //
 // This is not a stable sorting operation, elements with equal values may not be
@@ -121,3 +227,64 @@ func (a byArgName) Less(i int, j int) bool {
 func (a byArgName) Swap(i int, j int) {
 	a[i], a[j] = a[j], a[i]
 }
+
+func cloneArgValue(v interface{}, in *Interner) interface{} {
+	switch x := v.(type) {
+	case nil:
+		return nil
+	case string:
+		if in != nil {
+			if _, c, ok := in.intern(x); ok {
+				return c
+			}
+		}
+		return cloneValue(v)
+	case []byte:
+		return cloneBytes(x)
+	case BytesValue:
+		x.Data = cloneBytes(x.Data)
+		return x
+	case KeepValue:
+		x.Value = cloneArgValue(x.Value, in)
+		return x
+	default:
+		return cloneValue(v)
+	}
+}
+
+// cloneString returns a copy of s, or its canonical instance in in if in isn't
+// nil and s isn't too long to be interned.
+func cloneString(s string, in *Interner) string {
+	if in != nil {
+		if c, _, ok := in.intern(s); ok {
+			return c
+		}
+	}
+
+	if len(s) == 0 {
+		return ""
+	}
+
+	return string(append(make([]byte, 0, len(s)), s...))
+}
+
+// cloneStrings returns copies of a and b made with a single allocation.
+func cloneStrings(a, b string) (string, string) {
+	if len(a)+len(b) == 0 {
+		return "", ""
+	}
+
+	var sb strings.Builder
+	sb.Grow(len(a) + len(b))
+	sb.WriteString(a)
+	sb.WriteString(b)
+	s := sb.String()
+	return s[:len(a)], s[len(a):]
+}
+
+func cloneBytes(b []byte) []byte {
+	if b == nil {
+		return nil
+	}
+	return append(make([]byte, 0, len(b)), b...)
+}
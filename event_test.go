@@ -25,6 +25,69 @@ func TestEvent(t *testing.T) {
 			t.Errorf("%#v", e2)
 		}
 	})
+
+	t.Run("CloneBytes", func(t *testing.T) {
+		b1 := []byte("ABC")
+		b2 := []byte("DEF")
+		e1 := &Event{Args: Args{{"raw", b1}, {"wrapped", Bytes(b2).Base64()}, {"nil", nil}}}
+		e2 := e1.Clone()
+
+		b1[0] = 'X'
+		b2[0] = 'X'
+
+		if b := e2.Args[0].Value.([]byte); string(b) != "ABC" {
+			t.Error("the byte slice was aliased by the clone:", string(b))
+		}
+
+		if v := e2.Args[1].Value.(BytesValue); string(v.Data) != "DEF" || v.Format != BytesBase64 {
+			t.Errorf("the bytes value was aliased by the clone: %#v", v)
+		}
+
+		if v := e2.Args[2].Value; v != nil {
+			t.Error("bad nil value:", v)
+		}
+	})
+
+	t.Run("CloneNoArgs", func(t *testing.T) {
+		msg := []byte("Hello World")
+		src := []byte("file.go:42")
+		e1 := &Event{Message: bytesToString(msg), Source: bytesToString(src), Time: time.Now(), Debug: true}
+		e2 := e1.Clone()
+
+		if !reflect.DeepEqual(e1, e2) || e2.Args != nil {
+			t.Errorf("%#v", e2)
+		}
+
+		msg[0], src[0] = 'X', 'X'
+
+		if e2.Message != "Hello World" || e2.Source != "file.go:42" {
+			t.Errorf("the strings were aliased by the clone: %q %q", e2.Message, e2.Source)
+		}
+
+		if n := testing.AllocsPerRun(100, func() { e1.Clone() }); n > 2 {
+			t.Errorf("%g allocations", n)
+		}
+	})
+}
+
+func BenchmarkClone(b *testing.B) {
+	for _, test := range []struct {
+		name string
+		args Args
+	}{
+		{"no-args", nil},
+		{"args", Args{{"hello", "world"}}},
+	} {
+		e := &Event{Message: "Hello World", Source: "file.go:42", Args: test.args, Time: time.Now()}
+
+		b.Run(test.name, func(b *testing.B) {
+			b.ReportAllocs()
+
+			for i := 0; i != b.N; i++ {
+				e.Clone()
+			}
+		})
+	}
 }
 
 func TestArgs(t *testing.T) {
@@ -53,12 +116,67 @@ func TestArgs(t *testing.T) {
 			t.Error("%#v != %#v", a1, a2)
 		}
 	})
+	t.Run("Sorted", func(t *testing.T) {
+		args := A(map[string]interface{}{"c": 3, "a": 1, "b": 2, "d": 4}, Sorted)
+
+		if !reflect.DeepEqual(args, Args{{"a", 1}, {"b", 2}, {"c", 3}, {"d", 4}}) {
+			t.Errorf("%#v", args)
+		}
+	})
+	t.Run("SortedIndex", func(t *testing.T) {
+		for _, test := range []struct {
+			args  Args
+			order []int
+		}{
+			{nil, []int{}},
+			{Args{{"a", 0}}, []int{0}},
+			{Args{{"b", 0}, {"a", 1}}, []int{1, 0}},
+			{Args{{"c", 0}, {"a", 1}, {"b", 2}, {"a", 3}, {"c", 4}}, []int{1, 3, 2, 0, 4}},
+			{Args{{"x", 0}, {"x", 1}, {"x", 2}, {"a", 3}, {"x", 4}, {"x", 5}, {"b", 6}}, []int{3, 6, 0, 1, 2, 4, 5}},
+		} {
+			args := append(Args(nil), test.args...)
+
+			if order := args.SortedIndex(); !reflect.DeepEqual(order, test.order) {
+				t.Errorf("%v: %v != %v", test.args, order, test.order)
+			}
+
+			if !reflect.DeepEqual(args, test.args) {
+				t.Error("the argument list was modified:", args)
+			}
+		}
+	})
 }
 
 // This test is crafted to crash the program if some of the unsafe operations
 // perform illegal memory changes that mess up the GC state.
 //
 // Run it with CGO_ENABLED=0 GODEBUG=gccheckmark=1 GOTRACEBACK=crash GOGC=1
+func TestArgsEqual(t *testing.T) {
+	t0 := time.Date(2017, 1, 1, 23, 42, 0, 0, time.UTC)
+	args := Args{{"a", 1}, {"b", map[string][]int{"x": {1}}}, {"t", t0}}
+
+	tests := []struct {
+		other Args
+		equal bool
+	}{
+		{Args{{"a", 1}, {"b", map[string][]int{"x": {1}}}, {"t", t0.Local()}}, true},
+		{Args{{"a", int64(1)}, {"b", map[string][]int{"x": {1}}}, {"t", t0}}, false},
+		{Args{{"a", 1}, {"b", map[string][]int{"x": {2}}}, {"t", t0}}, false},
+		{Args{{"b", map[string][]int{"x": {1}}}, {"a", 1}, {"t", t0}}, false},
+		{Args{{"a", 1}, {"b", map[string][]int{"x": {1}}}}, false},
+	}
+
+	for _, test := range tests {
+		if eq := args.Equal(test.other); eq != test.equal {
+			t.Errorf("%v == %v: %t", args, test.other, eq)
+		}
+	}
+
+	if !Args(nil).Equal(Args{}) {
+		t.Error("nil and empty lists must be equal")
+	}
+}
+
 func TestUnsafe(t *testing.T) {
 	logger := Logger{
 		Handler:     Discard,
@@ -0,0 +1,216 @@
+package events
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"reflect"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// ExitTimeout is the maximum amount of time that Exit and Shutdown wait for the
+// registered handlers to be flushed and closed.
+var ExitTimeout = 5 * time.Second
+
+// ErrShutdownTimeout is returned by Shutdown when the registered handlers
+// could not be flushed and closed within ExitTimeout.
+var ErrShutdownTimeout = errors.New("events: timeout waiting for handlers to shut down")
+
+// RegisterOnExit registers h to be flushed and closed when the program exits
+// through Exit, or when it receives one of the signals passed to HandleSignals.
+//
+// The handler's Flush method is called if it implements the Flusher interface,
+// then its Close method if it implements io.Closer. Handlers are shut down in
+// the reverse order of their registration, registering the same handler more
+// than once has no effect.
+func RegisterOnExit(h Handler) {
+	exitHooks.mutex.Lock()
+	defer exitHooks.mutex.Unlock()
+
+	for _, x := range exitHooks.handlers {
+		if sameHandler(x, h) {
+			return
+		}
+	}
+
+	exitHooks.handlers = append(exitHooks.handlers, h)
+}
+
+// Exit shuts down the handlers registered with RegisterOnExit, then exits the
+// program with the given status code.
+//
+// If Exit is called again while handlers are being shut down (for example by
+// a handler, or because a signal arrived), the call never returns and doesn't
+// exit the program, the program exits with the code of the first call when the
+// shutdown completes. The handler being shut down when the call is made isn't
+// waited for anymore, so a handler calling Exit doesn't prevent the handlers
+// that come after it from being shut down.
+func Exit(code int) {
+	if nested, _ := shutdown(); nested {
+		select {}
+	}
+	os.Exit(code)
+}
+
+// Shutdown flushes and closes the handlers registered with RegisterOnExit,
+// waiting at most ExitTimeout for the operation to complete. The first error
+// returned by the handlers, or ErrShutdownTimeout, is returned.
+//
+// Shutdown only runs once, later calls wait for the first one to complete and
+// return the same error. Programs that return from their main function should
+// call Shutdown before doing so.
+func Shutdown() error {
+	_, err := shutdown()
+	return err
+}
+
+// shutdown is the implementation of Shutdown, nested is true if the call was
+// made while another one was shutting down the handlers.
+func shutdown() (nested bool, err error) {
+	exitHooks.mutex.Lock()
+
+	if exitHooks.done == nil {
+		exitHooks.done = make(chan struct{})
+		exitHooks.nested = make(chan struct{}, 1)
+		exitHooks.deadline = time.Now().Add(ExitTimeout)
+
+		handlers := exitHooks.handlers
+		deadline := exitHooks.deadline
+		exitHooks.mutex.Unlock()
+
+		exitHooks.err = shutdownHandlers(handlers, deadline, exitHooks.nested)
+		close(exitHooks.done)
+		return false, exitHooks.err
+	}
+
+	done := exitHooks.done
+	deadline := exitHooks.deadline
+	exitHooks.mutex.Unlock()
+
+	select {
+	case <-done:
+		return false, exitHooks.err
+	default:
+	}
+
+	// The call may be made by the handler being shut down, which would block
+	// the shutdown until the deadline if it kept waiting for the handler.
+	select {
+	case exitHooks.nested <- struct{}{}:
+	default:
+	}
+
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+
+	select {
+	case <-done:
+		return true, exitHooks.err
+	case <-timer.C:
+		return true, ErrShutdownTimeout
+	}
+}
+
+// HandleSignals installs a listener which exits the program when one of the
+// given signals is received. Before shutting down the registered handlers an
+// event is sent to the default handler to report the signal, the exit code is
+// 128 plus the signal number, like shells report processes terminated by a
+// signal.
+//
+// The returned function removes the listener, it may safely be called multiple
+// times.
+func HandleSignals(signals ...os.Signal) (stop func()) {
+	var pc [1]uintptr
+	runtime.Callers(2, pc[:])
+	file, line := SourceForPC(pc[0])
+	source := fmt.Sprintf("%s:%d", file, line)
+
+	sigchan := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(sigchan, signals...)
+
+	go func() {
+		select {
+		case sig := <-sigchan:
+			DefaultHandler.HandleEvent(&Event{
+				Message: fmt.Sprintf("exiting on %s signal", sig),
+				Source:  source,
+				Time:    time.Now(),
+				Args:    Args{{"signal", sig}},
+			})
+			Exit(signalExitCode(sig))
+		case <-done:
+		}
+	}()
+
+	once := sync.Once{}
+
+	return func() {
+		once.Do(func() {
+			signal.Stop(sigchan)
+			close(done)
+		})
+	}
+}
+
+var exitHooks struct {
+	mutex    sync.Mutex
+	handlers []Handler
+	done     chan struct{}
+	nested   chan struct{} // signaled by calls made during the shutdown
+	deadline time.Time
+	err      error
+}
+
+// shutdownHandlers flushes and closes handlers in reverse order. The handler
+// being shut down is abandoned when skip is signaled, the function moves on to
+// the next one.
+func shutdownHandlers(handlers []Handler, deadline time.Time, skip <-chan struct{}) (err error) {
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+
+	for i := len(handlers) - 1; i >= 0; i-- {
+		h := handlers[i]
+		result := make(chan error, 1)
+
+		go func() {
+			err := flushHandler(h)
+			if e := closeHandler(h); err == nil {
+				err = e
+			}
+			result <- err
+		}()
+
+		select {
+		case e := <-result:
+			if err == nil {
+				err = e
+			}
+		case <-skip:
+		case <-timer.C:
+			return ErrShutdownTimeout
+		}
+	}
+
+	return
+}
+
+// sameHandler compares two handlers, handlers of types that can't be compared
+// (like HandlerFunc) are never considered equal.
+func sameHandler(h1 Handler, h2 Handler) bool {
+	t1 := reflect.TypeOf(h1)
+	t2 := reflect.TypeOf(h2)
+	return t1 == t2 && t1 != nil && t1.Comparable() && h1 == h2
+}
+
+// signalExitCode returns the exit code for a program terminated by sig, the
+// signal types of most platforms are integers (syscall.Signal), but not all.
+func signalExitCode(sig os.Signal) int {
+	if v := reflect.ValueOf(sig); v.Kind() == reflect.Int {
+		return 128 + int(v.Int())
+	}
+	return 1
+}
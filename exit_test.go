@@ -0,0 +1,186 @@
+package events
+
+import (
+	"bufio"
+	"flag"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var exitHelper = flag.String("events.exit-helper", "", "run the exit test helper writing to the given file")
+
+// fileHandler buffers events in memory and writes them to a file when flushed,
+// it is used to verify that buffered events are not lost on exit.
+type fileHandler struct {
+	mutex  sync.Mutex
+	file   *os.File
+	buffer *bufio.Writer
+}
+
+func newFileHandler(path string) *fileHandler {
+	f, err := os.Create(path)
+	if err != nil {
+		panic(err)
+	}
+	return &fileHandler{file: f, buffer: bufio.NewWriterSize(f, 1<<20)}
+}
+
+func (h *fileHandler) HandleEvent(e *Event) {
+	h.mutex.Lock()
+	h.buffer.WriteString(e.Message + "\n")
+	h.mutex.Unlock()
+}
+
+func (h *fileHandler) Flush() error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.buffer.Flush()
+}
+
+func (h *fileHandler) Close() error {
+	return h.file.Close()
+}
+
+// TestExitHelper is not a real test, it runs in a subprocess started by the
+// other tests of this file.
+func TestExitHelper(t *testing.T) {
+	if len(*exitHelper) == 0 {
+		t.Skip("only runs as a subprocess")
+	}
+
+	h := newFileHandler(*exitHelper)
+	RegisterOnExit(h)
+	RegisterOnExit(h)
+	RegisterOnExit(HandlerFunc(func(e *Event) {}))
+	SwapDefaultHandler(h)
+
+	for i := 0; i != 3; i++ {
+		Log("queued event")
+	}
+
+	switch os.Getenv("EVENTS_EXIT_MODE") {
+	case "signal":
+		HandleSignals(os.Interrupt)
+		p, _ := os.FindProcess(os.Getpid())
+		p.Signal(os.Interrupt)
+		time.Sleep(10 * time.Second)
+	case "reentrant":
+		ExitTimeout = 100 * time.Millisecond
+		RegisterOnExit(HandlerFunc(func(e *Event) {}))
+		RegisterOnExit(&exitFlusher{exit: func() {
+			Log("flushing")
+			Exit(4)
+		}})
+		Exit(3)
+	default:
+		Exit(3)
+	}
+}
+
+type exitFlusher struct {
+	Handler
+	exit func()
+}
+
+func (f *exitFlusher) Flush() error {
+	f.exit()
+	return nil
+}
+
+func runExitHelper(t *testing.T, mode string) (output string, code int, elapsed time.Duration) {
+	path := filepath.Join(t.TempDir(), "events.log")
+	cmd := exec.Command(os.Args[0], "-test.run=^TestExitHelper$", "-events.exit-helper="+path)
+	cmd.Env = append(os.Environ(), "EVENTS_EXIT_MODE="+mode)
+
+	start := time.Now()
+	err := cmd.Run()
+	elapsed = time.Since(start)
+
+	if e, ok := err.(*exec.ExitError); ok {
+		code = e.ExitCode()
+	} else if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(b), code, elapsed
+}
+
+func TestExit(t *testing.T) {
+	output, code, _ := runExitHelper(t, "exit")
+
+	if code != 3 {
+		t.Error("bad exit code:", code)
+	}
+
+	if output != strings.Repeat("queued event\n", 3) {
+		t.Errorf("bad output: %q", output)
+	}
+}
+
+func TestExitReentrant(t *testing.T) {
+	output, code, elapsed := runExitHelper(t, "reentrant")
+
+	if code != 3 {
+		t.Error("bad exit code:", code)
+	}
+
+	if elapsed > 5*time.Second {
+		t.Error("the program took too long to exit:", elapsed)
+	}
+
+	// The file handler was registered first so it's flushed last, after the
+	// handler which called Exit was abandoned.
+	if output != strings.Repeat("queued event\n", 3)+"flushing\n" {
+		t.Errorf("bad output: %q", output)
+	}
+}
+
+func TestHandleSignals(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("sending os.Interrupt is not supported on windows")
+	}
+
+	output, code, _ := runExitHelper(t, "signal")
+
+	if code != 130 {
+		t.Error("bad exit code:", code)
+	}
+
+	if output != strings.Repeat("queued event\n", 3)+"exiting on interrupt signal\n" {
+		t.Errorf("bad output: %q", output)
+	}
+}
+
+func TestRegisterOnExit(t *testing.T) {
+	defer func(handlers []Handler) { exitHooks.handlers = handlers }(exitHooks.handlers)
+	exitHooks.handlers = nil
+
+	h := &drainHandler{}
+	RegisterOnExit(h)
+	RegisterOnExit(h)
+	RegisterOnExit(&drainHandler{})
+	RegisterOnExit(HandlerFunc(func(e *Event) {}))
+	RegisterOnExit(HandlerFunc(func(e *Event) {}))
+
+	if n := len(exitHooks.handlers); n != 4 {
+		t.Error("bad number of registered handlers:", n)
+	}
+}
+
+func TestSignalExitCode(t *testing.T) {
+	if code := signalExitCode(os.Interrupt); runtime.GOOS != "plan9" && code != 130 {
+		t.Error("bad exit code for os.Interrupt:", code)
+	}
+}
@@ -0,0 +1,90 @@
+package events
+
+import "time"
+
+// exitFunc is called by Fatal to exit the program, tests may replace it.
+var exitFunc = Exit
+
+// Fatal emits a fatal event to the default logger, then exits the program, see
+// Logger.Fatal.
+func Fatal(format string, args ...interface{}) {
+	DefaultLogger.fatal(1, format, args...)
+}
+
+// Panic emits a panic event to the default logger, then panics, see
+// Logger.Panic.
+func Panic(format string, args ...interface{}) {
+	DefaultLogger.panic(1, format, args...)
+}
+
+// Fatal is like Log but marks the event with a fatal=true argument, then
+// flushes and closes the logger's handler and exits the program with status
+// code 1.
+//
+// Flushing and closing the handler is bounded by ExitTimeout, the program then
+// exits through Exit, which also shuts down the handlers registered with
+// RegisterOnExit.
+func (l *Logger) Fatal(format string, args ...interface{}) {
+	l.fatal(1, format, args...)
+}
+
+// Panic is like Log but marks the event with a panic=true argument, then
+// flushes the logger's handler and panics with the event message.
+//
+// Flushing the handler is bounded by ExitTimeout. The handler isn't closed
+// since the program may recover from the panic.
+func (l *Logger) Panic(format string, args ...interface{}) {
+	l.panic(1, format, args...)
+}
+
+func (l *Logger) fatal(depth int, format string, args ...interface{}) {
+	c := l.marked("fatal")
+	c.log(nil, depth+1, false, format, args...)
+	shutdownHandlers([]Handler{c.Handler}, time.Now().Add(ExitTimeout), nil)
+	exitFunc(1)
+}
+
+func (l *Logger) panic(depth int, format string, args ...interface{}) {
+	var msg string
+	c := l.marked("panic")
+	h := c.Handler
+
+	c.Handler = HandlerFunc(func(e *Event) {
+		msg = e.Message
+		h.HandleEvent(e)
+	})
+
+	c.log(nil, depth+1, false, format, args...)
+	flushHandlerTimeout(h, ExitTimeout)
+	panic(msg)
+}
+
+// marked returns a copy of l which adds an argument with the given name and
+// the value true to its events.
+func (l *Logger) marked(name string) *Logger {
+	c := *l
+	c.Args = append(l.Args[:len(l.Args):len(l.Args)], Arg{name, true})
+
+	if c.Handler == nil {
+		c.Handler = DefaultHandler
+	}
+
+	return &c
+}
+
+// flushHandlerTimeout flushes h, waiting at most timeout for the operation to
+// complete.
+func flushHandlerTimeout(h Handler, timeout time.Duration) error {
+	result := make(chan error, 1)
+	go func() { result <- flushHandler(h) }()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case err := <-result:
+		return err
+	case <-timer.C:
+		return ErrShutdownTimeout
+	}
+}
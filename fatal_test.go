@@ -0,0 +1,95 @@
+package events
+
+import (
+	"testing"
+	"time"
+)
+
+// slowHandler delays events before recording them, so they are still queued
+// in an async handler when the logger returns.
+type slowHandler struct {
+	eventRecorder
+}
+
+func (h *slowHandler) HandleEvent(e *Event) {
+	time.Sleep(10 * time.Millisecond)
+	h.eventRecorder.HandleEvent(e)
+}
+
+func TestLoggerFatal(t *testing.T) {
+	r := &slowHandler{}
+	a := NewAsyncHandler(r, AsyncConfig{SummaryInterval: time.Hour})
+	l := NewLogger(a)
+
+	code := -1
+	prev := exitFunc
+	exitFunc = func(c int) {
+		code = c
+
+		if n := r.len(); n != 2 {
+			t.Error("the handler was not flushed before exiting:", n)
+		}
+	}
+	defer func() { exitFunc = prev }()
+
+	l.Log("A")
+	l.Fatal("cannot start: %{error}s", "oops")
+
+	if code != 1 {
+		t.Error("bad exit code:", code)
+	}
+
+	r.wait(t)
+	e := r.wait(t)
+
+	if e.Message != "cannot start: oops" || !e.Args.Map()["fatal"].(bool) || e.Args.Map()["error"] != "oops" {
+		t.Errorf("bad fatal event: %+v", e)
+	}
+
+	if len(e.Source) == 0 {
+		t.Error("the fatal event has no source")
+	}
+}
+
+func TestLoggerPanicEvent(t *testing.T) {
+	r := &slowHandler{}
+	a := NewAsyncHandler(r, AsyncConfig{SummaryInterval: time.Hour})
+	defer a.Close()
+	l := NewLogger(a)
+
+	func() {
+		defer func() {
+			if v := recover(); v != "invalid state: 42" {
+				t.Error("bad panic value:", v)
+			}
+		}()
+		l.Panic("invalid state: %{state}d", 42)
+	}()
+
+	if n := r.len(); n != 1 {
+		t.Fatal("the handler was not flushed before panicking:", n)
+	}
+
+	if e := r.wait(t); e.Args.Map()["panic"] != true {
+		t.Errorf("bad panic event: %+v", e)
+	}
+}
+
+func TestDefaultLoggerFatal(t *testing.T) {
+	r := &slowHandler{}
+	a := NewAsyncHandler(r, AsyncConfig{SummaryInterval: time.Hour})
+	defer a.Close()
+
+	old := SwapDefaultHandler(a)
+	defer SwapDefaultHandler(old)
+
+	prev := exitFunc
+	exitFunc = func(int) {}
+	defer func() { exitFunc = prev }()
+
+	Fatal("bye")
+
+	if n := r.len(); n != 1 {
+		t.Error("the default handler was not flushed:", n)
+	}
+}
@@ -0,0 +1,340 @@
+package events
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// FileHandler is a handler which encodes events with an Encoder and writes
+// them to files covering fixed periods of time (buckets), for example one file
+// per hour or per day.
+//
+// The names of the files are generated from a template where the parts within
+// braces are time layouts (see the time package), formatted with the start of
+// the bucket. For example "logs/app-{2006-01-02-15}.log" with hourly buckets
+// writes events to files like "logs/app-2024-05-01-13.log". Placeholders may
+// only appear in the base name of the template.
+//
+// The handler switches to the file of the next bucket when the current time
+// (as returned by Now) passes the end of the current bucket, which is checked
+// each time an event is received. The times of events are not used to select
+// files, so events with skewed clocks are written to the current file and
+// can't cause files of the present to be deleted. Existing files are appended
+// to, so a program restarting in the middle of a bucket continues writing its
+// file.
+//
+// When Retention is set, the handler deletes the files of old buckets each time
+// it opens a file. Only the files with names generated by the template are
+// deleted, other files in the directory are left untouched.
+//
+// It is safe to use a file handler concurrently from multiple goroutines.
+type FileHandler struct {
+	// Now returns the current time, it defaults to time.Now and may be set to
+	// a different function before the handler is used (in tests for example).
+	Now func() time.Time
+
+	// Location is the time zone used to compute the boundaries of buckets and
+	// to format file names, it defaults to UTC and may be changed before the
+	// handler is used.
+	Location *time.Location
+
+	// Retention is the number of previous buckets for which files are kept,
+	// older files are deleted. Zero means files are never deleted.
+	Retention int
+
+	errors   int64 // accessed atomically
+	template fileTemplate
+	bucket   time.Duration
+	encoder  Encoder
+
+	mutex sync.Mutex
+	file  *os.File
+	start time.Time // start of the bucket of file
+	err   error     // last error, returned by Close
+
+	// times of the last write and of the last error, and the last error,
+	// reported by EventHealth
+	lastWrite time.Time
+	lastError time.Time
+	lastErr   error
+}
+
+// NewFileHandler returns a FileHandler which writes events to the files named
+// after template, each covering a bucket of the given duration, in the format
+// of enc.
+func NewFileHandler(template string, bucket time.Duration, enc Encoder) *FileHandler {
+	if bucket <= 0 {
+		panic("events.NewFileHandler: the bucket duration must be positive")
+	}
+
+	t, err := parseFileTemplate(template)
+	if err != nil {
+		panic("events.NewFileHandler: " + err.Error())
+	}
+
+	return &FileHandler{
+		Now:      time.Now,
+		Location: time.UTC,
+		template: t,
+		bucket:   bucket,
+		encoder:  enc,
+	}
+}
+
+// HandleEvent satisfies the Handler interface.
+func (h *FileHandler) HandleEvent(e *Event) {
+	buf := encoderBufferPool.Get().(*encoderBuffer)
+	b, err := h.encoder.EncodeEvent(buf.b[:0], e)
+
+	h.mutex.Lock()
+
+	if err == nil {
+		var f *os.File
+		if f, err = h.open(); err == nil {
+			_, err = f.Write(b)
+		}
+	}
+
+	if err != nil {
+		h.err, h.lastErr, h.lastError = err, err, h.Now()
+		atomic.AddInt64(&h.errors, 1)
+	} else {
+		h.lastWrite = h.Now()
+	}
+
+	h.mutex.Unlock()
+
+	if cap(b) <= maxEncoderBufferSize {
+		buf.b = b[:0]
+	}
+
+	encoderBufferPool.Put(buf)
+}
+
+// Errors returns the number of events that couldn't be encoded or written.
+func (h *FileHandler) Errors() int64 {
+	return atomic.LoadInt64(&h.errors)
+}
+
+// EventHealth satisfies the HealthReporter interface. The handler is degraded
+// when the last event failed to be encoded or written.
+func (h *FileHandler) EventHealth() Health {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	health := Health{
+		Errors:    atomic.LoadInt64(&h.errors),
+		LastWrite: h.lastWrite,
+		LastError: h.lastError,
+	}
+
+	if h.lastErr != nil && !h.lastError.Before(h.lastWrite) {
+		health.Status, health.Message = Degraded, h.lastErr.Error()
+	}
+
+	return health
+}
+
+// Name returns the name of the file that events of time t are written to.
+func (h *FileHandler) Name(t time.Time) string {
+	return h.template.format(h.bucketOf(t))
+}
+
+// Close closes the current file, returning the last error that occurred. The
+// handler opens the file again if it receives more events.
+func (h *FileHandler) Close() error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	err := h.err
+	h.err = nil
+
+	if h.file != nil {
+		if cerr := h.file.Close(); err == nil {
+			err = cerr
+		}
+		h.file = nil
+	}
+
+	return err
+}
+
+// open returns the file of the bucket of the current time. It must be called
+// with the mutex locked.
+func (h *FileHandler) open() (*os.File, error) {
+	start := h.bucketOf(h.Now())
+
+	if h.file != nil {
+		if start.After(h.start) {
+			h.file.Close()
+			h.file = nil
+		} else {
+			return h.file, nil
+		}
+	}
+
+	f, err := os.OpenFile(h.template.format(start), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	h.file, h.start = f, start
+
+	if h.Retention > 0 {
+		h.sweep(start.Add(-time.Duration(h.Retention) * h.bucket))
+	}
+
+	return f, nil
+}
+
+// sweep deletes the files of the buckets starting before limit.
+func (h *FileHandler) sweep(limit time.Time) {
+	files, err := ioutil.ReadDir(h.template.dir)
+	if err != nil {
+		h.err = err
+		return
+	}
+
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+
+		if t, ok := h.template.parse(f.Name(), h.Location); ok && t.Before(limit) {
+			if err := os.Remove(filepath.Join(h.template.dir, f.Name())); err != nil {
+				h.err = err
+			}
+		}
+	}
+}
+
+// bucketOf returns the start of the bucket of t in the location of the handler.
+func (h *FileHandler) bucketOf(t time.Time) time.Time {
+	loc := h.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	t = t.In(loc)
+	_, offset := t.Zone()
+	shift := time.Duration(offset) * time.Second
+	return t.Add(shift).Truncate(h.bucket).Add(-shift)
+}
+
+// fileTemplate is the parsed representation of the templates of file names.
+type fileTemplate struct {
+	dir    string         // directory of the files
+	parts  []string       // literal parts of the base name, alternating with layouts
+	match  *regexp.Regexp // matches the generated base names
+	layout string         // the layouts joined by newlines
+}
+
+func parseFileTemplate(s string) (fileTemplate, error) {
+	dir, base := filepath.Split(s)
+
+	if strings.ContainsAny(dir, "{}") {
+		return fileTemplate{}, fmt.Errorf("time placeholders must be in the base name of the template %q", s)
+	}
+
+	if dir == "" {
+		dir = "."
+	}
+
+	t := fileTemplate{dir: dir}
+	expr := []string{"^"}
+	layouts := []string{}
+
+	for {
+		i := strings.IndexByte(base, '{')
+		if i < 0 {
+			break
+		}
+
+		j := strings.IndexByte(base[i:], '}')
+		if j < 0 {
+			return fileTemplate{}, fmt.Errorf("unclosed placeholder in the template %q", s)
+		}
+
+		literal, layout := base[:i], base[i+1:i+j]
+
+		if strings.IndexByte(literal, '}') >= 0 {
+			return fileTemplate{}, fmt.Errorf("unopened placeholder in the template %q", s)
+		}
+
+		if layout == "" {
+			return fileTemplate{}, fmt.Errorf("empty placeholder in the template %q", s)
+		}
+
+		if n := len(t.parts); literal == "" && n != 0 {
+			// adjacent placeholders are merged, they can't be told apart
+			// when parsing file names
+			t.parts[n-1] += layout
+			layouts[len(layouts)-1] += layout
+		} else {
+			t.parts = append(t.parts, literal, layout)
+			expr = append(expr, regexp.QuoteMeta(literal), "(.+?)")
+			layouts = append(layouts, layout)
+		}
+
+		base = base[i+j+1:]
+	}
+
+	if strings.IndexByte(base, '}') >= 0 {
+		return fileTemplate{}, fmt.Errorf("unopened placeholder in the template %q", s)
+	}
+
+	if len(layouts) == 0 {
+		return fileTemplate{}, fmt.Errorf("no time placeholders in the template %q", s)
+	}
+
+	t.parts = append(t.parts, base)
+	expr = append(expr, regexp.QuoteMeta(base), "$")
+	t.match = regexp.MustCompile(strings.Join(expr, ""))
+	t.layout = strings.Join(layouts, "\n")
+	return t, nil
+}
+
+func (t fileTemplate) format(start time.Time) string {
+	return filepath.Join(t.dir, t.formatBase(start))
+}
+
+func (t fileTemplate) formatBase(start time.Time) string {
+	b := make([]byte, 0, 64)
+
+	for i, p := range t.parts {
+		if i%2 == 0 {
+			b = append(b, p...)
+		} else {
+			b = start.AppendFormat(b, p)
+		}
+	}
+
+	return string(b)
+}
+
+// parse returns the time that base was generated from, and false if base was
+// not generated by the template.
+func (t fileTemplate) parse(base string, loc *time.Location) (time.Time, bool) {
+	m := t.match.FindStringSubmatch(base)
+	if m == nil {
+		return time.Time{}, false
+	}
+
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	start, err := time.ParseInLocation(t.layout, strings.Join(m[1:], "\n"), loc)
+	if err != nil || t.formatBase(start) != base {
+		return time.Time{}, false
+	}
+
+	return start, true
+}
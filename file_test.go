@@ -0,0 +1,281 @@
+package events
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// fileTestEncoder writes the message of events on a line.
+var fileTestEncoder = EncoderFunc(func(b []byte, e *Event) ([]byte, error) {
+	return append(append(b, e.Message...), '\n'), nil
+})
+
+func newTestFileHandler(t *testing.T, clock *fakeClock) (*FileHandler, string) {
+	dir := t.TempDir()
+	h := NewFileHandler(filepath.Join(dir, "app-{2006-01-02-15}.log"), time.Hour, fileTestEncoder)
+	h.Now = clock.Now
+	return h, dir
+}
+
+func readTestFile(t *testing.T, path string) string {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func listTestFiles(t *testing.T, dir string) []string {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestFileHandlerRollover(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 5, 1, 13, 59, 59, 0, time.UTC)}
+	h, dir := newTestFileHandler(t, clock)
+
+	h.HandleEvent(&Event{Message: "A", Time: clock.Now()})
+	clock.add(time.Second)
+	h.HandleEvent(&Event{Message: "B", Time: clock.Now()})
+
+	// The current time is enough to roll over, and late events are written to
+	// the current file.
+	clock.add(time.Hour)
+	h.HandleEvent(&Event{Message: "C", Time: clock.Now().Add(-2 * time.Hour)})
+
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"app-2024-05-01-13.log": "A\n",
+		"app-2024-05-01-14.log": "B\n",
+		"app-2024-05-01-15.log": "C\n",
+	}
+
+	for name, content := range want {
+		if s := readTestFile(t, filepath.Join(dir, name)); s != content {
+			t.Errorf("%s: bad content: %q", name, s)
+		}
+	}
+
+	if names := listTestFiles(t, dir); len(names) != len(want) {
+		t.Error("bad files:", names)
+	}
+}
+
+func TestFileHandlerClockSkew(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 5, 1, 14, 0, 0, 0, time.UTC)}
+	h, dir := newTestFileHandler(t, clock)
+	h.Retention = 2
+
+	for _, name := range []string{"app-2024-05-01-12.log", "app-2024-05-01-13.log"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// An event from a host whose clock is two days ahead neither rolls over nor
+	// sweeps the files of the present.
+	h.HandleEvent(&Event{Message: "A", Time: clock.Now().Add(48 * time.Hour)})
+	h.HandleEvent(&Event{Message: "B", Time: clock.Now()})
+
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"app-2024-05-01-12.log", "app-2024-05-01-13.log", "app-2024-05-01-14.log"}
+
+	if names := listTestFiles(t, dir); !reflect.DeepEqual(names, want) {
+		t.Error("bad files:", names)
+	}
+
+	if s := readTestFile(t, filepath.Join(dir, "app-2024-05-01-14.log")); s != "A\nB\n" {
+		t.Errorf("bad content: %q", s)
+	}
+}
+
+func TestFileHandlerRestart(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 5, 1, 13, 10, 0, 0, time.UTC)}
+	h, dir := newTestFileHandler(t, clock)
+	h.HandleEvent(&Event{Message: "A"})
+	h.Close()
+
+	// A new handler, like after a restart of the program in the same bucket.
+	clock.add(10 * time.Minute)
+	h = NewFileHandler(filepath.Join(dir, "app-{2006-01-02-15}.log"), time.Hour, fileTestEncoder)
+	h.Now = clock.Now
+	h.HandleEvent(&Event{Message: "B"})
+	h.Close()
+
+	// The same handler after being closed.
+	h.HandleEvent(&Event{Message: "C"})
+	h.Close()
+
+	if s := readTestFile(t, filepath.Join(dir, "app-2024-05-01-13.log")); s != "A\nB\nC\n" {
+		t.Errorf("bad content: %q", s)
+	}
+}
+
+func TestFileHandlerRetention(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 5, 1, 13, 0, 0, 0, time.UTC)}
+	h, dir := newTestFileHandler(t, clock)
+	h.Retention = 2
+
+	for _, name := range []string{
+		"app-2024-05-01-09.log",    // old bucket
+		"app-2024-05-01-11.log",    // kept bucket
+		"app-2024-05-01-15.log",    // future bucket
+		"app-2024-05-01-09.log.gz", // not generated by the template
+		"app-2024-05-01-9.log",     // not generated by the template
+		"app-2024-05-01-xx.log",    // not generated by the template
+		"other-2024-05-01-09.log",  // not generated by the template
+		"notes.txt",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := os.Mkdir(filepath.Join(dir, "app-2024-05-01-08.log"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	h.HandleEvent(&Event{Message: "A"})
+
+	want := []string{
+		"app-2024-05-01-08.log",
+		"app-2024-05-01-09.log.gz",
+		"app-2024-05-01-11.log",
+		"app-2024-05-01-13.log",
+		"app-2024-05-01-15.log",
+		"app-2024-05-01-9.log",
+		"app-2024-05-01-xx.log",
+		"notes.txt",
+		"other-2024-05-01-09.log",
+	}
+
+	if names := listTestFiles(t, dir); !reflect.DeepEqual(names, want) {
+		t.Error("bad files after the first sweep:", names)
+	}
+
+	// Crossing the next bucket boundaries deletes the older files.
+	clock.add(time.Hour)
+	h.HandleEvent(&Event{Message: "B"})
+	clock.add(time.Hour)
+	h.HandleEvent(&Event{Message: "C"})
+
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want = []string{
+		"app-2024-05-01-08.log",
+		"app-2024-05-01-09.log.gz",
+		"app-2024-05-01-13.log",
+		"app-2024-05-01-14.log",
+		"app-2024-05-01-15.log",
+		"app-2024-05-01-9.log",
+		"app-2024-05-01-xx.log",
+		"notes.txt",
+		"other-2024-05-01-09.log",
+	}
+
+	if names := listTestFiles(t, dir); !reflect.DeepEqual(names, want) {
+		t.Error("bad files after the last sweep:", names)
+	}
+}
+
+func TestFileHandlerLocation(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 5, 1, 23, 30, 0, 0, time.UTC)}
+	h := NewFileHandler("app-{20060102}.log", 24*time.Hour, fileTestEncoder)
+	h.Now = clock.Now
+
+	if name := h.Name(clock.Now()); name != "app-20240501.log" {
+		t.Error("bad name in UTC:", name)
+	}
+
+	h.Location = time.FixedZone("UTC+2", 2*3600)
+
+	if name := h.Name(clock.Now()); name != "app-20240502.log" {
+		t.Error("bad name in UTC+2:", name)
+	}
+}
+
+func TestFileHandlerErrors(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 5, 1, 13, 0, 0, 0, time.UTC)}
+	dir := t.TempDir()
+	h := NewFileHandler(filepath.Join(dir, "missing", "app-{2006}.log"), time.Hour, fileTestEncoder)
+	h.Now = clock.Now
+	h.HandleEvent(&Event{Message: "A"})
+
+	if n := h.Errors(); n != 1 {
+		t.Error("bad number of errors:", n)
+	}
+
+	if health := h.EventHealth(); health.Status != Degraded || health.Errors != 1 || !health.LastError.Equal(clock.Now()) {
+		t.Errorf("bad health: %+v", health)
+	}
+
+	if err := h.Close(); err == nil {
+		t.Error("the error was not returned by Close")
+	}
+
+	if err := os.Mkdir(filepath.Join(dir, "missing"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	clock.add(time.Minute)
+	h.HandleEvent(&Event{Message: "B"})
+	defer h.Close()
+
+	if health := h.EventHealth(); health.Status != Healthy || health.Errors != 1 || !health.LastWrite.Equal(clock.Now()) {
+		t.Errorf("bad health after a successful write: %+v", health)
+	}
+}
+
+func TestParseFileTemplate(t *testing.T) {
+	tests := []struct {
+		template string
+		valid    bool
+	}{
+		{"app-{2006-01-02}.log", true},
+		{"logs/{2006}{01}{02}.log", true},
+		{"app.log", false},
+		{"app-{}.log", false},
+		{"app-{2006.log", false},
+		{"app-2006}.log", false},
+		{"app-}{2006}.log", false},
+		{"logs-{2006}/app.log", false},
+	}
+
+	for _, test := range tests {
+		if _, err := parseFileTemplate(test.template); (err == nil) != test.valid {
+			t.Errorf("%s: bad error: %v", test.template, err)
+		}
+	}
+
+	tmpl, _ := parseFileTemplate("logs/{2006}{01}{02}.log")
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	if name := tmpl.format(start); name != filepath.Join("logs", "20240501.log") {
+		t.Error("bad name:", name)
+	}
+
+	if s, ok := tmpl.parse("20240501.log", time.UTC); !ok || !s.Equal(start) {
+		t.Error("bad time parsed from the name:", s, ok)
+	}
+}
@@ -0,0 +1,616 @@
+package events
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ParseFilter compiles expr into a predicate that can be used to filter
+// events.
+//
+// The expression language operates on the event fields and arguments, here is
+// an example:
+//
+//	source =~ "net/http*" && debug == false && args.status >= 500
+//
+// The supported fields are message, source, debug, and args.<name> to refer to
+// the value of an event argument. Fields can be compared to literal values,
+// which are double-quoted strings, numbers, true and false, with the
+// following operators:
+//
+//	==  !=     equality (strings, numbers, and booleans)
+//	=~  !~     glob match, where '*' matches any sequence of characters and
+//	           '?' matches a single character
+//	<  <=  >  >=  numeric comparisons
+//
+// The has(args.<name>) function tests whether an argument exists, a field used
+// alone is equivalent to comparing it to true. Expressions are combined with
+// &&, || and !, and may be grouped with parentheses.
+//
+// Comparisons on arguments that don't exist, or whose values have a type that
+// doesn't match the literal they are compared to, always evaluate to false.
+//
+// Syntax errors are reported with a *FilterError value.
+func ParseFilter(expr string) (func(*Event) bool, error) {
+	p := &filterParser{lexer: filterLexer{expr: expr}}
+	p.next()
+
+	f, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.tok.typ != tokEOF {
+		return nil, p.errorf("end of expression")
+	}
+
+	return f, nil
+}
+
+// FilterError is returned by ParseFilter when the expression has a syntax
+// error.
+type FilterError struct {
+	Expr   string // the expression being parsed
+	Pos    int    // byte offset of the error in the expression
+	Expect string // description of what the parser expected
+	Found  string // the token that was found instead
+}
+
+// Error satisfies the error interface.
+func (e *FilterError) Error() string {
+	return fmt.Sprintf("events: filter syntax error at position %d: expected %s but found %s", e.Pos, e.Expect, e.Found)
+}
+
+type filterParser struct {
+	lexer filterLexer
+	tok   filterToken
+}
+
+func (p *filterParser) next() {
+	p.tok = p.lexer.next()
+}
+
+func (p *filterParser) errorf(expect string) error {
+	return p.errorAt(p.tok, expect)
+}
+
+func (p *filterParser) errorAt(tok filterToken, expect string) error {
+	var found string
+
+	switch tok.typ {
+	case tokEOF:
+		found = "end of expression"
+	case tokError:
+		found = "invalid token " + strconv.Quote(tok.text)
+	default:
+		found = strconv.Quote(tok.text)
+	}
+
+	return &FilterError{
+		Expr:   p.lexer.expr,
+		Pos:    tok.pos,
+		Expect: expect,
+		Found:  found,
+	}
+}
+
+func (p *filterParser) parseOr() (func(*Event) bool, error) {
+	lhs, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.tok.typ == tokOr {
+		p.next()
+
+		rhs, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+
+		lhs = filterOr(lhs, rhs)
+	}
+
+	return lhs, nil
+}
+
+func (p *filterParser) parseAnd() (func(*Event) bool, error) {
+	lhs, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.tok.typ == tokAnd {
+		p.next()
+
+		rhs, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		lhs = filterAnd(lhs, rhs)
+	}
+
+	return lhs, nil
+}
+
+func (p *filterParser) parseUnary() (func(*Event) bool, error) {
+	switch p.tok.typ {
+	case tokNot:
+		p.next()
+
+		f, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		return filterNot(f), nil
+
+	case tokLParen:
+		p.next()
+
+		f, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		if p.tok.typ != tokRParen {
+			return nil, p.errorf("')'")
+		}
+
+		p.next()
+		return f, nil
+
+	case tokIdent:
+		return p.parseComparison()
+
+	default:
+		return nil, p.errorf("field, '!' or '('")
+	}
+}
+
+func (p *filterParser) parseComparison() (func(*Event) bool, error) {
+	switch name := p.tok.text; name {
+	case "true", "false":
+		p.next()
+		v := name == "true"
+		return func(*Event) bool { return v }, nil
+
+	case "has":
+		p.next()
+
+		if p.tok.typ != tokLParen {
+			return nil, p.errorf("'('")
+		}
+		p.next()
+
+		if p.tok.typ != tokIdent || !strings.HasPrefix(p.tok.text, "args.") || len(p.tok.text) == len("args.") {
+			return nil, p.errorf("argument name")
+		}
+		arg := p.tok.text[len("args."):]
+		p.next()
+
+		if p.tok.typ != tokRParen {
+			return nil, p.errorf("')'")
+		}
+		p.next()
+
+		return func(e *Event) bool {
+			_, ok := e.Args.Get(arg)
+			return ok
+		}, nil
+	}
+
+	field := p.tok
+	p.next()
+
+	op := p.tok
+	switch op.typ {
+	case tokEq, tokNe, tokMatch, tokNotMatch, tokLt, tokLe, tokGt, tokGe:
+		p.next()
+	default:
+		// A field used alone is compared to true, this only makes sense for
+		// fields that may have boolean values.
+		if field.text == "message" || field.text == "source" {
+			return nil, p.errorf("comparison operator")
+		}
+		op = filterToken{typ: tokEq}
+		return p.compile(field, op, filterToken{typ: tokIdent, text: "true", pos: field.pos})
+	}
+
+	switch p.tok.typ {
+	case tokString, tokNumber:
+	case tokIdent:
+		if p.tok.text != "true" && p.tok.text != "false" {
+			return nil, p.errorf("literal value")
+		}
+	default:
+		return nil, p.errorf("literal value")
+	}
+
+	value := p.tok
+	p.next()
+	return p.compile(field, op, value)
+}
+
+func (p *filterParser) compile(field filterToken, op filterToken, value filterToken) (func(*Event) bool, error) {
+	switch {
+	case field.text == "message":
+		s, err := p.stringValue(op, value)
+		if err != nil {
+			return nil, err
+		}
+		return filterString(op.typ, s, func(e *Event) string { return e.Message }), nil
+
+	case field.text == "source":
+		s, err := p.stringValue(op, value)
+		if err != nil {
+			return nil, err
+		}
+		return filterString(op.typ, s, func(e *Event) string { return e.Source }), nil
+
+	case field.text == "debug":
+		b, err := p.boolValue(op, value)
+		if err != nil {
+			return nil, err
+		}
+		if op.typ == tokNe {
+			b = !b
+		}
+		return func(e *Event) bool { return e.Debug == b }, nil
+
+	case strings.HasPrefix(field.text, "args.") && len(field.text) != len("args."):
+		return p.compileArg(field.text[len("args."):], op, value)
+
+	default:
+		return nil, p.errorAt(field, "message, source, debug or args.<name>")
+	}
+}
+
+func (p *filterParser) compileArg(name string, op filterToken, value filterToken) (func(*Event) bool, error) {
+	switch value.typ {
+	case tokString:
+		s, err := p.stringValue(op, value)
+		if err != nil {
+			return nil, err
+		}
+		return filterString(op.typ, s, func(e *Event) string {
+			v, _ := e.Args.Get(name)
+			s, _ := v.(string)
+			return s
+		}).withArg(name, isString), nil
+
+	case tokNumber:
+		x, _ := strconv.ParseFloat(value.text, 64)
+		cmp := filterNumber(op.typ, x)
+		if cmp == nil {
+			return nil, p.errorAt(op, "numeric operator")
+		}
+		return func(e *Event) bool {
+			v, ok := e.Args.Get(name)
+			if !ok {
+				return false
+			}
+			f, ok := toFloat(v)
+			return ok && cmp(f)
+		}, nil
+
+	default:
+		b, err := p.boolValue(op, value)
+		if err != nil {
+			return nil, err
+		}
+		eq := op.typ == tokEq
+		return func(e *Event) bool {
+			v, _ := e.Args.Get(name)
+			x, ok := v.(bool)
+			return ok && ((x == b) == eq)
+		}, nil
+	}
+}
+
+func (p *filterParser) stringValue(op filterToken, value filterToken) (string, error) {
+	switch op.typ {
+	case tokEq, tokNe, tokMatch, tokNotMatch:
+	default:
+		return "", p.errorAt(op, "'==', '!=', '=~' or '!~'")
+	}
+
+	if value.typ != tokString {
+		return "", p.errorAt(value, "string literal")
+	}
+
+	s, err := strconv.Unquote(value.text)
+	if err != nil {
+		return "", p.errorAt(value, "valid string literal")
+	}
+
+	return s, nil
+}
+
+func (p *filterParser) boolValue(op filterToken, value filterToken) (bool, error) {
+	switch op.typ {
+	case tokEq, tokNe:
+	default:
+		return false, p.errorAt(op, "'==' or '!='")
+	}
+
+	if value.typ != tokIdent {
+		return false, p.errorAt(value, "true or false")
+	}
+
+	return value.text == "true", nil
+}
+
+type stringFilter func(*Event) bool
+
+// withArg wraps the filter to make it evaluate to false when the argument is
+// missing or its value doesn't satisfy check.
+func (f stringFilter) withArg(name string, check func(interface{}) bool) func(*Event) bool {
+	return func(e *Event) bool {
+		v, ok := e.Args.Get(name)
+		return ok && check(v) && f(e)
+	}
+}
+
+func isString(v interface{}) bool {
+	_, ok := v.(string)
+	return ok
+}
+
+func filterString(op filterTokenType, s string, get func(*Event) string) stringFilter {
+	switch op {
+	case tokEq:
+		return func(e *Event) bool { return get(e) == s }
+	case tokNe:
+		return func(e *Event) bool { return get(e) != s }
+	case tokMatch:
+		return func(e *Event) bool { return globMatch(s, get(e)) }
+	default: // tokNotMatch
+		return func(e *Event) bool { return !globMatch(s, get(e)) }
+	}
+}
+
+func filterNumber(op filterTokenType, x float64) func(float64) bool {
+	switch op {
+	case tokEq:
+		return func(f float64) bool { return f == x }
+	case tokNe:
+		return func(f float64) bool { return f != x }
+	case tokLt:
+		return func(f float64) bool { return f < x }
+	case tokLe:
+		return func(f float64) bool { return f <= x }
+	case tokGt:
+		return func(f float64) bool { return f > x }
+	case tokGe:
+		return func(f float64) bool { return f >= x }
+	default:
+		return nil
+	}
+}
+
+func filterAnd(lhs func(*Event) bool, rhs func(*Event) bool) func(*Event) bool {
+	return func(e *Event) bool { return lhs(e) && rhs(e) }
+}
+
+func filterOr(lhs func(*Event) bool, rhs func(*Event) bool) func(*Event) bool {
+	return func(e *Event) bool { return lhs(e) || rhs(e) }
+}
+
+func filterNot(f func(*Event) bool) func(*Event) bool {
+	return func(e *Event) bool { return !f(e) }
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch x := v.(type) {
+	case int:
+		return float64(x), true
+	case int8:
+		return float64(x), true
+	case int16:
+		return float64(x), true
+	case int32:
+		return float64(x), true
+	case int64:
+		return float64(x), true
+	case uint:
+		return float64(x), true
+	case uint8:
+		return float64(x), true
+	case uint16:
+		return float64(x), true
+	case uint32:
+		return float64(x), true
+	case uint64:
+		return float64(x), true
+	case uintptr:
+		return float64(x), true
+	case float32:
+		return float64(x), true
+	case float64:
+		return x, true
+	default:
+		return 0, false
+	}
+}
+
+// globMatch reports whether s matches pattern, where '*' matches any sequence
+// of characters (including none) and '?' matches exactly one character.
+func globMatch(pattern string, s string) bool {
+	// Iterative matching with backtracking on the last '*' seen, this never
+	// allocates and runs in O(len(pattern) * len(s)) in the worst case.
+	p, i := 0, 0
+	star, next := -1, 0
+
+	for i < len(s) {
+		if p < len(pattern) {
+			switch pattern[p] {
+			case '*':
+				star, next = p, i
+				p++
+				continue
+			case '?':
+				_, n := utf8.DecodeRuneInString(s[i:])
+				p, i = p+1, i+n
+				continue
+			default:
+				if pattern[p] == s[i] {
+					p, i = p+1, i+1
+					continue
+				}
+			}
+		}
+
+		if star < 0 {
+			return false
+		}
+
+		_, n := utf8.DecodeRuneInString(s[next:])
+		next += n
+		p, i = star+1, next
+	}
+
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+
+	return p == len(pattern)
+}
+
+type filterTokenType int
+
+const (
+	tokEOF filterTokenType = iota
+	tokError
+	tokIdent
+	tokString
+	tokNumber
+	tokLParen
+	tokRParen
+	tokAnd
+	tokOr
+	tokNot
+	tokEq
+	tokNe
+	tokMatch
+	tokNotMatch
+	tokLt
+	tokLe
+	tokGt
+	tokGe
+)
+
+type filterToken struct {
+	typ  filterTokenType
+	text string
+	pos  int
+}
+
+type filterLexer struct {
+	expr string
+	pos  int
+}
+
+var filterOperators = [...]struct {
+	text string
+	typ  filterTokenType
+}{
+	// Two-character operators must come first so they match before their
+	// one-character prefixes.
+	{"&&", tokAnd},
+	{"||", tokOr},
+	{"==", tokEq},
+	{"!=", tokNe},
+	{"=~", tokMatch},
+	{"!~", tokNotMatch},
+	{"<=", tokLe},
+	{">=", tokGe},
+	{"<", tokLt},
+	{">", tokGt},
+	{"!", tokNot},
+	{"(", tokLParen},
+	{")", tokRParen},
+}
+
+func (l *filterLexer) next() filterToken {
+	for l.pos < len(l.expr) && isFilterSpace(l.expr[l.pos]) {
+		l.pos++
+	}
+
+	start := l.pos
+
+	if start == len(l.expr) {
+		return filterToken{typ: tokEOF, pos: start}
+	}
+
+	c := l.expr[start]
+
+	switch {
+	case c == '"':
+		for i := start + 1; i < len(l.expr); i++ {
+			switch l.expr[i] {
+			case '\\':
+				i++
+			case '"':
+				l.pos = i + 1
+				return filterToken{typ: tokString, text: l.expr[start:l.pos], pos: start}
+			}
+		}
+		l.pos = len(l.expr)
+		return filterToken{typ: tokError, text: l.expr[start:], pos: start}
+
+	case c == '-' || c == '.' || isFilterDigit(c):
+		i := start + 1
+		for i < len(l.expr) && isFilterNumber(l.expr[i]) {
+			i++
+		}
+		l.pos = i
+		text := l.expr[start:i]
+		if _, err := strconv.ParseFloat(text, 64); err != nil {
+			return filterToken{typ: tokError, text: text, pos: start}
+		}
+		return filterToken{typ: tokNumber, text: text, pos: start}
+
+	case isFilterIdentStart(c):
+		i := start + 1
+		for i < len(l.expr) && isFilterIdent(l.expr[i]) {
+			i++
+		}
+		l.pos = i
+		return filterToken{typ: tokIdent, text: l.expr[start:i], pos: start}
+	}
+
+	for _, op := range filterOperators {
+		if strings.HasPrefix(l.expr[start:], op.text) {
+			l.pos += len(op.text)
+			return filterToken{typ: op.typ, text: op.text, pos: start}
+		}
+	}
+
+	_, n := utf8.DecodeRuneInString(l.expr[start:])
+	l.pos += n
+	return filterToken{typ: tokError, text: l.expr[start:l.pos], pos: start}
+}
+
+func isFilterSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func isFilterDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isFilterNumber(c byte) bool {
+	return isFilterDigit(c) || c == '.' || c == 'e' || c == 'E' || c == '+' || c == '-'
+}
+
+func isFilterIdentStart(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c == '_'
+}
+
+func isFilterIdent(c byte) bool {
+	return isFilterIdentStart(c) || isFilterDigit(c) || c == '.' || c == '-'
+}
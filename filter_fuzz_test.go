@@ -0,0 +1,20 @@
+//go:build go1.18
+// +build go1.18
+
+package events
+
+import "testing"
+
+func FuzzParseFilter(f *testing.F) {
+	for _, test := range filterTests {
+		f.Add(test.expr)
+	}
+
+	f.Fuzz(func(t *testing.T, expr string) {
+		if filter, err := ParseFilter(expr); err == nil {
+			filter(filterEvent)
+		} else if _, ok := err.(*FilterError); !ok {
+			t.Error("bad error type:", err)
+		}
+	})
+}
@@ -0,0 +1,178 @@
+package events
+
+import (
+	"errors"
+	"io"
+	"testing"
+)
+
+var filterEvent = &Event{
+	Message: "Hello Luke!",
+	Source:  "net/http/server.go:42",
+	Args: Args{
+		{"status", 503},
+		{"bytes", uint64(1024)},
+		{"ratio", 0.5},
+		{"method", "GET"},
+		{"cached", false},
+		{"request-id", "1234"},
+		{"error", io.EOF},
+	},
+}
+
+var filterTests = []struct {
+	expr  string
+	match bool
+}{
+	{`true`, true},
+	{`false`, false},
+	{`debug`, false},
+	{`!debug`, true},
+	{`debug == false`, true},
+	{`debug != false`, false},
+	{`message == "Hello Luke!"`, true},
+	{`message != "Hello Luke!"`, false},
+	{`message =~ "Hello *!"`, true},
+	{`message =~ "Hello ????!"`, true},
+	{`message =~ "Hello ???!"`, false},
+	{`message !~ "Bye*"`, true},
+	{`source =~ "net/http*"`, true},
+	{`source =~ "net/rpc*"`, false},
+	{`source == "net/http/server.go:42"`, true},
+	{`args.status >= 500`, true},
+	{`args.status > 503`, false},
+	{`args.status == 503`, true},
+	{`args.status != 503`, false},
+	{`args.status < 600`, true},
+	{`args.status <= 503`, true},
+	{`args.bytes == 1024`, true},
+	{`args.bytes > 1e3`, true},
+	{`args.ratio < 1`, true},
+	{`args.ratio >= -0.5`, true},
+	{`args.method == "GET"`, true},
+	{`args.method =~ "G*"`, true},
+	{`args.method != "POST"`, true},
+	{`args.method != "GET"`, false},
+	{`args.cached == false`, true},
+	{`args.cached`, false},
+	{`!args.cached`, true},
+	{`args.request-id == "1234"`, true},
+	{`has(args.status)`, true},
+	{`has(args.missing)`, false},
+	{`!has(args.missing)`, true},
+	{`args.missing == "GET"`, false},
+	{`args.missing != "GET"`, false},
+	{`args.missing > 0`, false},
+	{`args.missing == true`, false},
+	{`args.method > 0`, false},
+	{`args.status == "503"`, false},
+	{`args.status == true`, false},
+	{`source =~ "net/http*" && debug == false && args.status >= 500`, true},
+	{`source =~ "net/http*" && debug == true && args.status >= 500`, false},
+	{`debug || args.status >= 500`, true},
+	{`debug || args.status < 500`, false},
+	{`debug && false || true`, true},
+	{`debug && (false || true)`, false},
+	{`!(debug || args.cached) && has(args.error)`, true},
+	{`((((true))))`, true},
+}
+
+func TestParseFilter(t *testing.T) {
+	for _, test := range filterTests {
+		t.Run(test.expr, func(t *testing.T) {
+			f, err := ParseFilter(test.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if match := f(filterEvent); match != test.match {
+				t.Error("bad match:", match)
+			}
+		})
+	}
+}
+
+func TestParseFilterError(t *testing.T) {
+	tests := []struct {
+		expr  string
+		error string
+	}{
+		{``, `events: filter syntax error at position 0: expected field, '!' or '(' but found end of expression`},
+		{`debug ==`, `events: filter syntax error at position 8: expected literal value but found end of expression`},
+		{`(debug`, `events: filter syntax error at position 6: expected ')' but found end of expression`},
+		{`debug true`, `events: filter syntax error at position 6: expected end of expression but found "true"`},
+		{`message`, `events: filter syntax error at position 7: expected comparison operator but found end of expression`},
+		{`message == 42`, `events: filter syntax error at position 11: expected string literal but found "42"`},
+		{`message >= "A"`, `events: filter syntax error at position 8: expected '==', '!=', '=~' or '!~' but found ">="`},
+		{`debug =~ "true"`, `events: filter syntax error at position 6: expected '==' or '!=' but found "=~"`},
+		{`args.status =~ 42`, `events: filter syntax error at position 12: expected numeric operator but found "=~"`},
+		{`args.status == abc`, `events: filter syntax error at position 15: expected literal value but found "abc"`},
+		{`time == "now"`, `events: filter syntax error at position 0: expected message, source, debug or args.<name> but found "time"`},
+		{`has(message)`, `events: filter syntax error at position 4: expected argument name but found "message"`},
+		{`has(args.a`, `events: filter syntax error at position 10: expected ')' but found end of expression`},
+		{`message == "abc`, `events: filter syntax error at position 11: expected literal value but found invalid token "\"abc"`},
+		{`debug = true`, `events: filter syntax error at position 6: expected end of expression but found invalid token "="`},
+		{`args.a > 1.2.3`, `events: filter syntax error at position 9: expected literal value but found invalid token "1.2.3"`},
+		{`debug && || true`, `events: filter syntax error at position 9: expected field, '!' or '(' but found "||"`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expr, func(t *testing.T) {
+			_, err := ParseFilter(test.expr)
+
+			var e *FilterError
+			if !errors.As(err, &e) {
+				t.Fatal("bad error:", err)
+			}
+			if e.Error() != test.error {
+				t.Error(e.Error())
+			}
+		})
+	}
+}
+
+func TestParseFilterAllocs(t *testing.T) {
+	f, err := ParseFilter(`source =~ "net/http*" && debug == false && args.status >= 500 && args.method == "GET" && has(args.error)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n := testing.AllocsPerRun(100, func() { f(filterEvent) }); n != 0 {
+		t.Error("filter evaluation should not allocate but did", n, "allocations")
+	}
+}
+
+func TestGlobMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		s       string
+		match   bool
+	}{
+		{"", "", true},
+		{"", "a", false},
+		{"*", "", true},
+		{"*", "anything/at/all", true},
+		{"a*c", "abc", true},
+		{"a*c", "abcbc", true},
+		{"a*c", "abcb", false},
+		{"a?c", "abc", true},
+		{"a?c", "aéc", true},
+		{"a?c", "ac", false},
+		{"*b*", "abc", true},
+		{"**", "abc", true},
+		{"a*b*c", "aXbYbZc", true},
+	}
+
+	for _, test := range tests {
+		if match := globMatch(test.pattern, test.s); match != test.match {
+			t.Errorf("globMatch(%q, %q) = %t", test.pattern, test.s, match)
+		}
+	}
+}
+
+func BenchmarkParseFilter(b *testing.B) {
+	f, _ := ParseFilter(`source =~ "net/http*" && debug == false && args.status >= 500`)
+
+	for i := 0; i != b.N; i++ {
+		f(filterEvent)
+	}
+}
@@ -0,0 +1,57 @@
+package events
+
+import (
+	"encoding"
+	"fmt"
+)
+
+// Formatter is the interface implemented by argument values that have a
+// specific representation in the output of event handlers. It is similar to
+// fmt.Stringer, but lets types render differently in events than they do with
+// the fmt package.
+//
+// Event encoders check for the interfaces implemented by argument values in
+// this order:
+//
+//  1. Formatter
+//  2. error
+//  3. encoding.TextMarshaler
+//  4. fmt.Stringer
+//
+// Text-based encoders (like the text and logfmt packages) follow the full
+// order, structured encoders (like the ecslogs package) render Formatter values
+// as strings and keep their native encoding for other values.
+//
+// Byte slices don't implement any of these interfaces, all encoders render them
+// as strings in the format configured on the handler, hexadecimal by default
+// (see BytesFormat and AppendBytesValue).
+type Formatter interface {
+	FormatEventValue() string
+}
+
+// FormatValue returns the string representation of v according to the order
+// of precedence documented on the Formatter interface. The returned boolean is
+// false if v implements none of these interfaces, in which case the encoder
+// should use its default representation of v.
+//
+// The function is intended to be used by the implementations of event
+// handlers.
+func FormatValue(v interface{}) (s string, ok bool) {
+	switch x := v.(type) {
+	case Formatter:
+		return x.FormatEventValue(), true
+	case error:
+		return x.Error(), true
+	case encoding.TextMarshaler:
+		if b, err := x.MarshalText(); err == nil {
+			return string(b), true
+		}
+		return fmt.Sprint(v), true
+	case fmt.Stringer:
+		return x.String(), true
+	case []byte:
+		return string(AppendBytes(nil, x, BytesDefault)), true
+	default:
+		return "", false
+	}
+}
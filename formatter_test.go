@@ -0,0 +1,108 @@
+package events_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/segmentio/events"
+	"github.com/segmentio/events/logfmt"
+	"github.com/segmentio/events/text"
+)
+
+// The value types below implement decreasing subsets of the interfaces checked
+// by events.FormatValue, each encoder must render them with the method of
+// highest precedence.
+
+type formatterValue struct{ errorValue }
+
+func (formatterValue) FormatEventValue() string { return "formatter" }
+
+type errorValue struct{ textValue }
+
+func (errorValue) Error() string { return "error" }
+
+type textValue struct{ stringerValue }
+
+func (textValue) MarshalText() ([]byte, error) { return []byte("text"), nil }
+
+type stringerValue struct{}
+
+func (stringerValue) String() string { return "stringer" }
+
+var formatterTests = []struct {
+	value interface{}
+	text  string
+}{
+	{formatterValue{}, "formatter"},
+	{errorValue{}, "error"},
+	{textValue{}, "text"},
+	{stringerValue{}, "stringer"},
+	{&formatterValue{}, "formatter"},
+}
+
+func TestFormatValue(t *testing.T) {
+	for _, test := range formatterTests {
+		if s, ok := events.FormatValue(test.value); !ok || s != test.text {
+			t.Errorf("%T: %q %t", test.value, s, ok)
+		}
+	}
+
+	for _, v := range []interface{}{nil, 42, "hello", []int{1}} {
+		if s, ok := events.FormatValue(v); ok {
+			t.Errorf("%#v: %q %t", v, s, ok)
+		}
+	}
+}
+
+// TestFormatterConformance verifies that all the text-based encoders apply the
+// same order of precedence to argument values.
+func TestFormatterConformance(t *testing.T) {
+	encoders := []struct {
+		name   string
+		encode func(events.Arg) string
+	}{
+		{
+			name: "logfmt",
+			encode: func(a events.Arg) string {
+				return string(logfmt.AppendArg(nil, a))
+			},
+		},
+		{
+			name: "text:layout",
+			encode: func(a events.Arg) string {
+				b := &bytes.Buffer{}
+				h := text.NewHandler("", b)
+				h.Layout = "{args}"
+				h.HandleEvent(&events.Event{Args: events.Args{a}})
+				return strings.TrimSuffix(b.String(), "\n")
+			},
+		},
+		{
+			name: "text:EnableArgs",
+			encode: func(a events.Arg) string {
+				b := &bytes.Buffer{}
+				h := text.NewHandler("", b)
+				h.TimeFormat = ""
+				h.EnableArgs = true
+				h.HandleEvent(&events.Event{Args: events.Args{a}})
+				s := strings.TrimSpace(b.String())
+				// errors are listed without their argument name
+				if strings.HasPrefix(s, "errors:") {
+					return a.Name + "=" + strings.TrimPrefix(s, "errors:\n\t\t- ")
+				}
+				return strings.Replace(s, ": ", "=", 1)
+			},
+		},
+	}
+
+	for _, enc := range encoders {
+		t.Run(enc.name, func(t *testing.T) {
+			for _, test := range formatterTests {
+				if s := enc.encode(events.Arg{"v", test.value}); s != "v="+test.text {
+					t.Errorf("%T: %q", test.value, s)
+				}
+			}
+		})
+	}
+}
@@ -1,5 +1,10 @@
 package events
 
+import (
+	"context"
+	"sort"
+)
+
 // The Handler interface is implemented by types that intend to be event routers
 // or apply transformations to an event before forwarding it to another handler.
 type Handler interface {
@@ -21,11 +26,71 @@ func (f HandlerFunc) HandleEvent(e *Event) {
 	f(e)
 }
 
+// The NextHandler interface may be implemented by handlers that want to stop
+// the propagation of events when they are called by a MultiHandler.
+type NextHandler interface {
+	Handler
+
+	// HandleEventNext is called instead of HandleEvent by the MultiHandler,
+	// returning true prevents the event from being passed to the handlers
+	// that come next.
+	HandleEventNext(e *Event) (stop bool)
+}
+
+// WithPriority returns a Handler wrapping h which is ordered by priority when
+// passed to MultiHandler. Handlers with higher priorities are called first,
+// handlers that weren't wrapped have a priority of zero.
+func WithPriority(h Handler, priority int) Handler {
+	return &priorityHandler{
+		Handler:  h,
+		priority: priority,
+	}
+}
+
+type priorityHandler struct {
+	Handler
+	priority int
+}
+
+func (p *priorityHandler) HandleEventNext(e *Event) bool {
+	return handleEventNext(p.Handler, e)
+}
+
+func (p *priorityHandler) Unwrap() Handler {
+	return p.Handler
+}
+
+func (p *priorityHandler) Flush() error {
+	return flushHandler(p.Handler)
+}
+
+func (p *priorityHandler) Close() error {
+	return closeHandler(p.Handler)
+}
+
+func priorityOf(h Handler) int {
+	if p, ok := h.(*priorityHandler); ok {
+		return p.priority
+	}
+	return 0
+}
+
 // MultiHandler returns a new Handler which broadcasts the events it receives
 // to its list of handlers.
+//
+// The handlers are called in order of decreasing priority (see WithPriority),
+// handlers with equal priorities are called in the order they were passed to
+// the function. If one of the handlers implements NextHandler and returns true
+// from its HandleEventNext method the event is not passed to the handlers that
+// come after it. The returned handler is also a NextHandler which reports that
+// the propagation was stopped, allowing MultiHandler values to be nested.
+//
+// Panics raised by the handlers are recovered and reported to the other
+// handlers with a diagnostic event, see Breaker.
 func MultiHandler(handlers ...Handler) Handler {
 	c := make([]Handler, len(handlers))
 	copy(c, handlers)
+	sort.Stable(byPriority(c))
 	return &multiHandler{
 		handlers: c,
 	}
@@ -36,15 +101,134 @@ type multiHandler struct {
 }
 
 func (m *multiHandler) HandleEvent(e *Event) {
+	m.HandleEventNext(e)
+}
+
+func (m *multiHandler) HandleEventNext(e *Event) bool {
+	for i, h := range m.handlers {
+		stop, diag := safeCall(h, func() bool { return handleEventNext(h, e) })
+
+		if diag != nil {
+			m.report(i, diag)
+		}
+
+		if stop {
+			return true
+		}
+	}
+	return false
+}
+
+// report passes the diagnostic event produced by a panic of the handler at
+// index i to the other handlers.
+func (m *multiHandler) report(i int, diag *Event) {
+	for j, h := range m.handlers {
+		if j != i {
+			safeCall(h, func() bool { h.HandleEvent(diag); return false })
+		}
+	}
+}
+
+// Unwrap returns the list of handlers that events are passed to.
+func (m *multiHandler) Unwrap() []Handler {
+	return m.handlers
+}
+
+// Flush flushes all handlers that implement the Flusher interface, returning
+// the first error that occurred.
+func (m *multiHandler) Flush() (err error) {
 	for _, h := range m.handlers {
-		h.HandleEvent(e)
+		if e := flushHandler(h); err == nil {
+			err = e
+		}
 	}
+	return
 }
 
-var (
-	// Discard is a handler that does nothing with the events it receives.
-	Discard Handler = HandlerFunc(func(e *Event) {})
+// Close closes all handlers that implement the io.Closer interface, returning
+// the first error that occurred.
+func (m *multiHandler) Close() (err error) {
+	for _, h := range m.handlers {
+		if e := closeHandler(h); err == nil {
+			err = e
+		}
+	}
+	return
+}
 
-	// DefaultHandler is the default handler used when non is specified.
-	DefaultHandler Handler = Discard
-)
+// FilterHandler returns a Handler which passes to h the events for which keep
+// returns true, and drops the others. Predicates may be compiled from filter
+// expressions with ParseFilter, for example:
+//
+//	keep, _ := events.ParseFilter(`debug == false && source =~ "example.com/app/*"`)
+//	h := events.FilterHandler(console, keep)
+//
+// Diagnostic events are always passed to h.
+func FilterHandler(h Handler, keep func(*Event) bool) Handler {
+	return &filterHandler{
+		handler: h,
+		keep:    keep,
+	}
+}
+
+type filterHandler struct {
+	handler Handler
+	keep    func(*Event) bool
+}
+
+func (f *filterHandler) HandleEvent(e *Event) {
+	if IsDiagnostic(e) || f.keep(e) {
+		f.handler.HandleEvent(e)
+	}
+}
+
+func (f *filterHandler) HandleEventSync(ctx context.Context, e *Event) error {
+	if IsDiagnostic(e) || f.keep(e) {
+		return syncStage(f, HandleEventSync(ctx, f.handler, e))
+	}
+	return nil
+}
+
+func (f *filterHandler) HandleEventContext(ctx context.Context, e *Event) error {
+	if IsDiagnostic(e) || f.keep(e) {
+		return HandleEventContext(ctx, f.handler, e)
+	}
+	return nil
+}
+
+func (f *filterHandler) Unwrap() Handler {
+	return f.handler
+}
+
+func (f *filterHandler) Flush() error {
+	return flushHandler(f.handler)
+}
+
+func (f *filterHandler) Close() error {
+	return closeHandler(f.handler)
+}
+
+func handleEventNext(h Handler, e *Event) bool {
+	if n, ok := h.(NextHandler); ok {
+		return n.HandleEventNext(e)
+	}
+	h.HandleEvent(e)
+	return false
+}
+
+type byPriority []Handler
+
+func (h byPriority) Len() int {
+	return len(h)
+}
+
+func (h byPriority) Less(i int, j int) bool {
+	return priorityOf(h[i]) > priorityOf(h[j])
+}
+
+func (h byPriority) Swap(i int, j int) {
+	h[i], h[j] = h[j], h[i]
+}
+
+// Discard is a handler that does nothing with the events it receives.
+var Discard Handler = HandlerFunc(func(e *Event) {})
@@ -1,6 +1,9 @@
 package events
 
-import "testing"
+import (
+	"reflect"
+	"testing"
+)
 
 func TestMultiHandler(t *testing.T) {
 	n := 0
@@ -15,3 +18,125 @@ func TestMultiHandler(t *testing.T) {
 		t.Error("bad count of handler received the event:", n)
 	}
 }
+
+type stopHandler struct {
+	name  string
+	stop  bool
+	calls *[]string
+}
+
+func (h *stopHandler) HandleEvent(e *Event) {
+	h.HandleEventNext(e)
+}
+
+func (h *stopHandler) HandleEventNext(e *Event) bool {
+	*h.calls = append(*h.calls, h.name)
+	return h.stop
+}
+
+func TestMultiHandlerNext(t *testing.T) {
+	tests := []struct {
+		name  string
+		stop  []bool
+		calls []string
+	}{
+		{
+			name:  "no-stop",
+			stop:  []bool{false, false, false},
+			calls: []string{"A", "B", "C"},
+		},
+		{
+			name:  "stop-at-first",
+			stop:  []bool{true, false, false},
+			calls: []string{"A"},
+		},
+		{
+			name:  "stop-in-middle",
+			stop:  []bool{false, true, false},
+			calls: []string{"A", "B"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			calls := []string{}
+			m := MultiHandler(
+				&stopHandler{name: "A", stop: test.stop[0], calls: &calls},
+				&stopHandler{name: "B", stop: test.stop[1], calls: &calls},
+				&stopHandler{name: "C", stop: test.stop[2], calls: &calls},
+			)
+			m.HandleEvent(&Event{})
+
+			if !reflect.DeepEqual(calls, test.calls) {
+				t.Error("bad calls:", calls)
+			}
+		})
+	}
+}
+
+func TestMultiHandlerPriority(t *testing.T) {
+	calls := []string{}
+	h := func(name string) Handler {
+		return HandlerFunc(func(e *Event) { calls = append(calls, name) })
+	}
+
+	m := MultiHandler(
+		h("A"),
+		WithPriority(h("B"), -1),
+		WithPriority(h("C"), 10),
+		h("D"),
+		WithPriority(h("E"), 10),
+		WithPriority(&stopHandler{name: "F", stop: true, calls: &calls}, -1),
+		h("G"),
+		WithPriority(h("H"), -2),
+	)
+	m.HandleEvent(&Event{})
+
+	if !reflect.DeepEqual(calls, []string{"C", "E", "A", "D", "G", "B", "F"}) {
+		t.Error("bad calls:", calls)
+	}
+}
+
+func TestMultiHandlerNested(t *testing.T) {
+	calls := []string{}
+	m := MultiHandler(
+		MultiHandler(
+			&stopHandler{name: "A", calls: &calls},
+			&stopHandler{name: "B", stop: true, calls: &calls},
+		),
+		&stopHandler{name: "C", calls: &calls},
+	)
+	m.HandleEvent(&Event{})
+
+	if !reflect.DeepEqual(calls, []string{"A", "B"}) {
+		t.Error("bad calls:", calls)
+	}
+}
+
+func TestFilterHandler(t *testing.T) {
+	keep, err := ParseFilter(`debug == false && source =~ "app/*"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := &eventRecorder{}
+	h := FilterHandler(r, keep)
+
+	h.HandleEvent(&Event{Message: "A", Source: "app/main.go:1"})
+	h.HandleEvent(&Event{Message: "B", Source: "app/main.go:2", Debug: true})
+	h.HandleEvent(&Event{Message: "C", Source: "lib/lib.go:3"})
+	h.HandleEvent(Diagnostic("D"))
+
+	var messages []string
+	for _, e := range r.events {
+		messages = append(messages, e.Message)
+	}
+
+	if !reflect.DeepEqual(messages, []string{"A", "D"}) {
+		t.Error("bad events passed through the filter:", messages)
+	}
+
+	if u := h.(interface{ Unwrap() Handler }).Unwrap(); u != r {
+		t.Error("bad unwrapped handler:", u)
+	}
+}
@@ -0,0 +1,157 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// HealthStatus values describe the state of a handler.
+type HealthStatus int
+
+const (
+	// Healthy handlers deliver events normally.
+	Healthy HealthStatus = iota
+
+	// Degraded handlers deliver events but had problems recently, like a full
+	// queue or a failed write.
+	Degraded
+
+	// Unhealthy handlers don't deliver events anymore.
+	Unhealthy
+)
+
+// String returns a human-readable representation of s.
+func (s HealthStatus) String() string {
+	switch s {
+	case Healthy:
+		return "healthy"
+	case Degraded:
+		return "degraded"
+	case Unhealthy:
+		return "unhealthy"
+	default:
+		return fmt.Sprintf("HealthStatus(%d)", int(s))
+	}
+}
+
+// MarshalText satisfies the encoding.TextMarshaler interface.
+func (s HealthStatus) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Health is the report of the state of a handler.
+type Health struct {
+	Handler    string       // type of the handler, set by HealthOf
+	Status     HealthStatus // state of the handler
+	Message    string       // description of the state, empty when healthy
+	QueueDepth int          // number of events waiting to be handled
+	QueueSize  int          // capacity of the queue
+	Dropped    int64        // number of events dropped since the handler was created
+	Errors     int64        // number of events that failed to be delivered
+	LastWrite  time.Time    // time of the last successful delivery
+	LastError  time.Time    // time of the last failure
+}
+
+// MarshalJSON satisfies the json.Marshaler interface, the zero times and
+// counters are omitted.
+func (h Health) MarshalJSON() ([]byte, error) {
+	type health struct {
+		Handler    string       `json:"handler"`
+		Status     HealthStatus `json:"status"`
+		Message    string       `json:"message,omitempty"`
+		QueueDepth int          `json:"queue_depth,omitempty"`
+		QueueSize  int          `json:"queue_size,omitempty"`
+		Dropped    int64        `json:"dropped,omitempty"`
+		Errors     int64        `json:"errors,omitempty"`
+		LastWrite  *time.Time   `json:"last_write,omitempty"`
+		LastError  *time.Time   `json:"last_error,omitempty"`
+	}
+
+	v := health{
+		Handler:    h.Handler,
+		Status:     h.Status,
+		Message:    h.Message,
+		QueueDepth: h.QueueDepth,
+		QueueSize:  h.QueueSize,
+		Dropped:    h.Dropped,
+		Errors:     h.Errors,
+	}
+
+	if !h.LastWrite.IsZero() {
+		v.LastWrite = &h.LastWrite
+	}
+
+	if !h.LastError.IsZero() {
+		v.LastError = &h.LastError
+	}
+
+	return json.Marshal(v)
+}
+
+// The HealthReporter interface may be implemented by handlers that can report
+// on their state.
+type HealthReporter interface {
+	EventHealth() Health
+}
+
+// HealthOf returns the health reports of h and of the handlers it wraps, in
+// depth-first order starting with h. Handlers that don't implement
+// HealthReporter are skipped.
+//
+// The wrapped handlers are discovered through the Unwrap method of handlers,
+// which returns either a Handler or a []Handler. The wrappers of this package
+// implement it, except the ones built on HandlerFunc.
+func HealthOf(h Handler) []Health {
+	var reports []Health
+	walkHandlers(h, make(map[breakerKey]bool), func(h Handler) {
+		if r, ok := h.(HealthReporter); ok {
+			report := r.EventHealth()
+			report.Handler = fmt.Sprintf("%T", h)
+			reports = append(reports, report)
+		}
+	})
+	return reports
+}
+
+// WorstHealth returns the most severe status of reports, or Healthy if the
+// list is empty.
+func WorstHealth(reports []Health) HealthStatus {
+	status := Healthy
+	for _, r := range reports {
+		if r.Status > status {
+			status = r.Status
+		}
+	}
+	return status
+}
+
+// walkHandlers calls f with h and the handlers it wraps, each handler is
+// visited once so handlers that wrap the default handler don't cause cycles.
+func walkHandlers(h Handler, seen map[breakerKey]bool, f func(Handler)) {
+	if h == nil {
+		return
+	}
+
+	key := breakerKeyOf(h)
+
+	if seen[key] {
+		return
+	}
+
+	seen[key] = true
+	f(h)
+
+	switch x := h.(type) {
+	case interface {
+		Unwrap() Handler
+	}:
+		walkHandlers(x.Unwrap(), seen, f)
+	case interface {
+		Unwrap() []Handler
+	}:
+		for _, c := range x.Unwrap() {
+			walkHandlers(c, seen, f)
+		}
+	}
+}
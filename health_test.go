@@ -0,0 +1,128 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+// healthSink is a handler which reports a fixed health.
+type healthSink struct {
+	eventRecorder
+	health Health
+}
+
+func (h *healthSink) EventHealth() Health {
+	return h.health
+}
+
+// flakyWriter fails writes while fail is true.
+type flakyWriter struct {
+	fail bool
+}
+
+func (w *flakyWriter) Write(b []byte) (int, error) {
+	if w.fail {
+		return 0, errors.New("connection refused")
+	}
+	return len(b), nil
+}
+
+func TestHealthOf(t *testing.T) {
+	sink := &healthSink{health: Health{Status: Degraded, Message: "reconnecting"}}
+	async := NewAsyncHandler(&eventRecorder{}, AsyncConfig{QueueSize: 10})
+	defer async.Close()
+	writer := NewWriterHandler(&flakyWriter{}, JSONEncoder{})
+
+	h := MultiHandler(
+		NewRateTracker(async, time.Minute, 1),
+		&eventRecorder{},
+		WithPriority(NewOnce(sink), 1),
+		&unwrapper{writer},
+	)
+
+	var types []string
+
+	for _, r := range HealthOf(h) {
+		types = append(types, r.Handler)
+	}
+
+	assertMessages(t, types, "*events.healthSink", "*events.AsyncHandler", "*events.WriterHandler")
+
+	if s := WorstHealth(HealthOf(h)); s != Degraded {
+		t.Error("bad status:", s)
+	}
+}
+
+// unwrapper is a wrapper defined outside of the package's handlers, to test
+// that HealthOf finds the handlers it wraps.
+type unwrapper struct {
+	Handler
+}
+
+func (u *unwrapper) Unwrap() Handler {
+	return u.Handler
+}
+
+func TestHealthOfCycle(t *testing.T) {
+	sink := &healthSink{}
+	old := SwapDefaultHandler(MultiHandler(sink, WithPriority(DefaultHandler, -1)))
+	defer SwapDefaultHandler(old)
+
+	if n := len(HealthOf(DefaultHandler)); n != 1 {
+		t.Error("bad number of reports:", n)
+	}
+}
+
+func TestAsyncHandlerHealth(t *testing.T) {
+	a, g := newBlockedAsyncHandler(t, AsyncConfig{Policy: DropNewest})
+	a.HandleEvent(&Event{Message: "D"})
+
+	want := Health{Status: Degraded, Message: "the queue is full", QueueDepth: 2, QueueSize: 2, Dropped: 1}
+
+	if h := a.EventHealth(); h != want {
+		t.Errorf("bad health: %+v", h)
+	}
+
+	close(g.release)
+	a.Close()
+
+	if h := a.EventHealth(); h.Status != Unhealthy || h.QueueDepth != 0 {
+		t.Errorf("bad health: %+v", h)
+	}
+}
+
+func TestWriterHandlerHealth(t *testing.T) {
+	w := &flakyWriter{}
+	h := NewWriterHandler(w, JSONEncoder{})
+
+	if r := h.EventHealth(); r.Status != Healthy || !r.LastWrite.IsZero() {
+		t.Errorf("bad health: %+v", r)
+	}
+
+	h.HandleEvent(&Event{Message: "A"})
+	w.fail = true
+	h.HandleEvent(&Event{Message: "B"})
+
+	r := h.EventHealth()
+
+	if r.Status != Degraded || r.Message != "connection refused" || r.Errors != 1 || r.LastWrite.IsZero() || r.LastError.Before(r.LastWrite) {
+		t.Errorf("bad health: %+v", r)
+	}
+
+	w.fail = false
+	h.HandleEvent(&Event{Message: "C"})
+
+	if r := h.EventHealth(); r.Status != Healthy || r.Errors != 1 {
+		t.Errorf("bad health: %+v", r)
+	}
+}
+
+func TestHealthJSON(t *testing.T) {
+	b, _ := json.Marshal(Health{Handler: "*events.healthSink", Status: Degraded, Message: "reconnecting", Dropped: 3})
+
+	if s := string(b); s != `{"handler":"*events.healthSink","status":"degraded","message":"reconnecting","dropped":3}` {
+		t.Error("bad JSON:", s)
+	}
+}
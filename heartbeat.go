@@ -0,0 +1,147 @@
+package events
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// The DropCounter interface may be implemented by handlers which discard
+// events, to report how many events they have dropped since they were created.
+type DropCounter interface {
+	Dropped() int64
+}
+
+// NewHeartbeat returns a handler which forwards events to h, and emits a
+// heartbeat event to h on each interval, so the absence of heartbeats can be
+// noticed when the program stops producing events.
+//
+// Heartbeats are diagnostic events (see Diagnostic) carrying these arguments:
+//
+//	handled:    number of events other than diagnostics that passed through the
+//	            returned handler since the last heartbeat
+//	dropped:    number of events dropped since the last heartbeat by the
+//	            handlers implementing DropCounter found in h, and in the
+//	            handlers that it wraps (through their Unwrap method)
+//	uptime:     time since the program started, as a time.Duration
+//	goroutines: number of goroutines of the program
+//
+// The heartbeats stop when the returned stop function is called, no heartbeat is
+// emitted after stop returned.
+func NewHeartbeat(h Handler, interval time.Duration) (handler Handler, stop func()) {
+	b := &heartbeatHandler{
+		handler: h,
+		done:    make(chan struct{}),
+		exit:    make(chan struct{}),
+	}
+
+	b.dropped = countDropped(h)
+	tick, stopTicker := newTicker(interval)
+	go b.run(tick, stopTicker)
+
+	return b, b.stop
+}
+
+type heartbeatHandler struct {
+	handled int64 // accessed atomically
+	handler Handler
+	dropped int64 // dropped count at the time of the last heartbeat
+	once    sync.Once
+	done    chan struct{}
+	exit    chan struct{}
+}
+
+func (b *heartbeatHandler) HandleEvent(e *Event) {
+	b.handle(e, forwardEvent)
+}
+
+func (b *heartbeatHandler) HandleEventSync(ctx context.Context, e *Event) error {
+	return handleSync(ctx, b, func(forward func(Handler, *Event)) { b.handle(e, forward) })
+}
+
+func (b *heartbeatHandler) HandleEventContext(ctx context.Context, e *Event) error {
+	return handleContext(ctx, func(forward func(Handler, *Event)) { b.handle(e, forward) })
+}
+
+func (b *heartbeatHandler) handle(e *Event, forward func(Handler, *Event)) {
+	if !IsDiagnostic(e) {
+		atomic.AddInt64(&b.handled, 1)
+	}
+	forward(b.handler, e)
+}
+
+func (b *heartbeatHandler) Unwrap() Handler {
+	return b.handler
+}
+
+func (b *heartbeatHandler) Flush() error {
+	return flushHandler(b.handler)
+}
+
+func (b *heartbeatHandler) Close() error {
+	b.stop()
+	return closeHandler(b.handler)
+}
+
+func (b *heartbeatHandler) stop() {
+	b.once.Do(func() { close(b.done) })
+	<-b.exit
+}
+
+func (b *heartbeatHandler) run(tick <-chan time.Time, stop func()) {
+	defer close(b.exit)
+	defer stop()
+
+	for {
+		select {
+		case <-b.done:
+			return
+		case now := <-tick:
+			// Both channels may be ready, stopping takes precedence.
+			select {
+			case <-b.done:
+				return
+			default:
+			}
+			b.beat(now)
+		}
+	}
+}
+
+func (b *heartbeatHandler) beat(now time.Time) {
+	dropped := countDropped(b.handler)
+
+	e := Diagnostic("heartbeat",
+		Arg{"handled", atomic.SwapInt64(&b.handled, 0)},
+		Arg{"dropped", dropped - b.dropped},
+		Arg{"uptime", now.Sub(processStart)},
+		Arg{"goroutines", runtime.NumGoroutine()},
+	)
+	e.Time = now
+	b.handler.HandleEvent(e)
+
+	b.dropped = dropped
+}
+
+// countDropped returns the sum of the counts of dropped events reported by h,
+// and by the handlers that it wraps (see HealthOf).
+func countDropped(h Handler) (n int64) {
+	walkHandlers(h, make(map[breakerKey]bool), func(h Handler) {
+		if c, ok := h.(DropCounter); ok {
+			n += c.Dropped()
+		}
+	})
+	return
+}
+
+// processStart is an approximation of the time at which the program started.
+var processStart = time.Now()
+
+// newTicker returns a channel delivering ticks every d, and a function to stop
+// it. It is replaced in tests.
+var newTicker = func(d time.Duration) (<-chan time.Time, func()) {
+	t := time.NewTicker(d)
+	return t.C, t.Stop
+}
@@ -0,0 +1,127 @@
+package events
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type dropCounterHandler struct {
+	eventRecorder
+	dropped int64
+}
+
+func (h *dropCounterHandler) Dropped() int64 {
+	return atomic.LoadInt64(&h.dropped)
+}
+
+// fakeTicker replaces the ticker used by heartbeats and aggregators until
+// restore is called, the returned channel delivers the ticks.
+func fakeTicker() (tick chan time.Time, stopped *int32, restore func()) {
+	tick, stopped = make(chan time.Time, 1), new(int32)
+	prev := newTicker
+	newTicker = func(time.Duration) (<-chan time.Time, func()) {
+		return tick, func() { atomic.StoreInt32(stopped, 1) }
+	}
+	restore = func() { newTicker = prev }
+	return
+}
+
+func TestHeartbeat(t *testing.T) {
+	tick, stopped, restore := fakeTicker()
+	defer restore()
+
+	r := &eventRecorder{}
+	d := &dropCounterHandler{dropped: 10}
+	h, stop := NewHeartbeat(MultiHandler(r, WithPriority(d, 1)), time.Second)
+
+	h.HandleEvent(&Event{Message: "A"})
+	h.HandleEvent(&Event{Message: "B"})
+	h.HandleEvent(&Event{Message: "C"})
+	atomic.AddInt64(&d.dropped, 2)
+
+	now := processStart.Add(time.Minute)
+	tick <- now
+
+	for _, m := range []string{"A", "B", "C"} {
+		if e := r.wait(t); e.Message != m {
+			t.Fatal("bad event:", e)
+		}
+	}
+
+	want := &Event{
+		Message: "heartbeat",
+		Source:  DiagnosticSource,
+		Time:    now,
+		Args:    Args{{"handled", int64(3)}, {"dropped", int64(2)}, {"uptime", time.Minute}},
+	}
+
+	e := r.wait(t)
+	e.Args = e.Args[:3] // the number of goroutines isn't predictable
+
+	if diff := Diff(want, e); len(diff) != 0 {
+		t.Error(diff)
+	}
+
+	// The counters are reset on each heartbeat.
+	h.HandleEvent(&Event{Message: "D"})
+	tick <- now.Add(time.Second)
+	r.wait(t)
+
+	if e := r.wait(t); e.Args.Map()["handled"] != int64(1) || e.Args.Map()["dropped"] != int64(0) {
+		t.Error("bad heartbeat:", e.Args)
+	}
+
+	stop()
+	stop() // idempotent
+
+	if atomic.LoadInt32(stopped) == 0 {
+		t.Error("the ticker was not stopped")
+	}
+
+	select {
+	case tick <- now.Add(2 * time.Second):
+	default:
+		t.Fatal("the tick channel was not drained")
+	}
+
+	time.Sleep(10 * time.Millisecond)
+
+	if n := r.len(); n != 0 {
+		t.Error("heartbeat emitted after stop:", n)
+	}
+}
+
+func TestHeartbeatClose(t *testing.T) {
+	tick, stopped, restore := fakeTicker()
+	defer restore()
+
+	d := &drainHandler{}
+	h, _ := NewHeartbeat(d, time.Second)
+
+	if err := h.(interface{ Close() error }).Close(); err != nil {
+		t.Error(err)
+	}
+
+	if atomic.LoadInt32(&d.closed) == 0 || atomic.LoadInt32(stopped) == 0 {
+		t.Error("the heartbeat was not stopped by Close")
+	}
+
+	tick <- processStart
+
+	if n := atomic.LoadInt64(&d.count); n != 0 {
+		t.Error("heartbeat emitted after Close:", n)
+	}
+}
+
+func TestCountDropped(t *testing.T) {
+	d1 := &dropCounterHandler{dropped: 1}
+	d2 := &dropCounterHandler{dropped: 2}
+
+	// The counters are found through the wrappers, each one is counted once.
+	h := FilterHandler(MultiHandler(NewMutatorChain(d1), ExpandErrors(d2), d1), func(*Event) bool { return true })
+
+	if n := countDropped(h); n != 3 {
+		t.Error("bad count of dropped events:", n)
+	}
+}
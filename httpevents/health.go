@@ -0,0 +1,48 @@
+package httpevents
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/segmentio/events"
+)
+
+// NewHealthHandler returns an HTTP handler which renders the health reports of
+// h and of the handlers it wraps (see events.HealthOf) as a JSON object, for
+// example:
+//
+//	{
+//	  "status": "degraded",
+//	  "handlers": [
+//	    {"handler": "*events.AsyncHandler", "status": "degraded", "message": "the queue is full", ...},
+//	    ...
+//	  ]
+//	}
+//
+// The response status is 503 when at least one handler is unhealthy, and 200
+// otherwise. Passing events.DefaultHandler as h reports the health of the
+// handler installed as default handler when the request is served.
+func NewHealthHandler(h events.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		reports := events.HealthOf(h)
+
+		if reports == nil {
+			reports = []events.Health{}
+		}
+
+		status := events.WorstHealth(reports)
+		code := http.StatusOK
+
+		if status == events.Unhealthy {
+			code = http.StatusServiceUnavailable
+		}
+
+		res.Header().Set("Content-Type", "application/json")
+		res.WriteHeader(code)
+
+		json.NewEncoder(res).Encode(struct {
+			Status   events.HealthStatus `json:"status"`
+			Handlers []events.Health     `json:"handlers"`
+		}{status, reports})
+	})
+}
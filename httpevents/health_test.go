@@ -0,0 +1,66 @@
+package httpevents
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/segmentio/events"
+)
+
+type healthSink struct {
+	health events.Health
+}
+
+func (h *healthSink) HandleEvent(e *events.Event) {}
+
+func (h *healthSink) EventHealth() events.Health {
+	return h.health
+}
+
+func TestHealthHandler(t *testing.T) {
+	tests := []struct {
+		handler events.Handler
+		code    int
+		body    string
+	}{
+		{
+			handler: events.MultiHandler(),
+			code:    http.StatusOK,
+			body:    `{"status":"healthy","handlers":[]}` + "\n",
+		},
+		{
+			handler: events.MultiHandler(
+				&healthSink{events.Health{Status: events.Healthy}},
+				&healthSink{events.Health{Status: events.Degraded, Message: "reconnecting", Errors: 2}},
+			),
+			code: http.StatusOK,
+			body: `{"status":"degraded","handlers":[` +
+				`{"handler":"*httpevents.healthSink","status":"healthy"},` +
+				`{"handler":"*httpevents.healthSink","status":"degraded","message":"reconnecting","errors":2}` +
+				`]}` + "\n",
+		},
+		{
+			handler: &healthSink{events.Health{Status: events.Unhealthy, Message: "closed"}},
+			code:    http.StatusServiceUnavailable,
+			body:    `{"status":"unhealthy","handlers":[{"handler":"*httpevents.healthSink","status":"unhealthy","message":"closed"}]}` + "\n",
+		},
+	}
+
+	for _, test := range tests {
+		res := httptest.NewRecorder()
+		NewHealthHandler(test.handler).ServeHTTP(res, httptest.NewRequest("GET", "/health", nil))
+
+		if res.Code != test.code {
+			t.Error("bad status code:", res.Code)
+		}
+
+		if ct := res.Header().Get("Content-Type"); ct != "application/json" {
+			t.Error("bad content type:", ct)
+		}
+
+		if s := res.Body.String(); s != test.body {
+			t.Errorf("bad body:\n%s", s)
+		}
+	}
+}
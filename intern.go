@@ -0,0 +1,129 @@
+package events
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultInternMaxLength is the default length above which strings are not
+// interned by an Interner.
+const DefaultInternMaxLength = 128
+
+// Interner is a table of canonical strings, used by Event.CloneInterned to
+// share the strings that repeat across events (like HTTP methods, status codes
+// or region names) instead of copying them in each clone, reducing the memory
+// used by handlers that retain many events.
+//
+// The table holds at most the number of entries given to NewInterner, the
+// entries that weren't used recently are evicted first (with the CLOCK
+// algorithm) when a new string is added to a full table.
+//
+// It is safe to use an interner concurrently from multiple goroutines.
+type Interner struct {
+	// MaxLength is the length above which strings are not interned, it
+	// defaults to DefaultInternMaxLength and may be changed before the
+	// interner is used.
+	MaxLength int
+
+	mutex   sync.RWMutex
+	index   map[string]int
+	entries []internEntry
+	hand    int // next entry considered for eviction
+}
+
+type internEntry struct {
+	value string
+	boxed interface{} // value converted to an interface, shared by the clones
+	used  uint32      // set when the entry is looked up, accessed atomically
+}
+
+// NewInterner returns an Interner which holds at most maxEntries strings.
+//
+// The function panics if maxEntries is lower than 1.
+func NewInterner(maxEntries int) *Interner {
+	if maxEntries < 1 {
+		panic("events.NewInterner: the maximum number of entries must be positive")
+	}
+
+	return &Interner{
+		MaxLength: DefaultInternMaxLength,
+		index:     make(map[string]int, maxEntries),
+		entries:   make([]internEntry, 0, maxEntries),
+	}
+}
+
+// Intern returns the canonical instance of s, adding a copy of s to the table
+// if it wasn't already there. Strings longer than MaxLength are returned
+// unchanged.
+func (in *Interner) Intern(s string) string {
+	if c, _, ok := in.intern(s); ok {
+		return c
+	}
+	return s
+}
+
+// Len returns the number of strings held by the interner.
+func (in *Interner) Len() int {
+	in.mutex.RLock()
+	n := len(in.entries)
+	in.mutex.RUnlock()
+	return n
+}
+
+// intern returns the canonical instance of s, and the same string converted
+// to an interface so clones can share it without allocating. The returned
+// boolean is false if s is too long to be interned.
+func (in *Interner) intern(s string) (string, interface{}, bool) {
+	if len(s) == 0 {
+		return "", "", true
+	}
+
+	if len(s) > in.MaxLength {
+		return "", nil, false
+	}
+
+	in.mutex.RLock()
+
+	if i, ok := in.index[s]; ok {
+		e := &in.entries[i]
+		atomic.StoreUint32(&e.used, 1)
+		c, v := e.value, e.boxed
+		in.mutex.RUnlock()
+		return c, v, true
+	}
+
+	in.mutex.RUnlock()
+	in.mutex.Lock()
+	defer in.mutex.Unlock()
+
+	if i, ok := in.index[s]; ok {
+		e := &in.entries[i]
+		return e.value, e.boxed, true
+	}
+
+	// The string may be backed by a buffer that gets reused, the table holds
+	// its own copy.
+	c := string(append(make([]byte, 0, len(s)), s...))
+	e := internEntry{value: c, boxed: c}
+
+	if len(in.entries) < cap(in.entries) {
+		in.index[c] = len(in.entries)
+		in.entries = append(in.entries, e)
+		return e.value, e.boxed, true
+	}
+
+	for {
+		old := &in.entries[in.hand]
+
+		if atomic.LoadUint32(&old.used) == 0 {
+			delete(in.index, old.value)
+			in.index[c] = in.hand
+			*old = e
+			in.hand = (in.hand + 1) % len(in.entries)
+			return e.value, e.boxed, true
+		}
+
+		atomic.StoreUint32(&old.used, 0)
+		in.hand = (in.hand + 1) % len(in.entries)
+	}
+}
@@ -0,0 +1,161 @@
+package events
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"unsafe"
+)
+
+func stringData(s string) uintptr {
+	return (*reflect.StringHeader)(unsafe.Pointer(&s)).Data
+}
+
+func TestInterner(t *testing.T) {
+	in := NewInterner(2)
+	in.MaxLength = 8
+
+	buf := []byte("GET")
+	a := in.Intern(string(buf))
+	b := in.Intern("GET")
+
+	if a != "GET" || stringData(a) != stringData(b) {
+		t.Error("the string was not interned")
+	}
+
+	if long := "0123456789"; stringData(in.Intern(long)) != stringData(long) || in.Len() != 1 {
+		t.Error("strings longer than MaxLength must not be interned")
+	}
+
+	// The table is bounded, the entry that wasn't used since it was added is
+	// evicted first.
+	in.Intern("POST")
+	in.Intern("GET")
+	in.Intern("PUT")
+
+	if n := in.Len(); n != 2 {
+		t.Error("bad number of entries:", n)
+	}
+
+	if stringData(in.Intern("GET")) != stringData(a) {
+		t.Error("the entry that was used recently was evicted")
+	}
+}
+
+func TestInternerConcurrent(t *testing.T) {
+	in := NewInterner(100)
+	wg := sync.WaitGroup{}
+
+	for i := 0; i != 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j != 10000; j++ {
+				s := strconv.Itoa((i * j) % 150)
+				if c := in.Intern(s); c != s {
+					t.Errorf("%q != %q", c, s)
+					return
+				}
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	if n := in.Len(); n > 100 {
+		t.Error("the interner grew beyond its maximum number of entries:", n)
+	}
+}
+
+func TestCloneInterned(t *testing.T) {
+	type point struct{ X, Y int }
+
+	in := NewInterner(10)
+	data := []byte{1, 2, 3}
+	e := &Event{
+		Message: "GET /",
+		Source:  "server.go:42",
+		Args: Args{
+			{"method", "GET"},
+			{"body", data},
+			{"wrapped", Bytes(data)},
+			{"point", point{1, 2}},
+		},
+	}
+
+	c1 := e.CloneInterned(in)
+	c2 := e.CloneInterned(in)
+
+	if !reflect.DeepEqual(e, c1) || !reflect.DeepEqual(e, c2) {
+		t.Errorf("bad clones:\n%+v\n%+v", c1, c2)
+	}
+
+	for _, s := range [][2]string{
+		{c1.Message, c2.Message},
+		{c1.Source, c2.Source},
+		{c1.Args[0].Name, c2.Args[0].Name},
+		{c1.Args[0].Value.(string), c2.Args[0].Value.(string)},
+	} {
+		if stringData(s[0]) != stringData(s[1]) {
+			t.Errorf("%q was not interned", s[0])
+		}
+	}
+
+	// Values that aren't strings are still copied.
+	data[0] = 42
+
+	if c1.Args[1].Value.([]byte)[0] != 1 || c2.Args[2].Value.(BytesValue).Data[0] != 1 {
+		t.Error("the clones share byte slices with the original event")
+	}
+
+	if c1.CloneInterned(nil).Message != "GET /" {
+		t.Error("cloning without an interner failed")
+	}
+}
+
+// BenchmarkAsyncQueueMemory measures the memory retained by the clones of
+// 10k events queued by a buffering handler, the strings of the events repeat
+// like they do with HTTP requests.
+func BenchmarkAsyncQueueMemory(b *testing.B) {
+	const n = 10000
+	var methods = []string{"GET", "POST", "PUT", "DELETE"}
+	var regions = []string{"us-east-1", "us-west-2", "eu-west-1"}
+
+	events := make([]*Event, n)
+
+	for i := range events {
+		// The strings are built on each event like a parser would do.
+		events[i] = &Event{
+			Message: fmt.Sprintf("%s /api/users", methods[i%len(methods)]),
+			Source:  fmt.Sprintf("server.go:%d", 42),
+			Args: Args{
+				{"method", fmt.Sprint(methods[i%len(methods)])},
+				{"status", strconv.Itoa(200 + 100*(i%4))},
+				{"region", fmt.Sprint(regions[i%len(regions)])},
+				{"id", i},
+			},
+		}
+	}
+
+	for _, test := range []struct {
+		name string
+		in   func() *Interner
+	}{
+		{"clone", func() *Interner { return nil }},
+		{"interned", func() *Interner { return NewInterner(1000) }},
+	} {
+		b.Run(test.name, func(b *testing.B) {
+			b.ReportAllocs()
+			queue := make([]*Event, n)
+
+			for i := 0; i != b.N; i++ {
+				in := test.in()
+				for j, e := range events {
+					queue[j] = e.CloneInterned(in)
+				}
+			}
+		})
+	}
+}
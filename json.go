@@ -0,0 +1,164 @@
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// UseJSONNumber controls how numbers are decoded by Args.UnmarshalJSON and
+// ParseJSONLine. By default, numbers that are integers and fit in 64 bits are
+// decoded as int64 values and other numbers as float64 values. When the
+// variable is set to true numbers are kept as json.Number values, preserving
+// their exact textual representation.
+var UseJSONNumber = false
+
+// MarshalJSON satisfies the json.Marshaler interface.
+//
+// The arguments are encoded as a JSON object, in the order they appear in the
+// list. Values implementing Formatter are encoded as strings, so are errors
+// that don't implement json.Marshaler and byte slices (in hexadecimal). Values
+// that can't be encoded to JSON are encoded as strings with the fmt package.
+func (args Args) MarshalJSON() ([]byte, error) {
+	b := &bytes.Buffer{}
+	b.WriteByte('{')
+
+	for i, a := range args {
+		if i != 0 {
+			b.WriteByte(',')
+		}
+
+		k, _ := json.Marshal(a.Name)
+		v, err := marshalJSONValue(a.Value)
+		if err != nil {
+			return nil, err
+		}
+
+		b.Write(k)
+		b.WriteByte(':')
+		b.Write(v)
+	}
+
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// UnmarshalJSON satisfies the json.Unmarshaler interface.
+//
+// The method expects a JSON object, each of its fields is appended to the
+// argument list in the order they appear in b. Numbers are decoded according
+// to the UseJSONNumber variable.
+func (args *Args) UnmarshalJSON(b []byte) error {
+	if bytes.Equal(bytes.TrimSpace(b), []byte("null")) {
+		return nil
+	}
+
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+
+	var list Args
+
+	if err := decodeJSONObject(d, func(name string, value interface{}) {
+		list = append(list, Arg{name, value})
+	}); err != nil {
+		return err
+	}
+
+	*args = list
+	return nil
+}
+
+func marshalJSONValue(v interface{}) ([]byte, error) {
+	if b, ok := appendJSONBytes(nil, v, BytesDefault); ok {
+		return b, nil
+	}
+
+	switch x := v.(type) {
+	case Formatter:
+		return json.Marshal(x.FormatEventValue())
+	case json.Marshaler:
+	case error:
+		return json.Marshal(x.Error())
+	}
+
+	b, err := json.Marshal(v)
+	switch err.(type) {
+	case *json.UnsupportedTypeError, *json.UnsupportedValueError:
+		return json.Marshal(fmt.Sprint(v))
+	}
+	return b, err
+}
+
+// appendJSONBytes appends v as a JSON string to b if it is a byte slice or a
+// BytesValue, see AppendBytesValue.
+func appendJSONBytes(b []byte, v interface{}, format BytesFormat) ([]byte, bool) {
+	switch v.(type) {
+	case []byte, BytesValue:
+	default:
+		return b, false
+	}
+
+	b, _ = AppendBytesValue(append(b, '"'), v, format)
+	return append(b, '"'), true
+}
+
+// decodeJSONObject reads a JSON object from d, which must have been configured
+// to decode numbers as json.Number, and calls f for each of its fields.
+func decodeJSONObject(d *json.Decoder, f func(string, interface{})) error {
+	tok, err := d.Token()
+	if err != nil {
+		return err
+	}
+
+	if tok != json.Delim('{') {
+		return fmt.Errorf("events: expected a JSON object but found %v", tok)
+	}
+
+	for d.More() {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+		key, _ := tok.(string)
+
+		var value interface{}
+		if err := d.Decode(&value); err != nil {
+			return err
+		}
+
+		f(key, convertJSONNumbers(value))
+	}
+
+	_, err = d.Token() // closing '}'
+	return err
+}
+
+// convertJSONNumbers replaces the json.Number values in v by int64 values when
+// they represent integers that fit in 64 bits, or float64 values otherwise. The
+// values are left unchanged when UseJSONNumber is true.
+func convertJSONNumbers(v interface{}) interface{} {
+	if UseJSONNumber {
+		return v
+	}
+
+	switch x := v.(type) {
+	case json.Number:
+		if i, err := strconv.ParseInt(string(x), 10, 64); err == nil {
+			return i
+		}
+		f, _ := strconv.ParseFloat(string(x), 64)
+		return f
+
+	case map[string]interface{}:
+		for k, v := range x {
+			x[k] = convertJSONNumbers(v)
+		}
+
+	case []interface{}:
+		for i, v := range x {
+			x[i] = convertJSONNumbers(v)
+		}
+	}
+	return v
+}
@@ -0,0 +1,173 @@
+package events
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestArgsMarshalJSON(t *testing.T) {
+	tests := []struct {
+		args Args
+		json string
+	}{
+		{
+			args: nil,
+			json: `{}`,
+		},
+		{
+			args: Args{{"name", "Luke"}, {"from", "Han"}, {"name", "Leia"}},
+			json: `{"name":"Luke","from":"Han","name":"Leia"}`,
+		},
+		{
+			args: Args{{"max", int64(math.MaxInt64)}, {"min", int64(math.MinInt64)}, {"u", uint64(math.MaxUint64)}},
+			json: `{"max":9223372036854775807,"min":-9223372036854775808,"u":18446744073709551615}`,
+		},
+		{
+			args: Args{{"pi", 3.141592653589793}, {"small", 1e-300}, {"int", 42}},
+			json: `{"pi":3.141592653589793,"small":1e-300,"int":42}`,
+		},
+		{
+			args: Args{{"error", io.EOF}, {"time", time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)}},
+			json: `{"error":"EOF","time":"2017-01-01T00:00:00Z"}`,
+		},
+		{
+			args: Args{{"nested", Args{{"a", 1}}}, {"list", []int{1, 2}}, {"nil", nil}},
+			json: `{"nested":{"a":1},"list":[1,2],"nil":null}`,
+		},
+		{
+			args: Args{{"complex", complex(1, 2)}, {"nan", math.NaN()}},
+			json: `{"complex":"(1+2i)","nan":"NaN"}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.json, func(t *testing.T) {
+			b, err := json.Marshal(test.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if s := string(b); s != test.json {
+				t.Errorf("\n%s\n%s", s, test.json)
+			}
+		})
+	}
+}
+
+func TestArgsUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		json string
+		args Args
+	}{
+		{
+			json: `{}`,
+			args: Args{},
+		},
+		{
+			json: `{"name":"Luke","from":"Han","name":"Leia"}`,
+			args: Args{{"name", "Luke"}, {"from", "Han"}, {"name", "Leia"}},
+		},
+		{
+			json: `{"max":9223372036854775807,"min":-9223372036854775808,"neg":-42}`,
+			args: Args{{"max", int64(math.MaxInt64)}, {"min", int64(math.MinInt64)}, {"neg", int64(-42)}},
+		},
+		{
+			json: `{"big":9223372036854775808,"pi":3.141592653589793,"exp":1e3,"dec":1.0}`,
+			args: Args{{"big", 9223372036854775808.0}, {"pi", 3.141592653589793}, {"exp", 1000.0}, {"dec", 1.0}},
+		},
+		{
+			json: `{"nested":{"id":9007199254740993},"list":[9007199254740993,0.5]}`,
+			args: Args{
+				{"nested", map[string]interface{}{"id": int64(9007199254740993)}},
+				{"list", []interface{}{int64(9007199254740993), 0.5}},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.json, func(t *testing.T) {
+			var args Args
+
+			if err := json.Unmarshal([]byte(test.json), &args); err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := Diff(&Event{Args: test.args}, &Event{Args: args}); len(diff) != 0 {
+				t.Error(diff)
+			}
+		})
+	}
+
+	t.Run("errors", func(t *testing.T) {
+		for _, s := range []string{`[]`, `42`, `{"a":}`, `{"a":1`} {
+			var args Args
+			if err := json.Unmarshal([]byte(s), &args); err == nil {
+				t.Errorf("%s: no error", s)
+			}
+			if err := args.UnmarshalJSON([]byte(s)); err == nil {
+				t.Errorf("%s: no error from UnmarshalJSON", s)
+			}
+		}
+	})
+
+	t.Run("null", func(t *testing.T) {
+		args := Args{{"a", 1}}
+		if err := json.Unmarshal([]byte(`null`), &args); err != nil || len(args) != 1 {
+			t.Error(args, err)
+		}
+	})
+}
+
+func TestArgsJSONRoundTrip(t *testing.T) {
+	args := Args{
+		{"max", int64(math.MaxInt64)},
+		{"min", int64(math.MinInt64)},
+		{"id", int64(1<<53 + 1)},
+		{"neg", int64(-1)},
+		{"float", 0.1 + 0.2},
+		{"tiny", 5e-324},
+		{"huge", 1.7976931348623157e308},
+		{"string", "hello"},
+		{"bool", true},
+	}
+
+	b, err := json.Marshal(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var found Args
+	if err := json.Unmarshal(b, &found); err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := Diff(&Event{Args: args}, &Event{Args: found}); len(diff) != 0 {
+		t.Errorf("%s\n%s", b, diff)
+	}
+}
+
+func TestUseJSONNumber(t *testing.T) {
+	defer func() { UseJSONNumber = false }()
+	UseJSONNumber = true
+
+	var args Args
+	if err := json.Unmarshal([]byte(`{"a":1.50,"b":[1e3]}`), &args); err != nil {
+		t.Fatal(err)
+	}
+
+	want := Args{{"a", json.Number("1.50")}, {"b", []interface{}{json.Number("1e3")}}}
+
+	if diff := Diff(&Event{Args: want}, &Event{Args: args}); len(diff) != 0 {
+		t.Error(diff)
+	}
+
+	e := ParseJSONLine(`{"ts":1500000000,"n":12345678901234567890}`)
+	want = Args{{"n", json.Number("12345678901234567890")}}
+
+	if diff := Diff(&Event{Time: time.Unix(1500000000, 0), Args: want}, e); len(diff) != 0 {
+		t.Error(diff)
+	}
+}
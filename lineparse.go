@@ -0,0 +1,324 @@
+package events
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseJSONLine parses line as a JSON object and returns the event that it
+// represents, or nil if line isn't a JSON object. It is intended to be used as
+// parse function with NewLineWriter.
+//
+// Known keys are mapped to the event fields: "msg" or "message" set the
+// message, "time", "ts" or "timestamp" set the time (either as a RFC 3339
+// string or a number of seconds since the unix epoch), "source" or "caller"
+// set the source, and a "level", "lvl" or "severity" of "debug" or "trace"
+// marks the event as a debug event. All other keys (including the level) are
+// added to the event arguments, in the order they appear in the line. Numbers
+// are decoded according to the UseJSONNumber variable.
+func ParseJSONLine(line string) *Event {
+	line = strings.TrimSpace(line)
+
+	if !strings.HasPrefix(line, "{") {
+		return nil
+	}
+
+	d := json.NewDecoder(strings.NewReader(line))
+	d.UseNumber()
+
+	e := &Event{}
+
+	if err := decodeJSONObject(d, e.setField); err != nil {
+		return nil
+	}
+
+	if d.InputOffset() != int64(len(line)) {
+		return nil // trailing data after the object
+	}
+
+	return e
+}
+
+// ParseLogfmtLine parses line as a list of logfmt key=value pairs and returns
+// the event that it represents, or nil if line isn't in the logfmt format.
+// It is intended to be used as parse function with NewLineWriter.
+//
+// Keys are mapped to the event fields with the same rules as ParseJSONLine,
+// argument values are always strings (or true for keys without values).
+func ParseLogfmtLine(line string) *Event {
+	e := &Event{}
+	pairs := 0
+	s := strings.TrimSpace(line)
+
+	for len(s) != 0 {
+		i := strings.IndexAny(s, "= \t\"")
+
+		switch {
+		case i == 0:
+			return nil // empty key, or quoted key
+		case i < 0:
+			e.setField(s, true)
+			s = ""
+			continue
+		}
+
+		key := s[:i]
+		s = s[i:]
+
+		if s[0] != '=' {
+			if s[0] == '"' {
+				return nil
+			}
+			e.setField(key, true)
+			s = strings.TrimLeft(s, " \t")
+			continue
+		}
+
+		s = s[1:]
+		pairs++
+
+		var value string
+
+		if strings.HasPrefix(s, `"`) {
+			j := quotedLength(s)
+			if j < 0 {
+				return nil
+			}
+			v, err := strconv.Unquote(s[:j])
+			if err != nil {
+				return nil
+			}
+			value, s = v, s[j:]
+		} else {
+			j := strings.IndexAny(s, " \t")
+			if j < 0 {
+				j = len(s)
+			}
+			value, s = s[:j], s[j:]
+			if strings.ContainsAny(value, `="`) {
+				return nil
+			}
+		}
+
+		if len(s) != 0 && s[0] != ' ' && s[0] != '\t' {
+			return nil
+		}
+
+		e.setField(key, value)
+		s = strings.TrimLeft(s, " \t")
+	}
+
+	if pairs == 0 {
+		return nil
+	}
+
+	return e
+}
+
+// ParseApacheCommonLog parses line in the Apache Common Log Format (optionally
+// followed by the referer and user agent of the Combined Log Format), and
+// returns the event that it represents, or nil if line isn't in this format.
+// It is intended to be used as parse function with NewLineWriter.
+//
+// The event arguments are remote_address, user, method, path, protocol,
+// status, bytes, and when present referer and agent.
+func ParseApacheCommonLog(line string) *Event {
+	// 127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326
+	s := strings.TrimSpace(line)
+
+	host, s, ok := cutField(s)
+	if !ok {
+		return nil
+	}
+
+	_, s, ok = cutField(s) // ident, unused in practice
+	if !ok {
+		return nil
+	}
+
+	user, s, ok := cutField(s)
+	if !ok {
+		return nil
+	}
+
+	if !strings.HasPrefix(s, "[") {
+		return nil
+	}
+
+	i := strings.IndexByte(s, ']')
+	if i < 0 {
+		return nil
+	}
+
+	t, err := time.Parse("02/Jan/2006:15:04:05 -0700", s[1:i])
+	if err != nil {
+		return nil
+	}
+
+	s = strings.TrimLeft(s[i+1:], " ")
+	request, s, ok := cutQuoted(s)
+	if !ok {
+		return nil
+	}
+
+	status, s, ok := cutField(s)
+	if !ok {
+		return nil
+	}
+
+	code, err := strconv.Atoi(status)
+	if err != nil || code < 100 || code > 999 {
+		return nil
+	}
+
+	length, s, _ := cutField(s)
+	size := 0
+
+	if length != "-" {
+		if size, err = strconv.Atoi(length); err != nil || size < 0 {
+			return nil
+		}
+	}
+
+	parts := strings.Split(request, " ")
+	if len(parts) != 3 {
+		return nil
+	}
+
+	e := &Event{
+		Message: request + " " + status,
+		Time:    t,
+		Args: Args{
+			{"remote_address", host},
+			{"user", dashToEmpty(user)},
+			{"method", parts[0]},
+			{"path", parts[1]},
+			{"protocol", parts[2]},
+			{"status", code},
+			{"bytes", size},
+		},
+	}
+
+	if len(s) != 0 {
+		referer, rest, ok := cutQuoted(s)
+		if !ok {
+			return nil
+		}
+
+		agent, rest, ok := cutQuoted(rest)
+		if !ok || len(rest) != 0 {
+			return nil
+		}
+
+		e.Args = append(e.Args, Arg{"referer", dashToEmpty(referer)}, Arg{"agent", agent})
+	}
+
+	return e
+}
+
+func (e *Event) setField(key string, value interface{}) {
+	switch key {
+	case "msg", "message":
+		if s, ok := value.(string); ok && len(e.Message) == 0 {
+			e.Message = s
+			return
+		}
+
+	case "time", "ts", "timestamp":
+		if t, ok := parseTimeValue(value); ok && e.Time.IsZero() {
+			e.Time = t
+			return
+		}
+
+	case "source", "caller":
+		if s, ok := value.(string); ok && len(e.Source) == 0 {
+			e.Source = s
+			return
+		}
+
+	case "level", "lvl", "severity":
+		if s, ok := value.(string); ok {
+			switch strings.ToLower(s) {
+			case "debug", "trace":
+				e.Debug = true
+			}
+		}
+	}
+
+	e.Args = append(e.Args, Arg{key, value})
+}
+
+func parseTimeValue(v interface{}) (time.Time, bool) {
+	switch x := v.(type) {
+	case string:
+		t, err := time.Parse(time.RFC3339Nano, x)
+		return t, err == nil
+	case int64:
+		return time.Unix(x, 0), true
+	case json.Number:
+		f, err := x.Float64()
+		if err != nil {
+			return time.Time{}, false
+		}
+		return parseTimeValue(f)
+	case float64:
+		sec := int64(x)
+		return time.Unix(sec, int64((x-float64(sec))*1e9)), true
+	default:
+		return time.Time{}, false
+	}
+}
+
+// quotedLength returns the length of the double-quoted string at the beginning
+// of s (including the quotes), or -1 if the string isn't terminated.
+func quotedLength(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return -1
+}
+
+func cutField(s string) (field string, rest string, ok bool) {
+	if len(s) == 0 {
+		return
+	}
+
+	if i := strings.IndexByte(s, ' '); i >= 0 {
+		field, rest = s[:i], strings.TrimLeft(s[i+1:], " ")
+	} else {
+		field = s
+	}
+
+	ok = len(field) != 0
+	return
+}
+
+func cutQuoted(s string) (field string, rest string, ok bool) {
+	if !strings.HasPrefix(s, `"`) {
+		return
+	}
+
+	i := quotedLength(s)
+	if i < 0 {
+		return
+	}
+
+	field = strings.Replace(s[1:i-1], `\"`, `"`, -1)
+	rest = strings.TrimLeft(s[i:], " ")
+	ok = true
+	return
+}
+
+func dashToEmpty(s string) string {
+	if s == "-" {
+		return ""
+	}
+	return s
+}
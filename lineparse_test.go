@@ -0,0 +1,186 @@
+package events
+
+import (
+	"testing"
+	"time"
+)
+
+type lineParseTest struct {
+	line  string
+	event *Event
+}
+
+func testLineParser(t *testing.T, parse func(string) *Event, tests []lineParseTest) {
+	for _, test := range tests {
+		t.Run(test.line, func(t *testing.T) {
+			e := parse(test.line)
+
+			if diff := Diff(test.event, e); len(diff) != 0 {
+				t.Errorf("%q\n%s", test.line, diff)
+			}
+		})
+	}
+}
+
+func TestParseJSONLine(t *testing.T) {
+	testLineParser(t, ParseJSONLine, []lineParseTest{
+		{
+			line: `{"level":"info","ts":1500000000.5,"caller":"main.go:42","msg":"server started","port":8080}`,
+			event: &Event{
+				Message: "server started",
+				Source:  "main.go:42",
+				Time:    time.Unix(1500000000, 500000000),
+				Args:    Args{{"level", "info"}, {"port", int64(8080)}},
+			},
+		},
+		{
+			line: `{"time":"2017-07-14T02:40:00Z","level":"debug","message":"cache miss","key":"users/1","hit":false}`,
+			event: &Event{
+				Message: "cache miss",
+				Time:    time.Date(2017, 7, 14, 2, 40, 0, 0, time.UTC),
+				Debug:   true,
+				Args:    Args{{"level", "debug"}, {"key", "users/1"}, {"hit", false}},
+			},
+		},
+		{
+			line: `  {"msg":"request","latency":0.25,"tags":["a",1],"http":{"status":200}}  `,
+			event: &Event{
+				Message: "request",
+				Args: Args{
+					{"latency", 0.25},
+					{"tags", []interface{}{"a", int64(1)}},
+					{"http", map[string]interface{}{"status": int64(200)}},
+				},
+			},
+		},
+		{
+			line: `{"msg":42,"time":"yesterday","id":18446744073709551615}`,
+			event: &Event{
+				Args: Args{{"msg", int64(42)}, {"time", "yesterday"}, {"id", 18446744073709551615.0}},
+			},
+		},
+		{
+			line:  `{}`,
+			event: &Event{},
+		},
+		{line: ``},
+		{line: `Hello World!`},
+		{line: `[1,2,3]`},
+		{line: `{"msg":"unterminated"`},
+		{line: `{"msg":}`},
+		{line: `{"msg":"a"} {"msg":"b"}`},
+		{line: `{"msg":"a"}garbage`},
+		{line: `{`},
+		{line: `{"a":1,}`},
+	})
+}
+
+func TestParseLogfmtLine(t *testing.T) {
+	testLineParser(t, ParseLogfmtLine, []lineParseTest{
+		{
+			line: `time=2017-07-14T02:40:00Z level=info msg="Starting server" addr=:8080`,
+			event: &Event{
+				Message: "Starting server",
+				Time:    time.Date(2017, 7, 14, 2, 40, 0, 0, time.UTC),
+				Args:    Args{{"level", "info"}, {"addr", ":8080"}},
+			},
+		},
+		{
+			line: `lvl=dbug msg="quoted \"value\"" path=/var/log empty= retry`,
+			event: &Event{
+				Message: `quoted "value"`,
+				Args:    Args{{"lvl", "dbug"}, {"path", "/var/log"}, {"empty", ""}, {"retry", true}},
+			},
+		},
+		{
+			line: "level=debug\tsource=worker.go:12  msg=tick",
+			event: &Event{
+				Message: "tick",
+				Source:  "worker.go:12",
+				Debug:   true,
+				Args:    Args{{"level", "debug"}},
+			},
+		},
+		{line: ``},
+		{line: `Hello World!`},
+		{line: `a = b`},
+		{line: `=value`},
+		{line: `msg="unterminated`},
+		{line: `msg="bad escape \q"`},
+		{line: `msg="a"b`},
+		{line: `key=a=b`},
+		{line: `"quoted"=key`},
+		{line: `key"=value`},
+	})
+}
+
+func TestParseApacheCommonLog(t *testing.T) {
+	testLineParser(t, ParseApacheCommonLog, []lineParseTest{
+		{
+			line: `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326`,
+			event: &Event{
+				Message: "GET /apache_pb.gif HTTP/1.0 200",
+				Time:    time.Date(2000, 10, 10, 13, 55, 36, 0, time.FixedZone("", -7*3600)),
+				Args: Args{
+					{"remote_address", "127.0.0.1"},
+					{"user", "frank"},
+					{"method", "GET"},
+					{"path", "/apache_pb.gif"},
+					{"protocol", "HTTP/1.0"},
+					{"status", 200},
+					{"bytes", 2326},
+				},
+			},
+		},
+		{
+			line: `10.1.2.3 - - [14/Jul/2017:02:40:00 +0000] "POST /api/v1/users?limit=10 HTTP/1.1" 304 - "-" "curl/7.54.0"`,
+			event: &Event{
+				Message: "POST /api/v1/users?limit=10 HTTP/1.1 304",
+				Time:    time.Date(2017, 7, 14, 2, 40, 0, 0, time.UTC),
+				Args: Args{
+					{"remote_address", "10.1.2.3"},
+					{"user", ""},
+					{"method", "POST"},
+					{"path", "/api/v1/users?limit=10"},
+					{"protocol", "HTTP/1.1"},
+					{"status", 304},
+					{"bytes", 0},
+					{"referer", ""},
+					{"agent", "curl/7.54.0"},
+				},
+			},
+		},
+		{
+			line: `::1 - - [14/Jul/2017:02:40:00 +0200] "GET / HTTP/2.0" 500 12 "http://example.com/" "Mozilla/5.0 (X11; \"Linux\")"`,
+			event: &Event{
+				Message: "GET / HTTP/2.0 500",
+				Time:    time.Date(2017, 7, 14, 2, 40, 0, 0, time.FixedZone("", 2*3600)),
+				Args: Args{
+					{"remote_address", "::1"},
+					{"user", ""},
+					{"method", "GET"},
+					{"path", "/"},
+					{"protocol", "HTTP/2.0"},
+					{"status", 500},
+					{"bytes", 12},
+					{"referer", "http://example.com/"},
+					{"agent", `Mozilla/5.0 (X11; "Linux")`},
+				},
+			},
+		},
+		{line: ``},
+		{line: `Hello World!`},
+		{line: `127.0.0.1 - frank`},
+		{line: `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700`},
+		{line: `127.0.0.1 - frank [yesterday] "GET / HTTP/1.0" 200 1`},
+		{line: `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET / HTTP/1.0`},
+		{line: `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET / HTTP/1.0"`},
+		{line: `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET / HTTP/1.0" OK 1`},
+		{line: `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET / HTTP/1.0" 42 1`},
+		{line: `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET / HTTP/1.0" 200`},
+		{line: `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET / HTTP/1.0" 200 -1`},
+		{line: `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "\x16\x03\x01" 400 0`},
+		{line: `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET / HTTP/1.0" 200 1 "-"`},
+		{line: `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET / HTTP/1.0" 200 1 "-" "agent" extra`},
+	})
+}
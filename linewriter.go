@@ -0,0 +1,169 @@
+package events
+
+import (
+	"io"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// MaxLineLength is the maximum length of the lines buffered by the writers
+// returned by NewLineWriter. Longer lines are split into multiple events.
+const MaxLineLength = 64 * 1024
+
+// NewLineWriter returns a writer which splits its input into lines and sends
+// them as events to h. It is intended to capture the output of subprocesses,
+// for example by setting it as the Stdout or Stderr of an exec.Cmd.
+//
+// Lines are constructed from the bytes written across multiple calls to Write,
+// the line endings (either "\n" or "\r\n") are stripped. Lines longer than
+// MaxLineLength are split (on rune boundaries when possible) to bound the
+// amount of memory used by the writer.
+//
+// Each line is passed to parse, which returns the event to send to the
+// handler. If parse is nil or returns nil the line is used as the event
+// message. The source is set on the events that don't have one, and events
+// with a zero time get the time at which the line was completed. The package
+// provides ParseJSONLine, ParseLogfmtLine and ParseApacheCommonLog for common
+// output formats.
+//
+// Calling Close sends the trailing partial line, if any. The writer is safe to
+// use concurrently from multiple goroutines.
+func NewLineWriter(h Handler, source string, parse func(line string) *Event) io.WriteCloser {
+	return &lineWriter{
+		handler: h,
+		source:  source,
+		parse:   parse,
+	}
+}
+
+type lineWriter struct {
+	mutex   sync.Mutex
+	handler Handler
+	source  string
+	parse   func(string) *Event
+	buffer  []byte
+	closed  bool
+}
+
+func (w *lineWriter) Write(b []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.closed {
+		return 0, io.ErrClosedPipe
+	}
+
+	n := len(b)
+
+	for len(b) != 0 {
+		i := indexNewline(b)
+
+		if i < 0 {
+			w.append(b)
+			break
+		}
+
+		w.append(b[:i])
+		b = b[i+1:]
+		w.flush(trimCR(w.buffer))
+		w.buffer = w.buffer[:0]
+	}
+
+	return n, nil
+}
+
+// append adds b to the buffered line, sending the beginning of the line as
+// events while it is longer than MaxLineLength. A trailing '\r' is kept in the
+// buffer since it may be the first half of a line ending.
+func (w *lineWriter) append(b []byte) {
+	for len(b) != 0 {
+		chunk := b
+		if room := MaxLineLength + 1 - len(w.buffer); len(chunk) > room {
+			chunk = chunk[:room]
+		}
+
+		w.buffer = append(w.buffer, chunk...)
+		b = b[len(chunk):]
+
+		for len(w.buffer) > MaxLineLength && !(len(w.buffer) == MaxLineLength+1 && w.buffer[MaxLineLength] == '\r') {
+			cut := runeBoundary(w.buffer, MaxLineLength)
+			w.flush(w.buffer[:cut])
+			w.buffer = w.buffer[:copy(w.buffer, w.buffer[cut:])]
+		}
+
+		if len(b) != 0 && len(w.buffer) > MaxLineLength {
+			// only a '\r' is pending, it wasn't followed by a newline
+			w.flush(w.buffer[:MaxLineLength])
+			w.buffer = w.buffer[:copy(w.buffer, w.buffer[MaxLineLength:])]
+		}
+	}
+}
+
+func (w *lineWriter) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if !w.closed {
+		w.closed = true
+
+		if len(w.buffer) != 0 {
+			w.flush(trimCR(w.buffer))
+		}
+
+		w.buffer = nil
+	}
+
+	return nil
+}
+
+func (w *lineWriter) flush(b []byte) {
+	line := string(b)
+
+	var e *Event
+	if w.parse != nil {
+		e = w.parse(line)
+	}
+
+	if e == nil {
+		e = &Event{Message: line}
+	}
+
+	if len(e.Source) == 0 {
+		e.Source = w.source
+	}
+
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	w.handler.HandleEvent(e)
+}
+
+func indexNewline(b []byte) int {
+	for i, c := range b {
+		if c == '\n' {
+			return i
+		}
+	}
+	return -1
+}
+
+func trimCR(b []byte) []byte {
+	if n := len(b); n != 0 && b[n-1] == '\r' {
+		b = b[:n-1]
+	}
+	return b
+}
+
+// runeBoundary returns the offset of the last rune boundary in b which is not
+// greater than n. If b doesn't contain valid UTF-8 around n the function
+// returns n.
+func runeBoundary(b []byte, n int) int {
+	for i := n; i > 0 && i > n-utf8.UTFMax; i-- {
+		if utf8.RuneStart(b[i]) {
+			return i
+		}
+	}
+	return n
+}
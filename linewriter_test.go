@@ -0,0 +1,183 @@
+package events
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLineWriter(t *testing.T) {
+	tests := []struct {
+		name   string
+		writes []string
+		lines  []string
+	}{
+		{
+			name:   "single line",
+			writes: []string{"Hello World!\n"},
+			lines:  []string{"Hello World!"},
+		},
+		{
+			name:   "multiple lines",
+			writes: []string{"A\nB\n\nC\n"},
+			lines:  []string{"A", "B", "", "C"},
+		},
+		{
+			name:   "split lines",
+			writes: []string{"Hel", "lo Wo", "rld!\nHow", " are you?\n"},
+			lines:  []string{"Hello World!", "How are you?"},
+		},
+		{
+			name:   "split rune",
+			writes: []string{"h\xc3", "\xa9llo \xf0\x9f", "\x98", "\x80\n"},
+			lines:  []string{"héllo 😀"},
+		},
+		{
+			name:   "CRLF",
+			writes: []string{"A\r\nB\r", "\nC\r\n"},
+			lines:  []string{"A", "B", "C"},
+		},
+		{
+			name:   "trailing partial line",
+			writes: []string{"A\nB"},
+			lines:  []string{"A", "B"},
+		},
+		{
+			name:   "trailing CR",
+			writes: []string{"A\r"},
+			lines:  []string{"A"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := &eventRecorder{}
+			w := NewLineWriter(r, "ffmpeg", nil)
+
+			for _, s := range test.writes {
+				if n, err := w.Write([]byte(s)); n != len(s) || err != nil {
+					t.Fatal("bad write:", n, err)
+				}
+			}
+
+			w.Close()
+
+			lines := []string{}
+			for _, e := range r.events {
+				if e.Source != "ffmpeg" {
+					t.Error("bad source:", e.Source)
+				}
+				if e.Time.IsZero() {
+					t.Error("missing event time")
+				}
+				if e.Debug {
+					t.Error("line events must not be debug events")
+				}
+				lines = append(lines, e.Message)
+			}
+
+			if !reflect.DeepEqual(lines, test.lines) {
+				t.Errorf("bad lines: %q", lines)
+			}
+		})
+	}
+}
+
+func TestLineWriterLongLines(t *testing.T) {
+	r := &eventRecorder{}
+	w := NewLineWriter(r, "", nil)
+
+	// Multi-byte runes are positioned to straddle the maximum line length.
+	line := strings.Repeat("a", MaxLineLength-1) + strings.Repeat("é", MaxLineLength)
+
+	for i := 0; i < len(line); i += 1000 {
+		j := i + 1000
+		if j > len(line) {
+			j = len(line)
+		}
+		w.Write([]byte(line[i:j]))
+	}
+
+	if len(w.(*lineWriter).buffer) > MaxLineLength {
+		t.Error("the buffered data exceeds the maximum line length")
+	}
+
+	w.Write([]byte("\nOK\n"))
+	w.Close()
+
+	var s string
+	for _, e := range r.events[:len(r.events)-1] {
+		if len(e.Message) > MaxLineLength {
+			t.Error("line too long:", len(e.Message))
+		}
+		if !strings.HasSuffix(e.Message, "a") && !strings.HasSuffix(e.Message, "é") {
+			t.Errorf("line split in the middle of a rune: %q", e.Message[len(e.Message)-4:])
+		}
+		s += e.Message
+	}
+
+	if s != line {
+		t.Error("the content of long lines was altered")
+	}
+
+	if e := r.events[len(r.events)-1]; e.Message != "OK" {
+		t.Error("bad last line:", e.Message)
+	}
+}
+
+func TestLineWriterParse(t *testing.T) {
+	t0 := time.Date(2017, 1, 1, 23, 42, 0, 0, time.UTC)
+	r := &eventRecorder{}
+	w := NewLineWriter(r, "terraform", func(line string) *Event {
+		if !strings.HasPrefix(line, "[DEBUG] ") {
+			return nil
+		}
+		return &Event{Message: line[8:], Source: "terraform/plugin", Time: t0, Debug: true}
+	})
+
+	fmt.Fprintf(w, "[DEBUG] loading plugins\nApply complete!\n")
+	w.Close()
+
+	if _, err := w.Write([]byte("A\n")); err == nil {
+		t.Error("writing to a closed writer must return an error")
+	}
+
+	if len(r.events) != 2 {
+		t.Fatal("bad event count:", len(r.events))
+	}
+
+	if e := r.events[0]; !reflect.DeepEqual(*e, Event{Message: "loading plugins", Source: "terraform/plugin", Time: t0, Debug: true}) {
+		t.Errorf("bad parsed event: %#v", *e)
+	}
+
+	if e := r.events[1]; e.Message != "Apply complete!" || e.Source != "terraform" || e.Debug {
+		t.Errorf("bad default event: %#v", *e)
+	}
+}
+
+func TestLineWriterLongLineSingleWrite(t *testing.T) {
+	r := &eventRecorder{}
+	w := NewLineWriter(r, "", nil)
+
+	w.Write([]byte(strings.Repeat("A", 200000) + "\nB"))
+
+	if n := len(w.(*lineWriter).buffer); n > MaxLineLength {
+		t.Error("the buffered data exceeds the maximum line length:", n)
+	}
+
+	w.Write([]byte("\n" + strings.Repeat("C", MaxLineLength) + "\r\n"))
+	w.Close()
+
+	var lengths []int
+	for _, e := range r.events {
+		lengths = append(lengths, len(e.Message))
+	}
+
+	want := []int{MaxLineLength, MaxLineLength, MaxLineLength, 200000 - 3*MaxLineLength, 1, MaxLineLength}
+
+	if !reflect.DeepEqual(lengths, want) {
+		t.Error("bad lengths of the lines:", lengths)
+	}
+}
@@ -0,0 +1,3 @@
+// Package logfmt provides functions to encode event arguments in the logfmt
+// format, a list of space-separated key=value pairs.
+package logfmt
@@ -0,0 +1,77 @@
+package logfmt
+
+import (
+	"time"
+
+	"github.com/segmentio/events"
+)
+
+// Encoder is an events.Encoder which encodes events as logfmt lines, in the
+// format understood by events.ParseLogfmtLine.
+//
+// Each line starts with the time, level, source and message of the event,
+// followed by the event arguments.
+type Encoder struct {
+	// TimeFormat is the format of event times, it defaults to
+	// time.RFC3339Nano when empty.
+	TimeFormat string
+
+	// Bytes is the format of byte slices, it defaults to hexadecimal.
+	Bytes events.BytesFormat
+
+	// SortArgs may be set to true to render the event arguments in the order
+	// of their names (see events.Args.SortedIndex).
+	SortArgs bool
+
+	// OmitNil and OmitZero may be set to true to skip the arguments with nil or
+	// zero values (see events.OmitValue). Values wrapped with events.Keep are
+	// always rendered.
+	OmitNil  bool
+	OmitZero bool
+}
+
+// EncodeEvent satisfies the events.Encoder interface.
+func (enc Encoder) EncodeEvent(dst []byte, e *events.Event) ([]byte, error) {
+	format := enc.TimeFormat
+	if len(format) == 0 {
+		format = time.RFC3339Nano
+	}
+
+	if !e.Time.IsZero() {
+		dst = append(dst, "time="...)
+		start := len(dst)
+		dst = e.Time.AppendFormat(dst, format)
+
+		// The time is formatted in place and only copied when it has to be
+		// quoted, which doesn't happen with the default format.
+		if s := dst[start:]; needsQuotes(string(s)) {
+			dst = appendString(dst[:start], string(s))
+		}
+
+		dst = append(dst, ' ')
+	}
+
+	if e.Debug {
+		dst = append(dst, "level=debug"...)
+	} else {
+		dst = append(dst, "level=info"...)
+	}
+
+	if len(e.Source) != 0 {
+		dst = append(dst, " source="...)
+		dst = appendString(dst, e.Source)
+	}
+
+	dst = append(dst, " msg="...)
+	dst = appendString(dst, e.Message)
+
+	if len(e.Args) != 0 {
+		var order []int
+		if enc.SortArgs && len(e.Args) > 1 {
+			order = e.Args.SortedIndex()
+		}
+		dst = enc.appendArgs(dst, e.Args, order, true)
+	}
+
+	return append(dst, '\n'), nil
+}
@@ -0,0 +1,140 @@
+package logfmt
+
+import (
+	"fmt"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/segmentio/events"
+)
+
+// AppendArgs appends the logfmt representation of args to b and returns the
+// extended buffer.
+func AppendArgs(b []byte, args events.Args) []byte {
+	return Encoder{}.appendArgs(b, args, nil, false)
+}
+
+// appendArgs is like AppendArgs but applies the options of enc, and renders
+// the arguments in the given order when it's not nil. When sep is true a space
+// is written before the first argument.
+func (enc Encoder) appendArgs(b []byte, args events.Args, order []int, sep bool) []byte {
+	for i := range args {
+		a := args[i]
+		if order != nil {
+			a = args[order[i]]
+		}
+
+		v, omit := events.OmitValue(a.Value, enc.OmitNil, enc.OmitZero)
+		if omit {
+			continue
+		}
+
+		if sep {
+			b = append(b, ' ')
+		}
+		sep = true
+
+		b = appendKey(b, a.Name)
+		b = append(b, '=')
+		b = appendValue(b, v, enc.Bytes)
+	}
+	return b
+}
+
+// AppendArg appends the logfmt representation of a to b and returns the
+// extended buffer.
+//
+// Characters that aren't allowed in keys (spaces, '=' and '"') are replaced by
+// underscores, values are quoted when they contain characters that would make
+// them ambiguous to parse.
+func AppendArg(b []byte, a events.Arg) []byte {
+	b = appendKey(b, a.Name)
+	b = append(b, '=')
+	return AppendValue(b, a.Value)
+}
+
+// AppendValue appends the logfmt representation of v to b and returns the
+// extended buffer.
+//
+// Values implementing events.Formatter, error, encoding.TextMarshaler or
+// fmt.Stringer are rendered with events.FormatValue, byte slices are rendered
+// in hexadecimal.
+func AppendValue(b []byte, v interface{}) []byte {
+	return appendValue(b, v, events.BytesDefault)
+}
+
+func appendValue(b []byte, v interface{}, format events.BytesFormat) []byte {
+	start := len(b)
+
+	if b, ok := events.AppendBytesValue(b, v, format); ok {
+		if s := b[start:]; needsQuotes(string(s)) {
+			b = appendString(b[:start], string(s))
+		}
+		return b
+	}
+
+	switch x := v.(type) {
+	case nil:
+		return b
+	case string:
+		return appendString(b, x)
+	case bool:
+		return strconv.AppendBool(b, x)
+	case int:
+		return strconv.AppendInt(b, int64(x), 10)
+	case int64:
+		return strconv.AppendInt(b, x, 10)
+	case uint64:
+		return strconv.AppendUint(b, x, 10)
+	case float64:
+		return strconv.AppendFloat(b, x, 'g', -1, 64)
+	default:
+		if s, ok := events.FormatValue(v); ok {
+			return appendString(b, s)
+		}
+		return appendString(b, fmt.Sprint(v))
+	}
+}
+
+func appendKey(b []byte, k string) []byte {
+	if len(k) == 0 {
+		return append(b, '_')
+	}
+
+	for _, c := range []byte(k) {
+		switch {
+		case c <= ' ' || c == '=' || c == '"' || c == 0x7f:
+			b = append(b, '_')
+		default:
+			b = append(b, c)
+		}
+	}
+
+	return b
+}
+
+func appendString(b []byte, s string) []byte {
+	if !needsQuotes(s) {
+		return append(b, s...)
+	}
+	return strconv.AppendQuote(b, s)
+}
+
+func needsQuotes(s string) bool {
+	if len(s) == 0 {
+		return true
+	}
+
+	for i, c := range s {
+		switch {
+		case c == utf8.RuneError:
+			if _, n := utf8.DecodeRuneInString(s[i:]); n == 1 {
+				return true
+			}
+		case c <= ' ' || c == '=' || c == '"' || c == '\\' || c == 0x7f:
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,53 @@
+package logfmt
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/segmentio/events"
+)
+
+func TestAppendArgs(t *testing.T) {
+	tests := []struct {
+		args   events.Args
+		output string
+	}{
+		{
+			args:   nil,
+			output: ``,
+		},
+		{
+			args:   events.Args{{"name", "Luke"}, {"from", "Han"}},
+			output: `name=Luke from=Han`,
+		},
+		{
+			args:   events.Args{{"answer", 42}, {"pi", 3.14}, {"ok", true}, {"n", uint64(1)}, {"i", int64(-1)}},
+			output: `answer=42 pi=3.14 ok=true n=1 i=-1`,
+		},
+		{
+			args:   events.Args{{"msg", "Hello World!"}, {"empty", ""}, {"quote", `"`}, {"eq", "a=b"}},
+			output: `msg="Hello World!" empty="" quote="\"" eq="a=b"`,
+		},
+		{
+			args:   events.Args{{"error", errors.New("oops")}, {"nil", nil}, {"d", time.Second}},
+			output: `error=oops nil= d=1s`,
+		},
+		{
+			args:   events.Args{{"bad key", 1}, {"", 2}, {"a=b", 3}},
+			output: `bad_key=1 _=2 a_b=3`,
+		},
+		{
+			args:   events.Args{{"unicode", "héllo"}, {"invalid", "\xff"}, {"newline", "a\nb"}},
+			output: `unicode=héllo invalid="\xff" newline="a\nb"`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.output, func(t *testing.T) {
+			if s := string(AppendArgs(nil, test.args)); s != test.output {
+				t.Error(s)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package events
 
 import (
+	"context"
 	"fmt"
 	"runtime"
 	"strconv"
@@ -15,12 +16,12 @@ var DefaultLogger = NewLogger(nil)
 
 // Log emits a log event to the default logger.
 func Log(format string, args ...interface{}) {
-	DefaultLogger.log(1, false, format, args...)
+	DefaultLogger.log(nil, 1, false, format, args...)
 }
 
 // Debug emits a debug event to the default logger.
 func Debug(format string, args ...interface{}) {
-	DefaultLogger.debug(1, format, args...)
+	DefaultLogger.debug(nil, 1, format, args...)
 }
 
 // A Logger is a wrapper around an event handler which exposes a Log method for
@@ -32,7 +33,8 @@ func Debug(format string, args ...interface{}) {
 //
 // The Log method also makes a special case when it gets an events.Args as last
 // argument, it doesn't use it to format the message and instead simply append
-// it to the event's argument list.
+// it to the event's argument list. The same goes for an events.PooledArgs
+// value, which is also given back to the pool once the event was handled.
 //
 // Here's an example with the defalut logger:
 //
@@ -71,7 +73,24 @@ type Logger struct {
 	EnableSource bool
 
 	// EnableDebug controls whether calls to Debug produces events.
+	// Debug events are never produced while they are disabled program-wide
+	// (see SetDebugEnabled), unless a Capture is active.
 	EnableDebug bool
+
+	// EnableErrorCauses controls whether the logger adds the arguments
+	// produced by ErrorArgs after each error argument of its events, listing
+	// the chain of errors that they wrap.
+	EnableErrorCauses bool
+
+	// Source is the source set on the events produced by the logger when
+	// EnableSource is false, which costs nothing per event. When EnableSource
+	// is true the caller's location takes precedence.
+	Source string
+
+	// DuplicatePolicy controls how the logger handles arguments of the same
+	// name on the events it produces, it defaults to KeepAll. The policy only
+	// applies to the events built by the logger.
+	DuplicatePolicy DuplicatePolicy
 }
 
 // NewLogger allocates and returns a new logger which sends events to handler.
@@ -83,20 +102,43 @@ func NewLogger(handler Handler) *Logger {
 	}
 }
 
+// NewPackageLogger returns a logger which sends events to the default handler
+// and sets the import path of the calling package as source of its events.
+//
+// The package is determined once when the function is called, the logger then
+// runs as fast as a logger with EnableSource set to false. It is intended to
+// create one logger per package, for example:
+//
+//	var log = events.NewPackageLogger()
+func NewPackageLogger() *Logger {
+	pc, _, _, _ := runtime.Caller(1)
+	return &Logger{
+		Source:      PackageForPC(pc),
+		EnableDebug: true,
+	}
+}
+
 // Log formats an event and sends it to the logger's handler.
 func (l *Logger) Log(format string, args ...interface{}) {
-	l.log(1, false, format, args...)
+	l.log(nil, 1, false, format, args...)
 }
 
-func (l *Logger) log(depth int, debug bool, format string, args ...interface{}) {
+func (l *Logger) log(ctx context.Context, depth int, debug bool, format string, args ...interface{}) {
 	var h = l.Handler
 	var s = logPool.Get().(*logState)
 	var a Args
+	var pooled bool
 
 	if h == nil {
 		h = DefaultHandler
 	}
 
+	if debug && !DebugEnabled() {
+		// The event is only produced for the active captures, it must not
+		// reach the other handlers.
+		h = &debugCaptures
+	}
+
 	if l.EnableSource {
 		var pc [1]uintptr
 		runtime.Callers(l.CallDepth+depth+2, pc[:])
@@ -110,50 +152,110 @@ func (l *Logger) log(depth int, debug bool, format string, args ...interface{})
 	}
 
 	if n := len(args); n != 0 {
-		if s, ok := args[n-1].(Args); ok {
-			a, args = s, args[:n-1]
+		switch x := args[n-1].(type) {
+		case Args:
+			a, args = x, args[:n-1]
+		case PooledArgs:
+			a, args, pooled = Args(x), args[:n-1], true
 		}
 	}
 
 	s.e.Args = append(s.e.Args, l.Args...)
-	s.fmt, s.e.Args = appendFormat(s.fmt, s.e.Args, format, args)
-	s.e.Args = append(s.e.Args, a...)
+	s.e.Args = appendScopeArgs(s.e.Args)
+
+	if len(args) == 0 && strings.IndexByte(format, '%') < 0 {
+		// The message doesn't need formatting, which is the case of most
+		// events without arguments.
+		s.msg = append(s.msg, format...)
+	} else {
+		s.fmt, s.e.Args = appendFormat(s.fmt, s.e.Args, format, args)
+		fmt.Fprintf(s, bytesToString(s.fmt), args...)
+		rememberFormat(s.msg, format)
+	}
 
-	fmt.Fprintf(s, bytesToString(s.fmt), args...)
+	s.e.Args = append(s.e.Args, a...)
+	s.e.Args = dedupArgs(s.e.Args, l.DuplicatePolicy)
 
 	s.e.Message = bytesToString(s.msg)
 	s.e.Source = bytesToString(s.src)
+
+	if len(s.e.Source) == 0 {
+		s.e.Source = l.Source
+	}
 	s.e.Debug = debug
 	s.e.Time = time.Now()
 
-	h.HandleEvent(&s.e)
+	// Events without arguments carry a nil list, the pooled backing array is
+	// restored once the event was handled.
+	backing := s.e.Args
+	if len(backing) == 0 {
+		s.e.Args = nil
+	}
+
+	expand := l.EnableErrorCauses && hasErrorCauses(s.e.Args)
+
+	if expand {
+		s.err = expandErrorArgs(s.err, s.e.Args)
+		s.e.Args, s.err = s.err, s.e.Args
+	}
+
+	if _, diag := safeCall(h, func() bool {
+		if ctx != nil {
+			HandleEventContext(ctx, h, &s.e)
+		} else {
+			h.HandleEvent(&s.e)
+		}
+		return false
+	}); diag != nil {
+		reportPanic(h, diag)
+	}
+
+	if expand {
+		s.e.Args, s.err = s.err, s.e.Args
+	}
+
+	if len(backing) == 0 {
+		s.e.Args = backing
+	}
 
 	// don't hold pointers to let the garbage collector free the objects
 	for i := range s.e.Args {
 		s.e.Args[i] = Arg{}
 	}
 
+	for i := range s.err {
+		s.err[i] = Arg{}
+	}
+
 	s.e.Message = ""
 	s.e.Source = ""
 	s.e.Args = s.e.Args[:0]
+	s.err = s.err[:0]
 
 	s.fmt = s.fmt[:0]
 	s.msg = s.msg[:0]
 	s.src = s.src[:0]
 
 	logPool.Put(s)
-	return
+
+	if pooled {
+		PutArgs(a)
+	}
 }
 
 // Debug is like Log but only produces events if the logger has debugging
 // enabled.
 func (l *Logger) Debug(format string, args ...interface{}) {
-	l.debug(1, format, args...)
+	l.debug(nil, 1, format, args...)
 }
 
-func (l *Logger) debug(depth int, format string, args ...interface{}) {
-	if l.EnableDebug {
-		l.log(depth+1, true, format, args...)
+func (l *Logger) debug(ctx context.Context, depth int, format string, args ...interface{}) {
+	if l.EnableDebug && (DebugEnabled() || debugCaptureActive()) {
+		l.log(ctx, depth+1, true, format, args...)
+	} else if n := len(args); n != 0 {
+		if a, ok := args[n-1].(PooledArgs); ok {
+			PutArgs(Args(a))
+		}
 	}
 }
 
@@ -169,16 +271,20 @@ func (l *Logger) With(args Args) *Logger {
 	}
 
 	return &Logger{
-		Args:         newArgs,
-		Handler:      l.Handler,
-		EnableSource: l.EnableSource,
-		EnableDebug:  l.EnableDebug,
+		Args:              newArgs,
+		Handler:           l.Handler,
+		EnableSource:      l.EnableSource,
+		EnableDebug:       l.EnableDebug,
+		EnableErrorCauses: l.EnableErrorCauses,
+		Source:            l.Source,
+		DuplicatePolicy:   l.DuplicatePolicy,
 	}
 }
 
 // logState is used to build events produced by Logger instances.
 type logState struct {
 	e   Event
+	err Args // arguments with expanded errors
 	fmt []byte
 	msg []byte
 	src []byte
@@ -2,6 +2,7 @@ package events
 
 import (
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 )
@@ -193,6 +194,48 @@ func BenchmarkLogger(b *testing.B) {
 	})
 }
 
+func TestNewPackageLogger(t *testing.T) {
+	r := &eventRecorder{}
+	l := NewPackageLogger()
+	l.Handler = r
+
+	l.Log("A")
+
+	if e := r.wait(t); e.Source != "github.com/segmentio/events" {
+		t.Error("bad source:", e.Source)
+	}
+
+	l.EnableSource = true
+	l.Log("B")
+
+	if e := r.wait(t); !strings.Contains(e.Source, ".go:") {
+		t.Error("the caller location didn't override the package source:", e.Source)
+	}
+
+}
+
+func BenchmarkPackageLogger(b *testing.B) {
+	plain := &Logger{Handler: Discard}
+	pkg := NewPackageLogger()
+	pkg.Handler = Discard
+
+	for _, test := range []struct {
+		name   string
+		logger *Logger
+	}{
+		{"plain", plain},
+		{"package", pkg},
+	} {
+		b.Run(test.name, func(b *testing.B) {
+			b.ReportAllocs()
+
+			for i := 0; i != b.N; i++ {
+				test.logger.Log("Hello %{name}s!", "Luke")
+			}
+		})
+	}
+}
+
 func checkEvents(t *testing.T, e1 []*Event, e2 []*Event) {
 	if len(e1) != len(e2) {
 		t.Error("length mismatch:", len(e1), "!=", len(e2))
@@ -208,10 +251,60 @@ func checkEvents(t *testing.T, e1 []*Event, e2 []*Event) {
 		v2.Source = ""
 		v1.Time = time.Time{}
 		v2.Time = time.Time{}
-		if !reflect.DeepEqual(v1, v2) {
-			t.Error("event mismatch at index", i)
-			t.Logf("%#v", v1)
-			t.Logf("%#v", v2)
+		if diff := Diff(&v2, &v1); len(diff) != 0 {
+			t.Errorf("event mismatch at index %d\n%s", i, diff)
 		}
 	}
 }
+
+func TestLoggerNoArgs(t *testing.T) {
+	var args Args
+	var called bool
+
+	logger := &Logger{Handler: HandlerFunc(func(e *Event) {
+		args, called = e.Args, true
+	})}
+
+	logger.Log("server started")
+
+	if !called || args != nil {
+		t.Errorf("events without arguments must have a nil list: %#v", args)
+	}
+
+	logger.Log("Hello %{name}s!", "Luke")
+	logger.Log("server started")
+
+	if args != nil {
+		t.Errorf("the pooled argument list leaked to an event without arguments: %#v", args)
+	}
+
+	if RaceEnabled {
+		return
+	}
+
+	if n := testing.AllocsPerRun(100, func() { logger.Log("server started") }); n != 0 {
+		t.Errorf("%g allocations", n)
+	}
+}
+
+func BenchmarkLoggerNoArgs(b *testing.B) {
+	logger := &Logger{Handler: Discard}
+
+	// The "format" case runs the general path, which formats the message.
+	for _, test := range []struct {
+		name   string
+		format string
+		args   []interface{}
+	}{
+		{"no-args", "server started", nil},
+		{"format", "server %s", []interface{}{"started"}},
+	} {
+		b.Run(test.name, func(b *testing.B) {
+			b.ReportAllocs()
+
+			for i := 0; i != b.N; i++ {
+				logger.Log(test.format, test.args...)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package events
+
+import "context"
+
+// The Mutator interface is implemented by types that transform events before
+// they reach a handler, like Canonicalizer or CardinalityLimiter.
+//
+// MutateEvent may modify e in place and return it, or return a different
+// event. Returning nil drops the event.
+type Mutator interface {
+	MutateEvent(e *Event) *Event
+}
+
+// MutatorFunc makes it possible for simple function types to be used as event
+// mutators.
+type MutatorFunc func(*Event) *Event
+
+// MutateEvent calls f.
+func (f MutatorFunc) MutateEvent(e *Event) *Event {
+	return f(e)
+}
+
+// NewMutatorChain returns a handler which applies the mutators ms to the
+// events it receives before passing them to h.
+//
+// The handler makes a single copy of each event (see Event.Clone) and the
+// mutators are applied to this copy in the order they were passed to the
+// function, each mutator receiving the event returned by the previous one. The
+// original events are never modified, so mutators don't need to copy the
+// events themselves, which a stack of handlers transforming events would have
+// to do. When a mutator returns nil the event is dropped and the following
+// mutators are not called.
+//
+// Diagnostic events are passed to h unchanged.
+func NewMutatorChain(h Handler, ms ...Mutator) Handler {
+	return &mutatorChain{
+		handler:  h,
+		mutators: append([]Mutator(nil), ms...),
+	}
+}
+
+type mutatorChain struct {
+	handler  Handler
+	mutators []Mutator
+}
+
+func (c *mutatorChain) HandleEvent(e *Event) {
+	c.handle(e, forwardEvent)
+}
+
+func (c *mutatorChain) HandleEventSync(ctx context.Context, e *Event) error {
+	return handleSync(ctx, c, func(forward func(Handler, *Event)) { c.handle(e, forward) })
+}
+
+func (c *mutatorChain) HandleEventContext(ctx context.Context, e *Event) error {
+	return handleContext(ctx, func(forward func(Handler, *Event)) { c.handle(e, forward) })
+}
+
+func (c *mutatorChain) handle(e *Event, forward func(Handler, *Event)) {
+	if !IsDiagnostic(e) && len(c.mutators) != 0 {
+		e = e.Clone()
+
+		for _, m := range c.mutators {
+			if e = m.MutateEvent(e); e == nil {
+				return
+			}
+		}
+	}
+
+	forward(c.handler, e)
+}
+
+func (c *mutatorChain) Unwrap() Handler {
+	return c.handler
+}
+
+func (c *mutatorChain) Flush() error {
+	return flushHandler(c.handler)
+}
+
+func (c *mutatorChain) Close() error {
+	return closeHandler(c.handler)
+}
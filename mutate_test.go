@@ -0,0 +1,132 @@
+package events
+
+import (
+	"reflect"
+	"testing"
+)
+
+func appendArgMutator(name string) Mutator {
+	return MutatorFunc(func(e *Event) *Event {
+		e.Args = append(e.Args, Arg{"mutator", name})
+		return e
+	})
+}
+
+func TestMutatorChain(t *testing.T) {
+	r := &eventRecorder{}
+	h := NewMutatorChain(r,
+		&Canonicalizer{Rules: SnakeCaseRules()},
+		NewCardinalityLimiter(nil, CardRule{Arg: "user_id", Budget: 1}),
+		appendArgMutator("A"),
+		appendArgMutator("B"),
+	)
+
+	e1 := &Event{Message: "hello", Args: Args{{"userId", 1}}}
+	e2 := &Event{Message: "hello", Args: Args{{"userId", 2}}}
+	h.HandleEvent(e1)
+	h.HandleEvent(e2)
+
+	if want := (Args{{"userId", 1}}); !reflect.DeepEqual(e1.Args, want) {
+		t.Error("the original event was modified:", e1.Args)
+	}
+
+	if want := (Args{{"userId", 2}}); !reflect.DeepEqual(e2.Args, want) {
+		t.Error("the original event was modified:", e2.Args)
+	}
+
+	if want := (Args{{"user_id", 1}, {"mutator", "A"}, {"mutator", "B"}}); !reflect.DeepEqual(r.wait(t).Args, want) {
+		t.Error("bad arguments of the first event:", r.events)
+	}
+
+	if want := (Args{{"user_id", DefaultCardOther}, {"mutator", "A"}, {"mutator", "B"}}); !reflect.DeepEqual(r.wait(t).Args, want) {
+		t.Error("bad arguments of the second event:", r.events)
+	}
+}
+
+func TestMutatorChainDrop(t *testing.T) {
+	r := &eventRecorder{}
+	called := false
+	h := NewMutatorChain(r,
+		MutatorFunc(func(e *Event) *Event {
+			if e.Debug {
+				return nil
+			}
+			return e
+		}),
+		MutatorFunc(func(e *Event) *Event {
+			called = true
+			return e
+		}),
+	)
+
+	h.HandleEvent(&Event{Message: "A", Debug: true})
+
+	if called || r.len() != 0 {
+		t.Error("the event was not dropped")
+	}
+
+	h.HandleEvent(&Event{Message: "B"})
+
+	if !called || r.wait(t).Message != "B" {
+		t.Error("the event was not forwarded")
+	}
+}
+
+func TestMutatorChainDiagnostic(t *testing.T) {
+	r := &eventRecorder{}
+	h := NewMutatorChain(r, MutatorFunc(func(e *Event) *Event { return nil }))
+	e := Diagnostic("report")
+	h.HandleEvent(e)
+
+	if r.len() != 1 || r.events[0].Message != "report" {
+		t.Error("the diagnostic event was not passed through")
+	}
+}
+
+// cloningHandler transforms events the way handlers do when they don't run in
+// a mutator chain, each one copying the events before modifying them.
+func cloningHandler(h Handler, m Mutator) Handler {
+	return HandlerFunc(func(e *Event) {
+		if e = m.MutateEvent(e.Clone()); e != nil {
+			h.HandleEvent(e)
+		}
+	})
+}
+
+func BenchmarkMutatorChain(b *testing.B) {
+	mutators := func() []Mutator {
+		return []Mutator{
+			&Canonicalizer{Rules: SnakeCaseRules()},
+			NewCardinalityLimiter(nil, CardRule{Arg: "user_id", Budget: 100}),
+			appendArgMutator("A"),
+			MutatorFunc(func(e *Event) *Event { e.Message = "hello world"; return e }),
+			MutatorFunc(func(e *Event) *Event { e.Args = e.Args[:len(e.Args)-1]; return e }),
+		}
+	}
+
+	e := &Event{
+		Message: "hello",
+		Source:  "file.go:42",
+		Args:    Args{{"userId", 42}, {"requestId", "abc"}, {"path", "/"}, {"status", 200}},
+	}
+
+	b.Run("chain", func(b *testing.B) {
+		h := NewMutatorChain(Discard, mutators()...)
+		b.ReportAllocs()
+		for i := 0; i != b.N; i++ {
+			h.HandleEvent(e)
+		}
+	})
+
+	b.Run("handlers", func(b *testing.B) {
+		ms := mutators()
+		h := Discard
+		for i := len(ms) - 1; i >= 0; i-- {
+			h = cloningHandler(h, ms[i])
+		}
+		b.ReportAllocs()
+		for i := 0; i != b.N; i++ {
+			h.HandleEvent(e)
+		}
+	})
+}
@@ -0,0 +1,8 @@
+//go:build !race
+// +build !race
+
+package events
+
+// RaceEnabled is true when the tests run with the race detector, see
+// race_test.go.
+const RaceEnabled = false
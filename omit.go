@@ -0,0 +1,116 @@
+package events
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// KeepValue is an argument value which is always emitted by encoders, even if
+// they are configured to omit nil or zero values. Values are built with the
+// Keep function.
+type KeepValue struct {
+	Value interface{}
+}
+
+// Keep wraps v in a KeepValue.
+func Keep(v interface{}) KeepValue {
+	return KeepValue{Value: v}
+}
+
+// FormatEventValue satisfies the Formatter interface, the wrapped value is
+// rendered with FormatValue, or the fmt package if it implements none of the
+// interfaces recognized by FormatValue.
+func (k KeepValue) FormatEventValue() string {
+	if s, ok := FormatValue(k.Value); ok {
+		return s
+	}
+	return fmt.Sprint(k.Value)
+}
+
+// MarshalJSON satisfies the json.Marshaler interface, the wrapped value is
+// encoded like Args.MarshalJSON does.
+func (k KeepValue) MarshalJSON() ([]byte, error) {
+	return marshalJSONValue(k.Value)
+}
+
+// OmitValue tells whether an argument with value v should be omitted by an
+// encoder configured to omit nil values (omitNil) or zero values (omitZero).
+// The returned value is v, or the wrapped value if v is a KeepValue, which is
+// never omitted.
+//
+// The function is intended to be used by the implementations of event
+// encoders.
+func OmitValue(v interface{}, omitNil bool, omitZero bool) (value interface{}, omit bool) {
+	if k, ok := v.(KeepValue); ok {
+		return k.Value, false
+	}
+
+	switch {
+	case omitZero:
+		return v, IsZero(v)
+	case omitNil:
+		return v, IsNil(v)
+	default:
+		return v, false
+	}
+}
+
+// IsNil returns true if v is nil, or a nil pointer, map, slice, channel or
+// function.
+func IsNil(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+
+	switch r := reflect.ValueOf(v); r.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Chan, reflect.Func, reflect.Interface:
+		return r.IsNil()
+	default:
+		return false
+	}
+}
+
+// IsZero returns true if v is nil or the zero value of its type, like an empty
+// string, the number zero, false or the zero time.
+//
+// Pointers are zero only when they are nil, a pointer to a zero value isn't.
+// Values with an IsZero method (like time.Time) are zero if the method returns
+// true. Empty slices and maps that aren't nil are not zero.
+func IsZero(v interface{}) bool {
+	switch x := v.(type) {
+	case string:
+		return x == ""
+	case int:
+		return x == 0
+	case int64:
+		return x == 0
+	case float64:
+		return x == 0
+	case bool:
+		return !x
+	}
+
+	if IsNil(v) {
+		return true
+	}
+
+	switch r := reflect.ValueOf(v); r.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Chan, reflect.Func, reflect.Interface:
+		return false
+	default:
+		if z, ok := v.(interface {
+			IsZero() bool
+		}); ok {
+			return z.IsZero()
+		}
+		return isZeroValue(r)
+	}
+}
+
+func isZeroValue(r reflect.Value) (zero bool) {
+	// Comparing structs that hold values of non-comparable types in interface
+	// fields panics, those are not considered zero.
+	defer func() { recover() }()
+	t := r.Type()
+	return t.Comparable() && r.Interface() == reflect.Zero(t).Interface()
+}
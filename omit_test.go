@@ -0,0 +1,71 @@
+package events
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestIsZero(t *testing.T) {
+	type point struct{ X, Y int }
+	zero := 0
+
+	tests := []struct {
+		value interface{}
+		zero  bool
+	}{
+		{nil, true},
+		{"", true},
+		{"A", false},
+		{0, true},
+		{1, false},
+		{uint8(0), true},
+		{0.0, true},
+		{false, true},
+		{true, false},
+		{time.Time{}, true},
+		{time.Now(), false},
+		{time.Duration(0), true},
+		{(*int)(nil), true},
+		{&zero, false},
+		{[]int(nil), true},
+		{[]int{}, false},
+		{map[string]int{}, false},
+		{point{}, true},
+		{point{X: 1}, false},
+		{struct{ V interface{} }{[]int{}}, false},
+		{errors.New(""), false},
+	}
+
+	for _, test := range tests {
+		if zero := IsZero(test.value); zero != test.zero {
+			t.Errorf("%#v: %t != %t", test.value, zero, test.zero)
+		}
+	}
+}
+
+func TestOmitValue(t *testing.T) {
+	tests := []struct {
+		value    interface{}
+		omitNil  bool
+		omitZero bool
+		result   interface{}
+		omit     bool
+	}{
+		{nil, false, false, nil, false},
+		{nil, true, false, nil, true},
+		{nil, false, true, nil, true},
+		{0, true, false, 0, false},
+		{0, false, true, 0, true},
+		{Keep(nil), true, true, nil, false},
+		{Keep(0), true, true, 0, false},
+	}
+
+	for _, test := range tests {
+		v, omit := OmitValue(test.value, test.omitNil, test.omitZero)
+
+		if v != test.result || omit != test.omit {
+			t.Errorf("%#v: nil=%t zero=%t: (%#v, %t) != (%#v, %t)", test.value, test.omitNil, test.omitZero, v, omit, test.result, test.omit)
+		}
+	}
+}
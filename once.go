@@ -0,0 +1,243 @@
+package events
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+)
+
+// DefaultOnceCapacity is the default number of fingerprints remembered by the
+// handlers returned by NewOnce.
+const DefaultOnceCapacity = 4096
+
+// OnceHandler is a handler which forwards the first event of each fingerprint
+// to another handler and drops the following ones. It is intended to be used on
+// events that should be reported once per process, like deprecation warnings,
+// but are produced by code paths that run many times.
+//
+// An event's fingerprint is computed from its message and its arguments, the
+// event time and source are ignored.
+//
+// The handler remembers at most Capacity fingerprints, the least recently seen
+// ones are forgotten first. An event whose fingerprint was forgotten is
+// forwarded again.
+//
+// It is safe to use the handler concurrently from multiple goroutines, when
+// two goroutines race on the first occurrence of a fingerprint only one of the
+// events is forwarded.
+type OnceHandler struct {
+	// Capacity is the maximum number of fingerprints remembered by the
+	// handler, it defaults to DefaultOnceCapacity and may be changed before
+	// the handler is used.
+	Capacity int
+
+	// MessageOnly may be set to true before the handler is used to compute
+	// fingerprints from event messages only, so events with the same message
+	// but different arguments are considered identical.
+	MessageOnly bool
+
+	handler Handler
+	mutex   sync.Mutex
+	lru     list.List // fingerprints, most recently seen first
+	seen    map[uint64]*list.Element
+}
+
+// NewOnce returns a OnceHandler which forwards events to h.
+func NewOnce(h Handler) *OnceHandler {
+	return &OnceHandler{
+		Capacity: DefaultOnceCapacity,
+		handler:  h,
+		seen:     make(map[uint64]*list.Element),
+	}
+}
+
+// HandleEvent satisfies the Handler interface.
+func (o *OnceHandler) HandleEvent(e *Event) {
+	o.handle(e, forwardEvent)
+}
+
+// HandleEventSync satisfies the SyncHandler interface.
+func (o *OnceHandler) HandleEventSync(ctx context.Context, e *Event) error {
+	return handleSync(ctx, o, func(forward func(Handler, *Event)) { o.handle(e, forward) })
+}
+
+// HandleEventContext satisfies the ContextHandler interface.
+func (o *OnceHandler) HandleEventContext(ctx context.Context, e *Event) error {
+	return handleContext(ctx, func(forward func(Handler, *Event)) { o.handle(e, forward) })
+}
+
+func (o *OnceHandler) handle(e *Event, forward func(Handler, *Event)) {
+	if IsDiagnostic(e) {
+		forward(o.handler, e)
+		return
+	}
+
+	f := fingerprint(e, !o.MessageOnly)
+
+	o.mutex.Lock()
+
+	if elem, ok := o.seen[f]; ok {
+		o.lru.MoveToFront(elem)
+		o.mutex.Unlock()
+		return
+	}
+
+	for o.lru.Len() != 0 && o.lru.Len() >= o.Capacity {
+		delete(o.seen, o.lru.Remove(o.lru.Back()).(uint64))
+	}
+
+	o.seen[f] = o.lru.PushFront(f)
+	o.mutex.Unlock()
+
+	forward(o.handler, e)
+}
+
+// Reset makes the handler forget all the fingerprints it has seen, the next
+// events are forwarded as if they had never been seen before.
+func (o *OnceHandler) Reset() {
+	o.mutex.Lock()
+	o.lru.Init()
+	o.seen = make(map[uint64]*list.Element)
+	o.mutex.Unlock()
+}
+
+// Unwrap returns the handler that events are forwarded to.
+func (o *OnceHandler) Unwrap() Handler {
+	return o.handler
+}
+
+// Flush flushes the handler that events are forwarded to.
+func (o *OnceHandler) Flush() error {
+	return flushHandler(o.handler)
+}
+
+// Close closes the handler that events are forwarded to.
+func (o *OnceHandler) Close() error {
+	return closeHandler(o.handler)
+}
+
+// fingerprint returns a 64 bits FNV-1a hash of the event message, and of the
+// event arguments if withArgs is true. Events without arguments, and arguments
+// with string values, are hashed without allocating memory.
+func fingerprint(e *Event, withArgs bool) uint64 {
+	h := fnvOffset64
+	h.writeString(e.Message)
+
+	if !withArgs || len(e.Args) == 0 {
+		return uint64(h)
+	}
+
+	return fingerprintArgs(h, e.Args)
+}
+
+// formatFingerprint returns the fingerprint of the format that a logger
+// produced the message of e from, or of its message if it wasn't formatted,
+// which is the same for all events produced by the same call site.
+func formatFingerprint(e *Event) uint64 {
+	formats.mutex.RLock()
+	f, ok := formats.fingerprints[e.Message]
+	formats.mutex.RUnlock()
+
+	if !ok {
+		f = stringFingerprint(e.Message)
+	}
+
+	return f
+}
+
+func stringFingerprint(s string) uint64 {
+	h := fnvOffset64
+	h.writeString(s)
+	return uint64(h)
+}
+
+// maxFormats is the number of formatted messages whose format fingerprint is
+// remembered.
+const maxFormats = 4096
+
+// formats maps the most recent messages formatted by loggers to the
+// fingerprints of their formats. Events don't carry their format, the table
+// lets the fingerprint be found after the event was copied or queued.
+var formats struct {
+	mutex        sync.RWMutex
+	fingerprints map[string]uint64
+	messages     [maxFormats]string // ring of the messages in fingerprints
+	next         int
+}
+
+// rememberFormat records that msg was produced from format, msg may be reused
+// by the caller after the function returned.
+func rememberFormat(msg []byte, format string) {
+	f := stringFingerprint(format)
+
+	formats.mutex.RLock()
+	g, ok := formats.fingerprints[bytesToString(msg)]
+	formats.mutex.RUnlock()
+
+	if ok && g == f {
+		return
+	}
+
+	m := string(msg)
+	formats.mutex.Lock()
+
+	if formats.fingerprints == nil {
+		formats.fingerprints = make(map[string]uint64)
+	}
+
+	if _, ok := formats.fingerprints[m]; !ok {
+		delete(formats.fingerprints, formats.messages[formats.next])
+		formats.messages[formats.next] = m
+		formats.next = (formats.next + 1) % maxFormats
+	}
+
+	formats.fingerprints[m] = f
+	formats.mutex.Unlock()
+}
+
+// fingerprintArgs is split from fingerprint because the hash escapes to the
+// heap when values are hashed with the fmt package.
+func fingerprintArgs(h fnv64a, args Args) uint64 {
+	for _, a := range args {
+		h.writeByte(0)
+		h.writeString(a.Name)
+		h.writeByte(0)
+
+		if s, ok := a.Value.(string); ok {
+			h.writeString(s)
+		} else {
+			fmt.Fprint(&h, a.Value)
+		}
+	}
+
+	return uint64(h)
+}
+
+// fnv64a is the state of a FNV-1a hash, it computes the same values as the
+// hash/fnv package but can hash strings without converting them to byte
+// slices.
+type fnv64a uint64
+
+const (
+	fnvOffset64 fnv64a = 14695981039346656037
+	fnvPrime64  fnv64a = 1099511628211
+)
+
+func (h *fnv64a) Write(b []byte) (int, error) {
+	for _, c := range b {
+		h.writeByte(c)
+	}
+	return len(b), nil
+}
+
+func (h *fnv64a) writeString(s string) {
+	for i := 0; i != len(s); i++ {
+		h.writeByte(s[i])
+	}
+}
+
+func (h *fnv64a) writeByte(c byte) {
+	*h ^= fnv64a(c)
+	*h *= fnvPrime64
+}
@@ -0,0 +1,190 @@
+package events
+
+import (
+	"hash/fnv"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestOnceHandler(t *testing.T) {
+	r := &eventRecorder{}
+	o := NewOnce(r)
+
+	o.HandleEvent(&Event{Message: "option X is deprecated"})
+	o.HandleEvent(&Event{Message: "option X is deprecated"})
+	o.HandleEvent(&Event{Message: "option X is deprecated", Args: Args{{"value", 1}}})
+	o.HandleEvent(&Event{Message: "option X is deprecated", Args: Args{{"value", 1}}})
+	o.HandleEvent(&Event{Message: "option X is deprecated", Args: Args{{"value", 2}}})
+	o.HandleEvent(&Event{Message: "option Y is deprecated", Source: "a.go:1"})
+	o.HandleEvent(&Event{Message: "option Y is deprecated", Source: "b.go:2"})
+
+	if n := r.len(); n != 4 {
+		t.Error("bad number of events forwarded:", n)
+	}
+
+	o.Reset()
+	o.HandleEvent(&Event{Message: "option X is deprecated"})
+
+	if n := r.len(); n != 5 {
+		t.Error("the event was not forwarded after Reset:", n)
+	}
+}
+
+func TestOnceHandlerMessageOnly(t *testing.T) {
+	r := &eventRecorder{}
+	o := NewOnce(r)
+	o.MessageOnly = true
+
+	o.HandleEvent(&Event{Message: "option X is deprecated", Args: Args{{"value", 1}}})
+	o.HandleEvent(&Event{Message: "option X is deprecated", Args: Args{{"value", 2}}})
+	o.HandleEvent(&Event{Message: "option Y is deprecated", Args: Args{{"value", 1}}})
+
+	if n := r.len(); n != 2 {
+		t.Error("bad number of events forwarded:", n)
+	}
+}
+
+func TestOnceHandlerCapacity(t *testing.T) {
+	h := &drainHandler{}
+	o := NewOnce(h)
+	o.Capacity = 2
+
+	for _, m := range []string{"A", "B", "A", "C", "A", "B"} {
+		o.HandleEvent(&Event{Message: m})
+	}
+
+	// "B" was the least recently seen when "C" arrived, so it was forgotten
+	// and forwarded again.
+	if n := atomic.LoadInt64(&h.count); n != 4 {
+		t.Error("bad number of events forwarded:", n)
+	}
+
+	if n := len(o.seen); n != 2 {
+		t.Error("bad number of fingerprints remembered:", n)
+	}
+}
+
+func TestOnceHandlerConcurrent(t *testing.T) {
+	h := &drainHandler{}
+	o := NewOnce(h)
+
+	start := make(chan struct{})
+	wg := sync.WaitGroup{}
+
+	for i := 0; i != 32; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			for j := 0; j != 10; j++ {
+				o.HandleEvent(&Event{Message: "warning " + strconv.Itoa(j)})
+			}
+		}()
+	}
+
+	close(start)
+	wg.Wait()
+
+	if n := atomic.LoadInt64(&h.count); n != 10 {
+		t.Error("bad number of events forwarded:", n)
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	e := &Event{Message: "hello", Args: Args{{"name", "Luke"}, {"answer", 42}}}
+
+	// The fingerprints must not change, they are visible in the RFC 5424
+	// message IDs.
+	ref := fnv.New64a()
+	ref.Write([]byte("hello"))
+
+	if f := fingerprint(e, false); f != ref.Sum64() {
+		t.Errorf("bad fingerprint without arguments: %x", f)
+	}
+
+	ref.Write([]byte("\x00name\x00Luke\x00answer\x0042"))
+
+	if f := fingerprint(e, true); f != ref.Sum64() {
+		t.Errorf("bad fingerprint with arguments: %x", f)
+	}
+
+	if f := fingerprint(&Event{Message: "hello"}, true); f != fingerprint(e, false) {
+		t.Errorf("bad fingerprint of an event without arguments: %x", f)
+	}
+
+	e = &Event{Message: "hello"}
+
+	if n := testing.AllocsPerRun(100, func() { fingerprint(e, true) }); n != 0 {
+		t.Errorf("%g allocations", n)
+	}
+}
+
+func TestFormatFingerprint(t *testing.T) {
+	var mutex sync.Mutex
+	var found []uint64
+
+	a := NewAsyncHandler(HandlerFunc(func(e *Event) {
+		mutex.Lock()
+		found = append(found, formatFingerprint(e))
+		mutex.Unlock()
+	}), AsyncConfig{})
+	defer a.Close()
+
+	// The events are copied when they are queued, the fingerprint of their
+	// format is still found.
+	l := NewLogger(a)
+	l.Log("%{user}s logged in", "luke")
+	l.Log("%{user}s logged in", "leia")
+	l.Log("hello")
+	a.Flush()
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if f := MessageFingerprint("%{user}s logged in"); len(found) != 3 || found[0] != f || found[1] != f {
+		t.Errorf("bad fingerprints: %x", found)
+	}
+
+	if found[2] != fingerprint(&Event{Message: "hello"}, false) {
+		t.Errorf("bad fingerprint of an event that wasn't formatted: %x", found[2])
+	}
+
+	// The formats of old messages are forgotten.
+	for i := 0; i != maxFormats; i++ {
+		rememberFormat([]byte("message "+strconv.Itoa(i)), "message %d")
+	}
+
+	formats.mutex.RLock()
+	n := len(formats.fingerprints)
+	formats.mutex.RUnlock()
+
+	if n != maxFormats {
+		t.Error("bad number of remembered formats:", n)
+	}
+
+	if f := formatFingerprint(&Event{Message: "luke logged in"}); f != stringFingerprint("luke logged in") {
+		t.Errorf("the format of an old message was not forgotten: %x", f)
+	}
+}
+
+func BenchmarkFingerprint(b *testing.B) {
+	for _, test := range []struct {
+		name string
+		args Args
+	}{
+		{"no-args", nil},
+		{"args", Args{{"name", "Luke"}, {"answer", 42}}},
+	} {
+		e := &Event{Message: "Hello World", Args: test.args}
+
+		b.Run(test.name, func(b *testing.B) {
+			b.ReportAllocs()
+
+			for i := 0; i != b.N; i++ {
+				fingerprint(e, true)
+			}
+		})
+	}
+}
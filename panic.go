@@ -0,0 +1,255 @@
+package events
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// PanicThreshold is the number of consecutive panics after which the
+	// circuit breaker of a handler opens and disables it.
+	PanicThreshold = 3
+
+	// PanicCooldown is the time during which a handler stays disabled after
+	// its circuit breaker opened.
+	PanicCooldown = time.Minute
+)
+
+// BreakerState values represent the states of the circuit breakers tracking
+// handlers that panicked.
+type BreakerState int
+
+const (
+	// BreakerClosed is the state of breakers of handlers that receive events.
+	BreakerClosed BreakerState = iota
+
+	// BreakerOpen is the state of breakers of handlers that panicked too many
+	// times in a row, the handlers don't receive events until the cooldown
+	// period is over.
+	BreakerOpen
+
+	// BreakerHalfOpen is the state of breakers whose cooldown period is over.
+	// The next event is passed to the handler, the breaker closes if the
+	// handler doesn't panic and opens again otherwise.
+	BreakerHalfOpen
+)
+
+// String satisfies the fmt.Stringer interface.
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return fmt.Sprintf("BreakerState(%d)", int(s))
+	}
+}
+
+// Breaker is a circuit breaker tracking the panics of a handler.
+//
+// Panics raised by handlers are recovered where events are dispatched, by
+// MultiHandler, DefaultHandler, the Logger and the AsyncHandler worker, and
+// converted to diagnostic events. A handler that panics PanicThreshold times in a row is
+// disabled for PanicCooldown.
+type Breaker struct {
+	mutex     sync.Mutex
+	open      bool
+	panics    int   // consecutive panics
+	total     int64 // panics since the breaker was created
+	openUntil time.Time
+}
+
+// HandlerBreaker returns the circuit breaker tracking h, or nil if h never
+// panicked.
+//
+// Handlers are identified by their type and address, so handlers that aren't
+// pointers share a breaker with the other values of their type, and functions
+// with the other closures created from the same function literal.
+//
+// MultiHandler and DefaultHandler never get a breaker, they recover the panics
+// of the handlers they pass events to, which have their own breakers.
+func HandlerBreaker(h Handler) *Breaker {
+	if h = breakerTarget(h); h == nil || atomic.LoadInt32(&breakers.count) == 0 {
+		return nil
+	}
+	if b, ok := breakers.m.Load(breakerKeyOf(h)); ok {
+		return b.(*Breaker)
+	}
+	return nil
+}
+
+// State returns the current state of the breaker.
+func (b *Breaker) State() BreakerState {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.state()
+}
+
+// Panics returns the total number of panics observed by the breaker.
+func (b *Breaker) Panics() int64 {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.total
+}
+
+func (b *Breaker) state() BreakerState {
+	switch {
+	case !b.open:
+		return BreakerClosed
+	case breakerNow().Before(b.openUntil):
+		return BreakerOpen
+	default:
+		return BreakerHalfOpen
+	}
+}
+
+func (b *Breaker) allow() bool {
+	return b.State() != BreakerOpen
+}
+
+func (b *Breaker) success() {
+	b.mutex.Lock()
+	b.open = false
+	b.panics = 0
+	b.mutex.Unlock()
+}
+
+func (b *Breaker) failure() BreakerState {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.panics++
+	b.total++
+
+	if b.open || b.panics >= PanicThreshold {
+		b.open = true
+		b.openUntil = breakerNow().Add(PanicCooldown)
+	}
+
+	return b.state()
+}
+
+var breakers struct {
+	count int32 // number of breakers in m, accessed atomically
+	m     sync.Map
+}
+
+// breakerNow is used to read the current time, tests may replace it.
+var breakerNow = time.Now
+
+type breakerKey struct {
+	t reflect.Type
+	p uintptr
+}
+
+func breakerKeyOf(h Handler) breakerKey {
+	v := reflect.ValueOf(h)
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Func, reflect.Map, reflect.Slice, reflect.Chan:
+		return breakerKey{v.Type(), v.Pointer()}
+	default:
+		return breakerKey{t: v.Type()}
+	}
+}
+
+func breakerOf(h Handler) *Breaker {
+	b, loaded := breakers.m.LoadOrStore(breakerKeyOf(h), &Breaker{})
+	if !loaded {
+		atomic.AddInt32(&breakers.count, 1)
+	}
+	return b.(*Breaker)
+}
+
+// breakerTarget returns the handler whose panics are tracked when events are
+// passed to h, priority wrappers are skipped. It returns nil for the handlers
+// which only forward events and recover the panics of the handlers they
+// forward to themselves.
+func breakerTarget(h Handler) Handler {
+	for {
+		switch x := h.(type) {
+		case *priorityHandler:
+			h = x.Handler
+		case *multiHandler, defaultHandler:
+			return nil
+		default:
+			return h
+		}
+	}
+}
+
+// safeCall calls f, which passes an event to h, and recovers from panics. When h
+// panics the function returns a diagnostic event describing the panic. If the
+// breaker of h is open f isn't called.
+func safeCall(h Handler, f func() bool) (stop bool, diag *Event) {
+	if h = breakerTarget(h); h == nil {
+		return f(), nil
+	}
+
+	b := HandlerBreaker(h)
+
+	if b != nil && !b.allow() {
+		return
+	}
+
+	defer func() {
+		if v := recover(); v != nil {
+			diag = panicEvent(h, v, breakerOf(h).failure())
+		}
+	}()
+
+	stop = f()
+
+	if b != nil {
+		b.success()
+	}
+
+	return
+}
+
+func panicEvent(h Handler, v interface{}, state BreakerState) *Event {
+	return Diagnostic(fmt.Sprintf("%T panicked: %v", h, v),
+		Arg{"handler", fmt.Sprintf("%T", h)},
+		Arg{"panic", fmt.Sprint(v)},
+		Arg{"stack", string(debug.Stack())},
+		Arg{"breaker", state.String()},
+	)
+}
+
+// reportPanic passes the diagnostic event produced by a panic of h to the
+// default handler, or writes it to stderr if h is the default handler or if
+// the default handler panics as well.
+func reportPanic(h Handler, diag *Event) {
+	if !isDefaultHandler(h) {
+		if _, d := safeCall(DefaultHandler, func() bool { DefaultHandler.HandleEvent(diag); return false }); d == nil {
+			return
+		}
+	}
+
+	stack, _ := diag.Args.Get("stack")
+	fmt.Fprintf(os.Stderr, "events: %s\n%s", diag.Message, stack)
+}
+
+// isDefaultHandler returns true if h is the default handler, or the handler it
+// forwards events to.
+func isDefaultHandler(h Handler) bool {
+	key := breakerKeyOf(h)
+
+	if key == breakerKeyOf(DefaultHandler) {
+		return true
+	}
+
+	if _, ok := DefaultHandler.(defaultHandler); ok {
+		return key == breakerKeyOf(loadDefault().handler)
+	}
+
+	return false
+}
@@ -0,0 +1,211 @@
+package events
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// panicHandler panics on events with the "panic" message, or on all events if
+// all is non-zero, and records the other events.
+type panicHandler struct {
+	eventRecorder
+	all   int32
+	calls int32
+}
+
+func (h *panicHandler) HandleEvent(e *Event) {
+	atomic.AddInt32(&h.calls, 1)
+
+	if atomic.LoadInt32(&h.all) != 0 || e.Message == "panic" {
+		panic("boom")
+	}
+
+	h.eventRecorder.HandleEvent(e)
+}
+
+// newPanicHandler returns a panicHandler whose breaker is removed when the test
+// completes, so the handlers allocated at the same address by the next tests
+// don't share its state.
+func newPanicHandler(t *testing.T, all int32) *panicHandler {
+	p := &panicHandler{all: all}
+	t.Cleanup(func() {
+		if _, ok := breakers.m.LoadAndDelete(breakerKeyOf(p)); ok {
+			atomic.AddInt32(&breakers.count, -1)
+		}
+	})
+	return p
+}
+
+func fakeBreakerClock() (*fakeClock, func()) {
+	clock := &fakeClock{now: time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)}
+	prev := breakerNow
+	breakerNow = clock.Now
+	return clock, func() { breakerNow = prev }
+}
+
+func assertPanicEvent(t *testing.T, e *Event) {
+	m := e.Args.Map()
+
+	if m["handler"] != "*events.panicHandler" || m["panic"] != "boom" {
+		t.Errorf("bad diagnostic event: %+v", e)
+	}
+
+	if s, _ := m["stack"].(string); !strings.Contains(s, "(*panicHandler).HandleEvent") {
+		t.Error("the stack doesn't show where the handler panicked:", s)
+	}
+}
+
+func TestMultiHandlerPanic(t *testing.T) {
+	p := newPanicHandler(t, 0)
+	r := &eventRecorder{}
+	m := MultiHandler(p, r)
+
+	m.HandleEvent(&Event{Message: "panic"})
+
+	assertPanicEvent(t, r.wait(t))
+
+	if e := r.wait(t); e.Message != "panic" {
+		t.Error("the event was not passed to the next handler:", e)
+	}
+
+	m.HandleEvent(&Event{Message: "A"})
+
+	if e := p.wait(t); e.Message != "A" {
+		t.Error("bad event:", e)
+	}
+
+	if b := HandlerBreaker(p); b == nil || b.State() != BreakerClosed || b.Panics() != 1 {
+		t.Errorf("bad breaker: %+v", b)
+	}
+}
+
+func TestHandlerBreaker(t *testing.T) {
+	clock, restore := fakeBreakerClock()
+	defer restore()
+
+	p := newPanicHandler(t, 1)
+	r := &eventRecorder{}
+	m := MultiHandler(p, r)
+
+	for i := 0; i != PanicThreshold; i++ {
+		m.HandleEvent(&Event{Message: "A"})
+	}
+
+	b := HandlerBreaker(p)
+
+	if s := b.State(); s != BreakerOpen {
+		t.Fatal("the breaker did not open:", s)
+	}
+
+	if e := r.events[len(r.events)-2]; e.Args.Map()["breaker"] != "open" {
+		t.Error("the last diagnostic event doesn't report the open breaker:", e)
+	}
+
+	r.reset()
+	m.HandleEvent(&Event{Message: "B"})
+
+	if n := atomic.LoadInt32(&p.calls); n != int32(PanicThreshold) {
+		t.Error("the disabled handler was called:", n)
+	}
+
+	if e := r.wait(t); e.Message != "B" || r.len() != 0 {
+		t.Error("bad event:", e)
+	}
+
+	// When the cooldown is over a panic opens the breaker again.
+	clock.add(PanicCooldown)
+
+	if s := b.State(); s != BreakerHalfOpen {
+		t.Fatal("bad breaker state:", s)
+	}
+
+	m.HandleEvent(&Event{Message: "C"})
+
+	if s := b.State(); s != BreakerOpen {
+		t.Fatal("the breaker did not open again:", s)
+	}
+
+	// The breaker closes when the handler recovers.
+	clock.add(PanicCooldown)
+	atomic.StoreInt32(&p.all, 0)
+	m.HandleEvent(&Event{Message: "D"})
+
+	if s := b.State(); s != BreakerClosed {
+		t.Fatal("the breaker did not close:", s)
+	}
+
+	if e := p.wait(t); e.Message != "D" {
+		t.Error("bad event:", e)
+	}
+}
+
+func TestLoggerPanic(t *testing.T) {
+	r := &eventRecorder{}
+	old := SwapDefaultHandler(r)
+	defer SwapDefaultHandler(old)
+
+	p := newPanicHandler(t, 0)
+	l := Logger{Handler: p}
+	l.Log("panic")
+	l.Log("A")
+
+	assertPanicEvent(t, r.wait(t))
+
+	if e := p.wait(t); e.Message != "A" {
+		t.Error("bad event:", e)
+	}
+}
+
+func TestDefaultHandlerPanic(t *testing.T) {
+	p := newPanicHandler(t, 1)
+	old := SwapDefaultHandler(p)
+	defer SwapDefaultHandler(old)
+
+	l := Logger{}
+
+	for i := 0; i != PanicThreshold; i++ {
+		l.Log("A")
+	}
+
+	if b := HandlerBreaker(DefaultHandler); b != nil {
+		t.Errorf("the default handler has a breaker: %+v", b)
+	}
+
+	if b := HandlerBreaker(p); b == nil || b.State() != BreakerOpen {
+		t.Errorf("the breaker of the panicking handler did not open: %+v", b)
+	}
+
+	r := &eventRecorder{}
+	SwapDefaultHandler(r)
+	l.Log("B")
+	l.LogContext(context.Background(), "C")
+
+	if e := r.wait(t); e.Message != "B" {
+		t.Error("bad event:", e)
+	}
+
+	if e := r.wait(t); e.Message != "C" {
+		t.Error("bad event:", e)
+	}
+}
+
+func TestAsyncHandlerPanic(t *testing.T) {
+	r := &eventRecorder{}
+	old := SwapDefaultHandler(r)
+	defer SwapDefaultHandler(old)
+
+	p := newPanicHandler(t, 0)
+	a := NewAsyncHandler(p, AsyncConfig{SummaryInterval: time.Hour})
+	a.HandleEvent(&Event{Message: "panic"})
+	a.HandleEvent(&Event{Message: "A"})
+	a.Close()
+
+	assertPanicEvent(t, r.wait(t))
+
+	if e := p.wait(t); e.Message != "A" {
+		t.Error("the worker stopped after the handler panicked:", e)
+	}
+}
@@ -0,0 +1,102 @@
+package events
+
+import "sync"
+
+// PoisonArgs enables a debugging mode of the argument pool, where the lists
+// passed to PutArgs are overwritten with poisoned arguments instead of being
+// reused. Handlers that retain arguments after returning then see poisoned
+// values, which makes retention bugs visible in tests.
+var PoisonArgs = false
+
+// PooledArgs is a list of arguments obtained from GetArgs.
+//
+// When passed as last argument to the Log or Debug methods of a Logger, a
+// PooledArgs value is treated like an Args value, and the list is given back
+// to the pool with PutArgs after the logger's handler returned. The program
+// must not use the list after passing it to the logger, and handlers that
+// keep events after returning must clone them (see Event.Clone).
+type PooledArgs Args
+
+// GetArgs returns an empty argument list with a capacity of at least capacity
+// elements, reusing lists given back to the pool with PutArgs when possible.
+func GetArgs(capacity int) Args {
+	c := argsClassOf(capacity)
+
+	if c < 0 {
+		return make(Args, 0, capacity)
+	}
+
+	if b, _ := argsPools[c].Get().(*Args); b != nil {
+		args := *b
+		*b = nil
+		argsBoxes.Put(b)
+		return args
+	}
+
+	return make(Args, 0, argsClasses[c])
+}
+
+// PutArgs gives args back to the pool so it can be returned by a future call
+// to GetArgs. The program must not use args after calling PutArgs.
+func PutArgs(args Args) {
+	args = args[:cap(args)]
+
+	if PoisonArgs {
+		for i := range args {
+			args[i] = poisonedArg
+		}
+		return
+	}
+
+	for i := range args {
+		args[i] = Arg{}
+	}
+
+	// Lists are stored in the largest class that they can hold, lists that are
+	// too small or too large for the pool are left to the garbage collector.
+	c := len(argsClasses) - 1
+
+	for c >= 0 && argsClasses[c] > len(args) {
+		c--
+	}
+
+	if c < 0 || len(args) > 2*argsClasses[len(argsClasses)-1] {
+		return
+	}
+
+	b, _ := argsBoxes.Get().(*Args)
+	if b == nil {
+		b = new(Args)
+	}
+	*b = args[:0]
+	argsPools[c].Put(b)
+}
+
+// AppendArg appends an argument with name and value to args, and returns the
+// updated list.
+func AppendArg(args Args, name string, value interface{}) Args {
+	return append(args, Arg{name, value})
+}
+
+// poisonedArg is written over the arguments of lists given back to the pool
+// while PoisonArgs is enabled.
+var poisonedArg = Arg{Name: "events.poisoned", Value: "events.poisoned"}
+
+var argsClasses = [...]int{8, 16, 32, 64, 128}
+
+var argsPools [len(argsClasses)]sync.Pool
+
+// argsBoxes holds the pointers used to store lists in the pools, so putting
+// lists back doesn't allocate.
+var argsBoxes sync.Pool
+
+// argsClassOf returns the index of the smallest size class that can hold n
+// arguments, or -1 if n is too large for the pool.
+func argsClassOf(n int) int {
+	for i, size := range argsClasses {
+		if n <= size {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,130 @@
+package events
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestGetArgs(t *testing.T) {
+	for _, test := range []struct {
+		capacity int
+		min      int
+	}{
+		{0, 8},
+		{1, 8},
+		{8, 8},
+		{9, 16},
+		{100, 128},
+		{1000, 1000},
+	} {
+		args := GetArgs(test.capacity)
+
+		if len(args) != 0 || cap(args) < test.min {
+			t.Errorf("GetArgs(%d): len=%d cap=%d", test.capacity, len(args), cap(args))
+		}
+
+		PutArgs(AppendArg(args, "a", 1))
+	}
+}
+
+func TestPutArgs(t *testing.T) {
+	args := AppendArg(GetArgs(8), "name", "Luke")
+	PutArgs(args)
+
+	// Lists are cleared before being reused so they don't retain values.
+	if args[:1][0] != (Arg{}) {
+		t.Error("the list was not cleared:", args[:1])
+	}
+}
+
+func TestPoisonArgs(t *testing.T) {
+	defer func() { PoisonArgs = false }()
+	PoisonArgs = true
+
+	var retained *Event
+	h := HandlerFunc(func(e *Event) { retained = e }) // doesn't clone
+
+	args := GetArgs(4)
+	args = AppendArg(args, "name", "Luke")
+	args = AppendArg(args, "from", "Han")
+
+	h.HandleEvent(&Event{Message: "hello", Args: args})
+	PutArgs(args)
+
+	for _, a := range retained.Args {
+		if a != poisonedArg {
+			t.Error("the argument retained by the handler was not poisoned:", a)
+		}
+	}
+
+	if next := GetArgs(4); &next[:1][0] == &args[0] {
+		t.Error("a poisoned list was reused")
+	}
+}
+
+func TestLoggerPooledArgs(t *testing.T) {
+	defer func() { PoisonArgs = false }()
+	PoisonArgs = true
+
+	r := &eventRecorder{}
+	l := NewLogger(r)
+	l.EnableSource = false
+
+	args := AppendArg(GetArgs(8), "from", "Han")
+	l.Log("Hello %{name}s!", "Luke", PooledArgs(args))
+
+	// The recorder clones events so it must be unaffected by the pool.
+	e := r.wait(t)
+	want := &Event{Message: "Hello Luke!", Args: Args{{"name", "Luke"}, {"from", "Han"}}, Time: e.Time}
+
+	if diff := Diff(want, e); len(diff) != 0 {
+		t.Error(diff)
+	}
+
+	if args[0] != poisonedArg {
+		t.Error("the list was not given back to the pool:", args)
+	}
+
+	args = AppendArg(GetArgs(8), "from", "Han")
+	l.EnableDebug = false
+	l.Debug("Hello %{name}s!", "Luke", PooledArgs(args))
+
+	if args[0] != poisonedArg {
+		t.Error("the list was not given back to the pool by a disabled Debug call:", args)
+	}
+}
+
+func BenchmarkLoggerArgs(b *testing.B) {
+	logger := Logger{
+		Handler: Discard,
+	}
+
+	for _, n := range []int{8, 32} {
+		names := make([]string, n)
+		for i := range names {
+			names[i] = "arg" + strconv.Itoa(i)
+		}
+
+		b.Run("make:"+strconv.Itoa(n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i != b.N; i++ {
+				args := make(Args, 0, n)
+				for j, name := range names {
+					args = AppendArg(args, name, j)
+				}
+				logger.Log("hello", args)
+			}
+		})
+
+		b.Run("pool:"+strconv.Itoa(n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i != b.N; i++ {
+				args := GetArgs(n)
+				for j, name := range names {
+					args = AppendArg(args, name, j)
+				}
+				logger.Log("hello", PooledArgs(args))
+			}
+		})
+	}
+}
@@ -0,0 +1,213 @@
+package events
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+)
+
+// The Reader interface is implemented by types that read sequences of events,
+// like the output of encoders stored in files.
+//
+// ReadEvent returns the next event, or io.EOF when there are no more events to
+// read.
+type Reader interface {
+	ReadEvent() (*Event, error)
+}
+
+// NewLineReader returns a Reader which reads events from the lines of r, for
+// example a file written with JSONEncoder.
+//
+// Each line is passed to parse, which returns the event that it represents. If
+// parse is nil or returns nil the line is used as the event message, like with
+// NewLineWriter. Empty lines are skipped and the line endings (either "\n" or
+// "\r\n") are stripped.
+func NewLineReader(r io.Reader, parse func(line string) *Event) Reader {
+	return &lineReader{
+		reader: bufio.NewReader(r),
+		parse:  parse,
+	}
+}
+
+type lineReader struct {
+	reader *bufio.Reader
+	parse  func(string) *Event
+}
+
+func (r *lineReader) ReadEvent() (*Event, error) {
+	for {
+		line, err := r.reader.ReadString('\n')
+
+		if len(line) == 0 && err != nil {
+			return nil, err
+		}
+
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+
+		if len(line) == 0 {
+			continue
+		}
+
+		var e *Event
+		if r.parse != nil {
+			e = r.parse(line)
+		}
+
+		if e == nil {
+			e = &Event{Message: line}
+		}
+
+		return e, nil
+	}
+}
+
+// ErrStopQuery may be returned by the function passed to StreamQuery.Run to
+// stop the query, Run then returns nil.
+var ErrStopQuery = errors.New("events: stop query")
+
+// StreamQuery reads the events matching a set of conditions from a Reader. The
+// events are processed one at a time, so queries run in constant memory
+// regardless of the size of the stream.
+//
+// Queries are built by chaining calls to the methods of StreamQuery, then
+// executed with Run. For example:
+//
+//	err := events.NewStreamQuery(events.NewLineReader(f, events.ParseJSONLine)).
+//		Where(func(e *events.Event) bool { return !e.Debug }).
+//		Select("user", "error").
+//		Limit(10).
+//		Run(func(e *events.Event) error {
+//			fmt.Println(e.Message, e.Args)
+//			return nil
+//		})
+type StreamQuery struct {
+	reader   Reader
+	where    []func(*Event) bool
+	names    []string
+	selected bool
+	limit    int
+	err      error
+}
+
+// NewStreamQuery returns a StreamQuery which reads events from r.
+func NewStreamQuery(r Reader) *StreamQuery {
+	return &StreamQuery{reader: r}
+}
+
+// Where adds a condition to q, the query only produces the events for which
+// all the conditions return true.
+func (q *StreamQuery) Where(pred func(*Event) bool) *StreamQuery {
+	q.where = append(q.where, pred)
+	return q
+}
+
+// WhereFilter is like Where but takes a filter expression (see ParseFilter).
+// A syntax error in the expression is returned by Run.
+func (q *StreamQuery) WhereFilter(expr string) *StreamQuery {
+	pred, err := ParseFilter(expr)
+
+	if err != nil {
+		if q.err == nil {
+			q.err = err
+		}
+		return q
+	}
+
+	return q.Where(pred)
+}
+
+// Select restricts the arguments of the events produced by q to the ones with
+// the given names, the message, source, time and debug fields of events are
+// always kept. By default the events have all their arguments.
+func (q *StreamQuery) Select(names ...string) *StreamQuery {
+	q.names = append(q.names, names...)
+	q.selected = true
+	return q
+}
+
+// Limit sets the maximum number of events produced by q, the query stops
+// reading events after reaching the limit. Zero or negative values mean no
+// limit.
+func (q *StreamQuery) Limit(n int) *StreamQuery {
+	q.limit = n
+	return q
+}
+
+// Run executes q, calling fn with each event it produces. The query stops when
+// the reader has no more events, the limit is reached, or fn returns an error.
+//
+// Run returns the error returned by fn or by the reader, or nil when the
+// reader returned io.EOF or fn returned ErrStopQuery.
+func (q *StreamQuery) Run(fn func(*Event) error) error {
+	if q.err != nil {
+		return q.err
+	}
+
+	for n := 0; q.limit <= 0 || n < q.limit; {
+		e, err := q.reader.ReadEvent()
+
+		switch err {
+		case nil:
+		case io.EOF:
+			return nil
+		default:
+			return err
+		}
+
+		if !q.match(e) {
+			continue
+		}
+
+		if q.selected {
+			e.Args = q.project(e.Args)
+		}
+
+		n++
+
+		switch err := fn(e); err {
+		case nil:
+		case ErrStopQuery:
+			return nil
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (q *StreamQuery) match(e *Event) bool {
+	for _, pred := range q.where {
+		if !pred(e) {
+			return false
+		}
+	}
+	return true
+}
+
+// project returns a new list holding the arguments of args selected by q, so
+// the memory of the other arguments can be released.
+func (q *StreamQuery) project(args Args) Args {
+	n := 0
+
+	for _, a := range args {
+		if containsString(q.names, a.Name) {
+			n++
+		}
+	}
+
+	if n == 0 {
+		return nil
+	}
+
+	list := make(Args, 0, n)
+
+	for _, a := range args {
+		if containsString(q.names, a.Name) {
+			list = append(list, a)
+		}
+	}
+
+	return list
+}
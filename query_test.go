@@ -0,0 +1,202 @@
+package events
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// countingReader counts the bytes read from the reader it wraps.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(b []byte) (int, error) {
+	n, err := c.r.Read(b)
+	c.n += int64(n)
+	return n, err
+}
+
+// writeQueryFile writes n events to a temporary file with JSONEncoder, and
+// returns the file open at the beginning and its size.
+func writeQueryFile(t *testing.T, n int) (*os.File, int64) {
+	f, err := ioutil.TempFile("", "events-query-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(f.Name())
+
+	w := bufio.NewWriter(f)
+	b := []byte(nil)
+	start := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for i := 0; i != n; i++ {
+		e := &Event{
+			Message: fmt.Sprintf("request %d", i),
+			Source:  "query_test.go:42",
+			Time:    start.Add(time.Duration(i) * time.Second),
+			Debug:   i%10 == 0,
+			Args: Args{
+				{"id", i},
+				{"status", 200 + 300*(i%7/6)},
+				{"user", fmt.Sprintf("user-%d", i%3)},
+				{"payload", strings.Repeat("x", 64)},
+			},
+		}
+
+		if b, err = (JSONEncoder{}).EncodeEvent(b[:0], e); err != nil {
+			t.Fatal(err)
+		}
+		w.Write(b)
+	}
+
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	size, _ := f.Seek(0, io.SeekCurrent)
+	f.Seek(0, io.SeekStart)
+	return f, size
+}
+
+func TestStreamQuery(t *testing.T) {
+	f, size := writeQueryFile(t, 10000)
+	defer f.Close()
+
+	var found []*Event
+	r := &countingReader{r: f}
+
+	err := NewStreamQuery(NewLineReader(r, ParseJSONLine)).
+		Where(func(e *Event) bool { return !e.Debug }).
+		WhereFilter(`args.status >= 500`).
+		Select("id", "user").
+		Limit(3).
+		Run(func(e *Event) error {
+			found = append(found, e)
+			return nil
+		})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ids []string
+
+	for _, e := range found {
+		v, _ := e.Args.Get("id")
+		ids = append(ids, fmt.Sprint(v))
+
+		if len(e.Args) != 2 || e.Args[1].Name != "user" {
+			t.Error("the arguments were not projected:", e.Args)
+		}
+
+		if e.Source != "query_test.go:42" || e.Time.IsZero() || !strings.HasPrefix(e.Message, "request ") {
+			t.Errorf("the event fields were not kept: %+v", e)
+		}
+	}
+
+	if !reflect.DeepEqual(ids, []string{"6", "13", "27"}) {
+		t.Error("bad events:", ids)
+	}
+
+	// The query stopped reading the file after finding the events, within the
+	// limits of the buffering done by the reader.
+	if r.n >= size/10 {
+		t.Errorf("the query read %d of the %d bytes of the file", r.n, size)
+	}
+}
+
+func TestStreamQueryAll(t *testing.T) {
+	f, _ := writeQueryFile(t, 10000)
+	defer f.Close()
+
+	n := 0
+	err := NewStreamQuery(NewLineReader(f, ParseJSONLine)).
+		WhereFilter(`args.user == "user-1"`).
+		Run(func(e *Event) error {
+			if len(e.Args) != 5 { // the level is an argument of parsed events
+				return fmt.Errorf("bad arguments: %v", e.Args)
+			}
+			n++
+			return nil
+		})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != 3333 {
+		t.Error("bad number of events:", n)
+	}
+}
+
+func TestStreamQueryStop(t *testing.T) {
+	f, size := writeQueryFile(t, 10000)
+	defer f.Close()
+
+	oops := errors.New("oops")
+
+	for _, test := range []struct {
+		err    error
+		result error
+	}{
+		{ErrStopQuery, nil},
+		{oops, oops},
+	} {
+		f.Seek(0, io.SeekStart)
+		r := &countingReader{r: f}
+		n := 0
+
+		err := NewStreamQuery(NewLineReader(r, ParseJSONLine)).Run(func(e *Event) error {
+			if n++; n == 100 {
+				return test.err
+			}
+			return nil
+		})
+
+		if err != test.result {
+			t.Errorf("%v: bad error: %v", test.err, err)
+		}
+
+		if n != 100 || r.n >= size/10 {
+			t.Errorf("%v: the query didn't stop: %d events, %d bytes", test.err, n, r.n)
+		}
+	}
+}
+
+func TestStreamQueryFilterError(t *testing.T) {
+	called := false
+	err := NewStreamQuery(NewLineReader(strings.NewReader("hello\n"), nil)).
+		WhereFilter(`args.status >=`).
+		Run(func(*Event) error { called = true; return nil })
+
+	if _, ok := err.(*FilterError); !ok || called {
+		t.Error("bad error:", err)
+	}
+}
+
+func TestLineReader(t *testing.T) {
+	r := NewLineReader(strings.NewReader("{\"message\":\"A\"}\r\n\nB\n{\"message\":\"C\"}"), ParseJSONLine)
+	var messages []string
+
+	for {
+		e, err := r.ReadEvent()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		messages = append(messages, e.Message)
+	}
+
+	assertMessages(t, messages, "A", "B", "C")
+}
@@ -0,0 +1,9 @@
+//go:build race
+// +build race
+
+package events
+
+// RaceEnabled is true when the tests run with the race detector, which makes
+// sync.Pool drop objects at random so the allocations of code paths using
+// pools can't be counted.
+const RaceEnabled = true
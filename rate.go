@@ -0,0 +1,210 @@
+package events
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RateTracker is a handler which forwards events to another handler and keeps
+// counts of the events it has seen over a sliding time window.
+//
+// The window is divided in buckets, counts are kept per bucket and expire one
+// bucket at a time, which is the granularity at which the tracker can report
+// statistics. Buckets are rotated when events arrive and expired buckets are
+// skipped when statistics are read, the tracker doesn't use a background
+// goroutine.
+//
+// It is safe to use a tracker concurrently from multiple goroutines.
+type RateTracker struct {
+	// Now returns the current time, it defaults to time.Now and may be set to
+	// a different function before the tracker is used (in tests for example).
+	Now func() time.Time
+
+	handler Handler
+	window  time.Duration
+	bucket  time.Duration
+	buckets []rateBucket
+	mutex   sync.Mutex // serializes bucket rotations
+}
+
+// RateSnapshot carries the counts of events seen by a RateTracker over its
+// time window.
+type RateSnapshot struct {
+	Window time.Duration // time window over which the events were counted
+	Events int64         // number of events
+	Debug  int64         // number of debug events
+	Errors int64         // number of events with at least one error argument
+}
+
+const (
+	rateEvents = iota
+	rateDebug
+	rateErrors
+	rateClasses
+)
+
+type rateBucket struct {
+	// epoch is the index of the time slice that the bucket holds counts for,
+	// the fields are accessed atomically.
+	epoch  int64
+	counts [rateClasses]int64
+}
+
+// NewRateTracker returns a RateTracker which forwards events to h and counts
+// them over window, divided in the given number of buckets.
+//
+// The function panics if window isn't positive, or if buckets is lower than 1
+// or greater than the number of nanoseconds in window.
+func NewRateTracker(h Handler, window time.Duration, buckets int) *RateTracker {
+	if window <= 0 {
+		panic("events.NewRateTracker: the time window must be positive")
+	}
+
+	if buckets < 1 || int64(buckets) > int64(window) {
+		panic("events.NewRateTracker: invalid number of buckets")
+	}
+
+	t := &RateTracker{
+		Now:     time.Now,
+		handler: h,
+		window:  window,
+		bucket:  window / time.Duration(buckets),
+		buckets: make([]rateBucket, buckets),
+	}
+
+	for i := range t.buckets {
+		t.buckets[i].epoch = -1
+	}
+
+	return t
+}
+
+// HandleEvent satisfies the Handler interface.
+func (t *RateTracker) HandleEvent(e *Event) {
+	t.handle(e, forwardEvent)
+}
+
+// HandleEventSync satisfies the SyncHandler interface.
+func (t *RateTracker) HandleEventSync(ctx context.Context, e *Event) error {
+	return handleSync(ctx, t, func(forward func(Handler, *Event)) { t.handle(e, forward) })
+}
+
+// HandleEventContext satisfies the ContextHandler interface.
+func (t *RateTracker) HandleEventContext(ctx context.Context, e *Event) error {
+	return handleContext(ctx, func(forward func(Handler, *Event)) { t.handle(e, forward) })
+}
+
+func (t *RateTracker) handle(e *Event, forward func(Handler, *Event)) {
+	if IsDiagnostic(e) {
+		forward(t.handler, e)
+		return
+	}
+
+	b := t.current(t.epoch())
+	atomic.AddInt64(&b.counts[rateEvents], 1)
+
+	if e.Debug {
+		atomic.AddInt64(&b.counts[rateDebug], 1)
+	}
+
+	for _, a := range e.Args {
+		if _, ok := a.Value.(error); ok {
+			atomic.AddInt64(&b.counts[rateErrors], 1)
+			break
+		}
+	}
+
+	forward(t.handler, e)
+}
+
+// Unwrap returns the handler that the tracker forwards events to.
+func (t *RateTracker) Unwrap() Handler {
+	return t.handler
+}
+
+// Flush flushes the handler that the tracker forwards events to.
+func (t *RateTracker) Flush() error {
+	return flushHandler(t.handler)
+}
+
+// Close closes the handler that the tracker forwards events to.
+func (t *RateTracker) Close() error {
+	return closeHandler(t.handler)
+}
+
+// Rate returns the number of events per second seen over the tracker's time
+// window.
+func (t *RateTracker) Rate() float64 {
+	return float64(t.Count(t.window)) / t.window.Seconds()
+}
+
+// Count returns the number of events seen over the last window, which is
+// rounded up to a multiple of the bucket duration and capped to the tracker's
+// time window.
+func (t *RateTracker) Count(window time.Duration) int64 {
+	return t.count(t.epoch(), window)[rateEvents]
+}
+
+// Snapshot returns the counts of events seen over the tracker's time window.
+func (t *RateTracker) Snapshot() RateSnapshot {
+	c := t.count(t.epoch(), t.window)
+	return RateSnapshot{
+		Window: t.window,
+		Events: c[rateEvents],
+		Debug:  c[rateDebug],
+		Errors: c[rateErrors],
+	}
+}
+
+func (t *RateTracker) epoch() int64 {
+	return t.Now().UnixNano() / int64(t.bucket)
+}
+
+// current returns the bucket for epoch, resetting it if it still holds counts
+// of an older epoch.
+func (t *RateTracker) current(epoch int64) *rateBucket {
+	b := &t.buckets[int(uint64(epoch)%uint64(len(t.buckets)))]
+
+	if atomic.LoadInt64(&b.epoch) < epoch {
+		t.mutex.Lock()
+
+		if atomic.LoadInt64(&b.epoch) < epoch {
+			for i := range b.counts {
+				atomic.StoreInt64(&b.counts[i], 0)
+			}
+			atomic.StoreInt64(&b.epoch, epoch)
+		}
+
+		t.mutex.Unlock()
+	}
+
+	return b
+}
+
+// count sums the counts of the buckets holding the epochs within window of the
+// given epoch, expired buckets are skipped without being reset.
+func (t *RateTracker) count(epoch int64, window time.Duration) (c [rateClasses]int64) {
+	if window <= 0 {
+		return
+	}
+
+	n := int64((window + t.bucket - 1) / t.bucket)
+
+	if n > int64(len(t.buckets)) {
+		n = int64(len(t.buckets))
+	}
+
+	for i := range t.buckets {
+		b := &t.buckets[i]
+
+		if e := atomic.LoadInt64(&b.epoch); e > epoch-n && e <= epoch {
+			for j := range c {
+				c[j] += atomic.LoadInt64(&b.counts[j])
+			}
+		}
+	}
+
+	return
+}
@@ -0,0 +1,191 @@
+package events
+
+import (
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	mutex sync.Mutex
+	now   time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) add(d time.Duration) {
+	c.mutex.Lock()
+	c.now = c.now.Add(d)
+	c.mutex.Unlock()
+}
+
+func newTestRateTracker(h Handler) (*RateTracker, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)}
+	t := NewRateTracker(h, 60*time.Second, 6)
+	t.Now = clock.Now
+	return t, clock
+}
+
+func TestRateTracker(t *testing.T) {
+	r := &eventRecorder{}
+	tracker, clock := newTestRateTracker(r)
+
+	tracker.HandleEvent(&Event{Message: "A"})
+	tracker.HandleEvent(&Event{Message: "B", Debug: true})
+	tracker.HandleEvent(&Event{Message: "C", Args: Args{{"error", io.EOF}, {"other", io.EOF}}})
+
+	if n := r.len(); n != 3 {
+		t.Error("events were not forwarded to the handler:", n)
+	}
+
+	if s := tracker.Snapshot(); s != (RateSnapshot{Window: 60 * time.Second, Events: 3, Debug: 1, Errors: 1}) {
+		t.Error("bad snapshot:", s)
+	}
+
+	if rate := tracker.Rate(); rate != 3.0/60 {
+		t.Error("bad rate:", rate)
+	}
+
+	// Add one event in each of the next 5 buckets, the first bucket is still
+	// within the window.
+	for i := 0; i != 5; i++ {
+		clock.add(10 * time.Second)
+		tracker.HandleEvent(&Event{Message: "D"})
+	}
+
+	if n := tracker.Count(time.Minute); n != 8 {
+		t.Error("bad count over the full window:", n)
+	}
+
+	if n := tracker.Count(10 * time.Second); n != 1 {
+		t.Error("bad count over one bucket:", n)
+	}
+
+	if n := tracker.Count(11 * time.Second); n != 2 {
+		t.Error("the window must be rounded up to the bucket duration:", n)
+	}
+
+	if n := tracker.Count(time.Hour); n != 8 {
+		t.Error("the window must be capped to the tracker window:", n)
+	}
+
+	if n := tracker.Count(0); n != 0 {
+		t.Error("bad count over an empty window:", n)
+	}
+
+	// Crossing the next bucket boundary expires the first bucket, even if no
+	// events were received.
+	clock.add(10 * time.Second)
+
+	if s := tracker.Snapshot(); s != (RateSnapshot{Window: 60 * time.Second, Events: 5}) {
+		t.Error("bad snapshot after expiring the first bucket:", s)
+	}
+
+	// The bucket is reused for new events.
+	tracker.HandleEvent(&Event{Message: "E", Debug: true})
+
+	if s := tracker.Snapshot(); s != (RateSnapshot{Window: 60 * time.Second, Events: 6, Debug: 1}) {
+		t.Error("bad snapshot after reusing the first bucket:", s)
+	}
+
+	// Moving past the whole window expires everything.
+	clock.add(2 * time.Minute)
+
+	if n := tracker.Count(time.Minute); n != 0 {
+		t.Error("bad count after the window expired:", n)
+	}
+}
+
+func TestRateTrackerBucketBoundary(t *testing.T) {
+	tracker, clock := newTestRateTracker(Discard)
+
+	// Events just before and just after a bucket boundary land in different
+	// buckets.
+	clock.add(10*time.Second - time.Nanosecond)
+	tracker.HandleEvent(&Event{})
+	clock.add(time.Nanosecond)
+	tracker.HandleEvent(&Event{})
+
+	if n := tracker.Count(10 * time.Second); n != 1 {
+		t.Error("bad count in the current bucket:", n)
+	}
+
+	// The older event expires exactly when its bucket falls out of the window.
+	clock.add(50*time.Second - time.Nanosecond)
+
+	if n := tracker.Count(time.Minute); n != 2 {
+		t.Error("bad count before the first bucket expired:", n)
+	}
+
+	clock.add(time.Nanosecond)
+
+	if n := tracker.Count(time.Minute); n != 1 {
+		t.Error("bad count after the first bucket expired:", n)
+	}
+}
+
+func TestRateTrackerConcurrent(t *testing.T) {
+	tracker, clock := newTestRateTracker(Discard)
+	wg := sync.WaitGroup{}
+
+	for i := 0; i != 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j != 1000; j++ {
+				tracker.HandleEvent(&Event{})
+				tracker.Snapshot()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if n := tracker.Count(time.Minute); n != 4000 {
+		t.Error("bad count:", n)
+	}
+
+	clock.add(time.Minute)
+
+	if n := tracker.Count(time.Minute); n != 0 {
+		t.Error("bad count:", n)
+	}
+}
+
+func TestNewRateTrackerPanics(t *testing.T) {
+	tests := []struct {
+		window  time.Duration
+		buckets int
+	}{
+		{0, 1},
+		{time.Second, 0},
+		{time.Nanosecond, 2},
+	}
+
+	for _, test := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("no panic with window=%s and buckets=%d", test.window, test.buckets)
+				}
+			}()
+			NewRateTracker(Discard, test.window, test.buckets)
+		}()
+	}
+}
+
+func BenchmarkRateTracker(b *testing.B) {
+	tracker := NewRateTracker(Discard, time.Minute, 60)
+	e := &Event{Message: "Hello World!", Args: Args{{"name", "Luke"}}}
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			tracker.HandleEvent(e)
+		}
+	})
+}
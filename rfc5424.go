@@ -0,0 +1,279 @@
+package events
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"unicode/utf8"
+)
+
+// RFC5424Encoder encodes events as syslog messages in the format defined by
+// RFC 5424, one per line.
+//
+// The MSGID of each message is the fingerprint of the format that the event
+// was produced from (see MessageFingerprint) written as 16 hexadecimal digits,
+// so messages produced by the same log call share the same ID. The source and
+// arguments of events are rendered as the parameters of a single structured
+// data element, and the message is encoded as UTF-8 text preceded by a byte
+// order mark.
+//
+// Messages are terminated by a newline, which is the framing expected by most
+// syslog servers receiving messages over TCP.
+type RFC5424Encoder struct {
+	// Facility is the syslog facility of the messages, it defaults to 1
+	// (user-level messages) when zero. Events are encoded with the
+	// informational severity, or the debug severity for debug events.
+	Facility int
+
+	// Hostname, AppName and ProcID are the values of the corresponding header
+	// fields, they default to the host name, the base name of the program and
+	// the process ID. Characters that aren't allowed in the header are
+	// replaced with underscores.
+	Hostname string
+	AppName  string
+	ProcID   string
+
+	// MsgID may be set to use a fixed MSGID instead of the event fingerprint.
+	MsgID string
+
+	// SDID is the SD-ID of the structured data element carrying the event
+	// arguments, it defaults to "events@32473". Names that aren't registered
+	// with IANA must have the form name@<private enterprise number>.
+	SDID string
+
+	// Bytes is the format of byte slices, it defaults to hexadecimal.
+	Bytes BytesFormat
+}
+
+const (
+	rfc5424TimeFormat = "2006-01-02T15:04:05.000000Z07:00"
+	rfc5424SDID       = "events@32473"
+	rfc5424BOM        = "\xef\xbb\xbf"
+)
+
+var rfc5424Defaults struct {
+	once     sync.Once
+	hostname string
+	appName  string
+	procID   string
+}
+
+func loadRFC5424Defaults() {
+	d := &rfc5424Defaults
+	d.hostname, _ = os.Hostname()
+	d.appName = filepath.Base(os.Args[0])
+	d.procID = strconv.Itoa(os.Getpid())
+}
+
+// EncodeEvent satisfies the Encoder interface.
+func (enc RFC5424Encoder) EncodeEvent(dst []byte, e *Event) ([]byte, error) {
+	rfc5424Defaults.once.Do(loadRFC5424Defaults)
+
+	facility := enc.Facility
+	if facility <= 0 || facility > 23 {
+		facility = 1
+	}
+
+	severity := 6
+	if e.Debug {
+		severity = 7
+	}
+
+	hostname := enc.Hostname
+	if len(hostname) == 0 {
+		hostname = rfc5424Defaults.hostname
+	}
+
+	appName := enc.AppName
+	if len(appName) == 0 {
+		appName = rfc5424Defaults.appName
+	}
+
+	procID := enc.ProcID
+	if len(procID) == 0 {
+		procID = rfc5424Defaults.procID
+	}
+
+	sdid := enc.SDID
+	if len(sdid) == 0 {
+		sdid = rfc5424SDID
+	}
+
+	dst = append(dst, '<')
+	dst = strconv.AppendInt(dst, int64(8*facility+severity), 10)
+	dst = append(dst, ">1 "...)
+
+	if e.Time.IsZero() {
+		dst = append(dst, '-')
+	} else {
+		dst = e.Time.AppendFormat(dst, rfc5424TimeFormat)
+	}
+
+	dst = append(dst, ' ')
+	dst = appendRFC5424Header(dst, hostname, 255)
+	dst = append(dst, ' ')
+	dst = appendRFC5424Header(dst, appName, 48)
+	dst = append(dst, ' ')
+	dst = appendRFC5424Header(dst, procID, 128)
+	dst = append(dst, ' ')
+
+	if len(enc.MsgID) != 0 {
+		dst = appendRFC5424Header(dst, enc.MsgID, 32)
+	} else {
+		dst = appendMsgID(dst, formatFingerprint(e))
+	}
+
+	dst = append(dst, ' ')
+
+	if len(e.Source) == 0 && len(e.Args) == 0 {
+		dst = append(dst, '-')
+	} else {
+		dst = append(dst, '[')
+		dst = appendRFC5424Name(dst, sdid, true)
+
+		if len(e.Source) != 0 {
+			dst = append(dst, ` source="`...)
+			dst = appendRFC5424Value(dst, e.Source)
+			dst = append(dst, '"')
+		}
+
+		for _, a := range e.Args {
+			dst = append(dst, ' ')
+			dst = appendRFC5424Name(dst, a.Name, false)
+			dst = append(dst, `="`...)
+			dst = appendRFC5424Arg(dst, a.Value, enc.Bytes)
+			dst = append(dst, '"')
+		}
+
+		dst = append(dst, ']')
+	}
+
+	if len(e.Message) != 0 {
+		dst = append(dst, ' ')
+		dst = append(dst, rfc5424BOM...)
+		dst = appendValidUTF8(dst, e.Message, false)
+	}
+
+	dst = append(dst, '\n')
+	return dst, nil
+}
+
+// appendMsgID appends the fingerprint f as 16 hexadecimal digits, so MSGIDs
+// all have the same length.
+func appendMsgID(dst []byte, f uint64) []byte {
+	const digits = "0123456789abcdef"
+	for i := 60; i >= 0; i -= 4 {
+		dst = append(dst, digits[(f>>uint(i))&0xf])
+	}
+	return dst
+}
+
+// appendRFC5424Header appends s as a header field limited to max characters,
+// characters outside of the printable US-ASCII range are replaced with
+// underscores and empty values are rendered as the NILVALUE.
+func appendRFC5424Header(dst []byte, s string, max int) []byte {
+	if len(s) == 0 {
+		return append(dst, '-')
+	}
+
+	if len(s) > max {
+		s = s[:max]
+	}
+
+	for i := 0; i != len(s); i++ {
+		if c := s[i]; c < 33 || c > 126 {
+			dst = append(dst, '_')
+		} else {
+			dst = append(dst, c)
+		}
+	}
+
+	return dst
+}
+
+// appendRFC5424Name appends s as an SD-NAME, which is limited to 32 printable
+// US-ASCII characters other than '=', ']', '"' and space, the invalid
+// characters are replaced with underscores. The '@' character is only allowed
+// in SD-IDs, where it introduces a private enterprise number.
+func appendRFC5424Name(dst []byte, s string, id bool) []byte {
+	if len(s) == 0 {
+		return append(dst, '_')
+	}
+
+	if len(s) > 32 {
+		s = s[:32]
+	}
+
+	for i := 0; i != len(s); i++ {
+		switch c := s[i]; {
+		case c < 33 || c > 126, c == '=', c == ']', c == '"', c == '@' && !id:
+			dst = append(dst, '_')
+		default:
+			dst = append(dst, c)
+		}
+	}
+
+	return dst
+}
+
+func appendRFC5424Arg(dst []byte, v interface{}, format BytesFormat) []byte {
+	switch x := v.(type) {
+	case nil:
+		return dst
+	case string:
+		return appendRFC5424Value(dst, x)
+	case bool:
+		return strconv.AppendBool(dst, x)
+	case int:
+		return strconv.AppendInt(dst, int64(x), 10)
+	case int64:
+		return strconv.AppendInt(dst, x, 10)
+	case float64:
+		return strconv.AppendFloat(dst, x, 'g', -1, 64)
+	}
+
+	if b, ok := AppendBytesValue(nil, v, format); ok {
+		return append(dst, b...)
+	}
+
+	if s, ok := FormatValue(v); ok {
+		return appendRFC5424Value(dst, s)
+	}
+
+	return appendRFC5424Value(dst, fmt.Sprint(v))
+}
+
+// appendRFC5424Value appends s as a PARAM-VALUE, escaping the '"', '\' and ']'
+// characters with a backslash.
+func appendRFC5424Value(dst []byte, s string) []byte {
+	return appendValidUTF8(dst, s, true)
+}
+
+// appendValidUTF8 appends s, replacing invalid UTF-8 sequences with the
+// replacement character, and escaping PARAM-VALUE characters if escape is
+// true.
+func appendValidUTF8(dst []byte, s string, escape bool) []byte {
+	for i := 0; i != len(s); {
+		c := s[i]
+
+		if c < utf8.RuneSelf {
+			if escape && (c == '"' || c == '\\' || c == ']') {
+				dst = append(dst, '\\')
+			}
+			dst = append(dst, c)
+			i++
+			continue
+		}
+
+		r, n := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && n == 1 {
+			dst = append(dst, string(utf8.RuneError)...)
+		} else {
+			dst = append(dst, s[i:i+n]...)
+		}
+		i += n
+	}
+	return dst
+}
@@ -0,0 +1,155 @@
+package events
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRFC5424Encoder(t *testing.T) {
+	const bom = "\xef\xbb\xbf"
+
+	tests := []struct {
+		name string
+		enc  RFC5424Encoder
+		e    Event
+		out  string
+	}{
+		// The examples of RFC 5424 section 6.5, with the informational
+		// severity and microsecond timestamps.
+		{
+			name: "rfc:example1",
+			enc:  RFC5424Encoder{Facility: 4, Hostname: "mymachine.example.com", AppName: "su", ProcID: "-", MsgID: "ID47"},
+			e: Event{
+				Message: "'su root' failed for lonvick on /dev/pts/8",
+				Time:    time.Date(2003, 10, 11, 22, 14, 15, 3000000, time.UTC),
+			},
+			out: "<38>1 2003-10-11T22:14:15.003000Z mymachine.example.com su - ID47 - " + bom + "'su root' failed for lonvick on /dev/pts/8\n",
+		},
+		{
+			name: "rfc:example2",
+			enc:  RFC5424Encoder{Facility: 20, Hostname: "192.0.2.1", AppName: "myproc", ProcID: "8710", MsgID: "-"},
+			e: Event{
+				Message: "%% It's time to make the do-nuts.",
+				Time:    time.Date(2003, 8, 24, 5, 14, 15, 3000, time.FixedZone("", -7*3600)),
+			},
+			out: "<166>1 2003-08-24T05:14:15.000003-07:00 192.0.2.1 myproc 8710 - - " + bom + "%% It's time to make the do-nuts.\n",
+		},
+		{
+			name: "rfc:example3",
+			enc:  RFC5424Encoder{Facility: 20, Hostname: "mymachine.example.com", AppName: "evntslog", ProcID: "-", MsgID: "ID47", SDID: "exampleSDID@32473"},
+			e: Event{
+				Message: "An application event log entry...",
+				Args:    Args{{"iut", "3"}, {"eventSource", "Application"}, {"eventID", "1011"}},
+				Time:    time.Date(2003, 10, 11, 22, 14, 15, 3000000, time.UTC),
+			},
+			out: `<166>1 2003-10-11T22:14:15.003000Z mymachine.example.com evntslog - ID47 [exampleSDID@32473 iut="3" eventSource="Application" eventID="1011"] ` + bom + "An application event log entry...\n",
+		},
+		{
+			name: "rfc:example3:nomsg",
+			enc:  RFC5424Encoder{Facility: 20, Hostname: "mymachine.example.com", AppName: "evntslog", ProcID: "-", MsgID: "ID47", SDID: "exampleSDID@32473"},
+			e: Event{
+				Args: Args{{"iut", "3"}, {"eventSource", "Application"}, {"eventID", "1011"}},
+				Time: time.Date(2003, 10, 11, 22, 14, 15, 3000000, time.UTC),
+			},
+			out: `<166>1 2003-10-11T22:14:15.003000Z mymachine.example.com evntslog - ID47 [exampleSDID@32473 iut="3" eventSource="Application" eventID="1011"]` + "\n",
+		},
+		{
+			name: "hostile:names",
+			enc:  RFC5424Encoder{Hostname: "host", AppName: "app", ProcID: "1", MsgID: "ID"},
+			e: Event{
+				Message: "names",
+				Args: Args{
+					{"a b", 1},
+					{"a=b", 2},
+					{`a"b]`, 3},
+					{"a@b", 4},
+					{"", 5},
+					{"café", 6},
+					{"abcdefghijklmnopqrstuvwxyz0123456789", 7},
+				},
+			},
+			out: `<14>1 - host app 1 ID [events@32473 a_b="1" a_b="2" a_b_="3" a_b="4" _="5" caf__="6" abcdefghijklmnopqrstuvwxyz012345="7"] ` + bom + "names\n",
+		},
+		{
+			name: "hostile:values",
+			enc:  RFC5424Encoder{Hostname: "host", AppName: "app", ProcID: "1", MsgID: "ID"},
+			e: Event{
+				Message: "values",
+				Source:  `file.go:42`,
+				Args: Args{
+					{"escaped", `"quoted" [bracketed] back\slash`},
+					{"utf8", "héllo\xffwörld"},
+					{"error", errors.New("oops]")},
+					{"nil", nil},
+					{"bytes", []byte{0xde, 0xad}},
+					{"duration", time.Second},
+				},
+			},
+			out: `<14>1 - host app 1 ID [events@32473 source="file.go:42" escaped="\"quoted\" [bracketed\] back\\slash" utf8="héllo�wörld" error="oops\]" nil="" bytes="dead" duration="1s"] ` + bom + "values\n",
+		},
+		{
+			name: "hostile:header",
+			enc:  RFC5424Encoder{Facility: 3, Hostname: "my host", AppName: strings.Repeat("a", 60), ProcID: "1\n", SDID: "bad id"},
+			e: Event{
+				Message: "bad\xffmessage ] \"",
+				Args:    Args{{"a", true}},
+				Debug:   true,
+			},
+			out: "<31>1 - my_host " + strings.Repeat("a", 48) + " 1_ " + "ccbdacdaccd01c81" + ` [bad_id a="true"] ` + bom + "bad\uFFFDmessage ] \"\n",
+		},
+	}
+
+	for _, test := range tests {
+		b, err := test.enc.EncodeEvent(nil, &test.e)
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+
+		if s := string(b); s != test.out {
+			t.Errorf("%s:\n- %q\n+ %q", test.name, test.out, s)
+		}
+	}
+}
+
+func TestRFC5424EncoderMsgID(t *testing.T) {
+	enc := RFC5424Encoder{Hostname: "host", AppName: "app", ProcID: "1"}
+	msgid := func(e *Event) string {
+		b, _ := enc.EncodeEvent(nil, e)
+		return strings.Fields(string(b))[5]
+	}
+
+	a := msgid(&Event{Message: "A %d", Args: Args{{"n", 1}}})
+	b := msgid(&Event{Message: "A %d", Args: Args{{"n", 2}}})
+	c := msgid(&Event{Message: "B"})
+
+	if len(a) != 16 || a != b || a == c {
+		t.Errorf("bad MSGIDs: %q, %q, %q", a, b, c)
+	}
+
+	// Events produced by the same call of a logger share the same MSGID, even
+	// if their messages are different.
+	var ids []string
+	l := NewLogger(HandlerFunc(func(e *Event) { ids = append(ids, msgid(e)) }))
+
+	for _, user := range []string{"luke", "leia", "luke"} {
+		l.Log("%{user}s logged in", user)
+	}
+	l.Log("%{user}s logged out", "luke")
+	l.Log("B")
+
+	if ids[0] != ids[1] || ids[0] != ids[2] || ids[0] == ids[3] || ids[4] != c {
+		t.Errorf("bad MSGIDs: %q", ids)
+	}
+}
+
+func TestRFC5424EncoderDefaults(t *testing.T) {
+	b, _ := RFC5424Encoder{}.EncodeEvent(nil, &Event{Message: "A"})
+	f := strings.Fields(string(b))
+
+	if f[0] != "<14>1" || f[2] == "-" || f[3] == "-" || f[4] == "-" {
+		t.Errorf("bad header: %q", b)
+	}
+}
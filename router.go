@@ -0,0 +1,232 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultRouterIdleTTL is the default time after which the handlers of tenants
+// that received no events are evicted from a Router.
+const DefaultRouterIdleTTL = 10 * time.Minute
+
+// Router is a handler which routes events to per-tenant handlers, where the
+// tenant of an event is the value of one of its arguments.
+//
+// Tenant handlers are constructed the first time an event is routed to them,
+// and cached until they were idle for IdleTTL. Evicted handlers are closed (if
+// they implement io.Closer), and constructed again if the tenant receives new
+// events. Evictions happen while the router handles events, it doesn't use a
+// background goroutine.
+//
+// It is safe to use a router concurrently from multiple goroutines, only one
+// handler is constructed when multiple goroutines route the first events of a
+// tenant.
+type Router struct {
+	// IdleTTL is the time after which idle tenant handlers are evicted, it
+	// defaults to DefaultRouterIdleTTL and may be changed before the router is
+	// used.
+	IdleTTL time.Duration
+
+	// Now returns the current time, it defaults to time.Now and may be set to
+	// a different function before the router is used (in tests for example).
+	Now func() time.Time
+
+	key      string
+	factory  func(string) Handler
+	fallback Handler
+
+	mutex   sync.Mutex
+	tenants map[string]*routerEntry
+	swept   time.Time
+	closed  int32 // set to 1 by Close, accessed atomically
+}
+
+type routerEntry struct {
+	calls   int64 // events being handled, accessed atomically
+	handler Handler
+	once    sync.Once
+	used    time.Time // guarded by the router mutex
+}
+
+// NewRouter returns a Router which routes events to the handlers returned by
+// factory for the values of their argument named key. Values that aren't
+// strings are converted with the fmt package. Events that don't have the
+// argument are passed to fallback, or dropped if fallback is nil.
+func NewRouter(key string, factory func(tenant string) Handler, fallback Handler) *Router {
+	return &Router{
+		IdleTTL:  DefaultRouterIdleTTL,
+		Now:      time.Now,
+		key:      key,
+		factory:  factory,
+		fallback: fallback,
+		tenants:  make(map[string]*routerEntry),
+	}
+}
+
+// HandleEvent satisfies the Handler interface.
+func (r *Router) HandleEvent(e *Event) {
+	r.handle(e, forwardEvent)
+}
+
+// HandleEventSync satisfies the SyncHandler interface.
+func (r *Router) HandleEventSync(ctx context.Context, e *Event) error {
+	return handleSync(ctx, r, func(forward func(Handler, *Event)) { r.handle(e, forward) })
+}
+
+// HandleEventContext satisfies the ContextHandler interface.
+func (r *Router) HandleEventContext(ctx context.Context, e *Event) error {
+	return handleContext(ctx, func(forward func(Handler, *Event)) { r.handle(e, forward) })
+}
+
+func (r *Router) handle(e *Event, forward func(Handler, *Event)) {
+	v, ok := e.Args.Get(r.key)
+
+	if !ok {
+		if r.fallback != nil && atomic.LoadInt32(&r.closed) == 0 {
+			forward(r.fallback, e)
+		}
+		return
+	}
+
+	tenant, ok := v.(string)
+	if !ok {
+		tenant = fmt.Sprint(v)
+	}
+
+	entry, evicted := r.acquire(tenant)
+	closeHandlers(evicted)
+
+	if entry == nil {
+		return
+	}
+
+	defer atomic.AddInt64(&entry.calls, -1)
+	entry.once.Do(func() { entry.handler = r.factory(tenant) })
+
+	if entry.handler != nil {
+		forward(entry.handler, e)
+	}
+}
+
+// acquire returns the entry of tenant with its call counter incremented, and
+// the handlers evicted by the call. The entry is nil if the router is closed.
+func (r *Router) acquire(tenant string) (entry *routerEntry, evicted []Handler) {
+	now := r.Now()
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.closed != 0 {
+		return
+	}
+
+	if now.Sub(r.swept) >= r.IdleTTL {
+		r.swept = now
+
+		for name, old := range r.tenants {
+			if now.Sub(old.used) >= r.IdleTTL && atomic.LoadInt64(&old.calls) == 0 {
+				delete(r.tenants, name)
+				evicted = append(evicted, old.handler)
+			}
+		}
+	}
+
+	if entry = r.tenants[tenant]; entry == nil {
+		entry = &routerEntry{}
+		r.tenants[tenant] = entry
+	}
+
+	entry.used = now
+	atomic.AddInt64(&entry.calls, 1)
+	return
+}
+
+// Unwrap returns the tenant handlers and the fallback handler.
+func (r *Router) Unwrap() []Handler {
+	return r.handlers(false)
+}
+
+// Flush flushes the tenant handlers and the fallback handler, returning the
+// first error that occurred.
+func (r *Router) Flush() (err error) {
+	for _, h := range r.handlers(false) {
+		if e := flushHandler(h); err == nil {
+			err = e
+		}
+	}
+	return
+}
+
+// Close closes the tenant handlers and the fallback handler, returning the
+// first error that occurred. Events received after Close are dropped.
+func (r *Router) Close() error {
+	return closeHandlers(r.handlers(true))
+}
+
+// handlers returns the tenant handlers and the fallback handler. When close is
+// true the router is marked closed, and the function waits for the events that
+// were being passed to the tenant handlers.
+func (r *Router) handlers(close bool) []Handler {
+	r.mutex.Lock()
+
+	if r.closed != 0 {
+		r.mutex.Unlock()
+		return nil
+	}
+
+	names := make([]string, 0, len(r.tenants))
+	entries := make([]*routerEntry, 0, len(r.tenants))
+
+	for tenant, entry := range r.tenants {
+		names = append(names, tenant)
+		entries = append(entries, entry)
+	}
+
+	if close {
+		atomic.StoreInt32(&r.closed, 1)
+		r.tenants = nil
+	}
+
+	r.mutex.Unlock()
+
+	list := make([]Handler, 0, len(entries)+1)
+
+	for i, entry := range entries {
+		tenant := names[i]
+
+		// Wait for the handler to be constructed if another goroutine
+		// is doing it. The factory is called without holding the mutex
+		// so it may be slow, or use the router.
+		entry.once.Do(func() { entry.handler = r.factory(tenant) })
+
+		// No calls can start after the router was closed, the ones in
+		// flight complete before the handler is closed.
+		for close && atomic.LoadInt64(&entry.calls) != 0 {
+			time.Sleep(time.Millisecond)
+		}
+
+		if entry.handler != nil {
+			list = append(list, entry.handler)
+		}
+	}
+
+	if r.fallback != nil {
+		list = append(list, r.fallback)
+	}
+
+	return list
+}
+
+func closeHandlers(list []Handler) (err error) {
+	for _, h := range list {
+		if h != nil {
+			if e := closeHandler(h); err == nil {
+				err = e
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,211 @@
+package events
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type routerFactory struct {
+	mutex    sync.Mutex
+	handlers map[string][]*drainHandler
+}
+
+func (f *routerFactory) new(tenant string) Handler {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.handlers == nil {
+		f.handlers = make(map[string][]*drainHandler)
+	}
+
+	h := &drainHandler{}
+	f.handlers[tenant] = append(f.handlers[tenant], h)
+	return h
+}
+
+func (f *routerFactory) get(tenant string) []*drainHandler {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.handlers[tenant]
+}
+
+func newTestRouter(fallback Handler) (*Router, *routerFactory, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)}
+	f := &routerFactory{}
+	r := NewRouter("tenant", f.new, fallback)
+	r.IdleTTL = time.Minute
+	r.Now = clock.Now
+	return r, f, clock
+}
+
+func TestRouter(t *testing.T) {
+	fallback := &eventRecorder{}
+	r, f, _ := newTestRouter(fallback)
+
+	r.HandleEvent(&Event{Message: "A", Args: Args{{"tenant", "acme"}}})
+	r.HandleEvent(&Event{Message: "B", Args: Args{{"tenant", "acme"}}})
+	r.HandleEvent(&Event{Message: "C", Args: Args{{"tenant", "globex"}}})
+	r.HandleEvent(&Event{Message: "D", Args: Args{{"tenant", 42}}})
+	r.HandleEvent(&Event{Message: "E", Args: Args{{"user", "luke"}}})
+
+	for tenant, count := range map[string]int64{"acme": 2, "globex": 1, "42": 1} {
+		if h := f.get(tenant); len(h) != 1 || h[0].count != count {
+			t.Errorf("bad handlers for %s: %+v", tenant, h)
+		}
+	}
+
+	if e := fallback.wait(t); e.Message != "E" {
+		t.Error("bad event passed to the fallback handler:", e)
+	}
+
+	if err := r.Flush(); err != nil {
+		t.Error(err)
+	}
+
+	if err := r.Close(); err != nil {
+		t.Error(err)
+	}
+
+	for _, tenant := range []string{"acme", "globex", "42"} {
+		if h := f.get(tenant)[0]; h.flushed == 0 || h.closed == 0 {
+			t.Errorf("the handler of %s was not flushed and closed", tenant)
+		}
+	}
+
+	r.HandleEvent(&Event{Message: "F", Args: Args{{"tenant", "acme"}}})
+	r.HandleEvent(&Event{Message: "G"})
+
+	if len(f.get("acme")) != 1 || fallback.len() != 0 {
+		t.Error("events were handled after Close")
+	}
+}
+
+func TestRouterEviction(t *testing.T) {
+	r, f, clock := newTestRouter(nil)
+
+	r.HandleEvent(&Event{Message: "A", Args: Args{{"tenant", "acme"}}})
+	r.HandleEvent(&Event{Message: "B", Args: Args{{"tenant", "globex"}}})
+
+	clock.add(30 * time.Second)
+	r.HandleEvent(&Event{Message: "C", Args: Args{{"tenant", "globex"}}})
+
+	clock.add(45 * time.Second)
+	r.HandleEvent(&Event{Message: "D", Args: Args{{"tenant", "globex"}}})
+
+	// acme was idle for more than a minute, its handler was closed.
+	if h := f.get("acme"); len(h) != 1 || atomic.LoadInt32(&h[0].closed) == 0 {
+		t.Error("the idle handler was not evicted")
+	}
+
+	if h := f.get("globex"); len(h) != 1 || h[0].closed != 0 || h[0].count != 3 {
+		t.Errorf("the active handler was evicted: %+v", h)
+	}
+
+	r.HandleEvent(&Event{Message: "E", Args: Args{{"tenant", "acme"}}})
+
+	if h := f.get("acme"); len(h) != 2 || h[1].count != 1 || h[1].closed != 0 {
+		t.Errorf("the evicted handler was not constructed again: %+v", h)
+	}
+}
+
+func TestRouterConcurrentFirstUse(t *testing.T) {
+	r, f, _ := newTestRouter(nil)
+
+	start := make(chan struct{})
+	wg := sync.WaitGroup{}
+
+	for i := 0; i != 32; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			r.HandleEvent(&Event{Args: Args{{"tenant", "acme"}}})
+		}()
+	}
+
+	close(start)
+	wg.Wait()
+
+	if h := f.get("acme"); len(h) != 1 || atomic.LoadInt64(&h[0].count) != 32 {
+		t.Errorf("bad handlers: %+v", h)
+	}
+}
+
+// closeRecorder records the number of events that were handled when it was
+// closed.
+type closeRecorder struct {
+	*stallHandler
+	handled int
+}
+
+func (h *closeRecorder) Close() error {
+	h.handled = len(h.messages())
+	return nil
+}
+
+func TestRouterCloseInFlight(t *testing.T) {
+	h := &closeRecorder{stallHandler: newStallHandler()}
+	r := NewRouter("tenant", func(string) Handler { return h }, nil)
+
+	go r.HandleEvent(&Event{Message: "stall", Args: Args{{"tenant", "acme"}}})
+	<-h.started
+
+	closed := make(chan error, 1)
+	go func() { closed <- r.Close() }()
+
+	select {
+	case <-closed:
+		t.Fatal("the router was closed while an event was being handled")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(h.release)
+
+	if err := <-closed; err != nil {
+		t.Error(err)
+	}
+
+	if h.handled != 1 {
+		t.Error("the handler was closed before the event was handled")
+	}
+}
+
+func TestRouterSlowFactory(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	r := NewRouter("tenant", func(tenant string) Handler {
+		if tenant == "slow" {
+			close(started)
+			<-release
+		}
+		return &drainHandler{}
+	}, nil)
+	defer r.Close()
+
+	go r.HandleEvent(&Event{Args: Args{{"tenant", "slow"}}})
+	<-started
+
+	// Flush waits for the handler of the slow tenant to be constructed, the
+	// router keeps routing the events of the other tenants meanwhile.
+	flushed := make(chan error)
+	go func() { flushed <- r.Flush() }()
+	time.Sleep(10 * time.Millisecond)
+
+	done := make(chan struct{})
+	go func() {
+		r.HandleEvent(&Event{Args: Args{{"tenant", "acme"}}})
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("the router was blocked by the construction of a handler")
+	}
+
+	close(release)
+	<-flushed
+}
@@ -0,0 +1,446 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Kind values represent the types of values that event arguments may have.
+type Kind int
+
+const (
+	// KindAny matches values of any type.
+	KindAny Kind = iota
+
+	// KindString matches string values.
+	KindString
+
+	// KindInt matches signed and unsigned integer values.
+	KindInt
+
+	// KindFloat matches floating point values.
+	KindFloat
+
+	// KindBool matches boolean values.
+	KindBool
+
+	// KindTime matches time.Time values.
+	KindTime
+
+	// KindDuration matches time.Duration values.
+	KindDuration
+
+	// KindError matches values implementing the error interface.
+	KindError
+
+	// KindBytes matches byte slices.
+	KindBytes
+
+	// KindObject matches maps and structs.
+	KindObject
+
+	// KindArray matches slices and arrays, except byte slices.
+	KindArray
+
+	// KindNull matches nil values, including nil pointers.
+	KindNull
+)
+
+var kindNames = [...]string{
+	KindAny:      "any",
+	KindString:   "string",
+	KindInt:      "int",
+	KindFloat:    "float",
+	KindBool:     "bool",
+	KindTime:     "time",
+	KindDuration: "duration",
+	KindError:    "error",
+	KindBytes:    "bytes",
+	KindObject:   "object",
+	KindArray:    "array",
+	KindNull:     "null",
+}
+
+// String satisfies the fmt.Stringer interface.
+func (k Kind) String() string {
+	if k >= 0 && int(k) < len(kindNames) {
+		return kindNames[k]
+	}
+	return fmt.Sprintf("Kind(%d)", int(k))
+}
+
+// Match returns true if v is a value of kind k, which is the case when KindOf
+// returns k. All values match KindAny, and all errors match KindError.
+func (k Kind) Match(v interface{}) bool {
+	switch k {
+	case KindAny:
+		return true
+	case KindError:
+		_, ok := v.(error)
+		return ok
+	}
+	return KindOf(v) == k
+}
+
+// KindOf returns the kind of v, which is the most specific kind matching the
+// value. Pointers are classified by the kind of the value they point to, and
+// values of named types by their underlying type. KindAny is returned for the
+// values that don't fit any kind, like channels or functions.
+//
+// Numbers decoded as json.Number are classified as integers if they have no
+// fractional part.
+func KindOf(v interface{}) Kind {
+	switch x := v.(type) {
+	case nil:
+		return KindNull
+	case string:
+		return KindString
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, uintptr:
+		return KindInt
+	case float32, float64:
+		return KindFloat
+	case bool:
+		return KindBool
+	case time.Time:
+		return KindTime
+	case time.Duration:
+		return KindDuration
+	case []byte, BytesValue:
+		return KindBytes
+	case KeepValue:
+		return KindOf(x.Value)
+	case json.Number:
+		if _, err := x.Int64(); err == nil {
+			return KindInt
+		}
+		return KindFloat
+	case error:
+		return KindError
+	}
+
+	switch r := reflect.ValueOf(v); r.Kind() {
+	case reflect.Ptr:
+		if r.IsNil() {
+			return KindNull
+		}
+		return KindOf(r.Elem().Interface())
+	case reflect.String:
+		return KindString
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return KindInt
+	case reflect.Float32, reflect.Float64:
+		return KindFloat
+	case reflect.Bool:
+		return KindBool
+	case reflect.Slice:
+		if r.Type().Elem().Kind() == reflect.Uint8 {
+			return KindBytes
+		}
+		return KindArray
+	case reflect.Array:
+		return KindArray
+	case reflect.Map, reflect.Struct:
+		return KindObject
+	default:
+		return KindAny
+	}
+}
+
+// Presence values define whether schema fields must be present in events.
+type Presence int
+
+const (
+	// Optional fields may be omitted from events.
+	Optional Presence = iota
+
+	// Required fields must be present in events.
+	Required
+)
+
+// Field describes an argument of events defined by a schema.
+type Field struct {
+	Name     string
+	Kind     Kind
+	Presence Presence
+}
+
+// Schema describes a kind of events that a program produces.
+//
+// Schemas are registered in a global registry with Define or DefineSchema, the
+// registry is safe to use concurrently from multiple goroutines.
+type Schema struct {
+	// Name uniquely identifies the schema, events built by the schema have
+	// their Source set to this name.
+	Name string
+
+	// Message is the template used to generate the message of events built
+	// by the schema. It uses the same format as the Logger, where the verbs
+	// must have argument names, for example "user %{user_id}s logged in".
+	// When empty, the message is the schema name.
+	Message string
+
+	// Fields is the list of arguments that the events may have, events with
+	// arguments that aren't listed are invalid.
+	Fields []Field
+}
+
+// SchemaError is returned when event arguments don't match their schema, it
+// lists all the violations that were found.
+type SchemaError struct {
+	Schema     string
+	Violations []string
+}
+
+// Error satisfies the error interface.
+func (e *SchemaError) Error() string {
+	return "events: " + e.Schema + ": " + strings.Join(e.Violations, ", ")
+}
+
+// DefineSchema registers a schema with the given name and fields, and returns a
+// function which builds events for it. It is a shortcut for calling Define on
+// a Schema value without message template.
+func DefineSchema(name string, fields ...Field) func(args ...Arg) (*Event, error) {
+	return Schema{Name: name, Fields: fields}.Define()
+}
+
+// Define registers s and returns a function which builds events for it.
+//
+// The function returned by Define validates the arguments it receives against
+// the schema, returning a *SchemaError if they don't match. Otherwise it
+// returns an event with its message generated from the schema template, its
+// source set to the schema name, and its time set to the current time.
+//
+// The method panics if a schema with the same name was already registered.
+func (s Schema) Define() func(args ...Arg) (*Event, error) {
+	if len(s.Name) == 0 {
+		panic("events.Schema.Define: the schema has no name")
+	}
+
+	c := &compiledSchema{
+		Schema: Schema{
+			Name:    s.Name,
+			Message: s.Message,
+			Fields:  append([]Field(nil), s.Fields...),
+		},
+		fields: make(map[string]Field, len(s.Fields)),
+	}
+
+	for _, f := range c.Fields {
+		c.fields[f.Name] = f
+	}
+
+	if len(s.Message) != 0 {
+		var format []byte
+		format, c.names = appendFormat(nil, nil, s.Message, nil)
+		c.format = string(format)
+	}
+
+	schemas.mutex.Lock()
+	defer schemas.mutex.Unlock()
+
+	if _, exists := schemas.registry[s.Name]; exists {
+		panic("events.Schema.Define: a schema named " + s.Name + " already exists")
+	}
+
+	if schemas.registry == nil {
+		schemas.registry = make(map[string]*compiledSchema)
+	}
+
+	schemas.registry[s.Name] = c
+	return c.new
+}
+
+// LookupSchema returns the registered schema with the given name.
+func LookupSchema(name string) (s Schema, ok bool) {
+	var c *compiledSchema
+
+	if c, ok = lookupSchema(name); ok {
+		s = c.Schema
+		s.Fields = append([]Field(nil), s.Fields...)
+	}
+
+	return
+}
+
+// Validate checks that args match the schema, returning a *SchemaError if they
+// don't.
+func (s Schema) Validate(args Args) error {
+	fields := make(map[string]Field, len(s.Fields))
+
+	for _, f := range s.Fields {
+		fields[f.Name] = f
+	}
+
+	return validateSchema(s.Name, s.Fields, fields, args)
+}
+
+// ValidateMode values define how ValidateHandler deals with invalid events.
+type ValidateMode int
+
+const (
+	// ValidateStrict drops invalid events and reports them with a new event
+	// carrying a "schema_violation" argument.
+	ValidateStrict ValidateMode = iota
+
+	// ValidateLenient passes invalid events with an additional
+	// "schema_violation" argument describing the problem.
+	ValidateLenient
+)
+
+// ValidateHandler returns a handler which validates the events it receives
+// against the registered schema named after their source, before passing them
+// to h. Events with a source that isn't the name of a schema are invalid, so
+// the handler is intended to be used on the events built with schemas.
+func ValidateHandler(h Handler, mode ValidateMode) Handler {
+	return &validateHandler{
+		handler: h,
+		mode:    mode,
+	}
+}
+
+type validateHandler struct {
+	handler Handler
+	mode    ValidateMode
+}
+
+func (v *validateHandler) HandleEvent(e *Event) {
+	v.handle(e, forwardEvent)
+}
+
+func (v *validateHandler) HandleEventSync(ctx context.Context, e *Event) error {
+	return handleSync(ctx, v, func(forward func(Handler, *Event)) { v.handle(e, forward) })
+}
+
+func (v *validateHandler) HandleEventContext(ctx context.Context, e *Event) error {
+	return handleContext(ctx, func(forward func(Handler, *Event)) { v.handle(e, forward) })
+}
+
+func (v *validateHandler) handle(e *Event, forward func(Handler, *Event)) {
+	var err error
+
+	if IsDiagnostic(e) {
+		forward(v.handler, e)
+		return
+	}
+
+	if c, ok := lookupSchema(e.Source); ok {
+		err = c.validate(e.Args)
+	} else {
+		err = &SchemaError{Schema: e.Source, Violations: []string{"unknown schema"}}
+	}
+
+	if err == nil {
+		forward(v.handler, e)
+		return
+	}
+
+	if v.mode == ValidateLenient {
+		c := *e
+		c.Args = append(append(make(Args, 0, len(e.Args)+1), e.Args...), Arg{"schema_violation", err.Error()})
+		forward(v.handler, &c)
+		return
+	}
+
+	forward(v.handler, Diagnostic("event dropped: "+err.Error(),
+		Arg{"schema", e.Source},
+		Arg{"message", e.Message},
+		Arg{"schema_violation", err.Error()},
+	))
+}
+
+func (v *validateHandler) Unwrap() Handler {
+	return v.handler
+}
+
+func (v *validateHandler) Flush() error {
+	return flushHandler(v.handler)
+}
+
+func (v *validateHandler) Close() error {
+	return closeHandler(v.handler)
+}
+
+var schemas struct {
+	mutex    sync.RWMutex
+	registry map[string]*compiledSchema
+}
+
+type compiledSchema struct {
+	Schema
+	fields map[string]Field
+	format string // message template stripped of argument names
+	names  Args   // argument names referenced by the template
+}
+
+func lookupSchema(name string) (c *compiledSchema, ok bool) {
+	schemas.mutex.RLock()
+	c, ok = schemas.registry[name]
+	schemas.mutex.RUnlock()
+	return
+}
+
+func (c *compiledSchema) new(args ...Arg) (*Event, error) {
+	if err := c.validate(args); err != nil {
+		return nil, err
+	}
+
+	e := &Event{
+		Message: c.Name,
+		Source:  c.Name,
+		Args:    append(Args(nil), args...),
+		Time:    time.Now(),
+	}
+
+	if len(c.Message) != 0 {
+		values := make([]interface{}, len(c.names))
+
+		for i, a := range c.names {
+			if v, ok := e.Args.Get(a.Name); ok {
+				values[i] = v
+			} else {
+				values[i] = missing
+			}
+		}
+
+		e.Message = fmt.Sprintf(c.format, values...)
+	}
+
+	return e, nil
+}
+
+func (c *compiledSchema) validate(args Args) error {
+	return validateSchema(c.Name, c.Fields, c.fields, args)
+}
+
+func validateSchema(name string, fieldList []Field, fields map[string]Field, args Args) error {
+	var violations []string
+
+	for _, f := range fieldList {
+		if _, ok := args.Get(f.Name); !ok && f.Presence == Required {
+			violations = append(violations, "missing required field "+f.Name)
+		}
+	}
+
+	for _, a := range args {
+		f, ok := fields[a.Name]
+
+		switch {
+		case !ok:
+			violations = append(violations, "unknown field "+a.Name)
+		case !f.Kind.Match(a.Value):
+			violations = append(violations, fmt.Sprintf("field %s must be of kind %s but found %T", a.Name, f.Kind, a.Value))
+		}
+	}
+
+	if len(violations) != 0 {
+		return &SchemaError{Schema: name, Violations: violations}
+	}
+
+	return nil
+}
@@ -0,0 +1,389 @@
+package events
+
+import (
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var (
+	newUserLogin = Schema{
+		Name:    "test.user.login",
+		Message: "user %{user_id}s logged in from %{address}s",
+		Fields: []Field{
+			{"user_id", KindString, Required},
+			{"attempts", KindInt, Optional},
+			{"address", KindString, Optional},
+		},
+	}.Define()
+
+	newPaymentFailed = DefineSchema("test.payment.failed",
+		Field{"amount", KindFloat, Required},
+		Field{"error", KindError, Required},
+		Field{"delay", KindDuration, Optional},
+	)
+)
+
+func TestSchema(t *testing.T) {
+	e, err := newUserLogin(Arg{"user_id", "luke"}, Arg{"address", "127.0.0.1"}, Arg{"attempts", 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &Event{
+		Message: "user luke logged in from 127.0.0.1",
+		Source:  "test.user.login",
+		Args:    Args{{"user_id", "luke"}, {"address", "127.0.0.1"}, {"attempts", 2}},
+		Time:    time.Now(),
+	}
+
+	if diff := (DiffOptions{TimeTolerance: time.Second}).Diff(want, e); len(diff) != 0 {
+		t.Error(diff)
+	}
+
+	e, err = newUserLogin(Arg{"user_id", "luke"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if e.Message != "user luke logged in from MISSING" {
+		t.Error("bad message for missing optional field:", e.Message)
+	}
+
+	e, err = newPaymentFailed(Arg{"amount", 4.2}, Arg{"error", io.EOF}, Arg{"delay", time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if e.Message != "test.payment.failed" || e.Source != "test.payment.failed" {
+		t.Error("bad event:", e)
+	}
+}
+
+func TestSchemaErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		new        func(...Arg) (*Event, error)
+		args       Args
+		violations []string
+	}{
+		{
+			name:       "missing required field",
+			new:        newUserLogin,
+			args:       Args{{"attempts", 1}},
+			violations: []string{"missing required field user_id"},
+		},
+		{
+			name:       "wrong kind",
+			new:        newUserLogin,
+			args:       Args{{"user_id", 42}},
+			violations: []string{"field user_id must be of kind string but found int"},
+		},
+		{
+			name:       "unknown field",
+			new:        newUserLogin,
+			args:       Args{{"user_id", "luke"}, {"userid", "luke"}},
+			violations: []string{"unknown field userid"},
+		},
+		{
+			name: "multiple violations",
+			new:  newPaymentFailed,
+			args: Args{{"amount", 42}, {"delay", 1.5}},
+			violations: []string{
+				"missing required field error",
+				"field amount must be of kind float but found int",
+				"field delay must be of kind duration but found float64",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			e, err := test.new(test.args...)
+
+			if e != nil {
+				t.Error("unexpected event:", e)
+			}
+
+			serr, ok := err.(*SchemaError)
+			if !ok {
+				t.Fatalf("bad error: %#v", err)
+			}
+
+			if strings.Join(serr.Violations, "\n") != strings.Join(test.violations, "\n") {
+				t.Errorf("bad violations:\n%q\n%q", serr.Violations, test.violations)
+			}
+		})
+	}
+}
+
+var schemaRegistryRuns int32
+
+func TestSchemaRegistry(t *testing.T) {
+	s, ok := LookupSchema("test.user.login")
+	if !ok {
+		t.Fatal("schema not found")
+	}
+
+	if s.Name != "test.user.login" || len(s.Fields) != 3 {
+		t.Error("bad schema:", s)
+	}
+
+	s.Fields[0].Name = "modified"
+
+	if s, _ := LookupSchema("test.user.login"); s.Fields[0].Name != "user_id" {
+		t.Error("the registered schema was modified")
+	}
+
+	if _, ok := LookupSchema("test.unknown"); ok {
+		t.Error("unknown schema found")
+	}
+
+	if s, _ := LookupSchema("test.user.login"); s.Validate(Args{{"user_id", "luke"}}) != nil {
+		t.Error("valid arguments reported as invalid")
+	}
+
+	// The copy was modified to require another field.
+	if err := s.Validate(Args{{"user_id", "luke"}}); err == nil {
+		t.Error("the modified copy of the schema accepted invalid arguments")
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("defining a schema twice must panic")
+			}
+		}()
+		DefineSchema("test.user.login")
+	}()
+
+	// The registry is global, the schemas get different names each time the
+	// test runs.
+	run := strconv.Itoa(int(atomic.AddInt32(&schemaRegistryRuns, 1)))
+	wg := sync.WaitGroup{}
+
+	for i := 0; i != 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			DefineSchema("test.concurrent." + run + "." + string(rune('a'+i)))
+			LookupSchema("test.user.login")
+			newUserLogin(Arg{"user_id", "luke"})
+		}(i)
+	}
+
+	wg.Wait()
+}
+
+func TestValidateHandler(t *testing.T) {
+	valid, _ := newUserLogin(Arg{"user_id", "luke"})
+	invalid := &Event{Message: "user logged in", Source: "test.user.login", Args: Args{{"userid", "luke"}}}
+	unknown := &Event{Message: "hello", Source: "test.unknown"}
+
+	t.Run("strict", func(t *testing.T) {
+		r := &eventRecorder{}
+		h := ValidateHandler(r, ValidateStrict)
+
+		h.HandleEvent(valid)
+		h.HandleEvent(invalid)
+		h.HandleEvent(unknown)
+
+		if e := r.wait(t); e.Message != valid.Message {
+			t.Error("valid event not forwarded:", e)
+		}
+
+		for _, test := range []struct {
+			source    string
+			violation string
+		}{
+			{"test.user.login", "events: test.user.login: missing required field user_id, unknown field userid"},
+			{"test.unknown", "events: test.unknown: unknown schema"},
+		} {
+			e := r.wait(t)
+
+			if v, _ := e.Args.Get("schema_violation"); v != test.violation {
+				t.Errorf("bad violation: %q", v)
+			}
+
+			if v, _ := e.Args.Get("schema"); v != test.source {
+				t.Errorf("bad schema: %q", v)
+			}
+
+			if !strings.HasPrefix(e.Message, "event dropped: ") {
+				t.Errorf("bad message: %q", e.Message)
+			}
+		}
+	})
+
+	t.Run("lenient", func(t *testing.T) {
+		r := &eventRecorder{}
+		h := ValidateHandler(r, ValidateLenient)
+
+		h.HandleEvent(valid)
+		h.HandleEvent(invalid)
+		h.HandleEvent(unknown)
+
+		if e := r.wait(t); len(e.Args) != 1 {
+			t.Error("valid event was annotated:", e)
+		}
+
+		want := &Event{
+			Message: "user logged in",
+			Source:  "test.user.login",
+			Args: Args{
+				{"userid", "luke"},
+				{"schema_violation", "events: test.user.login: missing required field user_id, unknown field userid"},
+			},
+		}
+
+		if diff := Diff(want, r.wait(t)); len(diff) != 0 {
+			t.Error(diff)
+		}
+
+		if v, _ := r.wait(t).Args.Get("schema_violation"); v != "events: test.unknown: unknown schema" {
+			t.Errorf("bad violation: %q", v)
+		}
+
+		if len(invalid.Args) != 1 {
+			t.Error("the original event was modified")
+		}
+	})
+
+	t.Run("wrapper", func(t *testing.T) {
+		d := &drainHandler{}
+		h := ValidateHandler(d, ValidateStrict)
+
+		if u, ok := h.(interface{ Unwrap() Handler }); !ok || u.Unwrap() != d {
+			t.Error("the handler doesn't unwrap to the handler it wraps")
+		}
+
+		flushHandler(h)
+		closeHandler(h)
+
+		if d.flushed == 0 || d.closed == 0 {
+			t.Error("the wrapped handler was not flushed and closed")
+		}
+	})
+}
+
+func TestKindMatch(t *testing.T) {
+	tests := []struct {
+		kind  Kind
+		value interface{}
+		match bool
+	}{
+		{KindAny, nil, true},
+		{KindString, "", true},
+		{KindString, []byte(""), false},
+		{KindInt, int8(1), true},
+		{KindInt, uint64(1), true},
+		{KindInt, 1.0, false},
+		{KindInt, time.Second, false},
+		{KindFloat, float32(1), true},
+		{KindBool, false, true},
+		{KindTime, time.Time{}, true},
+		{KindDuration, time.Second, true},
+		{KindError, io.EOF, true},
+		{KindError, "EOF", false},
+		{KindString, nil, false},
+		{KindBytes, []byte(""), true},
+		{KindArray, []int{}, true},
+		{KindObject, map[string]int{}, true},
+		{KindNull, nil, true},
+		{KindNull, 0, false},
+		{KindInt, time.March, true},
+		{KindInt, json.Number("1"), true},
+		{KindFloat, json.Number("1.5"), true},
+		{KindInt, new(int), true},
+		{KindNull, (*int)(nil), true},
+	}
+
+	for _, test := range tests {
+		if match := test.kind.Match(test.value); match != test.match {
+			t.Errorf("%s.Match(%#v): %t", test.kind, test.value, match)
+		}
+	}
+}
+
+func TestKindOf(t *testing.T) {
+	type status int
+	type point struct{ X, Y int }
+	type blob []byte
+
+	var (
+		i    = 42
+		now  = time.Now()
+		nilp *int
+	)
+
+	tests := []struct {
+		value interface{}
+		kind  Kind
+	}{
+		{nil, KindNull},
+		{"", KindString},
+		{int(1), KindInt},
+		{int8(1), KindInt},
+		{int16(1), KindInt},
+		{int32(1), KindInt},
+		{int64(1), KindInt},
+		{uint(1), KindInt},
+		{uint8(1), KindInt},
+		{uint16(1), KindInt},
+		{uint32(1), KindInt},
+		{uint64(1), KindInt},
+		{uintptr(1), KindInt},
+		{float32(1), KindFloat},
+		{float64(1), KindFloat},
+		{json.Number("1"), KindInt},
+		{json.Number("1.5"), KindFloat},
+		{true, KindBool},
+		{now, KindTime},
+		{time.Second, KindDuration},
+		{io.EOF, KindError},
+		{[]byte("A"), KindBytes},
+		{blob("A"), KindBytes},
+		{[]int{1}, KindArray},
+		{[]int(nil), KindArray},
+		{[2]int{}, KindArray},
+		{[]interface{}{}, KindArray},
+		{map[string]interface{}{}, KindObject},
+		{point{}, KindObject},
+		{status(1), KindInt},
+		{&i, KindInt},
+		{&now, KindTime},
+		{&point{}, KindObject},
+		{nilp, KindNull},
+		{error(nil), KindNull},
+		{make(chan int), KindAny},
+		{func() {}, KindAny},
+		{complex(1, 2), KindAny},
+	}
+
+	for _, test := range tests {
+		if kind := KindOf(test.value); kind != test.kind {
+			t.Errorf("KindOf(%#v): %s != %s", test.value, kind, test.kind)
+		}
+
+		// Match agrees with KindOf, except for KindAny which matches all
+		// values.
+		for k := KindString; k <= KindNull; k++ {
+			if match := k.Match(test.value); match != (k == test.kind) {
+				t.Errorf("%s.Match(%#v): %t", k, test.value, match)
+			}
+		}
+	}
+}
+
+func TestArgsKinds(t *testing.T) {
+	kinds := Args{{"a", 1}, {"b", "B"}, {"a", 1.5}, {"c", nil}}.Kinds()
+
+	if len(kinds) != 3 || kinds["a"] != KindFloat || kinds["b"] != KindString || kinds["c"] != KindNull {
+		t.Error("bad kinds:", kinds)
+	}
+}
@@ -0,0 +1,117 @@
+package events
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// PushScope records args in a scope attached to the calling goroutine, loggers
+// then add those arguments to every event they produce on that goroutine,
+// after their own arguments and before the ones passed to the log call.
+//
+// The function is intended for legacy code paths that cannot carry a context,
+// new code should prefer arguments passed explicitly or through Logger.With.
+// Scopes are not inherited by the goroutines started while they are active.
+//
+// Scopes nest, the returned function pops the scope and any scope that was
+// pushed after it and is still active. It should be called on the goroutine
+// that pushed the scope, typically with a defer statement, calling it more
+// than once has no effect:
+//
+//	defer events.PushScope(events.Arg{"request_id", id})()
+//
+// Goroutines are identified by the ID the runtime reports in their stack
+// trace, which is only looked up while at least one scope is active in the
+// program, loggers don't pay for the feature otherwise.
+func PushScope(args ...Arg) (pop func()) {
+	gid := goroutineID()
+	seq := atomic.AddUint64(&scopeSeq, 1)
+
+	scopeMutex.Lock()
+	scopes[gid] = append(scopes[gid], scopeFrame{
+		seq:  seq,
+		args: append(Args(nil), args...),
+	})
+	scopeMutex.Unlock()
+	atomic.AddInt64(&scopeCount, 1)
+
+	return func() { popScope(gid, seq) }
+}
+
+type scopeFrame struct {
+	seq  uint64
+	args Args
+}
+
+var (
+	scopeSeq   uint64 // sequence of scope identifiers, accessed atomically
+	scopeCount int64  // number of active scopes, accessed atomically
+	scopeMutex sync.RWMutex
+	scopes     = make(map[uint64][]scopeFrame)
+)
+
+func popScope(gid uint64, seq uint64) {
+	scopeMutex.Lock()
+	frames := scopes[gid]
+	n := 0
+
+	for i := range frames {
+		if frames[i].seq == seq {
+			// Clear the popped frames so the arguments they hold can be
+			// garbage collected.
+			for j := i; j != len(frames); j++ {
+				frames[j] = scopeFrame{}
+			}
+			n, frames = len(frames)-i, frames[:i]
+			break
+		}
+	}
+
+	switch {
+	case n == 0:
+	case len(frames) == 0:
+		delete(scopes, gid)
+	default:
+		scopes[gid] = frames
+	}
+
+	scopeMutex.Unlock()
+	atomic.AddInt64(&scopeCount, -int64(n))
+}
+
+// appendScopeArgs appends the arguments of the scopes active on the calling
+// goroutine to args.
+func appendScopeArgs(args Args) Args {
+	if atomic.LoadInt64(&scopeCount) == 0 {
+		return args
+	}
+
+	gid := goroutineID()
+	scopeMutex.RLock()
+
+	for _, f := range scopes[gid] {
+		args = append(args, f.args...)
+	}
+
+	scopeMutex.RUnlock()
+	return args
+}
+
+// goroutineID returns the ID of the calling goroutine, parsed from the first
+// line of its stack trace which has the form "goroutine 42 [running]:".
+func goroutineID() uint64 {
+	var buf [64]byte
+	b := buf[:runtime.Stack(buf[:], false)]
+	id := uint64(0)
+
+	for i := len("goroutine "); i < len(b); i++ {
+		c := b[i]
+		if c < '0' || c > '9' {
+			break
+		}
+		id = 10*id + uint64(c-'0')
+	}
+
+	return id
+}
@@ -0,0 +1,138 @@
+package events
+
+import (
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func assertNoScopes(t *testing.T) {
+	scopeMutex.RLock()
+	n := len(scopes)
+	scopeMutex.RUnlock()
+
+	if n != 0 || atomic.LoadInt64(&scopeCount) != 0 {
+		t.Errorf("scopes leaked: %d goroutines, %d scopes", n, atomic.LoadInt64(&scopeCount))
+	}
+}
+
+func TestPushScope(t *testing.T) {
+	r := &eventRecorder{}
+	l := Logger{Handler: r, Args: Args{{"service", "test"}}}
+
+	pop1 := PushScope(Arg{"request_id", 1})
+	pop2 := PushScope(Arg{"user", "luke"})
+	l.Log("A %{n}d", 42, Args{{"x", true}})
+	pop2()
+	l.Log("B")
+	pop1()
+	l.Log("C")
+
+	for _, test := range []Args{
+		{{"service", "test"}, {"request_id", 1}, {"user", "luke"}, {"n", 42}, {"x", true}},
+		{{"service", "test"}, {"request_id", 1}},
+		{{"service", "test"}},
+	} {
+		if e := r.wait(t); !reflect.DeepEqual(e.Args, test) {
+			t.Errorf("bad event arguments: %v != %v", e.Args, test)
+		}
+	}
+
+	assertNoScopes(t)
+}
+
+func TestPushScopeLIFO(t *testing.T) {
+	r := &eventRecorder{}
+	l := Logger{Handler: r}
+
+	pop1 := PushScope(Arg{"a", 1})
+	pop2 := PushScope(Arg{"b", 2})
+
+	// Popping an outer scope also pops the scopes pushed after it, and popping
+	// a scope twice has no effect.
+	pop1()
+	pop2()
+	pop1()
+	l.Log("A")
+
+	if e := r.wait(t); len(e.Args) != 0 {
+		t.Error("the scope arguments were not removed:", e.Args)
+	}
+
+	assertNoScopes(t)
+}
+
+func TestPushScopeGoroutines(t *testing.T) {
+	var errors int32
+	var wg sync.WaitGroup
+
+	l := Logger{Handler: HandlerFunc(func(e *Event) {
+		g, ok := e.Args.Get("g")
+		n, _ := e.Args.Get("n")
+
+		switch e.Message {
+		case "inner":
+			if len(e.Args) != 2 || !ok || n != g {
+				atomic.AddInt32(&errors, 1)
+			}
+		case "outer":
+			if len(e.Args) != 1 || !ok {
+				atomic.AddInt32(&errors, 1)
+			}
+		}
+	})}
+
+	for i := 0; i != 2000; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			for j := 0; j != 10; j++ {
+				pop := PushScope(Arg{"g", i})
+				func() {
+					defer PushScope(Arg{"n", i})()
+					l.Log("inner")
+				}()
+				l.Log("outer")
+				pop()
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&errors); n != 0 {
+		t.Error("events had arguments of scopes from other goroutines:", n)
+	}
+
+	assertNoScopes(t)
+}
+
+func TestGoroutineID(t *testing.T) {
+	id := goroutineID()
+	ch := make(chan uint64)
+	go func() { ch <- goroutineID() }()
+
+	if id == 0 || id == <-ch || id != goroutineID() {
+		t.Error("bad goroutine IDs")
+	}
+}
+
+func BenchmarkScope(b *testing.B) {
+	l := Logger{Handler: Discard}
+
+	b.Run("none", func(b *testing.B) {
+		for i := 0; i != b.N; i++ {
+			l.Log("Hello %{name}s!", "Luke")
+		}
+	})
+
+	b.Run("active", func(b *testing.B) {
+		defer PushScope(Arg{"request_id", 1})()
+
+		for i := 0; i != b.N; i++ {
+			l.Log("Hello %{name}s!", "Luke")
+		}
+	})
+}
@@ -0,0 +1,148 @@
+package events
+
+import "context"
+
+// ShardedHandler is a handler which spreads events over multiple async
+// handlers, each passing events to its own sink from its own goroutine, so the
+// throughput of the handler scales with the number of shards when a single
+// goroutine can't keep up with the producers.
+//
+// Events are routed to shards by key, events with equal keys always go to the
+// same shard and are passed to its sink in the order they were received.
+// There is no ordering guarantee between events with different keys.
+//
+// The queues of the shards use the Block policy, producers are slowed down to
+// the rate at which the sinks handle events instead of losing events.
+//
+// It is safe to use a sharded handler concurrently from multiple goroutines.
+type ShardedHandler struct {
+	shards []*AsyncHandler
+	keyFn  func(*Event) uint64
+}
+
+// NewShardedHandler returns a ShardedHandler with the given number of shards,
+// where newSink is called with the index of each shard to construct the
+// handler that it passes events to.
+//
+// keyFn returns the key used to select the shard of each event, when nil the
+// fingerprint of the event message is used, so all events with the same
+// message are handled in order. The handler must be closed to release the
+// resources it holds.
+func NewShardedHandler(newSink func(shard int) Handler, shards int, keyFn func(*Event) uint64) *ShardedHandler {
+	if newSink == nil {
+		panic("events.NewShardedHandler: the sink constructor must not be nil")
+	}
+
+	if shards < 1 {
+		panic("events.NewShardedHandler: the number of shards must be at least 1")
+	}
+
+	if keyFn == nil {
+		keyFn = shardKey
+	}
+
+	s := &ShardedHandler{
+		shards: make([]*AsyncHandler, shards),
+		keyFn:  keyFn,
+	}
+
+	for i := range s.shards {
+		s.shards[i] = NewAsyncHandler(newSink(i), AsyncConfig{Policy: Block})
+	}
+
+	return s
+}
+
+func shardKey(e *Event) uint64 {
+	return fingerprint(e, false)
+}
+
+// HandleEvent satisfies the Handler interface.
+func (s *ShardedHandler) HandleEvent(e *Event) {
+	s.shard(e).HandleEvent(e)
+}
+
+// HandleEventSync satisfies the SyncHandler interface, the event bypasses the
+// queue of its shard.
+func (s *ShardedHandler) HandleEventSync(ctx context.Context, e *Event) error {
+	return syncStage(s, s.shard(e).HandleEventSync(ctx, e))
+}
+
+// HandleEventContext satisfies the ContextHandler interface.
+func (s *ShardedHandler) HandleEventContext(ctx context.Context, e *Event) error {
+	return s.shard(e).HandleEventContext(ctx, e)
+}
+
+func (s *ShardedHandler) shard(e *Event) *AsyncHandler {
+	return s.shards[s.keyFn(e)%uint64(len(s.shards))]
+}
+
+// Unwrap returns the async handlers of the shards.
+func (s *ShardedHandler) Unwrap() []Handler {
+	handlers := make([]Handler, len(s.shards))
+	for i, a := range s.shards {
+		handlers[i] = a
+	}
+	return handlers
+}
+
+// Stats returns the sum of the counters of all shards.
+func (s *ShardedHandler) Stats() (stats AsyncStats) {
+	for _, a := range s.shards {
+		x := a.Stats()
+		stats.QueueLength += x.QueueLength
+		stats.QueueSize += x.QueueSize
+		stats.Handled += x.Handled
+		stats.DroppedNewest += x.DroppedNewest
+		stats.DroppedOldest += x.DroppedOldest
+		stats.Timeouts += x.Timeouts
+		stats.Canceled += x.Canceled
+	}
+	return
+}
+
+// ShardStats returns the counters of each shard, indexed like the shards passed
+// to the sink constructor.
+func (s *ShardedHandler) ShardStats() []AsyncStats {
+	stats := make([]AsyncStats, len(s.shards))
+	for i, a := range s.shards {
+		stats[i] = a.Stats()
+	}
+	return stats
+}
+
+// Dropped satisfies the DropCounter interface.
+func (s *ShardedHandler) Dropped() (n int64) {
+	for _, a := range s.shards {
+		n += a.Dropped()
+	}
+	return
+}
+
+// Flush flushes all shards concurrently, returning the first error that
+// occurred.
+func (s *ShardedHandler) Flush() error {
+	return s.each((*AsyncHandler).Flush)
+}
+
+// Close closes all shards concurrently, returning the first error that
+// occurred.
+func (s *ShardedHandler) Close() error {
+	return s.each((*AsyncHandler).Close)
+}
+
+func (s *ShardedHandler) each(f func(*AsyncHandler) error) (err error) {
+	errs := make(chan error, len(s.shards))
+
+	for _, a := range s.shards {
+		go func(a *AsyncHandler) { errs <- f(a) }(a)
+	}
+
+	for range s.shards {
+		if e := <-errs; err == nil {
+			err = e
+		}
+	}
+
+	return
+}
@@ -0,0 +1,227 @@
+package events
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// shardSink records the events passed to one shard.
+type shardSink struct {
+	shard   int
+	mutex   sync.Mutex
+	events  []*Event
+	flushed int
+	closed  int
+	err     error
+}
+
+func (s *shardSink) HandleEvent(e *Event) {
+	s.mutex.Lock()
+	s.events = append(s.events, e)
+	s.mutex.Unlock()
+}
+
+func (s *shardSink) Flush() error {
+	s.mutex.Lock()
+	s.flushed++
+	s.mutex.Unlock()
+	return s.err
+}
+
+func (s *shardSink) Close() error {
+	s.mutex.Lock()
+	s.closed++
+	s.mutex.Unlock()
+	return s.err
+}
+
+func newShardSinks(n int) ([]*shardSink, func(int) Handler) {
+	sinks := make([]*shardSink, n)
+	for i := range sinks {
+		sinks[i] = &shardSink{shard: i}
+	}
+	return sinks, func(i int) Handler { return sinks[i] }
+}
+
+func TestShardedHandlerOrder(t *testing.T) {
+	sinks, newSink := newShardSinks(4)
+	h := NewShardedHandler(newSink, 4, func(e *Event) uint64 {
+		return uint64(e.Args[0].Value.(int))
+	})
+
+	wg := sync.WaitGroup{}
+
+	for key := 0; key != 8; key++ {
+		wg.Add(1)
+		go func(key int) {
+			defer wg.Done()
+			for seq := 0; seq != 1000; seq++ {
+				h.HandleEvent(&Event{Message: "hello", Args: Args{{"key", key}, {"seq", seq}}})
+			}
+		}(key)
+	}
+
+	wg.Wait()
+
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range sinks {
+		next := map[int]int{}
+
+		for _, e := range s.events {
+			key, seq := e.Args[0].Value.(int), e.Args[1].Value.(int)
+
+			if key%4 != s.shard {
+				t.Fatalf("event of key %d delivered to shard %d", key, s.shard)
+			}
+
+			if seq != next[key] {
+				t.Fatalf("shard %d: event %d of key %d delivered after event %d", s.shard, seq, key, next[key]-1)
+			}
+
+			next[key]++
+		}
+
+		if len(s.events) != 2000 {
+			t.Errorf("shard %d: bad number of events: %d", s.shard, len(s.events))
+		}
+	}
+
+	if stats := h.Stats(); stats.Handled != 8000 || stats.QueueSize != 4*DefaultAsyncQueueSize {
+		t.Errorf("bad stats: %+v", stats)
+	}
+}
+
+func TestShardedHandlerDefaultKey(t *testing.T) {
+	sinks, newSink := newShardSinks(8)
+	h := NewShardedHandler(newSink, 8, nil)
+
+	for i := 0; i != 100; i++ {
+		h.HandleEvent(&Event{Message: "A", Args: Args{{"i", i}}})
+		h.HandleEvent(&Event{Message: "B", Args: Args{{"i", i}}})
+	}
+
+	h.Close()
+
+	for _, s := range sinks {
+		count := map[string]int{}
+
+		for _, e := range s.events {
+			if i := e.Args[0].Value.(int); i != count[e.Message] {
+				t.Fatalf("shard %d: bad order of events %q: %d", s.shard, e.Message, i)
+			}
+			count[e.Message]++
+		}
+
+		for msg, n := range count {
+			if n != 100 {
+				t.Errorf("shard %d: events %q were split across shards: %d", s.shard, msg, n)
+			}
+		}
+	}
+}
+
+func TestShardedHandlerFlushClose(t *testing.T) {
+	sinks, newSink := newShardSinks(3)
+	sinks[1].err = errors.New("oops")
+	h := NewShardedHandler(newSink, 3, func(e *Event) uint64 { return 0 })
+
+	h.HandleEvent(&Event{Message: "hello"})
+
+	if err := h.Flush(); err != sinks[1].err {
+		t.Error("bad flush error:", err)
+	}
+
+	if len(sinks[0].events) != 1 {
+		t.Error("the queued event was not flushed")
+	}
+
+	if err := h.Close(); err != sinks[1].err {
+		t.Error("bad close error:", err)
+	}
+
+	h.HandleEvent(&Event{Message: "dropped"})
+
+	for _, s := range sinks {
+		if s.flushed != 1 || s.closed != 1 {
+			t.Errorf("shard %d: flushed=%d closed=%d", s.shard, s.flushed, s.closed)
+		}
+	}
+
+	stats := h.ShardStats()
+
+	if len(stats) != 3 || stats[0].Handled != 1 || stats[0].DroppedNewest != 1 {
+		t.Errorf("bad shard stats: %+v", stats)
+	}
+
+	if n := h.Dropped(); n != 1 {
+		t.Error("bad number of dropped events:", n)
+	}
+
+	if reports := HealthOf(h); len(reports) != 3 || reports[2].Status != Unhealthy {
+		t.Errorf("bad health of the closed handler: %+v", reports)
+	}
+}
+
+func TestShardedHandlerPanics(t *testing.T) {
+	tests := []struct {
+		newSink func(int) Handler
+		shards  int
+	}{
+		{nil, 1},
+		{func(int) Handler { return Discard }, 0},
+	}
+
+	for _, test := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("no panic with %d shards", test.shards)
+				}
+			}()
+			NewShardedHandler(test.newSink, test.shards, nil)
+		}()
+	}
+}
+
+// contendedSink simulates a sink which spends time handling events, most of it
+// outside of a lock that it shares with the other sinks.
+type contendedSink struct {
+	mutex *sync.Mutex
+}
+
+func (s contendedSink) HandleEvent(e *Event) {
+	spin(2 * time.Microsecond)
+	s.mutex.Lock()
+	spin(100 * time.Nanosecond)
+	s.mutex.Unlock()
+}
+
+func spin(d time.Duration) {
+	for t := time.Now(); time.Since(t) < d; {
+	}
+}
+
+func BenchmarkShardedHandler(b *testing.B) {
+	for _, shards := range []int{1, 4, 8} {
+		b.Run(fmt.Sprint(shards), func(b *testing.B) {
+			mutex := &sync.Mutex{}
+			h := NewShardedHandler(func(int) Handler { return contendedSink{mutex} }, shards, func(e *Event) uint64 {
+				return uint64(e.Args[0].Value.(int))
+			})
+
+			b.RunParallel(func(pb *testing.PB) {
+				for i := 0; pb.Next(); i++ {
+					h.HandleEvent(&Event{Message: "hello", Args: Args{{"i", i}}})
+				}
+			})
+
+			h.Close()
+		})
+	}
+}
@@ -15,6 +15,41 @@ func SourceForPC(pc uintptr) (file string, line int) {
 	return
 }
 
+// PackageForPC returns the import path of the package of the function at the
+// given program counter address.
+//
+// Vendored packages are reported with the import path they are vendored from,
+// and the ".test" suffix of test binaries is removed.
+func PackageForPC(pc uintptr) string {
+	fn := runtime.FuncForPC(pc)
+	if fn == nil {
+		return ""
+	}
+	return packageForFunc(fn.Name())
+}
+
+// packageForFunc extracts the package import path from a qualified function
+// name like "github.com/segmentio/events.(*Logger).Log".
+func packageForFunc(name string) string {
+	// The dots of the last path element are escaped in function names, the
+	// first dot after the last slash separates the package path from the
+	// function name.
+	i := strings.LastIndexByte(name, '/')
+	if j := strings.IndexByte(name[i+1:], '.'); j >= 0 {
+		name = name[:i+1+j]
+	}
+
+	name = strings.Replace(name, "%2e", ".", -1)
+
+	if i := strings.LastIndex(name, "/vendor/"); i >= 0 {
+		name = name[i+len("/vendor/"):]
+	} else if strings.HasPrefix(name, "vendor/") {
+		name = name[len("vendor/"):]
+	}
+
+	return strings.TrimSuffix(name, ".test")
+}
+
 // =============================================================================
 // From https://github.com/pkg/errors/blob/master/stack.go
 //
@@ -19,3 +19,37 @@ func TestSourceForPC(t *testing.T) {
 		t.Error("bad line:", line)
 	}
 }
+
+func TestPackageForFunc(t *testing.T) {
+	tests := []struct {
+		name string
+		pkg  string
+	}{
+		{"github.com/segmentio/events.NewLogger", "github.com/segmentio/events"},
+		{"github.com/segmentio/events.(*Logger).Log", "github.com/segmentio/events"},
+		{"github.com/segmentio/events.init.0", "github.com/segmentio/events"},
+		{"github.com/segmentio/events.TestLogger.func1", "github.com/segmentio/events"},
+		{"github.com/segmentio/events/text.(*Handler).HandleEvent", "github.com/segmentio/events/text"},
+		{"github.com/segmentio/events_test.TestEncoderConformance", "github.com/segmentio/events_test"},
+		{"github.com/segmentio/events%2etest.init", "github.com/segmentio/events"},
+		{"gopkg.in/yaml%2ev2.Unmarshal", "gopkg.in/yaml.v2"},
+		{"github.com/app/vendor/github.com/segmentio/events.Log", "github.com/segmentio/events"},
+		{"vendor/golang.org/x/net/http2.(*Framer).ReadFrame", "golang.org/x/net/http2"},
+		{"main.main", "main"},
+		{"main.init.0.func1", "main"},
+	}
+
+	for _, test := range tests {
+		if pkg := packageForFunc(test.name); pkg != test.pkg {
+			t.Errorf("%s: %q != %q", test.name, pkg, test.pkg)
+		}
+	}
+}
+
+func TestPackageForPC(t *testing.T) {
+	pc, _, _, _ := runtime.Caller(0)
+
+	if pkg := PackageForPC(pc); pkg != "github.com/segmentio/events" {
+		t.Error("bad package:", pkg)
+	}
+}
@@ -0,0 +1,125 @@
+package events
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync/atomic"
+	"time"
+)
+
+// spanNow returns the current time of spans, tests may replace it.
+var spanNow = time.Now
+
+// Span represents an operation started with Start, the events emitted when
+// the span starts and finishes carry the same span_id argument so they can be
+// correlated.
+//
+// It is safe to finish a span from any goroutine, only the first call to
+// Finish or FinishWithError emits an event.
+type Span struct {
+	// ID is the value of the span_id argument of the span events.
+	ID string
+
+	handler  Handler
+	message  string
+	args     Args
+	start    time.Time
+	finishes int32 // accessed atomically
+}
+
+// Start emits an event with the given message and arguments to h, and returns
+// a span which emits the finish event of the operation to h. If h is nil the
+// events are sent to the default handler.
+//
+// The start event carries the span_id argument, and a span argument set to
+// "start". The finish event has the same message and arguments, with the
+// span argument set to "finish" and these additional arguments:
+//
+//	duration: the time elapsed since the span started, as a time.Duration
+//	outcome:  "ok", or "error" when finished by FinishWithError
+//	error:    the error passed to FinishWithError, if any
+func Start(h Handler, message string, args ...Arg) *Span {
+	s := newSpan(h, message, args)
+	s.emit(s.event("start", s.start))
+	return s
+}
+
+// StartQuiet is like Start but doesn't emit the start event, which reduces the
+// volume of events when only the durations of operations matter.
+func StartQuiet(h Handler, message string, args ...Arg) *Span {
+	return newSpan(h, message, args)
+}
+
+func newSpan(h Handler, message string, args Args) *Span {
+	return &Span{
+		ID:      newSpanID(),
+		handler: h,
+		message: message,
+		args:    append(Args(nil), args...),
+		start:   spanNow(),
+	}
+}
+
+// Finish emits the finish event of the span with the "ok" outcome, args are
+// added to the event after the arguments given to Start.
+//
+// Finishing a span more than once has no effect other than emitting a
+// diagnostic event reporting the number of calls.
+func (s *Span) Finish(args ...Arg) {
+	s.finish(nil, args)
+}
+
+// FinishWithError is like Finish but the outcome of the span is "error" and
+// err is added to the event arguments, unless err is nil.
+func (s *Span) FinishWithError(err error, args ...Arg) {
+	s.finish(err, args)
+}
+
+func (s *Span) finish(err error, args Args) {
+	if n := atomic.AddInt32(&s.finishes, 1); n != 1 {
+		s.emit(Diagnostic("span finished more than once",
+			Arg{"span_id", s.ID},
+			Arg{"message", s.message},
+			Arg{"calls", int(n)},
+		))
+		return
+	}
+
+	now := spanNow()
+	e := s.event("finish", now)
+	e.Args = append(e.Args, args...)
+	e.Args = append(e.Args, Arg{"duration", now.Sub(s.start)})
+
+	if err != nil {
+		e.Args = append(e.Args, Arg{"outcome", "error"}, Arg{"error", err})
+	} else {
+		e.Args = append(e.Args, Arg{"outcome", "ok"})
+	}
+
+	s.emit(e)
+}
+
+func (s *Span) event(state string, t time.Time) *Event {
+	args := make(Args, 0, len(s.args)+6)
+	args = append(args, s.args...)
+	args = append(args, Arg{"span_id", s.ID}, Arg{"span", state})
+	return &Event{
+		Message: s.message,
+		Args:    args,
+		Time:    t,
+	}
+}
+
+func (s *Span) emit(e *Event) {
+	h := s.handler
+	if h == nil {
+		h = DefaultHandler
+	}
+	h.HandleEvent(e)
+}
+
+func newSpanID() string {
+	var b [8]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,121 @@
+package events
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func fakeSpanClock() (*fakeClock, func()) {
+	clock := &fakeClock{now: time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)}
+	prev := spanNow
+	spanNow = clock.Now
+	return clock, func() { spanNow = prev }
+}
+
+func TestSpan(t *testing.T) {
+	clock, restore := fakeSpanClock()
+	defer restore()
+
+	r := &eventRecorder{}
+	s := Start(r, "query", Arg{"table", "users"})
+	clock.add(1500 * time.Millisecond)
+	s.Finish(Arg{"rows", 3})
+
+	start, finish := r.wait(t), r.wait(t)
+
+	if len(s.ID) != 16 {
+		t.Error("bad span ID:", s.ID)
+	}
+
+	if want := (Args{{"table", "users"}, {"span_id", s.ID}, {"span", "start"}}); start.Message != "query" || !reflect.DeepEqual(start.Args, want) {
+		t.Errorf("bad start event: %+v", start)
+	}
+
+	want := Args{
+		{"table", "users"},
+		{"span_id", s.ID},
+		{"span", "finish"},
+		{"rows", 3},
+		{"duration", 1500 * time.Millisecond},
+		{"outcome", "ok"},
+	}
+
+	if finish.Message != "query" || !reflect.DeepEqual(finish.Args, want) {
+		t.Errorf("bad finish event: %+v", finish)
+	}
+
+	if !finish.Time.Equal(start.Time.Add(1500 * time.Millisecond)) {
+		t.Error("bad finish time:", finish.Time)
+	}
+}
+
+func TestSpanError(t *testing.T) {
+	_, restore := fakeSpanClock()
+	defer restore()
+
+	r := &eventRecorder{}
+	err := errors.New("timeout")
+	StartQuiet(r, "query").FinishWithError(err)
+	StartQuiet(r, "query").FinishWithError(nil)
+
+	if m := r.wait(t).Args.Map(); m["outcome"] != "error" || m["error"] != err {
+		t.Error("bad error outcome:", m)
+	}
+
+	if m := r.wait(t).Args.Map(); m["outcome"] != "ok" || m["error"] != nil {
+		t.Error("bad ok outcome:", m)
+	}
+
+	if n := r.len(); n != 0 {
+		t.Error("quiet spans must not emit start events:", n)
+	}
+}
+
+func TestSpanFinishOnce(t *testing.T) {
+	r := &eventRecorder{}
+	s := StartQuiet(r, "query")
+	wg := sync.WaitGroup{}
+
+	for i := 0; i != 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.Finish()
+		}()
+	}
+
+	wg.Wait()
+
+	var finishes, diagnostics int
+
+	for _, e := range r.events {
+		switch {
+		case IsDiagnostic(e):
+			diagnostics++
+			if id, _ := e.Args.Get("span_id"); id != s.ID {
+				t.Error("bad diagnostic event:", e)
+			}
+		case e.Args.Map()["span"] == "finish":
+			finishes++
+		}
+	}
+
+	if finishes != 1 || diagnostics != 9 {
+		t.Errorf("bad event counts: finishes=%d diagnostics=%d", finishes, diagnostics)
+	}
+}
+
+func TestSpanDefaultHandler(t *testing.T) {
+	r := &eventRecorder{}
+	old := SwapDefaultHandler(r)
+	defer SwapDefaultHandler(old)
+
+	Start(nil, "A").Finish()
+
+	if n := r.len(); n != 2 {
+		t.Error("the events were not sent to the default handler:", n)
+	}
+}
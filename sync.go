@@ -0,0 +1,191 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// The SyncHandler interface may be implemented by handlers which defer the
+// delivery of events (like AsyncHandler), to provide a path which delivers
+// events immediately.
+type SyncHandler interface {
+	Handler
+
+	// HandleEventSync passes e to the handlers that come next without going
+	// through queues or buffers, returning after the event was delivered or
+	// when ctx is done.
+	HandleEventSync(ctx context.Context, e *Event) error
+}
+
+// ErrHandlerClosed is the reason reported by SyncError when an event was passed
+// to a handler that was already closed.
+var ErrHandlerClosed = errors.New("events: handler closed")
+
+// ErrHandlerPanicked is the reason reported by SyncError when a handler
+// panicked while it was delivering an event. The panic is recovered and
+// reported with a diagnostic event, like in MultiHandler.
+var ErrHandlerPanicked = errors.New("events: handler panicked")
+
+// SyncError is returned by LogSync and HandleEventSync when the synchronous
+// delivery of an event could not complete.
+type SyncError struct {
+	// Stage describes where the delivery stopped, as the list of handler
+	// types that the event went through, the last one being the handler that
+	// didn't complete.
+	Stage string
+
+	// Err is the reason why the delivery did not complete, ctx.Err() when
+	// the context was done.
+	Err error
+}
+
+// Error satisfies the error interface.
+func (e *SyncError) Error() string {
+	return "events: synchronous delivery stopped at " + e.Stage + ": " + e.Err.Error()
+}
+
+// Unwrap returns the reason why the delivery did not complete.
+func (e *SyncError) Unwrap() error {
+	return e.Err
+}
+
+// LogSync delivers e synchronously to h and all the handlers that come after
+// it, bypassing the queues of handlers implementing SyncHandler. It is intended
+// to be used for critical events, like the last event emitted before a program
+// exits because of a fatal error.
+//
+// The function returns after all handlers have received the event, or returns
+// a *SyncError if the delivery didn't complete within timeout. The event is
+// cloned, so it may be reused by the program after the function returned even
+// if some handlers are still blocked.
+func LogSync(h Handler, e *Event, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return HandleEventSync(ctx, h, e.Clone())
+}
+
+// HandleEventSync passes e to h, using its HandleEventSync method if h
+// implements SyncHandler.
+//
+// Other handlers are called with HandleEvent and flushed in a separate
+// goroutine, so the function can give up when ctx is done. In that case the
+// handler still receives the event and may deliver it after HandleEventSync
+// returned, so e must not be modified by the caller.
+//
+// Panics raised by h are recovered and reported to the default handler, the
+// function then returns a *SyncError wrapping ErrHandlerPanicked.
+func HandleEventSync(ctx context.Context, h Handler, e *Event) error {
+	_, err := handleEventSyncNext(ctx, h, e, func(diag *Event) { reportPanic(h, diag) })
+	return err
+}
+
+// handleEventSyncNext passes e to h like HandleEventSync, panics of h are
+// recovered and the diagnostic events describing them are passed to report.
+func handleEventSyncNext(ctx context.Context, h Handler, e *Event, report func(*Event)) (stop bool, err error) {
+	if s, ok := h.(SyncHandler); ok {
+		_, diag := safeCall(h, func() bool { err = s.HandleEventSync(ctx, e); return false })
+
+		if diag != nil {
+			report(diag)
+			err = &SyncError{Stage: fmt.Sprintf("%T", h), Err: ErrHandlerPanicked}
+		}
+
+		return false, err
+	}
+
+	done := make(chan bool, 1)
+	panicked := make(chan struct{})
+
+	go func() {
+		stop, diag := safeCall(h, func() bool {
+			stop := handleEventNext(h, e)
+			flushHandler(h)
+			return stop
+		})
+
+		if diag != nil {
+			report(diag)
+			close(panicked)
+			return
+		}
+
+		done <- stop
+	}()
+
+	select {
+	case stop = <-done:
+		return stop, nil
+	case <-panicked:
+		return false, &SyncError{Stage: fmt.Sprintf("%T", h), Err: ErrHandlerPanicked}
+	case <-ctx.Done():
+		return false, &SyncError{Stage: fmt.Sprintf("%T", h), Err: ctx.Err()}
+	}
+}
+
+// forwardEvent passes e to h, it is the forward function used by wrappers
+// when they handle events asynchronously.
+func forwardEvent(h Handler, e *Event) {
+	h.HandleEvent(e)
+}
+
+// handleSync calls handle with a forward function which passes events to the
+// handlers wrapped by w with HandleEventSync, returning the first error that
+// occurred. Wrappers implement both of their handling methods by passing the
+// forward function down to a single one which does the work.
+func handleSync(ctx context.Context, w Handler, handle func(forward func(Handler, *Event))) (err error) {
+	handle(func(h Handler, e *Event) {
+		if herr := HandleEventSync(ctx, h, e); err == nil {
+			err = herr
+		}
+	})
+	return syncStage(w, err)
+}
+
+// syncStage prefixes the stage of err with the type of h, when err is a
+// *SyncError.
+func syncStage(h Handler, err error) error {
+	if e, ok := err.(*SyncError); ok {
+		e.Stage = fmt.Sprintf("%T > %s", h, e.Stage)
+	}
+	return err
+}
+
+// HandleEventSync passes the event to all handlers, returning the first error
+// that occurred. Handlers that come after one that timed out still receive the
+// event, but the method doesn't wait for them anymore.
+func (m *multiHandler) HandleEventSync(ctx context.Context, e *Event) (err error) {
+	for i, h := range m.handlers {
+		i := i
+		stop, herr := handleEventSyncNext(ctx, h, e, func(diag *Event) { m.report(i, diag) })
+
+		if err == nil {
+			err = herr
+		}
+
+		if stop {
+			break
+		}
+	}
+	return syncStage(m, err)
+}
+
+func (p *priorityHandler) HandleEventSync(ctx context.Context, e *Event) error {
+	return HandleEventSync(ctx, p.Handler, e)
+}
+
+func (defaultHandler) HandleEventSync(ctx context.Context, e *Event) error {
+	for {
+		b := loadDefault()
+		atomic.AddInt64(&b.calls, 1)
+
+		if b == loadDefault() {
+			defer atomic.AddInt64(&b.calls, -1)
+			return HandleEventSync(ctx, b.handler, e)
+		}
+
+		atomic.AddInt64(&b.calls, -1)
+	}
+}
@@ -0,0 +1,232 @@
+package events
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stallHandler blocks on events with the "stall" message until it's released,
+// and records all events.
+type stallHandler struct {
+	eventRecorder
+	started chan struct{}
+	release chan struct{}
+}
+
+func newStallHandler() *stallHandler {
+	return &stallHandler{
+		started: make(chan struct{}, 10),
+		release: make(chan struct{}),
+	}
+}
+
+func (h *stallHandler) HandleEvent(e *Event) {
+	if e.Message == "stall" {
+		h.started <- struct{}{}
+		<-h.release
+	}
+	h.eventRecorder.HandleEvent(e)
+}
+
+func (h *stallHandler) messages() (msgs []string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	for _, e := range h.events {
+		msgs = append(msgs, e.Message)
+	}
+
+	return
+}
+
+func TestLogSyncFullQueue(t *testing.T) {
+	h := newStallHandler()
+	a := NewAsyncHandler(h, AsyncConfig{QueueSize: 2, Policy: Block, SummaryInterval: time.Hour})
+
+	a.HandleEvent(&Event{Message: "stall"})
+	<-h.started
+	a.HandleEvent(&Event{Message: "B"})
+	a.HandleEvent(&Event{Message: "C"})
+
+	start := time.Now()
+
+	if err := LogSync(a, &Event{Message: "fatal"}, time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Error("the synchronous event was delayed by the queue:", d)
+	}
+
+	if msgs := h.messages(); len(msgs) != 1 || msgs[0] != "fatal" {
+		t.Error("the synchronous event was not delivered:", msgs)
+	}
+
+	close(h.release)
+	a.Close()
+
+	assertMessages(t, h.messages(), "fatal", "stall", "B", "C")
+
+	if n := a.Stats().Handled; n != 4 {
+		t.Error("bad number of handled events:", n)
+	}
+}
+
+func TestLogSyncTimeout(t *testing.T) {
+	h := newStallHandler()
+	a := NewAsyncHandler(h, AsyncConfig{SummaryInterval: time.Hour})
+	defer a.Close()
+	defer close(h.release)
+
+	start := time.Now()
+	err := LogSync(a, &Event{Message: "stall"}, 20*time.Millisecond)
+
+	if d := time.Since(start); d < 20*time.Millisecond || d > 500*time.Millisecond {
+		t.Error("the deadline was not respected:", d)
+	}
+
+	e, ok := err.(*SyncError)
+	if !ok {
+		t.Fatalf("bad error: %#v", err)
+	}
+
+	if e.Err != context.DeadlineExceeded {
+		t.Error("bad error reason:", e.Err)
+	}
+
+	if e.Stage != "*events.AsyncHandler > *events.stallHandler" {
+		t.Error("bad stage:", e.Stage)
+	}
+
+	if s := e.Error(); !strings.Contains(s, "*events.stallHandler") {
+		t.Error("the error doesn't mention the stage that timed out:", s)
+	}
+}
+
+func TestLogSyncMultiHandler(t *testing.T) {
+	r := &eventRecorder{}
+	h := newStallHandler()
+	defer close(h.release)
+
+	err := LogSync(MultiHandler(h, r), &Event{Message: "stall"}, 20*time.Millisecond)
+
+	if e, ok := err.(*SyncError); !ok || e.Stage != "*events.multiHandler > *events.stallHandler" {
+		t.Errorf("bad error: %v", err)
+	}
+
+	// The delivery gave up on the first handler, the next ones still get the
+	// event.
+	if e := r.wait(t); e.Message != "stall" {
+		t.Error("bad event:", e)
+	}
+
+	if n := r.len(); n != 0 {
+		t.Error("the event was duplicated:", n)
+	}
+}
+
+func TestLogSyncClosed(t *testing.T) {
+	r := &eventRecorder{}
+	a := NewAsyncHandler(r, AsyncConfig{SummaryInterval: time.Hour})
+	a.Close()
+	r.reset()
+
+	if err := LogSync(a, &Event{Message: "fatal"}, time.Second); err == nil || err.(*SyncError).Err != ErrHandlerClosed {
+		t.Error("bad error:", err)
+	}
+
+	if n := r.len(); n != 0 {
+		t.Error("the event was passed to a closed handler")
+	}
+}
+
+func TestLogSyncPanic(t *testing.T) {
+	r := &eventRecorder{}
+	old := SwapDefaultHandler(r)
+	defer SwapDefaultHandler(old)
+
+	for _, h := range []Handler{
+		newPanicHandler(t, 0),
+		NewAsyncHandler(newPanicHandler(t, 0), AsyncConfig{SummaryInterval: time.Hour}),
+	} {
+		err := LogSync(h, &Event{Message: "panic"}, time.Second)
+
+		if e, ok := err.(*SyncError); !ok || e.Err != ErrHandlerPanicked {
+			t.Errorf("%T: bad error: %v", h, err)
+		}
+
+		assertPanicEvent(t, r.wait(t))
+		closeHandler(h)
+		r.reset()
+	}
+}
+
+func TestLogSyncMultiHandlerPanic(t *testing.T) {
+	r := &eventRecorder{}
+	p := newPanicHandler(t, 0)
+
+	err := LogSync(MultiHandler(p, r), &Event{Message: "panic"}, time.Second)
+
+	if e, ok := err.(*SyncError); !ok || e.Stage != "*events.multiHandler > *events.panicHandler" {
+		t.Errorf("bad error: %v", err)
+	}
+
+	// The panic is reported to the other handlers, which also receive the
+	// event.
+	assertPanicEvent(t, r.wait(t))
+
+	if e := r.wait(t); e.Message != "panic" {
+		t.Error("bad event:", e)
+	}
+}
+
+// testWrapper is a constructor of one of the handlers which wrap another one.
+type testWrapper struct {
+	name string
+	wrap func(Handler) Handler
+}
+
+func testWrappers(t *testing.T) []testWrapper {
+	return []testWrapper{
+		{"RateTracker", func(h Handler) Handler { return NewRateTracker(h, time.Minute, 6) }},
+		{"OnceHandler", func(h Handler) Handler { return NewOnce(h) }},
+		{"Capture", func(h Handler) Handler { return NewCaptureController(h) }},
+		{"Aggregator", func(h Handler) Handler { return NewAggregator(h, time.Hour, AggSpec{Messages: []string{"other"}}) }},
+		{"Localizer", func(h Handler) Handler { return NewLocalizer(h, NewCatalog(), "fr") }},
+		{"SchemaCollector", func(h Handler) Handler { return NewSchemaCollector(h) }},
+		{"NewMutatorChain", func(h Handler) Handler { return NewMutatorChain(h, MutatorFunc(func(e *Event) *Event { return e })) }},
+		{"CardinalityLimiter", func(h Handler) Handler { return NewCardinalityLimiter(h) }},
+		{"Heartbeat", func(h Handler) Handler { b, stop := NewHeartbeat(h, time.Hour); t.Cleanup(stop); return b }},
+		{"Router", func(h Handler) Handler { return NewRouter("tenant", nil, h) }},
+		{"FilterHandler", func(h Handler) Handler { return FilterHandler(h, func(*Event) bool { return true }) }},
+		{"BufferedHandler", func(h Handler) Handler { return NewBufferedHandler(h, 1, time.Hour) }},
+		{"ExpandErrors", func(h Handler) Handler { return ExpandErrors(h) }},
+		{"ValidateHandler", func(h Handler) Handler { return ValidateHandler(h, ValidateLenient) }},
+		{"CanonicalizeHandler", func(h Handler) Handler { return CanonicalizeHandler(h, &Canonicalizer{Rules: SnakeCaseRules()}) }},
+	}
+}
+
+func TestLogSyncWrappers(t *testing.T) {
+	for _, w := range testWrappers(t) {
+		t.Run(w.name, func(t *testing.T) {
+			h := newStallHandler()
+			a := NewAsyncHandler(h, AsyncConfig{QueueSize: 1, Policy: Block, SummaryInterval: time.Hour})
+			defer a.Close()
+			defer close(h.release)
+
+			a.HandleEvent(&Event{Message: "stall"})
+			<-h.started
+			a.HandleEvent(&Event{Message: "B"})
+
+			if err := LogSync(w.wrap(a), &Event{Message: "fatal"}, time.Second); err != nil {
+				t.Fatal(err)
+			}
+
+			if msgs := h.messages(); len(msgs) != 1 || msgs[0] != "fatal" {
+				t.Error("the synchronous event was not delivered:", msgs)
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/segmentio/events"
@@ -23,8 +24,43 @@ type Handler struct {
 	TimeLocation *time.Location // location to output the event time in
 	EnableArgs   bool           // output detailes of each args in the events
 
+	// Layout controls the order and alignment of the fields written for each
+	// event. When set, the EnableArgs field is ignored and the event arguments
+	// are only written where the layout has an {args} placeholder.
+	//
+	// Layouts are made of literal text and placeholders surrounded by curly
+	// braces, for example:
+	//
+	//	{time} {level:>5} {source:<30} {message} {args}
+	//
+	// The supported placeholders are:
+	//
+	//	time     the event time, formatted with TimeFormat
+	//	level    one of DEBUG, INFO or ERROR (following the ecslogs rules)
+	//	source   the event source
+	//	message  the event message
+	//	args     the event arguments as a space-separated list of name=value
+	//
+	// A placeholder may be followed by a colon and an alignment specifier, '<'
+	// pads the value on the right to the given width, '>' pads it on the left,
+	// omitting the alignment defaults to '<'. Widths are counted in runes,
+	// values longer than the width are never truncated. Unknown or malformed
+	// placeholders are rendered literally.
+	Layout string
+
+	// Bytes is the format of byte slices in the event arguments, it defaults
+	// to hexadecimal.
+	Bytes events.BytesFormat
+
+	// SortArgs may be set to true to write the event arguments in the order
+	// of their names (see events.Args.SortedIndex).
+	SortArgs bool
+
 	// synchronizes writes to the output
 	mutex sync.Mutex
+
+	// cache of the compiled layout
+	layout atomic.Value
 }
 
 // NewHandler creates a new handler which writes to output with a prefix on each
@@ -41,8 +77,33 @@ func NewHandler(prefix string, output io.Writer) *Handler {
 func (h *Handler) HandleEvent(e *events.Event) {
 	buf := bufferPool.Get().(*buffer)
 	buf.b = buf.b[:0]
+	h.encode(buf, e)
+	h.write(buf)
+}
+
+// EncodeEvent satisfies the events.Encoder interface, it appends to dst the
+// text that the handler would write to its output for e. The Output field is
+// not used, so a Handler value can be used as an encoder for other handlers
+// (see events.NewWriterHandler).
+func (h *Handler) EncodeEvent(dst []byte, e *events.Event) ([]byte, error) {
+	buf := bufferPool.Get().(*buffer)
+	b := buf.b
+	buf.b = dst
+	h.encode(buf, e)
+	dst, buf.b = buf.b, b[:0]
+	bufferPool.Put(buf)
+	return dst, nil
+}
+
+func (h *Handler) encode(buf *buffer, e *events.Event) {
 	buf.b = append(buf.b, h.Prefix...)
 
+	if len(h.Layout) != 0 {
+		h.compiledLayout().format(buf, h, e)
+		buf.b = append(buf.b, '\n')
+		return
+	}
+
 	if fmt := h.TimeFormat; len(fmt) != 0 {
 		loc := h.TimeLocation
 		if loc == nil {
@@ -60,37 +121,87 @@ func (h *Handler) HandleEvent(e *events.Event) {
 	buf.b = append(buf.b, e.Message...)
 	buf.b = append(buf.b, '\n')
 
-	if h.EnableArgs {
+	if h.EnableArgs && len(e.Args) != 0 {
 		hasError := false
+		order := h.argsOrder(e)
+
+		for i := range e.Args {
+			a := argAt(e.Args, order, i)
 
-		for _, a := range e.Args {
 			if _, ok := a.Value.(error); ok {
 				hasError = true
 			} else {
 				buf.b = append(buf.b, '\t')
 				buf.b = append(buf.b, a.Name...)
 				buf.b = append(buf.b, ':', ' ')
-				fmt.Fprintf(buf, "%v\n", a.Value)
+				appendValue(buf, a.Value, h.Bytes)
+				buf.b = append(buf.b, '\n')
 			}
 		}
 
 		if hasError {
 			fmt.Fprint(buf, "\terrors:\n")
 
-			for _, a := range e.Args {
-				if err, ok := a.Value.(error); ok {
-					fmt.Fprintf(buf, "\t\t- %+v\n", err)
+			for i := range e.Args {
+				if err, ok := argAt(e.Args, order, i).Value.(error); ok {
+					if f, ok := err.(events.Formatter); ok {
+						fmt.Fprintf(buf, "\t\t- %s\n", f.FormatEventValue())
+					} else {
+						fmt.Fprintf(buf, "\t\t- %+v\n", err)
+					}
 				}
 			}
 		}
 	}
+}
+
+// argsOrder returns the order in which the arguments of e are written, nil
+// means the order of the list.
+func (h *Handler) argsOrder(e *events.Event) []int {
+	if h.SortArgs && len(e.Args) > 1 {
+		return e.Args.SortedIndex()
+	}
+	return nil
+}
 
+func argAt(args events.Args, order []int, i int) events.Arg {
+	if order != nil {
+		i = order[i]
+	}
+	return args[i]
+}
+
+// appendValue writes the representation of an argument value to buf, values
+// that implement none of the interfaces recognized by events.FormatValue are
+// formatted with the %v verb.
+func appendValue(buf *buffer, v interface{}, format events.BytesFormat) {
+	if b, ok := events.AppendBytesValue(buf.b, v, format); ok {
+		buf.b = b
+	} else if s, ok := events.FormatValue(v); ok {
+		buf.b = append(buf.b, s...)
+	} else {
+		fmt.Fprintf(buf, "%v", v)
+	}
+}
+
+func (h *Handler) write(buf *buffer) {
 	h.mutex.Lock()
 	h.Output.Write(buf.b)
 	h.mutex.Unlock()
 	bufferPool.Put(buf)
 }
 
+func (h *Handler) compiledLayout() *layout {
+	l, _ := h.layout.Load().(*layout)
+
+	if l == nil || l.src != h.Layout {
+		l = parseLayout(h.Layout)
+		h.layout.Store(l)
+	}
+
+	return l
+}
+
 // This buffer type is used as an optimization, it's faster than the standard
 // bytes.Buffer because it doesn't expose such a rich API.
 type buffer struct {
@@ -18,6 +18,6 @@ func init() {
 	DefaultPrefix = fmt.Sprintf("%s[%d]: ", filepath.Base(os.Args[0]), os.Getpid())
 
 	if events.IsTerminal(1) {
-		events.DefaultHandler = NewHandler(DefaultPrefix, os.Stdout)
+		events.SwapDefaultHandler(NewHandler(DefaultPrefix, os.Stdout))
 	}
 }
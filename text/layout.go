@@ -0,0 +1,197 @@
+package text
+
+import (
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/segmentio/events"
+)
+
+// The layout type is the compiled representation of a layout string set on
+// the Layout field of a Handler, see the field for the syntax.
+type layout struct {
+	src   string
+	parts []layoutPart
+}
+
+type layoutPart struct {
+	field layoutField
+	text  string
+	align byte
+	width int
+}
+
+type layoutField int
+
+const (
+	layoutText layoutField = iota
+	layoutTime
+	layoutLevel
+	layoutSource
+	layoutMessage
+	layoutArgs
+)
+
+var layoutFields = map[string]layoutField{
+	"time":    layoutTime,
+	"level":   layoutLevel,
+	"source":  layoutSource,
+	"message": layoutMessage,
+	"args":    layoutArgs,
+}
+
+func parseLayout(s string) *layout {
+	l := &layout{src: s}
+
+	for i := 0; i != len(s); {
+		j := strings.IndexByte(s[i:], '{')
+		if j < 0 {
+			l.text(s[i:])
+			break
+		}
+		l.text(s[i : i+j])
+		i += j
+
+		k := strings.IndexByte(s[i:], '}')
+		if k < 0 {
+			l.text(s[i:])
+			break
+		}
+
+		if p, ok := parseLayoutPart(s[i+1 : i+k]); ok {
+			l.parts = append(l.parts, p)
+		} else {
+			l.text(s[i : i+k+1])
+		}
+
+		i += k + 1
+	}
+
+	return l
+}
+
+func parseLayoutPart(s string) (p layoutPart, ok bool) {
+	name, spec := s, ""
+
+	if i := strings.IndexByte(s, ':'); i >= 0 {
+		name, spec = s[:i], s[i+1:]
+	}
+
+	if p.field, ok = layoutFields[name]; !ok {
+		return
+	}
+
+	p.align = '<'
+
+	if len(spec) != 0 {
+		switch spec[0] {
+		case '<', '>':
+			p.align, spec = spec[0], spec[1:]
+		}
+
+		if p.width, ok = parseLayoutWidth(spec); !ok {
+			return
+		}
+	}
+
+	return
+}
+
+func parseLayoutWidth(s string) (int, bool) {
+	n, err := strconv.Atoi(s)
+	return n, err == nil && n >= 0
+}
+
+func (l *layout) text(s string) {
+	if len(s) == 0 {
+		return
+	}
+
+	// Merge consecutive literal text, this happens when placeholders are
+	// rendered literally.
+	if n := len(l.parts); n != 0 && l.parts[n-1].field == layoutText {
+		l.parts[n-1].text += s
+		return
+	}
+
+	l.parts = append(l.parts, layoutPart{field: layoutText, text: s})
+}
+
+func (l *layout) format(buf *buffer, h *Handler, e *events.Event) {
+	for _, p := range l.parts {
+		start := len(buf.b)
+
+		switch p.field {
+		case layoutText:
+			buf.b = append(buf.b, p.text...)
+			continue
+
+		case layoutTime:
+			if fmt := h.TimeFormat; len(fmt) != 0 {
+				loc := h.TimeLocation
+				if loc == nil {
+					loc = time.Local
+				}
+				buf.b = e.Time.In(loc).AppendFormat(buf.b, fmt)
+			}
+
+		case layoutLevel:
+			buf.b = append(buf.b, level(e)...)
+
+		case layoutSource:
+			buf.b = append(buf.b, e.Source...)
+
+		case layoutMessage:
+			buf.b = append(buf.b, e.Message...)
+
+		case layoutArgs:
+			order := h.argsOrder(e)
+
+			for i := range e.Args {
+				a := argAt(e.Args, order, i)
+
+				if i != 0 {
+					buf.b = append(buf.b, ' ')
+				}
+				buf.b = append(buf.b, a.Name...)
+				buf.b = append(buf.b, '=')
+				appendValue(buf, a.Value, h.Bytes)
+			}
+		}
+
+		if n := utf8.RuneCount(buf.b[start:]); n < p.width {
+			pad(buf, start, p.width-n, p.align)
+		}
+	}
+}
+
+func pad(buf *buffer, start int, n int, align byte) {
+	for i := 0; i != n; i++ {
+		buf.b = append(buf.b, ' ')
+	}
+
+	if align == '>' {
+		end := len(buf.b)
+		copy(buf.b[start+n:end], buf.b[start:end-n])
+
+		for i := start; i != start+n; i++ {
+			buf.b[i] = ' '
+		}
+	}
+}
+
+func level(e *events.Event) string {
+	for _, a := range e.Args {
+		if _, ok := a.Value.(error); ok {
+			return "ERROR"
+		}
+	}
+
+	if e.Debug {
+		return "DEBUG"
+	}
+
+	return "INFO"
+}
@@ -0,0 +1,90 @@
+package text
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/segmentio/events"
+)
+
+func TestHandlerLayout(t *testing.T) {
+	tests := []struct {
+		layout string
+		output string
+	}{
+		{
+			layout: "{time} {level:>5} {source:<60} {message} {args}",
+			output: "2017-01-01 23:42:00.123 ERROR github.com/segmentio/events/text/layout_test.go:18           Hello Luke! name=Luke from=Han error=EOF\n",
+		},
+		{
+			layout: "{level:<5} | {time} | {message}",
+			output: "ERROR | 2017-01-01 23:42:00.123 | Hello Luke!\n",
+		},
+		{
+			layout: "{time} - {message}\n\t{args}",
+			output: "2017-01-01 23:42:00.123 - Hello Luke!\n\tname=Luke from=Han error=EOF\n",
+		},
+		{
+			layout: "{message:>15}|{message:15}|",
+			output: "    Hello Luke!|Hello Luke!    |\n",
+		},
+		{
+			layout: "{hello} {message:>x} {message} {message",
+			output: "{hello} {message:>x} Hello Luke! {message\n",
+		},
+		{
+			layout: "",
+			output: "2017-01-01 23:42:00.123 - github.com/segmentio/events/text/layout_test.go:18 - Hello Luke!\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.layout, func(t *testing.T) {
+			b := &bytes.Buffer{}
+			h := NewHandler("", b)
+			h.Layout = test.layout
+
+			h.HandleEvent(&events.Event{
+				Message: "Hello Luke!",
+				Source:  "github.com/segmentio/events/text/layout_test.go:18",
+				Args:    events.Args{{"name", "Luke"}, {"from", "Han"}, {"error", io.EOF}},
+				Time:    time.Date(2017, 1, 1, 23, 42, 0, 123000000, time.Local),
+			})
+
+			if s := b.String(); s != test.output {
+				t.Errorf("\n%q\n%q", s, test.output)
+			}
+		})
+	}
+
+	t.Run("runes", func(t *testing.T) {
+		b := &bytes.Buffer{}
+		h := NewHandler("==> ", b)
+		h.Layout = "[{level:>6}] [{message:<8}]"
+
+		h.HandleEvent(&events.Event{Message: "héllo", Debug: true})
+
+		if s, x := b.String(), "==> [ DEBUG] [héllo   ]\n"; s != x {
+			t.Errorf("\n%q\n%q", s, x)
+		}
+	})
+}
+
+func BenchmarkHandlerLayout(b *testing.B) {
+	h := NewHandler("", ioutil.Discard)
+	h.Layout = "{time} {level:>5} {source:<30} {message} {args}"
+	e := &events.Event{
+		Message: "Hello Luke!",
+		Source:  "github.com/segmentio/events/text/handler_test.go:18",
+		Args:    events.Args{{"name", "Luke"}, {"from", "Han"}},
+		Time:    time.Date(2017, 1, 1, 23, 42, 0, 123000000, time.UTC),
+		Debug:   true,
+	}
+
+	for i := 0; i != b.N; i++ {
+		h.HandleEvent(e)
+	}
+}
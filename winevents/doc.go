@@ -0,0 +1,6 @@
+// Package winevents provides the implementation of an event handler that
+// writes events to the Windows Event Log.
+//
+// The package can be imported on all platforms, but the handler can only be
+// constructed on Windows.
+package winevents
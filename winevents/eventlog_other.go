@@ -0,0 +1,13 @@
+//go:build !windows
+// +build !windows
+
+package winevents
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func openEventLog(source string) (eventLogWriter, error) {
+	return nil, fmt.Errorf("winevents: cannot open the %q event log source, the windows event log is not available on %s", source, runtime.GOOS)
+}
@@ -0,0 +1,20 @@
+//go:build !windows
+// +build !windows
+
+package winevents
+
+import "testing"
+
+func TestNewEventLogHandlerUnsupported(t *testing.T) {
+	h, err := NewEventLogHandler("events-test")
+
+	if h != nil {
+		t.Error("a handler should not be returned on this platform")
+	}
+
+	if err == nil {
+		t.Error("an error should be returned on this platform")
+	} else {
+		t.Log(err)
+	}
+}
@@ -0,0 +1,153 @@
+//go:build windows
+// +build windows
+
+package winevents
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+var advapi32 = syscall.NewLazyDLL("advapi32.dll")
+
+var (
+	procRegCreateKeyExW       = advapi32.NewProc("RegCreateKeyExW")
+	procRegSetValueExW        = advapi32.NewProc("RegSetValueExW")
+	procRegisterEventSourceW  = advapi32.NewProc("RegisterEventSourceW")
+	procDeregisterEventSource = advapi32.NewProc("DeregisterEventSource")
+	procReportEventW          = advapi32.NewProc("ReportEventW")
+)
+
+const (
+	eventlogErrorType       = 0x0001
+	eventlogWarningType     = 0x0002
+	eventlogInformationType = 0x0004
+
+	regOptionNonVolatile = 0
+	regCreatedNewKey     = 1
+	regExpandSZ          = 2
+	regDWORD             = 4
+
+	keySetValue = 0x0002
+
+	eventLogKey = `SYSTEM\CurrentControlSet\Services\EventLog\Application\`
+	messageFile = `%SystemRoot%\System32\EventCreate.exe`
+)
+
+type eventLog struct {
+	handle syscall.Handle
+}
+
+func openEventLog(source string) (eventLogWriter, error) {
+	// Registering the source requires administrator privileges, it's not
+	// fatal if it fails since the source may have been installed already,
+	// the event log still accepts entries from unregistered sources.
+	installSource(source)
+
+	name, err := syscall.UTF16PtrFromString(source)
+	if err != nil {
+		return nil, err
+	}
+
+	h, _, e := procRegisterEventSourceW.Call(0, uintptr(unsafe.Pointer(name)))
+	if h == 0 {
+		return nil, e
+	}
+
+	return &eventLog{handle: syscall.Handle(h)}, nil
+}
+
+func installSource(source string) error {
+	path, err := syscall.UTF16PtrFromString(eventLogKey + source)
+	if err != nil {
+		return err
+	}
+
+	var key syscall.Handle
+	var disposition uint32
+
+	r, _, _ := procRegCreateKeyExW.Call(
+		uintptr(syscall.HKEY_LOCAL_MACHINE),
+		uintptr(unsafe.Pointer(path)),
+		0,
+		0,
+		regOptionNonVolatile,
+		keySetValue,
+		0,
+		uintptr(unsafe.Pointer(&key)),
+		uintptr(unsafe.Pointer(&disposition)),
+	)
+	if r != 0 {
+		return syscall.Errno(r)
+	}
+	defer syscall.RegCloseKey(key)
+
+	if disposition != regCreatedNewKey {
+		return nil // already installed
+	}
+
+	file, _ := syscall.UTF16FromString(messageFile)
+	types := uint32(eventlogErrorType | eventlogWarningType | eventlogInformationType)
+
+	if err := setValue(key, "EventMessageFile", regExpandSZ, unsafe.Pointer(&file[0]), len(file)*2); err != nil {
+		return err
+	}
+	return setValue(key, "TypesSupported", regDWORD, unsafe.Pointer(&types), 4)
+}
+
+func setValue(key syscall.Handle, name string, typ uint32, data unsafe.Pointer, size int) error {
+	n, err := syscall.UTF16PtrFromString(name)
+	if err != nil {
+		return err
+	}
+
+	r, _, _ := procRegSetValueExW.Call(uintptr(key), uintptr(unsafe.Pointer(n)), 0, uintptr(typ), uintptr(data), uintptr(size))
+	if r != 0 {
+		return syscall.Errno(r)
+	}
+	return nil
+}
+
+func (l *eventLog) Info(eid uint32, msg string) error {
+	return l.report(eventlogInformationType, eid, msg)
+}
+
+func (l *eventLog) Warning(eid uint32, msg string) error {
+	return l.report(eventlogWarningType, eid, msg)
+}
+
+func (l *eventLog) Error(eid uint32, msg string) error {
+	return l.report(eventlogErrorType, eid, msg)
+}
+
+func (l *eventLog) Close() error {
+	r, _, e := procDeregisterEventSource.Call(uintptr(l.handle))
+	if r == 0 {
+		return e
+	}
+	return nil
+}
+
+func (l *eventLog) report(typ uint16, eid uint32, msg string) error {
+	s, err := syscall.UTF16PtrFromString(msg)
+	if err != nil {
+		return err
+	}
+
+	strs := [1]*uint16{s}
+	r, _, e := procReportEventW.Call(
+		uintptr(l.handle),
+		uintptr(typ),
+		0,
+		uintptr(eid),
+		0,
+		1,
+		0,
+		uintptr(unsafe.Pointer(&strs[0])),
+		0,
+	)
+	if r == 0 {
+		return e
+	}
+	return nil
+}
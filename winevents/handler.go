@@ -0,0 +1,147 @@
+package winevents
+
+import (
+	"unicode/utf8"
+
+	"github.com/segmentio/events"
+	"github.com/segmentio/events/logfmt"
+)
+
+// MaxEntrySize is the maximum number of characters (UTF-16 code units) that
+// the Windows Event Log accepts in the string of a single entry.
+const MaxEntrySize = 31839
+
+// EventID is the identifier set on every entry written by the handler.
+const EventID = 1
+
+// EventLogHandler is an event handler which writes events to the Windows Event
+// Log.
+//
+// The entry type is chosen from the event: events with an argument that
+// satisfies the error interface produce Error entries, events with a "level"
+// argument set to "warn" or "warning" produce Warning entries, all other
+// events (including debug events) produce Information entries.
+// The entry text is the event message followed by the logfmt representation of
+// its arguments on the next line, truncated to MaxEntrySize.
+//
+// It is safe to use a handler concurrently from multiple goroutines.
+type EventLogHandler struct {
+	writer eventLogWriter
+}
+
+// NewEventLogHandler creates a new handler which writes to the Windows Event
+// Log with the given source name, registering the source if it doesn't exist
+// yet.
+//
+// On platforms other than Windows the function always returns an error.
+func NewEventLogHandler(source string) (*EventLogHandler, error) {
+	w, err := openEventLog(source)
+	if err != nil {
+		return nil, err
+	}
+	return newEventLogHandler(w), nil
+}
+
+func newEventLogHandler(w eventLogWriter) *EventLogHandler {
+	return &EventLogHandler{writer: w}
+}
+
+// HandleEvent satisfies the events.Handler interface.
+func (h *EventLogHandler) HandleEvent(e *events.Event) {
+	text := string(appendEntry(make([]byte, 0, 256), e))
+
+	switch entryType(e) {
+	case entryError:
+		h.writer.Error(EventID, text)
+	case entryWarning:
+		h.writer.Warning(EventID, text)
+	default:
+		h.writer.Info(EventID, text)
+	}
+}
+
+// Close releases the handle to the event log.
+func (h *EventLogHandler) Close() error {
+	return h.writer.Close()
+}
+
+// The eventLogWriter interface abstracts the event log API so the handler can
+// be tested on all platforms.
+type eventLogWriter interface {
+	Info(eid uint32, msg string) error
+	Warning(eid uint32, msg string) error
+	Error(eid uint32, msg string) error
+	Close() error
+}
+
+type entry int
+
+const (
+	entryInformation entry = iota
+	entryWarning
+	entryError
+)
+
+func entryType(e *events.Event) entry {
+	t := entryInformation
+
+	for _, a := range e.Args {
+		if _, ok := a.Value.(error); ok {
+			return entryError
+		}
+		if a.Name == "level" {
+			if s, _ := a.Value.(string); s == "warn" || s == "warning" {
+				t = entryWarning
+			}
+		}
+	}
+
+	return t
+}
+
+// truncated is appended to the entries that were cut to fit MaxEntrySize.
+const truncated = "...[truncated]"
+
+func appendEntry(b []byte, e *events.Event) []byte {
+	b = append(b, e.Message...)
+
+	if len(e.Args) != 0 {
+		b = append(b, '\n')
+		b = logfmt.AppendArgs(b, e.Args)
+	}
+
+	return truncate(b, MaxEntrySize)
+}
+
+// truncate cuts b so it doesn't exceed max UTF-16 code units, leaving room for
+// the truncation marker. Cuts always happen on rune boundaries.
+func truncate(b []byte, max int) []byte {
+	if len(b) <= max { // fast path, a byte never encodes more than one unit
+		return b
+	}
+
+	n := 0
+	cut := -1
+	limit := max - len(truncated)
+
+	for i := 0; i < len(b); {
+		r, size := utf8.DecodeRune(b[i:])
+
+		units := 1
+		if r >= 0x10000 {
+			units = 2
+		}
+
+		if n+units > limit && cut < 0 {
+			cut = i
+		}
+
+		if n += units; n > max {
+			return append(b[:cut], truncated...)
+		}
+
+		i += size
+	}
+
+	return b
+}
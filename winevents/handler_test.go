@@ -0,0 +1,98 @@
+package winevents
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/segmentio/events"
+)
+
+type mockEntry struct {
+	typ  string
+	eid  uint32
+	text string
+}
+
+type mockWriter struct {
+	entries []mockEntry
+	closed  bool
+}
+
+func (w *mockWriter) Info(eid uint32, msg string) error    { return w.add("info", eid, msg) }
+func (w *mockWriter) Warning(eid uint32, msg string) error { return w.add("warning", eid, msg) }
+func (w *mockWriter) Error(eid uint32, msg string) error   { return w.add("error", eid, msg) }
+func (w *mockWriter) Close() error                         { w.closed = true; return nil }
+
+func (w *mockWriter) add(typ string, eid uint32, msg string) error {
+	w.entries = append(w.entries, mockEntry{typ, eid, msg})
+	return nil
+}
+
+func TestEventLogHandler(t *testing.T) {
+	w := &mockWriter{}
+	h := newEventLogHandler(w)
+
+	h.HandleEvent(&events.Event{Message: "Hello World!"})
+	h.HandleEvent(&events.Event{Message: "Hello Luke!", Debug: true, Args: events.Args{{"name", "Luke"}}})
+	h.HandleEvent(&events.Event{Message: "disk almost full", Args: events.Args{{"level", "warning"}, {"usage", 0.95}}})
+	h.HandleEvent(&events.Event{Message: "read failed", Args: events.Args{{"level", "warn"}, {"error", io.EOF}}})
+	h.Close()
+
+	if !reflect.DeepEqual(w.entries, []mockEntry{
+		{"info", EventID, "Hello World!"},
+		{"info", EventID, "Hello Luke!\nname=Luke"},
+		{"warning", EventID, "disk almost full\nlevel=warning usage=0.95"},
+		{"error", EventID, "read failed\nlevel=warn error=EOF"},
+	}) {
+		t.Errorf("bad entries: %#v", w.entries)
+	}
+
+	if !w.closed {
+		t.Error("closing the handler didn't close the writer")
+	}
+}
+
+func TestEventLogHandlerTruncate(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+	}{
+		{"ascii", strings.Repeat("A", 2*MaxEntrySize)},
+		{"multi-byte", strings.Repeat("é", MaxEntrySize+1)},
+		{"surrogates", strings.Repeat("😀", MaxEntrySize/2+1)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := &mockWriter{}
+			newEventLogHandler(w).HandleEvent(&events.Event{Message: test.message})
+
+			text := w.entries[0].text
+
+			if !strings.HasSuffix(text, truncated) {
+				t.Error("missing truncation marker")
+			}
+
+			if n := len(utf16.Encode([]rune(text))); n > MaxEntrySize {
+				t.Error("entry too long:", n)
+			}
+
+			if !strings.HasPrefix(test.message, strings.TrimSuffix(text, truncated)) {
+				t.Error("truncation didn't happen on a rune boundary")
+			}
+		})
+	}
+
+	t.Run("fits", func(t *testing.T) {
+		w := &mockWriter{}
+		message := strings.Repeat("é", MaxEntrySize)
+		newEventLogHandler(w).HandleEvent(&events.Event{Message: message})
+
+		if w.entries[0].text != message {
+			t.Error("entries that fit must not be truncated")
+		}
+	})
+}